@@ -0,0 +1,226 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ambisonics
+
+import (
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/hrtf"
+	"azul3d.org/audio.v1/spectral"
+)
+
+// BinauralDecoder decodes an Ambisonic sound field to binaural stereo audio
+// for headphones. It implements the audio.Reader interface. BinauralDecoders
+// must be allocated via the NewBinauralDecoder function.
+//
+// The sound field is decoded to a number of virtual speakers spread evenly
+// around the listener, each rendered with the head-related impulse responses
+// of its direction. As the decoding is linear, this is done by convolving
+// each channel of the sound field with a single pair of precomputed filters,
+// in the frequency domain. Once the input stream ends the tail of the
+// filters is rendered, after which EOS is returned.
+type BinauralDecoder struct {
+	r       *audio.FrameReader
+	in      audio.Config
+	config  audio.Config
+	block   int
+	fft     *spectral.FFT
+	filters [2][][]complex128 // per ear spectrum of each input channel's filter
+	length  int               // length of the filters in frames
+
+	// Processing state.
+	fill      int          // frames into the current block
+	cur, prev [][]float64  // per channel input of the current and previous block
+	seg       []float64    // time domain segment of two blocks
+	spec, acc []complex128 // input and accumulated output spectra
+	out       [2][]float64 // output of the last block, per ear
+	pos, end  int          // position in, and end of, the output of the block
+	start     int64        // input frame of the first frame of the output
+	read      int64        // input frames read
+	buf       audio.F64Samples
+
+	// Reading state.
+	eos       bool
+	remaining int // frames still to be output, once the input has ended
+}
+
+// Config returns the audio configuration of the decoded stereo stream.
+func (d *BinauralDecoder) Config() audio.Config {
+	return d.config
+}
+
+// next reads and convolves the next block of input, returning false if it
+// could not be completed yet (e.g. on an error).
+func (d *BinauralDecoder) next() (ok bool, err error) {
+	ch := d.in.Channels
+	for !d.eos && d.fill < d.block {
+		want := (d.block - d.fill) * ch
+		if len(d.buf) < want {
+			d.buf = make(audio.F64Samples, want)
+		}
+		var m int
+		m, err = d.r.Read(d.buf[:want])
+		frames := m / ch
+		for f := 0; f < frames; f++ {
+			for j := 0; j < ch; j++ {
+				d.cur[j][d.fill+f] = float64(d.buf[f*ch+j])
+			}
+		}
+		d.fill += frames
+		d.read += int64(frames)
+		d.remaining += frames
+		if err == audio.EOS {
+			d.eos, err = true, nil
+			d.remaining += d.length - 1
+		} else if err != nil || m == 0 {
+			return false, err
+		}
+	}
+	for j := range d.cur {
+		for i := d.fill; i < d.block; i++ {
+			d.cur[j][i] = 0
+		}
+	}
+
+	b := d.block
+	acc := [2][]complex128{d.acc[:b+1], d.acc[b+1:]}
+	for i := range d.acc {
+		d.acc[i] = 0
+	}
+	for j := range d.cur {
+		copy(d.seg, d.prev[j])
+		copy(d.seg[b:], d.cur[j])
+		d.fft.TransformReal(d.spec, d.seg)
+		d.prev[j], d.cur[j] = d.cur[j], d.prev[j]
+		for e := range acc {
+			for i, h := range d.filters[e][j] {
+				acc[e][i] += d.spec[i] * h
+			}
+		}
+	}
+	for e := range acc {
+		d.fft.InverseReal(d.seg, acc[e])
+		copy(d.out[e], d.seg[b:])
+	}
+	d.fill = 0
+	d.start += int64(d.end)
+	d.pos, d.end = 0, b
+	if d.eos {
+		if d.end > d.remaining {
+			d.end = d.remaining
+		}
+		d.remaining -= d.end
+	} else {
+		d.remaining -= b
+	}
+	return true, nil
+}
+
+// Read implements the audio.Reader interface.
+func (d *BinauralDecoder) Read(b audio.Slice) (n int, err error) {
+	frames := b.Len() / 2
+	for f := 0; f < frames; {
+		if d.pos == d.end {
+			if d.eos && d.remaining == 0 {
+				break
+			}
+			var ok bool
+			if ok, err = d.next(); !ok {
+				break
+			}
+			continue
+		}
+		b.Set(f*2, audio.F64(d.out[0][d.pos]))
+		b.Set(f*2+1, audio.F64(d.out[1][d.pos]))
+		d.pos++
+		f++
+		n += 2
+	}
+	if n == 0 && err == nil && d.eos && d.remaining == 0 && d.pos == d.end {
+		err = audio.EOS
+	}
+	return n, err
+}
+
+// SourcePosition implements the audio.Positioner interface. The latency of
+// the decoder is compensated for, and the position continues past the end of
+// the input stream while the tail of the filters is rendered.
+func (d *BinauralDecoder) SourcePosition() uint64 {
+	next := d.start + int64(d.pos) // input frame of the next output frame
+	return d.r.SourcePositionAt(int(next - d.read))
+}
+
+// TotalLatency implements the audio.Positioner interface. It is the latency
+// of the input stream plus one block, which must be read before any of its
+// output is available.
+func (d *BinauralDecoder) TotalLatency() int {
+	return (d.r.TotalLatency()/d.in.Channels + d.block) * d.config.Channels
+}
+
+// NewBinauralDecoder returns a new binaural decoder of the sound field r, whose
+// samples are laid out according to the given audio configuration, using the
+// given set of head-related impulse responses (which are resampled to the
+// sample rate of the stream if needed).
+//
+// It panics if the stream is not AmbiX of a supported order.
+func NewBinauralDecoder(r audio.Reader, c audio.Config, set *hrtf.Set) *BinauralDecoder {
+	order := mustOrder(c)
+	set = set.Resample(c.SampleRate)
+	n := c.Channels
+	length := set.Len()
+	b := 1
+	for b < length {
+		b *= 2
+	}
+	d := &BinauralDecoder{
+		r:      audio.NewFrameReader(r, c),
+		in:     c,
+		config: audio.Config{SampleRate: c.SampleRate, Channels: 2},
+		block:  b,
+		fft:    spectral.NewFFT(2 * b),
+		length: length,
+		cur:    make([][]float64, n),
+		prev:   make([][]float64, n),
+		seg:    make([]float64, 2*b),
+		spec:   make([]complex128, b+1),
+		acc:    make([]complex128, 2*(b+1)),
+		out:    [2][]float64{make([]float64, b), make([]float64, b)},
+	}
+	for j := range d.cur {
+		d.cur[j] = make([]float64, b)
+		d.prev[j] = make([]float64, b)
+	}
+
+	// The filter of each input channel is the sum of the impulse responses
+	// of each virtual speaker, weighted by its gain for the channel.
+	virtual := samplingDecoder(order)
+	ir := [2][][]float64{make([][]float64, n), make([][]float64, n)}
+	for e := range ir {
+		for j := range ir[e] {
+			ir[e][j] = make([]float64, length)
+		}
+	}
+	left, right := make([]float64, length), make([]float64, length)
+	for k, dir := range sphere(virtualSpeakers) {
+		set.Lookup(dir, left, right)
+		for j, g := range virtual[k] {
+			for i := range left {
+				ir[0][j][i] += g * left[i]
+				ir[1][j][i] += g * right[i]
+			}
+		}
+	}
+	for e := range ir {
+		d.filters[e] = make([][]complex128, n)
+		for j, h := range ir[e] {
+			for i := range d.seg {
+				d.seg[i] = 0
+			}
+			copy(d.seg, h)
+			d.filters[e][j] = make([]complex128, b+1)
+			d.fft.TransformReal(d.filters[e][j], d.seg)
+		}
+	}
+	return d
+}
@@ -0,0 +1,91 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ambisonics
+
+import (
+	"math"
+	"testing"
+
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/hrtf"
+)
+
+func TestBinauralDecoder(t *testing.T) {
+	const rate = 16000
+	set := hrtf.SphericalHead(rate)
+	for _, tst := range []struct {
+		azimuth     float64
+		leftLouder  bool
+		rightLouder bool
+	}{
+		{90, true, false},
+		{-90, false, true},
+	} {
+		mono := audio.Config{SampleRate: rate, Channels: 1}
+		in := make(audio.F64Samples, 2000)
+		for i := range in {
+			in[i] = audio.F64(math.Sin(2 * math.Pi * 4000 * float64(i) / rate))
+		}
+		e := NewEncoder(audio.NewBuffer(in), mono, 3, tst.azimuth*math.Pi/180, 0)
+		d := NewBinauralDecoder(e, e.Config(), set)
+		if want := (audio.Config{SampleRate: rate, Channels: 2}); d.Config() != want {
+			t.Fatalf("Config() = %v, want %v", d.Config(), want)
+		}
+		out := readAll(t, d, 2)
+		if want := (len(in) + set.Len() - 1) * 2; len(out) != want {
+			t.Fatalf("read %d samples, want %d", len(out), want)
+		}
+		var l, r float64
+		for i := 0; i < len(out); i += 2 {
+			l += float64(out[i] * out[i])
+			r += float64(out[i+1] * out[i+1])
+		}
+		if tst.leftLouder && l <= 2*r || tst.rightLouder && r <= 2*l {
+			t.Errorf("azimuth %v: left energy %v, right energy %v", tst.azimuth, l, r)
+		}
+	}
+}
+
+func TestBinauralDecoderOverlapSave(t *testing.T) {
+	// Decoding an impulse in blocks matches directly summing the filters.
+	const rate = 8000
+	set := hrtf.SphericalHead(rate)
+	c := Config(rate, 1)
+	in := make(audio.F64Samples, 4*300)
+	in[4*100] = 1
+	in[4*100+3] = 1
+	out := readAll(t, NewBinauralDecoder(audio.NewBuffer(in), c, set), 2)
+	impulse := make(audio.F64Samples, 4)
+	impulse[0], impulse[3] = 1, 1
+	ref := readAll(t, NewBinauralDecoder(audio.NewBuffer(impulse), c, set), 2)
+	for i := range out {
+		var want audio.F64
+		if j := i - 2*100; j >= 0 && j < len(ref) {
+			want = ref[j]
+		}
+		if !near(float64(out[i]), float64(want), 1e-9) {
+			t.Fatalf("sample %d = %v, want %v", i, out[i], want)
+		}
+	}
+}
+
+func TestBinauralDecoderSourcePosition(t *testing.T) {
+	const rate = 16000
+	set := hrtf.SphericalHead(rate)
+	mono := audio.Config{SampleRate: rate, Channels: 1}
+	e := NewEncoder(audio.NewBuffer(make(audio.F64Samples, 2000)), mono, 1, 0, 0)
+	d := NewBinauralDecoder(e, e.Config(), set)
+	buf := make(audio.F64Samples, 2*100)
+	for read := 100; read <= 1500; read += 100 {
+		d.Read(buf)
+		// The position is of the mono source, compensating for the latency.
+		if p := d.SourcePosition(); p != uint64(read) {
+			t.Fatalf("after %d frames SourcePosition got %d, want %d", read, p, read)
+		}
+	}
+	if l := d.TotalLatency(); l != 2*d.block {
+		t.Fatalf("TotalLatency got %d, want %d", l, 2*d.block)
+	}
+}
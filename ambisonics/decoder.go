@@ -0,0 +1,112 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ambisonics
+
+import (
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/spatial"
+)
+
+// Decoder decodes an Ambisonic sound field to the feeds of a speaker layout.
+// It implements the audio.Reader interface. Decoders must be allocated via
+// the NewDecoder function.
+//
+// The sound field is decoded to a number of virtual speakers spread evenly
+// around the listener (with max-rE weighting), each of which is then panned
+// across the speakers of the layout (see spatial.Layout.Pan). This works for
+// any layout, regular or not, including stereo.
+type Decoder struct {
+	r      *audio.FrameReader
+	in     audio.Config
+	config audio.Config
+	matrix [][]float64 // per speaker gain of each input channel
+	buf    audio.F64Samples
+}
+
+// Config returns the audio configuration of the decoded stream, whose
+// channels are the speakers of the layout.
+func (d *Decoder) Config() audio.Config {
+	return d.config
+}
+
+// Read implements the audio.Reader interface.
+func (d *Decoder) Read(b audio.Slice) (n int, err error) {
+	frames := b.Len() / d.config.Channels
+	if frames == 0 {
+		return 0, nil
+	}
+	if want := frames * d.in.Channels; len(d.buf) < want {
+		d.buf = make(audio.F64Samples, want)
+	}
+	m, err := d.r.Read(d.buf[:frames*d.in.Channels])
+	frames = m / d.in.Channels
+	for f := 0; f < frames; f++ {
+		x := d.buf[f*d.in.Channels : (f+1)*d.in.Channels]
+		for s, row := range d.matrix {
+			var y float64
+			for j, g := range row {
+				y += g * float64(x[j])
+			}
+			b.Set(f*d.config.Channels+s, audio.F64(y))
+		}
+	}
+	return frames * d.config.Channels, err
+}
+
+// SourcePosition implements the audio.Positioner interface.
+func (d *Decoder) SourcePosition() uint64 {
+	return d.r.SourcePosition()
+}
+
+// TotalLatency implements the audio.Positioner interface. The decoder
+// introduces no latency of its own.
+func (d *Decoder) TotalLatency() int {
+	return d.r.TotalLatency() / d.in.Channels * d.config.Channels
+}
+
+// decodeMatrix returns the matrix decoding a sound field of the given order to
+// the speakers of the layout.
+func decodeMatrix(order int, layout spatial.Layout) [][]float64 {
+	virtual := samplingDecoder(order)
+	dirs := sphere(virtualSpeakers)
+	n := Channels(order)
+	m := make([][]float64, len(layout))
+	for s := range m {
+		m[s] = make([]float64, n)
+	}
+	gains := make([]float64, len(layout))
+	for k, dir := range dirs {
+		layout.Pan(dir, gains)
+		for s, g := range gains {
+			if g == 0 {
+				continue
+			}
+			for j, v := range virtual[k] {
+				m[s][j] += g * v
+			}
+		}
+	}
+	normalize(m, order)
+	return m
+}
+
+// NewDecoder returns a new decoder of the sound field r, whose samples are laid
+// out according to the given audio configuration, to the given speaker layout
+// (e.g. spatial.DefaultLayout(2) for stereo). The decoded stream has one
+// channel per speaker, in the order of the layout.
+//
+// It panics if the stream is not AmbiX of a supported order.
+func NewDecoder(r audio.Reader, c audio.Config, layout spatial.Layout) *Decoder {
+	order := mustOrder(c)
+	return &Decoder{
+		r:  audio.NewFrameReader(r, c),
+		in: c,
+		config: audio.Config{
+			SampleRate: c.SampleRate,
+			Channels:   len(layout),
+		},
+		matrix: decodeMatrix(order, layout),
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ambisonics
+
+import (
+	"math"
+	"testing"
+
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/spatial"
+)
+
+// decodeDirection decodes a single frame of a sound from the given azimuth in
+// degrees, returning the gain of each speaker.
+func decodeDirection(t *testing.T, order int, layout spatial.Layout, azimuth float64) []float64 {
+	c := Config(48000, order)
+	coeffs := make([]float64, c.Channels)
+	Encode(order, spatial.Direction(azimuth*math.Pi/180, 0), coeffs)
+	in := make(audio.F64Samples, len(coeffs))
+	for i, v := range coeffs {
+		in[i] = audio.F64(v)
+	}
+	d := NewDecoder(audio.NewBuffer(in), c, layout)
+	if got := d.Config(); got.Channels != len(layout) || got.SampleRate != 48000 || got.Layout != audio.SpeakerLayout {
+		t.Fatalf("Config() = %v", got)
+	}
+	out := readAll(t, d, len(layout))
+	if len(out) != len(layout) {
+		t.Fatalf("read %d samples, want %d", len(out), len(layout))
+	}
+	gains := make([]float64, len(out))
+	for i, v := range out {
+		gains[i] = float64(v)
+	}
+	return gains
+}
+
+func TestDecoderDirection(t *testing.T) {
+	// The energy vector of the decoded speaker gains, which predicts the
+	// perceived direction, points towards the sound (within the speakers, the
+	// surrounds of 5.0 being too far apart to image between).
+	for _, tst := range []struct {
+		channels int
+		span     float64
+	}{
+		{4, 180},
+		{5, 90},
+		{8, 180},
+	} {
+		channels := tst.channels
+		layout := spatial.DefaultLayout(channels)
+		for order := 1; order <= MaxOrder; order++ {
+			for az := -tst.span; az < 180 && az <= tst.span; az += 15 {
+				gains := decodeDirection(t, order, layout, az)
+				var rE spatial.Vec3
+				for s, g := range gains {
+					if !layout[s].LFE {
+						rE = rE.Add(spatial.Direction(layout[s].Azimuth, 0).Scale(g * g))
+					}
+				}
+				got := math.Atan2(-rE.X, rE.Y) * 180 / math.Pi
+				diff := math.Mod(got-az+540, 360) - 180
+				if math.Abs(diff) > 25 {
+					t.Errorf("%d channels, order %d: sound at %v degrees perceived at %v", channels, order, az, got)
+				}
+			}
+		}
+	}
+}
+
+func TestDecoderStereo(t *testing.T) {
+	layout := spatial.DefaultLayout(2)
+	for order := 1; order <= MaxOrder; order++ {
+		left := decodeDirection(t, order, layout, 60)
+		if left[0] <= left[1] {
+			t.Errorf("order %d: sound from the left decoded to %v", order, left)
+		}
+		front := decodeDirection(t, order, layout, 0)
+		if !near(front[0], front[1], 1e-9) {
+			t.Errorf("order %d: sound from the front decoded to %v", order, front)
+		}
+	}
+}
+
+func TestDecoderMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+	NewDecoder(nil, audio.Config{SampleRate: 48000, Channels: 4}, spatial.DefaultLayout(2))
+}
+
+func TestDecoderSourcePosition(t *testing.T) {
+	mono := audio.Config{SampleRate: 48000, Channels: 1}
+	e := NewEncoder(audio.NewBuffer(make(audio.F64Samples, 1000)), mono, 2, 0, 0)
+	d := NewDecoder(e, e.Config(), spatial.DefaultLayout(6))
+	d.Read(make(audio.F64Samples, 6*250))
+	if p := d.SourcePosition(); p != 250 {
+		t.Fatalf("SourcePosition got %d, want 250", p)
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package ambisonics implements Ambisonic (B-format) surround sound of up to
+// third order.
+//
+// An Ambisonic stream describes the sound field around a listener, rather
+// than the feeds of particular speakers: it may be rotated (e.g. to follow
+// the listener's head or a camera) and then decoded to any speaker layout or
+// binaurally for headphones. Streams use the AmbiX convention of ACN channel
+// order and SN3D normalization, and are marked as such by the Layout of their
+// audio.Config (see Config) such that AmbiX files round-trip through codecs
+// which support it.
+//
+// An Encoder encodes a mono stream from a direction into a sound field; many
+// sources are encoded and mixed at once by a spatial.Mixer using a Panner:
+//
+//	c := ambisonics.Config(44100, 3)
+//	mixer := spatial.NewMixer(c)
+//	mixer.SetPanner(ambisonics.NewPanner(3))
+//	...
+//	rot := ambisonics.NewRotator(mixer, c, head.Conjugate())
+//	out := ambisonics.NewBinauralDecoder(rot, c, set)
+//
+// Directions follow the listener's frame of reference of the spatial package:
+// X is right, Y is forward and Z is up, with azimuths counter-clockwise from
+// the front.
+package ambisonics
@@ -0,0 +1,176 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ambisonics
+
+import (
+	"sync"
+
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/spatial"
+)
+
+// Panner is a spatial.Panner which encodes the sources of a spatial.Mixer
+// into an Ambisonic sound field, such that the mixer outputs an AmbiX stream
+// (see Config). Panners must be allocated via the NewPanner function.
+type Panner struct {
+	order int
+}
+
+// Order returns the order of the sound field encoded by the panner.
+func (p *Panner) Order() int {
+	return p.order
+}
+
+// NewVoice implements the spatial.Panner interface.
+//
+// It panics if the stream is not AmbiX of the panner's order.
+func (p *Panner) NewVoice(c audio.Config) spatial.Voice {
+	if order, ok := Order(c); !ok || order != p.order {
+		panic("ambisonics: audio configuration does not match the order of the panner")
+	}
+	return newVoice(p.order)
+}
+
+// NewPanner returns a new panner which encodes sources into a sound field of
+// the given order.
+//
+// It panics if the order is not between one and MaxOrder.
+func NewPanner(order int) *Panner {
+	checkOrder(order)
+	return &Panner{order: order}
+}
+
+// voice encodes a single source into a sound field.
+type voice struct {
+	order         int
+	gains, target []float64
+	started       bool
+}
+
+func (v *voice) Mix(out audio.Slice, in audio.F64Samples, dir spatial.Vec3, gain float64) {
+	channels := len(v.target)
+	Encode(v.order, dir, v.target)
+	for ch := range v.target {
+		v.target[ch] *= gain
+	}
+	if !v.started {
+		// Start at the target gains, rather than ramping from silence.
+		copy(v.gains, v.target)
+		v.started = true
+	}
+	frames := len(in)
+	for f, x := range in {
+		t := float64(f+1) / float64(frames)
+		for ch := 0; ch < channels; ch++ {
+			g := v.gains[ch] + (v.target[ch]-v.gains[ch])*t
+			j := f*channels + ch
+			out.Set(j, out.At(j)+audio.F64(g*float64(x)))
+		}
+	}
+	copy(v.gains, v.target)
+}
+
+func newVoice(order int) *voice {
+	n := Channels(order)
+	return &voice{
+		order:  order,
+		gains:  make([]float64, n),
+		target: make([]float64, n),
+	}
+}
+
+// Encoder encodes a mono audio stream arriving from a single direction into
+// an Ambisonic sound field. It implements the audio.Reader interface, and
+// reads an AmbiX stream (see Config). Encoders must be allocated via the
+// NewEncoder function.
+//
+// It is safe to change the direction from another goroutine while audio is
+// being read; to avoid audible clicks the gains are then ramped over each
+// read.
+type Encoder struct {
+	r      *audio.FrameReader
+	config audio.Config
+	voice  *voice
+	buf    audio.F64Samples
+
+	access             sync.Mutex
+	azimuth, elevation float64
+}
+
+// Config returns the audio configuration of the encoded stream.
+func (e *Encoder) Config() audio.Config {
+	return e.config
+}
+
+// Direction returns the direction that the source arrives from (see
+// SetDirection).
+func (e *Encoder) Direction() (azimuth, elevation float64) {
+	e.access.Lock()
+	defer e.access.Unlock()
+	return e.azimuth, e.elevation
+}
+
+// SetDirection sets the direction that the source arrives from, as its
+// azimuth (counter-clockwise from the front: positive angles are to the left)
+// and elevation (positive angles are above the listener) in radians.
+func (e *Encoder) SetDirection(azimuth, elevation float64) {
+	e.access.Lock()
+	e.azimuth, e.elevation = azimuth, elevation
+	e.access.Unlock()
+}
+
+// Read implements the audio.Reader interface.
+func (e *Encoder) Read(b audio.Slice) (n int, err error) {
+	frames := b.Len() / e.config.Channels
+	if frames == 0 {
+		return 0, nil
+	}
+	if len(e.buf) < frames {
+		e.buf = make(audio.F64Samples, frames)
+	}
+	m, err := e.r.Read(e.buf[:frames])
+	if m == 0 {
+		return 0, err
+	}
+	n = m * e.config.Channels
+	for i := 0; i < n; i++ {
+		b.Set(i, 0)
+	}
+	az, el := e.Direction()
+	e.voice.Mix(b.Slice(0, n), e.buf[:m], spatial.Direction(az, el), 1)
+	return n, err
+}
+
+// SourcePosition implements the audio.Positioner interface.
+func (e *Encoder) SourcePosition() uint64 {
+	return e.r.SourcePosition()
+}
+
+// TotalLatency implements the audio.Positioner interface. The encoder
+// introduces no latency of its own.
+func (e *Encoder) TotalLatency() int {
+	return e.r.TotalLatency() * e.config.Channels
+}
+
+// NewEncoder returns a new encoder of the mono stream r, whose samples are
+// laid out according to the given audio configuration, into a sound field of
+// the given order. The source arrives from the given direction (see
+// SetDirection).
+//
+// It panics if the stream is not mono, or the order is not between one and
+// MaxOrder.
+func NewEncoder(r audio.Reader, c audio.Config, order int, azimuth, elevation float64) *Encoder {
+	if c.Channels != 1 {
+		panic("ambisonics: encoded stream must be mono")
+	}
+	checkOrder(order)
+	return &Encoder{
+		r:         audio.NewFrameReader(r, c),
+		config:    Config(c.SampleRate, order),
+		voice:     newVoice(order),
+		azimuth:   azimuth,
+		elevation: elevation,
+	}
+}
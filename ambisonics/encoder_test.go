@@ -0,0 +1,87 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ambisonics
+
+import (
+	"math"
+	"testing"
+
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/spatial"
+)
+
+// readAll reads all of r, whose channels are given.
+func readAll(t *testing.T, r audio.Reader, channels int) audio.F64Samples {
+	var out audio.F64Samples
+	buf := make(audio.F64Samples, 100*channels)
+	for {
+		n, err := r.Read(buf)
+		out = append(out, buf[:n]...)
+		if err == audio.EOS {
+			return out
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	mono := audio.Config{SampleRate: 8000, Channels: 1}
+	in := make(audio.F64Samples, 1000)
+	for i := range in {
+		in[i] = audio.F64(math.Sin(float64(i) * 0.1))
+	}
+	az, el := 0.6, 0.3
+	e := NewEncoder(audio.NewBuffer(in), mono, 3, az, el)
+	if want := Config(8000, 3); e.Config() != want {
+		t.Fatalf("Config() = %v, want %v", e.Config(), want)
+	}
+	out := readAll(t, e, 16)
+	if len(out) != len(in)*16 {
+		t.Fatalf("read %d samples, want %d", len(out), len(in)*16)
+	}
+	coeffs := make([]float64, 16)
+	Encode(3, spatial.Direction(az, el), coeffs)
+	for i, x := range in {
+		for ch, g := range coeffs {
+			if got := float64(out[i*16+ch]); !near(got, g*float64(x), 1e-9) {
+				t.Fatalf("frame %d channel %d = %v, want %v", i, ch, got, g*float64(x))
+			}
+		}
+	}
+}
+
+func TestPannerMixer(t *testing.T) {
+	c := Config(8000, 1)
+	m := spatial.NewMixer(c)
+	m.SetPanner(NewPanner(1))
+	in := make(audio.F64Samples, 500)
+	for i := range in {
+		in[i] = 1
+	}
+	// A source to the left of the listener.
+	e := spatial.DefaultEmitter
+	e.Position = spatial.Vec3{X: -1}
+	m.Add(audio.NewBuffer(in), audio.Config{SampleRate: 8000, Channels: 1}, e)
+	out := make(audio.F64Samples, 4*100)
+	n, _ := m.Read(out)
+	if n == 0 {
+		t.Fatal("read nothing")
+	}
+	f := out[4*50 : 4*51]
+	if !near(float64(f[0]), 1, 1e-6) || !near(float64(f[1]), 1, 1e-6) || !near(float64(f[2]), 0, 1e-6) || !near(float64(f[3]), 0, 1e-6) {
+		t.Errorf("frame = %v, want [1 1 0 0]", f)
+	}
+}
+
+func TestPannerMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+	NewPanner(1).NewVoice(Config(8000, 2))
+}
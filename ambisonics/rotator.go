@@ -0,0 +1,254 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ambisonics
+
+import (
+	"math"
+	"sync"
+
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/dsp"
+	"azul3d.org/audio.v1/spatial"
+)
+
+// invert returns the inverse of the square matrix a, by Gauss-Jordan
+// elimination with partial pivoting. The matrix a is modified.
+func invert(a [][]float64) [][]float64 {
+	n := len(a)
+	inv := make([][]float64, n)
+	for i := range inv {
+		inv[i] = make([]float64, n)
+		inv[i][i] = 1
+	}
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		inv[col], inv[pivot] = inv[pivot], inv[col]
+		p := a[col][col]
+		for j := 0; j < n; j++ {
+			a[col][j] /= p
+			inv[col][j] /= p
+		}
+		for row := 0; row < n; row++ {
+			if row == col {
+				continue
+			}
+			f := a[row][col]
+			for j := 0; j < n; j++ {
+				a[row][j] -= f * a[col][j]
+				inv[row][j] -= f * inv[col][j]
+			}
+		}
+	}
+	return inv
+}
+
+// rotation computes the matrices which rotate sound fields of a single order.
+//
+// Each degree of a sound field is rotated independently, by the matrix M
+// mapping the spherical harmonics of any direction d to those of the rotated
+// direction q*d. It is found by least squares over a set of directions D:
+// given the matrices Y and Y' of the harmonics of D and of the rotated D (one
+// row per direction), M = Y'^T * Y * (Y^T * Y)^-1, of which the part not
+// depending on the rotation is precomputed.
+type rotation struct {
+	order int
+	dirs  []spatial.Vec3
+	y     [][]float64   // harmonics of each direction
+	proj  [][][]float64 // per degree Y * (Y^T * Y)^-1
+}
+
+// matrix stores the matrix rotating the sound field by q in m, whose length
+// must be the square of the number of channels (in row-major order).
+func (r *rotation) matrix(q spatial.Quat, m []float64) {
+	n := Channels(r.order)
+	for i := range m {
+		m[i] = 0
+	}
+	m[0] = 1
+	yr := make([]float64, n)
+	for k, d := range r.dirs {
+		Encode(r.order, q.Rotate(d), yr)
+		for deg := 1; deg <= r.order; deg++ {
+			o, s := deg*deg, 2*deg+1
+			p := r.proj[deg][k]
+			for i := 0; i < s; i++ {
+				row := m[(o+i)*n+o:]
+				for j := 0; j < s; j++ {
+					row[j] += yr[o+i] * p[j]
+				}
+			}
+		}
+	}
+}
+
+func newRotation(order int) *rotation {
+	n := Channels(order)
+	r := &rotation{
+		order: order,
+		dirs:  sphere(virtualSpeakers),
+		proj:  make([][][]float64, order+1),
+	}
+	r.y = make([][]float64, len(r.dirs))
+	for k, d := range r.dirs {
+		r.y[k] = make([]float64, n)
+		Encode(order, d, r.y[k])
+	}
+	for deg := 1; deg <= order; deg++ {
+		o, s := deg*deg, 2*deg+1
+		gram := make([][]float64, s)
+		for i := range gram {
+			gram[i] = make([]float64, s)
+			for j := range gram[i] {
+				for _, y := range r.y {
+					gram[i][j] += y[o+i] * y[o+j]
+				}
+			}
+		}
+		inv := invert(gram)
+		r.proj[deg] = make([][]float64, len(r.dirs))
+		for k, y := range r.y {
+			p := make([]float64, s)
+			for j := range p {
+				for i := 0; i < s; i++ {
+					p[j] += y[o+i] * inv[i][j]
+				}
+			}
+			r.proj[deg][k] = p
+		}
+	}
+	return r
+}
+
+// Rotator rotates an Ambisonic sound field, e.g. to counter the rotation of
+// the listener's head as reported by a head tracker, or to follow a camera.
+// It implements both the audio.Reader and dsp.Processor interfaces. Rotators
+// must be allocated via the NewRotator function.
+//
+// It is safe to change the rotation from another goroutine while audio is
+// being processed; to avoid audible clicks the rotation is then crossfaded
+// over dsp.DefaultSmoothing.
+type Rotator struct {
+	*dsp.Reader
+	config audio.Config
+	rot    *rotation
+
+	access sync.Mutex
+	q      spatial.Quat
+	dirty  bool
+
+	// Processing state.
+	from, to      []float64 // matrices crossfaded between
+	ramp, rampLen int       // remaining and total crossfade length in frames
+	frame         []float64
+}
+
+// Config returns the audio configuration of the stream.
+func (r *Rotator) Config() audio.Config {
+	return r.config
+}
+
+// Rotation returns the rotation of the sound field.
+func (r *Rotator) Rotation() spatial.Quat {
+	r.access.Lock()
+	defer r.access.Unlock()
+	return r.q
+}
+
+// SetRotation sets the rotation of the sound field, such that a sound
+// arriving from the direction d is rotated to arrive from q.Rotate(d). To
+// counter the rotation of a listener's head, use the conjugate of its
+// orientation.
+func (r *Rotator) SetRotation(q spatial.Quat) {
+	r.access.Lock()
+	r.q = q
+	r.dirty = true
+	r.access.Unlock()
+}
+
+// Implements the dsp.Processor interface.
+func (r *Rotator) Process(s audio.Slice, c audio.Config) {
+	if c != r.config {
+		panic("ambisonics: Rotator audio configuration mismatch")
+	}
+	r.access.Lock()
+	q, dirty := r.q, r.dirty
+	r.dirty = false
+	r.access.Unlock()
+
+	n := c.Channels
+	if dirty {
+		// Crossfade from wherever the current crossfade (if any) is at.
+		t := 1 - float64(r.ramp)/float64(r.rampLen)
+		for i := range r.from {
+			r.from[i] += (r.to[i] - r.from[i]) * t
+		}
+		r.rot.matrix(q.Normalized(), r.to)
+		r.rampLen = int(dsp.DefaultSmoothing.Seconds()*float64(c.SampleRate)) + 1
+		r.ramp = r.rampLen
+	}
+
+	frames := s.Len() / n
+	for f := 0; f < frames; f++ {
+		for ch := range r.frame {
+			r.frame[ch] = float64(s.At(f*n + ch))
+		}
+		t := 1.0
+		if r.ramp > 0 {
+			r.ramp--
+			t = 1 - float64(r.ramp)/float64(r.rampLen)
+		}
+		for i := 0; i < n; i++ {
+			deg := degree(i)
+			lo, hi := deg*deg, (deg+1)*(deg+1)
+			var y float64
+			to := r.to[i*n : (i+1)*n]
+			if t < 1 {
+				from := r.from[i*n : (i+1)*n]
+				for j := lo; j < hi; j++ {
+					y += (from[j] + (to[j]-from[j])*t) * r.frame[j]
+				}
+			} else {
+				for j := lo; j < hi; j++ {
+					y += to[j] * r.frame[j]
+				}
+			}
+			s.Set(f*n+i, audio.F64(y))
+		}
+	}
+}
+
+// NewRotator returns a new rotator of the sound field r, whose samples are
+// laid out according to the given audio configuration, by the given rotation
+// (see SetRotation).
+//
+// If the rotator is only to be used as a dsp.Processor, r may be nil.
+//
+// It panics if the stream is not AmbiX of a supported order.
+func NewRotator(r audio.Reader, c audio.Config, q spatial.Quat) *Rotator {
+	order := mustOrder(c)
+	n := c.Channels
+	rt := &Rotator{
+		config: c,
+		rot:    newRotation(order),
+		q:      q,
+		from:   make([]float64, n*n),
+		to:     make([]float64, n*n),
+		frame:  make([]float64, n),
+		// Not crossfading, but avoid a division by zero.
+		rampLen: 1,
+	}
+	rt.rot.matrix(q.Normalized(), rt.to)
+	copy(rt.from, rt.to)
+	if r != nil {
+		rt.Reader = dsp.NewReader(r, c, rt)
+	}
+	return rt
+}
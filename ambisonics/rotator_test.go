@@ -0,0 +1,66 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ambisonics
+
+import (
+	"math"
+	"testing"
+
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/spatial"
+)
+
+func TestRotator(t *testing.T) {
+	for order := 1; order <= MaxOrder; order++ {
+		c := Config(48000, order)
+		n := c.Channels
+		q := spatial.AxisAngle(spatial.Vec3{X: 1, Y: 2, Z: 0.5}, 1.1)
+		r := NewRotator(nil, c, q)
+		for _, d := range sphere(10) {
+			s := make(audio.F64Samples, n)
+			coeffs := make([]float64, n)
+			Encode(order, d, coeffs)
+			for i, v := range coeffs {
+				s[i] = audio.F64(v)
+			}
+			r.Process(s, c)
+			Encode(order, q.Rotate(d), coeffs)
+			for i, v := range coeffs {
+				if !near(float64(s[i]), v, 1e-9) {
+					t.Fatalf("order %d: direction %v channel %d = %v, want %v", order, d, i, s[i], v)
+				}
+			}
+		}
+	}
+}
+
+func TestRotatorCrossfade(t *testing.T) {
+	c := Config(48000, 1)
+	r := NewRotator(nil, c, spatial.IdentityQuat)
+	// A source to the front, turned to the left.
+	front := []float64{1, 0, 0, 1}
+	r.SetRotation(spatial.AxisAngle(spatial.Vec3{Z: 1}, math.Pi/2))
+	frames := 48000 / 10
+	s := make(audio.F64Samples, frames*4)
+	for f := 0; f < frames; f++ {
+		for ch, v := range front {
+			s[f*4+ch] = audio.F64(v)
+		}
+	}
+	r.Process(s, c)
+	// The left channel rises smoothly to one, as the front falls to zero.
+	var prev float64
+	for f := 0; f < frames; f++ {
+		y := float64(s[f*4+1])
+		if y < prev-1e-12 || y-prev > 0.01 {
+			t.Fatalf("frame %d: left %v after %v", f, y, prev)
+		}
+		prev = y
+	}
+	last := s[(frames-1)*4:]
+	if !near(float64(last[1]), 1, 1e-9) || !near(float64(last[3]), 0, 1e-9) {
+		t.Errorf("last frame = %v, want [1 1 0 0]", last)
+	}
+}
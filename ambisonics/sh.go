@@ -0,0 +1,219 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ambisonics
+
+import (
+	"math"
+
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/spatial"
+)
+
+// MaxOrder is the highest Ambisonic order supported.
+const MaxOrder = 3
+
+// Channels returns the number of channels of a sound field of the given
+// order, which is the square of the order plus one.
+func Channels(order int) int {
+	return (order + 1) * (order + 1)
+}
+
+// Config returns the audio configuration of an AmbiX stream of the given
+// sample rate and order.
+func Config(sampleRate, order int) audio.Config {
+	return audio.Config{
+		SampleRate: sampleRate,
+		Channels:   Channels(order),
+		Layout:     audio.AmbiX,
+	}
+}
+
+// Order returns the order of the AmbiX stream with the given audio
+// configuration. If the stream is not AmbiX, or is of an unsupported order,
+// ok is false.
+func Order(c audio.Config) (order int, ok bool) {
+	if c.Layout != audio.AmbiX {
+		return 0, false
+	}
+	for order = 1; order <= MaxOrder; order++ {
+		if Channels(order) == c.Channels {
+			return order, true
+		}
+	}
+	return 0, false
+}
+
+// checkOrder panics if order is not a supported order.
+func checkOrder(order int) {
+	if order < 1 || order > MaxOrder {
+		panic("ambisonics: invalid order")
+	}
+}
+
+// mustOrder returns the order of the AmbiX stream with the given audio
+// configuration, or panics if it is not one.
+func mustOrder(c audio.Config) int {
+	order, ok := Order(c)
+	if !ok {
+		panic("ambisonics: audio configuration is not a supported AmbiX stream")
+	}
+	return order
+}
+
+// Encode stores the gains of each channel (in ACN order, with SN3D
+// normalization) of a sound of the given order arriving from the direction
+// dir, in the listener's frame of reference (see spatial.Listener.Local), in
+// coeffs, whose length must be at least Channels(order). These are the real
+// spherical harmonics of the direction.
+//
+// A zero direction (a sound at the listener) is encoded omnidirectionally,
+// into the first channel only.
+func Encode(order int, dir spatial.Vec3, coeffs []float64) {
+	checkOrder(order)
+	coeffs = coeffs[:Channels(order)]
+	for i := range coeffs {
+		coeffs[i] = 0
+	}
+	coeffs[0] = 1
+	dir = dir.Normalized()
+	if dir == (spatial.Vec3{}) {
+		return
+	}
+
+	// The Ambisonic frame of reference has X forward, Y left and Z up.
+	x, y, z := dir.Y, -dir.X, dir.Z
+	coeffs[1] = y
+	coeffs[2] = z
+	coeffs[3] = x
+	if order < 2 {
+		return
+	}
+	s3 := math.Sqrt(3)
+	coeffs[4] = s3 * x * y
+	coeffs[5] = s3 * y * z
+	coeffs[6] = (3*z*z - 1) / 2
+	coeffs[7] = s3 * x * z
+	coeffs[8] = s3 / 2 * (x*x - y*y)
+	if order < 3 {
+		return
+	}
+	s58, s15, s38 := math.Sqrt(5.0/8), math.Sqrt(15), math.Sqrt(3.0/8)
+	coeffs[9] = s58 * y * (3*x*x - y*y)
+	coeffs[10] = s15 * x * y * z
+	coeffs[11] = s38 * y * (5*z*z - 1)
+	coeffs[12] = z * (5*z*z - 3) / 2
+	coeffs[13] = s38 * x * (5*z*z - 1)
+	coeffs[14] = s15 / 2 * z * (x*x - y*y)
+	coeffs[15] = s58 * x * (x*x - 3*y*y)
+}
+
+// degree returns the degree (order) of the spherical harmonic of the given
+// ACN channel.
+func degree(acn int) int {
+	n := 0
+	for (n+1)*(n+1) <= acn {
+		n++
+	}
+	return n
+}
+
+// legendre returns the Legendre polynomial of degree n (of at most MaxOrder)
+// at x.
+func legendre(n int, x float64) float64 {
+	switch n {
+	case 0:
+		return 1
+	case 1:
+		return x
+	case 2:
+		return (3*x*x - 1) / 2
+	}
+	return (5*x*x*x - 3*x) / 2
+}
+
+// maxREWeights returns the weight of each degree of a sound field of the
+// given order, which when decoding maximizes the energy vector (and so the
+// perceived sharpness of sources) rather than reconstructing the sound field
+// exactly at the center, as is best for listening over an area.
+func maxREWeights(order int) []float64 {
+	rE := math.Cos(137.9 * math.Pi / 180 / (float64(order) + 1.51))
+	w := make([]float64, order+1)
+	for n := range w {
+		w[n] = legendre(n, rE)
+	}
+	return w
+}
+
+// sphere returns n (an even number of) directions spread nearly uniformly over
+// the sphere: a Fibonacci lattice over the left hemisphere, mirrored to the
+// right such that decoding is symmetric.
+func sphere(n int) []spatial.Vec3 {
+	dirs := make([]spatial.Vec3, 0, n)
+	golden := math.Pi * (3 - math.Sqrt(5))
+	h := n / 2
+	for i := 0; i < h; i++ {
+		x := (float64(i) + 0.5) / float64(h)
+		r := math.Sqrt(1 - x*x)
+		s, c := math.Sincos(golden * float64(i))
+		dirs = append(dirs, spatial.Vec3{X: -x, Y: r * c, Z: r * s})
+		dirs = append(dirs, spatial.Vec3{X: x, Y: r * c, Z: r * s})
+	}
+	return dirs
+}
+
+// virtualSpeakers is the number of directions that sound fields are sampled
+// at, for decoding and rotating them. It is plenty for the highest order.
+const virtualSpeakers = 64
+
+// samplingDecoder returns the matrix (of virtualSpeakers rows, one per
+// direction of sphere(virtualSpeakers), by Channels(order) columns) which
+// decodes a sound field to the gains of virtual speakers spread evenly around
+// the listener, with max-rE weighting. It is normalized such that the sum of
+// the squared gains of a sound from any direction is about one.
+func samplingDecoder(order int) [][]float64 {
+	dirs := sphere(virtualSpeakers)
+	weights := maxREWeights(order)
+	n := Channels(order)
+	d := make([][]float64, len(dirs))
+	for k, dir := range dirs {
+		d[k] = make([]float64, n)
+		Encode(order, dir, d[k])
+		for j := range d[k] {
+			deg := degree(j)
+			d[k][j] *= float64(2*deg+1) * weights[deg] / float64(len(dirs))
+		}
+	}
+	normalize(d, order)
+	return d
+}
+
+// normalize scales the decoding matrix d (of any number of rows, by
+// Channels(order) columns) such that the sum of the squared outputs of a
+// sound, averaged over all directions, is one.
+func normalize(d [][]float64, order int) {
+	dirs := sphere(4 * virtualSpeakers)
+	y := make([]float64, Channels(order))
+	var energy float64
+	for _, dir := range dirs {
+		Encode(order, dir, y)
+		for _, row := range d {
+			var g float64
+			for j, v := range row {
+				g += v * y[j]
+			}
+			energy += g * g
+		}
+	}
+	energy /= float64(len(dirs))
+	if energy == 0 {
+		return
+	}
+	s := 1 / math.Sqrt(energy)
+	for _, row := range d {
+		for j := range row {
+			row[j] *= s
+		}
+	}
+}
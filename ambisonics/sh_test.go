@@ -0,0 +1,116 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ambisonics
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/spatial"
+)
+
+func near(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+func TestConfigOrder(t *testing.T) {
+	for order := 1; order <= MaxOrder; order++ {
+		c := Config(48000, order)
+		if c.Layout != audio.AmbiX || c.Channels != (order+1)*(order+1) {
+			t.Errorf("Config(48000, %d) = %v", order, c)
+		}
+		if got, ok := Order(c); !ok || got != order {
+			t.Errorf("Order(%v) = %d, %v", c, got, ok)
+		}
+	}
+	for _, c := range []audio.Config{
+		{SampleRate: 48000, Channels: 4},
+		{SampleRate: 48000, Channels: 5, Layout: audio.AmbiX},
+		{SampleRate: 48000, Channels: 1, Layout: audio.AmbiX},
+		{SampleRate: 48000, Channels: 25, Layout: audio.AmbiX},
+	} {
+		if order, ok := Order(c); ok {
+			t.Errorf("Order(%v) = %d, want not ok", c, order)
+		}
+	}
+	if s := Config(44100, 1).String(); s != "Config(SampleRate=44100, Channels=4, Layout=AmbiX)" {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+func TestEncodeAxes(t *testing.T) {
+	for _, tst := range []struct {
+		dir  spatial.Vec3
+		acn  int // the first order channel of the direction
+		sign float64
+	}{
+		{spatial.Vec3{Y: 1}, 3, 1},   // front: X
+		{spatial.Vec3{Y: -1}, 3, -1}, // back
+		{spatial.Vec3{X: -1}, 1, 1},  // left: Y
+		{spatial.Vec3{X: 1}, 1, -1},  // right
+		{spatial.Vec3{Z: 1}, 2, 1},   // up: Z
+	} {
+		coeffs := make([]float64, 4)
+		Encode(1, tst.dir, coeffs)
+		want := []float64{1, 0, 0, 0}
+		want[tst.acn] = tst.sign
+		for i := range want {
+			if !near(coeffs[i], want[i], 1e-12) {
+				t.Errorf("Encode(%v) = %v, want %v", tst.dir, coeffs, want)
+				break
+			}
+		}
+	}
+	coeffs := make([]float64, Channels(3))
+	Encode(3, spatial.Vec3{}, coeffs)
+	for i, v := range coeffs[1:] {
+		if v != 0 {
+			t.Errorf("zero direction: channel %d = %v", i+1, v)
+		}
+	}
+}
+
+func TestEncodeSN3D(t *testing.T) {
+	// With SN3D normalization the squared harmonics of each degree sum to
+	// one, for any direction.
+	rng := rand.New(rand.NewSource(1))
+	coeffs := make([]float64, Channels(MaxOrder))
+	for i := 0; i < 100; i++ {
+		dir := spatial.Vec3{X: rng.NormFloat64(), Y: rng.NormFloat64(), Z: rng.NormFloat64()}
+		Encode(MaxOrder, dir, coeffs)
+		for n := 0; n <= MaxOrder; n++ {
+			var sum float64
+			for _, v := range coeffs[n*n : (n+1)*(n+1)] {
+				sum += v * v
+			}
+			if !near(sum, 1, 1e-9) {
+				t.Fatalf("%v: degree %d sums to %v", dir, n, sum)
+			}
+		}
+	}
+}
+
+func TestInvert(t *testing.T) {
+	a := [][]float64{{0, 2, 1}, {1, 1, 0}, {3, 0, 1}}
+	orig := [][]float64{{0, 2, 1}, {1, 1, 0}, {3, 0, 1}}
+	inv := invert(a)
+	for i := range orig {
+		for j := range orig {
+			var v float64
+			for k := range orig {
+				v += orig[i][k] * inv[k][j]
+			}
+			want := 0.0
+			if i == j {
+				want = 1
+			}
+			if !near(v, want, 1e-12) {
+				t.Fatalf("a * inverse[%d][%d] = %v, want %v", i, j, v, want)
+			}
+		}
+	}
+}
@@ -24,13 +24,48 @@ type Config struct {
 
 	// Channels is the number of channels the stream contains.
 	Channels int
+
+	// Layout describes what the channels of the stream represent. Decoders
+	// and encoders of formats which can describe it (e.g. AmbiX WAVE files)
+	// should report and store it.
+	Layout ChannelLayout
 }
 
 // String returns an string representation of this audio config.
 func (c Config) String() string {
+	if c.Layout != SpeakerLayout {
+		return fmt.Sprintf("Config(SampleRate=%v, Channels=%v, Layout=%v)", c.SampleRate, c.Channels, c.Layout)
+	}
 	return fmt.Sprintf("Config(SampleRate=%v, Channels=%v)", c.SampleRate, c.Channels)
 }
 
+// ChannelLayout describes what the channels of an audio stream represent.
+type ChannelLayout uint8
+
+const (
+	// SpeakerLayout is the default layout, where each channel is the feed of
+	// a single speaker in the usual WAVE channel order (e.g. left and right
+	// for stereo).
+	SpeakerLayout ChannelLayout = iota
+
+	// AmbiX is an Ambisonic sound field (B-format) in the AmbiX convention:
+	// channels are in ACN order with SN3D normalization, and the order of the
+	// sound field follows from the number of channels, which is the square
+	// of the order plus one.
+	AmbiX
+)
+
+// String returns a string representation of the channel layout.
+func (l ChannelLayout) String() string {
+	switch l {
+	case SpeakerLayout:
+		return "SpeakerLayout"
+	case AmbiX:
+		return "AmbiX"
+	}
+	return fmt.Sprintf("ChannelLayout(%d)", uint8(l))
+}
+
 // Encoder is the generic audio encoder interface.
 type Encoder interface {
 	Writer
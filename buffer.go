@@ -7,8 +7,9 @@ package audio
 // A Buffer is a variable-sized buffer of audio samples with Read and Write
 // methods. Buffers must be allocated via the NewBuffer function.
 type Buffer struct {
-	buf Slice // contents are the samples buf[off : len(buf)]
-	off int   // read at buf[off], write at buf[len(buf)]
+	buf Slice  // contents are the samples buf[off : len(buf)]
+	off int    // read at buf[off], write at buf[len(buf)]
+	pos uint64 // number of samples read, for SourcePosition
 }
 
 // Samples returns a slice of the unread portion of the buffer. If the caller
@@ -24,6 +25,17 @@ func (b *Buffer) Len() int {
 	return b.buf.Len() - b.off
 }
 
+// LenFrames returns the number of whole frames of the unread portion of the
+// buffer, given the number of interleaved channels.
+//
+// It panics if channels is less than one.
+func (b *Buffer) LenFrames(channels int) int {
+	if channels < 1 {
+		panic("audio.Buffer.LenFrames: invalid channel count")
+	}
+	return b.Len() / channels
+}
+
 // Truncate discards all but the first n unread samples from the buffer.
 // It panics if n is negative or greater than the length of the buffer.
 func (b *Buffer) Truncate(n int) {
@@ -132,6 +144,7 @@ func (b *Buffer) WriteTo(w Writer) (n int64, err error) {
 			panic("audio.Buffer.WriteTo: invalid Write count")
 		}
 		b.off += m
+		b.pos += uint64(m)
 		n = int64(m)
 		if e != nil {
 			return n, e
@@ -168,9 +181,38 @@ func (b *Buffer) Read(p Slice) (n int, err error) {
 	}
 	n = b.buf.Slice(b.off, b.buf.Len()).CopyTo(p)
 	b.off += n
+	b.pos += uint64(n)
 	return
 }
 
+// ReadFrames reads the next whole frames from the buffer, given the number of
+// interleaved channels, until p is full or the buffer is drained of whole
+// frames. The return value frames is the number of frames read. If the buffer
+// has no whole frames to return, err is EOS (unless p cannot hold a single
+// frame); otherwise it is nil.
+//
+// It panics if channels is less than one.
+func (b *Buffer) ReadFrames(p Slice, channels int) (frames int, err error) {
+	if channels < 1 {
+		panic("audio.Buffer.ReadFrames: invalid channel count")
+	}
+	frames = p.Len() / channels
+	if frames == 0 {
+		return 0, nil
+	}
+	if avail := b.LenFrames(channels); frames > avail {
+		frames = avail
+	}
+	if frames == 0 {
+		return 0, EOS
+	}
+	n := frames * channels
+	b.buf.Slice(b.off, b.off+n).CopyTo(p)
+	b.off += n
+	b.pos += uint64(n)
+	return frames, nil
+}
+
 // Next returns a slice containing the next n samples from the buffer,
 // advancing the buffer as if the samples had been returned by Read.
 // If there are fewer than n samples in the buffer, Next returns the entire buffer.
@@ -182,6 +224,7 @@ func (b *Buffer) Next(n int) Slice {
 	}
 	data := b.buf.Slice(b.off, b.off+n)
 	b.off += n
+	b.pos += uint64(n)
 	return data
 }
 
@@ -195,6 +238,7 @@ func (b *Buffer) ReadSample() (c F64, err error) {
 	}
 	c = b.buf.At(b.off)
 	b.off++
+	b.pos++
 	return c, nil
 }
 
@@ -209,9 +253,22 @@ func (b *Buffer) Seek(offset uint64) error {
 		return EOS
 	}
 	b.off = int(offset)
+	b.pos = offset
 	return nil
 }
 
+// SourcePosition implements the Positioner interface. The buffer is a source
+// in its own right, so the position is the number of samples read from it
+// (or the offset of the last successful Seek, plus those read since).
+func (b *Buffer) SourcePosition() uint64 {
+	return b.pos
+}
+
+// TotalLatency implements the Positioner interface. It always returns zero.
+func (b *Buffer) TotalLatency() int {
+	return 0
+}
+
 // NewBuffer creates and initializes a new Buffer using buf as its initial
 // contents. The buffer will internally use the given slice, buf, which also
 // defines the sample storage type. NewBuffer is intended to prepare a Buffer
@@ -0,0 +1,474 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// SampleEncoding describes how a single audio sample is encoded into bytes.
+type SampleEncoding uint8
+
+const (
+	// IntEncoding is linear integer PCM (signed or unsigned).
+	IntEncoding SampleEncoding = iota
+
+	// FloatEncoding is IEEE 754 floating-point PCM in the range of -1 to +1.
+	FloatEncoding
+
+	// MuLawEncoding is G.711 mu-law companded PCM.
+	MuLawEncoding
+
+	// ALawEncoding is G.711 a-law companded PCM.
+	ALawEncoding
+)
+
+// String returns a string representation of the sample encoding.
+func (e SampleEncoding) String() string {
+	switch e {
+	case IntEncoding:
+		return "Int"
+	case FloatEncoding:
+		return "Float"
+	case MuLawEncoding:
+		return "MuLaw"
+	case ALawEncoding:
+		return "ALaw"
+	}
+	return fmt.Sprintf("SampleEncoding(%d)", uint8(e))
+}
+
+// SampleFormat describes the byte-level representation of audio samples, as
+// used by raw (headerless) PCM data and by the data chunks of most container
+// formats.
+type SampleFormat struct {
+	// Encoding is the sample encoding.
+	Encoding SampleEncoding
+
+	// Bits is the number of significant bits per sample. It must be 32 or 64
+	// for FloatEncoding, 8 for the companded encodings and between 1 and 32
+	// for IntEncoding.
+	Bits int
+
+	// Size is the size of the container of each sample in bytes, which may be
+	// larger than the number of significant bits (e.g. 24-bit samples stored
+	// in 4 bytes). Samples are stored in the least significant bits of their
+	// container, sign-extended for signed formats. If zero, the smallest
+	// container that can hold Bits is used.
+	Size int
+
+	// Signed specifies whether IntEncoding samples are signed (two's
+	// complement, at least 2 bits) or unsigned (offset binary). It is ignored
+	// for other encodings.
+	Signed bool
+
+	// BigEndian specifies the byte order of multi-byte samples; little endian
+	// is used if false.
+	BigEndian bool
+}
+
+// Common sample formats.
+var (
+	FormatU8    = SampleFormat{Encoding: IntEncoding, Bits: 8}
+	FormatS16LE = SampleFormat{Encoding: IntEncoding, Bits: 16, Signed: true}
+	FormatS16BE = SampleFormat{Encoding: IntEncoding, Bits: 16, Signed: true, BigEndian: true}
+	FormatS24LE = SampleFormat{Encoding: IntEncoding, Bits: 24, Signed: true}
+	FormatS32LE = SampleFormat{Encoding: IntEncoding, Bits: 32, Signed: true}
+	FormatF32LE = SampleFormat{Encoding: FloatEncoding, Bits: 32}
+	FormatF64LE = SampleFormat{Encoding: FloatEncoding, Bits: 64}
+	FormatMuLaw = SampleFormat{Encoding: MuLawEncoding, Bits: 8}
+	FormatALaw  = SampleFormat{Encoding: ALawEncoding, Bits: 8}
+)
+
+// String returns a string representation of the sample format.
+func (f SampleFormat) String() string {
+	return fmt.Sprintf("SampleFormat(Encoding=%v, Bits=%v, Size=%v, Signed=%v, BigEndian=%v)", f.Encoding, f.Bits, f.BytesPerSample(), f.Signed, f.BigEndian)
+}
+
+// BytesPerSample returns the container size of each sample in bytes.
+func (f SampleFormat) BytesPerSample() int {
+	if f.Size != 0 {
+		return f.Size
+	}
+	return (f.Bits + 7) / 8
+}
+
+// Validate returns an error if the sample format is not supported.
+func (f SampleFormat) Validate() error {
+	size := f.BytesPerSample()
+	switch f.Encoding {
+	case IntEncoding:
+		minBits := 1
+		if f.Signed {
+			minBits = 2
+		}
+		if f.Bits >= minBits && f.Bits <= 32 && size >= (f.Bits+7)/8 && size <= 8 {
+			return nil
+		}
+	case FloatEncoding:
+		if (f.Bits == 32 || f.Bits == 64) && size == f.Bits/8 {
+			return nil
+		}
+	case MuLawEncoding, ALawEncoding:
+		if f.Bits == 8 && size == 1 {
+			return nil
+		}
+	}
+	return fmt.Errorf("audio: unsupported %v", f)
+}
+
+func (f SampleFormat) byteOrder() binary.ByteOrder {
+	if f.BigEndian {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// putUint stores the low len(b) bytes of v into b.
+func (f SampleFormat) putUint(b []byte, v uint64) {
+	if f.BigEndian {
+		for i := len(b) - 1; i >= 0; i-- {
+			b[i] = byte(v)
+			v >>= 8
+		}
+		return
+	}
+	for i := range b {
+		b[i] = byte(v)
+		v >>= 8
+	}
+}
+
+// uint loads an unsigned integer from all of the bytes in b.
+func (f SampleFormat) uint(b []byte) (v uint64) {
+	if f.BigEndian {
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		return
+	}
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	return
+}
+
+// clamp clamps s to the range of -1 to +1.
+func clamp(s F64) F64 {
+	switch {
+	case s > 1:
+		return 1
+	case s < -1:
+		return -1
+	}
+	return s
+}
+
+// encode encodes a single sample into b, which is exactly one container in
+// size.
+func (f SampleFormat) encode(b []byte, s F64) {
+	switch f.Encoding {
+	case IntEncoding:
+		if f.Signed {
+			max := F64(uint64(1)<<uint(f.Bits-1) - 1)
+			v := int64(math.Floor(float64(clamp(s)*max + 0.5)))
+			f.putUint(b, uint64(v))
+			return
+		}
+		max := F64(uint64(1)<<uint(f.Bits) - 1)
+		v := uint64(math.Floor(float64((clamp(s)+1)/2*max + 0.5)))
+		f.putUint(b, v)
+	case FloatEncoding:
+		if f.Bits == 32 {
+			f.byteOrder().PutUint32(b, math.Float32bits(float32(s)))
+			return
+		}
+		f.byteOrder().PutUint64(b, math.Float64bits(float64(s)))
+	case MuLawEncoding:
+		b[0] = byte(PCM16ToMuLaw(F64ToPCM16(clamp(s))))
+	case ALawEncoding:
+		b[0] = byte(PCM16ToALaw(F64ToPCM16(clamp(s))))
+	}
+}
+
+// decode decodes a single sample from b, which is exactly one container in
+// size.
+func (f SampleFormat) decode(b []byte) F64 {
+	switch f.Encoding {
+	case IntEncoding:
+		v := f.uint(b)
+		shift := uint(64 - f.Bits)
+		if f.Signed {
+			// Sign-extend from the significant bits.
+			max := F64(uint64(1)<<uint(f.Bits-1) - 1)
+			return F64(int64(v<<shift)>>shift) / max
+		}
+		max := F64(uint64(1)<<uint(f.Bits) - 1)
+		return F64(v<<shift>>shift)/max*2 - 1
+	case FloatEncoding:
+		if f.Bits == 32 {
+			return F64(math.Float32frombits(f.byteOrder().Uint32(b)))
+		}
+		return F64(math.Float64frombits(f.byteOrder().Uint64(b)))
+	case MuLawEncoding:
+		return PCM16ToF64(MuLawToPCM16(MuLaw(b[0])))
+	case ALawEncoding:
+		return PCM16ToF64(ALawToPCM16(ALaw(b[0])))
+	}
+	panic("audio: invalid sample encoding")
+}
+
+// Marshal encodes the samples of src into dst according to the sample format.
+// It returns the number of samples encoded, which is the minimum of src.Len()
+// and the number of whole samples that fit into dst.
+//
+// Slices whose type matches the sample format exactly (e.g. PCM16Samples and
+// signed 16-bit samples in a 2-byte container) are encoded without any
+// intermediate conversion to F64. Otherwise samples outside of the range of
+// -1 to +1 are clamped.
+//
+// It panics if the sample format is invalid.
+func (f SampleFormat) Marshal(dst []byte, src Slice) int {
+	if err := f.Validate(); err != nil {
+		panic(err)
+	}
+	size := f.BytesPerSample()
+	n := len(dst) / size
+	if src.Len() < n {
+		n = src.Len()
+	}
+	order := f.byteOrder()
+	switch s := src.(type) {
+	case PCM8Samples:
+		if f.Encoding == IntEncoding && !f.Signed && f.Bits == 8 && size == 1 {
+			for i := 0; i < n; i++ {
+				dst[i] = byte(s[i])
+			}
+			return n
+		}
+	case PCM16Samples:
+		if f.Encoding == IntEncoding && f.Signed && f.Bits == 16 && size == 2 {
+			for i := 0; i < n; i++ {
+				order.PutUint16(dst[i*2:], uint16(s[i]))
+			}
+			return n
+		}
+	case PCM32Samples:
+		if f.Encoding == IntEncoding && f.Signed && f.Bits == 32 && size == 4 {
+			for i := 0; i < n; i++ {
+				order.PutUint32(dst[i*4:], uint32(s[i]))
+			}
+			return n
+		}
+	case F32Samples:
+		if f.Encoding == FloatEncoding && f.Bits == 32 {
+			for i := 0; i < n; i++ {
+				order.PutUint32(dst[i*4:], math.Float32bits(float32(s[i])))
+			}
+			return n
+		}
+	case F64Samples:
+		if f.Encoding == FloatEncoding && f.Bits == 64 {
+			for i := 0; i < n; i++ {
+				order.PutUint64(dst[i*8:], math.Float64bits(float64(s[i])))
+			}
+			return n
+		}
+	case MuLawSamples:
+		if f.Encoding == MuLawEncoding {
+			for i := 0; i < n; i++ {
+				dst[i] = byte(s[i])
+			}
+			return n
+		}
+	case ALawSamples:
+		if f.Encoding == ALawEncoding {
+			for i := 0; i < n; i++ {
+				dst[i] = byte(s[i])
+			}
+			return n
+		}
+	}
+	for i := 0; i < n; i++ {
+		f.encode(dst[i*size:(i+1)*size], src.At(i))
+	}
+	return n
+}
+
+// Unmarshal decodes the samples encoded in src according to the sample format
+// into dst. It returns the number of samples decoded, which is the minimum of
+// dst.Len() and the number of whole samples in src.
+//
+// Slices whose type matches the sample format exactly are decoded without any
+// intermediate conversion to F64.
+//
+// It panics if the sample format is invalid.
+func (f SampleFormat) Unmarshal(dst Slice, src []byte) int {
+	if err := f.Validate(); err != nil {
+		panic(err)
+	}
+	size := f.BytesPerSample()
+	n := len(src) / size
+	if dst.Len() < n {
+		n = dst.Len()
+	}
+	order := f.byteOrder()
+	switch d := dst.(type) {
+	case PCM8Samples:
+		if f.Encoding == IntEncoding && !f.Signed && f.Bits == 8 && size == 1 {
+			for i := 0; i < n; i++ {
+				d[i] = PCM8(src[i])
+			}
+			return n
+		}
+	case PCM16Samples:
+		if f.Encoding == IntEncoding && f.Signed && f.Bits == 16 && size == 2 {
+			for i := 0; i < n; i++ {
+				d[i] = PCM16(order.Uint16(src[i*2:]))
+			}
+			return n
+		}
+	case PCM32Samples:
+		if f.Encoding == IntEncoding && f.Signed && f.Bits == 32 && size == 4 {
+			for i := 0; i < n; i++ {
+				d[i] = PCM32(order.Uint32(src[i*4:]))
+			}
+			return n
+		}
+	case F32Samples:
+		if f.Encoding == FloatEncoding && f.Bits == 32 {
+			for i := 0; i < n; i++ {
+				d[i] = F32(math.Float32frombits(order.Uint32(src[i*4:])))
+			}
+			return n
+		}
+	case F64Samples:
+		if f.Encoding == FloatEncoding && f.Bits == 64 {
+			for i := 0; i < n; i++ {
+				d[i] = F64(math.Float64frombits(order.Uint64(src[i*8:])))
+			}
+			return n
+		}
+	case MuLawSamples:
+		if f.Encoding == MuLawEncoding {
+			for i := 0; i < n; i++ {
+				d[i] = MuLaw(src[i])
+			}
+			return n
+		}
+	case ALawSamples:
+		if f.Encoding == ALawEncoding {
+			for i := 0; i < n; i++ {
+				d[i] = ALaw(src[i])
+			}
+			return n
+		}
+	}
+	for i := 0; i < n; i++ {
+		dst.Set(i, f.decode(src[i*size:(i+1)*size]))
+	}
+	return n
+}
+
+// byteReader implements the Reader interface by decoding raw bytes.
+type byteReader struct {
+	r    io.Reader
+	f    SampleFormat
+	size int
+	buf  []byte
+	n    int    // number of undecoded bytes at the start of buf
+	pos  uint64 // number of samples decoded
+}
+
+func (b *byteReader) Read(p Slice) (n int, err error) {
+	if p.Len() == 0 {
+		return 0, nil
+	}
+	need := p.Len() * b.size
+	if cap(b.buf) < need {
+		buf := make([]byte, need)
+		copy(buf, b.buf[:b.n])
+		b.buf = buf
+	}
+	b.buf = b.buf[:need]
+
+	// Read at least one whole sample.
+	var m int
+	m, err = io.ReadAtLeast(b.r, b.buf[b.n:], b.size-b.n)
+	b.n += m
+
+	whole := b.n / b.size * b.size
+	n = b.f.Unmarshal(p, b.buf[:whole])
+	b.n = copy(b.buf, b.buf[whole:b.n])
+	b.pos += uint64(n)
+
+	switch {
+	case err == io.EOF && b.n > 0, err == io.ErrUnexpectedEOF:
+		err = ErrUnexpectedEOS
+	case err == io.EOF:
+		err = EOS
+	}
+	return
+}
+
+func (b *byteReader) SourcePosition() uint64 {
+	return b.pos
+}
+
+func (b *byteReader) TotalLatency() int {
+	return 0
+}
+
+// NewByteReader returns a reader that decodes raw audio samples of the given
+// sample format from r, e.g. for reading headerless PCM data. The returned
+// reader implements the Positioner interface.
+//
+// If r ends in the middle of a sample, ErrUnexpectedEOS is returned.
+//
+// It panics if the sample format is invalid.
+func NewByteReader(r io.Reader, f SampleFormat) Reader {
+	if err := f.Validate(); err != nil {
+		panic(err)
+	}
+	return &byteReader{r: r, f: f, size: f.BytesPerSample()}
+}
+
+// byteWriter implements the Writer interface by encoding raw bytes.
+type byteWriter struct {
+	w    io.Writer
+	f    SampleFormat
+	size int
+	buf  []byte
+}
+
+func (b *byteWriter) Write(p Slice) (n int, err error) {
+	need := p.Len() * b.size
+	if cap(b.buf) < need {
+		b.buf = make([]byte, need)
+	}
+	b.buf = b.buf[:need]
+	b.f.Marshal(b.buf, p)
+	m, err := b.w.Write(b.buf)
+	n = m / b.size
+	if err == nil && n < p.Len() {
+		err = ErrShortWrite
+	}
+	return n, err
+}
+
+// NewByteWriter returns a writer that encodes audio samples into raw bytes of
+// the given sample format and writes them to w, e.g. for writing headerless
+// PCM data.
+//
+// It panics if the sample format is invalid.
+func NewByteWriter(w io.Writer, f SampleFormat) Writer {
+	if err := f.Validate(); err != nil {
+		panic(err)
+	}
+	return &byteWriter{w: w, f: f, size: f.BytesPerSample()}
+}
@@ -0,0 +1,129 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audio
+
+import (
+	"bytes"
+	"testing"
+	"testing/iotest"
+)
+
+var codecTestSamples = F64Samples{0, 0.5, -0.5, 1, -1, 0.25, -0.125}
+
+func TestSampleFormatRoundTrip(t *testing.T) {
+	formats := []struct {
+		f   SampleFormat
+		eps F64
+	}{
+		{FormatU8, 1.0 / 127},
+		{FormatS16LE, 1.0 / 32767},
+		{FormatS16BE, 1.0 / 32767},
+		{FormatS24LE, 1.0 / 8388607},
+		{SampleFormat{Encoding: IntEncoding, Bits: 24, Size: 4, Signed: true, BigEndian: true}, 1.0 / 8388607},
+		{SampleFormat{Encoding: IntEncoding, Bits: 20, Size: 3}, 1.0 / 524287},
+		{FormatS32LE, 1e-9},
+		{FormatF32LE, 0},
+		{FormatF64LE, 0},
+		{FormatMuLaw, 0.02},
+		{FormatALaw, 0.02},
+	}
+	for _, tst := range formats {
+		buf := make([]byte, len(codecTestSamples)*tst.f.BytesPerSample())
+		if n := tst.f.Marshal(buf, codecTestSamples); n != len(codecTestSamples) {
+			t.Fatalf("%v: Marshal got %d, want %d", tst.f, n, len(codecTestSamples))
+		}
+		out := make(F64Samples, len(codecTestSamples))
+		if n := tst.f.Unmarshal(out, buf); n != len(codecTestSamples) {
+			t.Fatalf("%v: Unmarshal got %d, want %d", tst.f, n, len(codecTestSamples))
+		}
+		for i, want := range codecTestSamples {
+			if d := out[i] - want; d > tst.eps || d < -tst.eps {
+				t.Fatalf("%v: sample %d got %v, want %v", tst.f, i, out[i], want)
+			}
+		}
+	}
+}
+
+func TestSampleFormatFastPath(t *testing.T) {
+	// Fast paths must produce the same bytes as the generic F64 path.
+	tests := []struct {
+		f SampleFormat
+		s Slice
+	}{
+		{FormatU8, make(PCM8Samples, len(codecTestSamples))},
+		{FormatS16BE, make(PCM16Samples, len(codecTestSamples))},
+		{FormatS32LE, make(PCM32Samples, len(codecTestSamples))},
+		{FormatF32LE, make(F32Samples, len(codecTestSamples))},
+		{FormatMuLaw, make(MuLawSamples, len(codecTestSamples))},
+		{FormatALaw, make(ALawSamples, len(codecTestSamples))},
+	}
+	for _, tst := range tests {
+		codecTestSamples.CopyTo(tst.s)
+		fast := make([]byte, tst.s.Len()*tst.f.BytesPerSample())
+		tst.f.Marshal(fast, tst.s)
+
+		generic := make([]byte, len(fast))
+		for i := 0; i < tst.s.Len(); i++ {
+			size := tst.f.BytesPerSample()
+			tst.f.encode(generic[i*size:(i+1)*size], tst.s.At(i))
+		}
+		if !bytes.Equal(fast, generic) {
+			t.Fatalf("%T: fast path got %v, want %v", tst.s, fast, generic)
+		}
+
+		back := tst.s.Make(tst.s.Len(), tst.s.Len())
+		tst.f.Unmarshal(back, fast)
+		for i := 0; i < tst.s.Len(); i++ {
+			if back.At(i) != tst.s.At(i) {
+				t.Fatalf("%T: sample %d got %v, want %v", tst.s, i, back.At(i), tst.s.At(i))
+			}
+		}
+	}
+}
+
+func TestByteReaderWriter(t *testing.T) {
+	var raw bytes.Buffer
+	w := NewByteWriter(&raw, FormatS16LE)
+	if n, err := w.Write(codecTestSamples); n != len(codecTestSamples) || err != nil {
+		t.Fatalf("Write got (%d, %v)", n, err)
+	}
+	if raw.Len() != len(codecTestSamples)*2 {
+		t.Fatalf("wrote %d bytes, want %d", raw.Len(), len(codecTestSamples)*2)
+	}
+
+	// Read back in small, odd chunks, from a reader returning a byte at a
+	// time.
+	r := NewByteReader(iotest.OneByteReader(&raw), FormatS16LE)
+	buf := NewBuffer(F64Samples{})
+	chunk := make(F64Samples, 3)
+	for {
+		n, err := r.Read(chunk)
+		buf.Write(chunk[:n])
+		if err == EOS {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if buf.Len() != len(codecTestSamples) {
+		t.Fatalf("read %d samples, want %d", buf.Len(), len(codecTestSamples))
+	}
+	for i, want := range codecTestSamples {
+		if got := buf.Samples().At(i); got-want > 1.0/32767 || want-got > 1.0/32767 {
+			t.Fatalf("sample %d = %v, want %v", i, got, want)
+		}
+	}
+
+	// A trailing partial sample is an unexpected end of stream.
+	r = NewByteReader(bytes.NewReader([]byte{1, 2, 3}), FormatS16LE)
+	out := make(F64Samples, 4)
+	if n, err := r.Read(out); n != 1 || err != nil {
+		t.Fatalf("Read got (%d, %v), want (1, nil)", n, err)
+	}
+	if _, err := r.Read(out); err != ErrUnexpectedEOS {
+		t.Fatalf("Read got %v, want ErrUnexpectedEOS", err)
+	}
+}
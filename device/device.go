@@ -0,0 +1,98 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package device
+
+import (
+	"errors"
+	"time"
+
+	"azul3d.org/audio.v1"
+)
+
+// ErrRunning is returned by Start when the device is already running.
+var ErrRunning = errors.New("device: already running")
+
+// Callback fills b, which holds a single period of interleaved frames laid out
+// according to the audio configuration of the device, with the next audio to
+// be played. Callbacks are called from a single goroutine at a time, and must
+// not block for long.
+type Callback func(b audio.Slice)
+
+// ReaderCallback returns a callback which reads each period from r. Should the
+// stream end or fail, the rest of the period (and all periods after it) are
+// silent.
+func ReaderCallback(r audio.Reader) Callback {
+	var err error
+	return func(b audio.Slice) {
+		n := 0
+		for n < b.Len() && err == nil {
+			var m int
+			m, err = r.Read(b.Slice(n, b.Len()))
+			n += m
+			if m == 0 && err == nil {
+				// No progress; treat the stream as ended.
+				err = audio.EOS
+			}
+		}
+		for i := n; i < b.Len(); i++ {
+			b.Set(i, 0)
+		}
+	}
+}
+
+// Params describes the buffering of a device.
+type Params struct {
+	// Period is the number of frames pulled from the callback at once.
+	Period int
+
+	// Periods is the number of periods buffered ahead of playback, at least
+	// two for continuous playback. The buffering latency is Period * Periods
+	// frames.
+	Periods int
+}
+
+// DefaultParams are the default buffering parameters of a device: two
+// periods of 512 frames, about 23 ms at 44.1 kHz.
+var DefaultParams = Params{Period: 512, Periods: 2}
+
+// Device is an audio output device.
+type Device interface {
+	// Config returns the audio configuration of the device.
+	Config() audio.Config
+
+	// Params returns the buffering parameters of the device.
+	Params() Params
+
+	// Start starts playback, pulling audio from the callback. It returns
+	// ErrRunning if the device is already running.
+	Start(cb Callback) error
+
+	// Stop stops playback, after which the callback is no longer called. It
+	// is a no-op if the device is not running.
+	Stop() error
+
+	// Latency returns the output latency of the device: the time between
+	// audio being pulled from the callback and it being heard.
+	Latency() time.Duration
+
+	// Position returns the number of frames that have been heard since the
+	// device was created.
+	Position() int64
+}
+
+// frames returns the number of frames in the duration d at the given sample
+// rate, rounded to the nearest frame.
+func frames(d time.Duration, sampleRate int) int64 {
+	// Split the duration to avoid overflow for long durations.
+	sec, frac := d/time.Second, d%time.Second
+	return int64(sec)*int64(sampleRate) + (int64(frac)*int64(sampleRate)+int64(time.Second/2))/int64(time.Second)
+}
+
+// duration returns the duration of n frames at the given sample rate.
+func duration(n int64, sampleRate int) time.Duration {
+	// Split the frames to avoid overflow for long durations.
+	sec, frac := n/int64(sampleRate), n%int64(sampleRate)
+	return time.Duration(sec)*time.Second + time.Duration(frac)*time.Second/time.Duration(sampleRate)
+}
@@ -0,0 +1,51 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package device
+
+import (
+	"testing"
+	"time"
+
+	"azul3d.org/audio.v1"
+)
+
+func TestReaderCallback(t *testing.T) {
+	in := make(audio.F64Samples, 150)
+	for i := range in {
+		in[i] = 1
+	}
+	cb := ReaderCallback(audio.NewBuffer(in))
+	for period := 0; period < 3; period++ {
+		b := make(audio.F64Samples, 100)
+		for i := range b {
+			b[i] = 5
+		}
+		cb(b)
+		for i, v := range b {
+			want := audio.F64(0)
+			if period*100+i < 150 {
+				want = 1
+			}
+			if v != want {
+				t.Fatalf("period %d sample %d = %v, want %v", period, i, v, want)
+			}
+		}
+	}
+}
+
+func TestFrames(t *testing.T) {
+	if n := frames(time.Second/3, 44100); n != 14700 {
+		t.Errorf("frames(1/3s) = %d, want 14700", n)
+	}
+	if n := frames(1000*time.Hour, 192000); n != 1000*3600*192000 {
+		t.Errorf("frames(1000h) = %d", n)
+	}
+	if d := duration(22050, 44100); d != time.Second/2 {
+		t.Errorf("duration(22050) = %v", d)
+	}
+	if d := duration(1000*3600*192000, 192000); d != 1000*time.Hour {
+		t.Errorf("duration(1000h) = %v", d)
+	}
+}
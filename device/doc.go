@@ -0,0 +1,21 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package device defines an interface to audio output devices, and implements
+// a virtual device for testing.
+//
+// A Device pulls audio from a Callback, one period of frames at a time, and
+// keeps a number of periods buffered ahead of playback. Backends for real
+// devices live in other packages; the Virtual device plays to an
+// audio.Writer instead, driven by either a simulated clock (which advances
+// only when told to, such that tests are deterministic) or the wall clock,
+// and can simulate the underruns and latency of real devices:
+//
+//	var rec audio.Buffer
+//	dev := device.NewVirtual(c, device.VirtualParams{Params: device.DefaultParams}, &rec)
+//	dev.Start(device.ReaderCallback(scheduler))
+//	dev.Advance(time.Second)
+//	dev.Stall(20 * time.Millisecond) // e.g. a slow frame; may underrun
+//	dev.Advance(time.Second)
+package device
@@ -0,0 +1,362 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package device
+
+import (
+	"sync"
+	"time"
+
+	"azul3d.org/audio.v1"
+)
+
+// Clock is the clock which drives a virtual device.
+type Clock uint8
+
+const (
+	// SimulatedClock advances only when the Advance method is called, from
+	// the calling goroutine, such that playback is deterministic.
+	SimulatedClock Clock = iota
+
+	// WallClock advances in real time, from a goroutine of the device.
+	WallClock
+)
+
+// String returns a string representation of the clock.
+func (c Clock) String() string {
+	switch c {
+	case SimulatedClock:
+		return "SimulatedClock"
+	case WallClock:
+		return "WallClock"
+	}
+	return "Clock(invalid)"
+}
+
+// VirtualParams describes the parameters of a virtual device.
+type VirtualParams struct {
+	Params
+
+	// Clock is the clock which drives the device.
+	Clock Clock
+
+	// Latency is the simulated hardware latency of the device, between audio
+	// being played and it being heard, in addition to its buffering.
+	Latency time.Duration
+}
+
+// Virtual is a virtual output device, which plays to an audio.Writer (e.g. to
+// record it to a file, or an audio.Buffer to inspect it). It implements the
+// Device interface. Virtual devices must be allocated via the NewVirtual
+// function.
+//
+// Like a real device, it keeps a number of periods buffered ahead of
+// playback, and calls the callback whenever a period of the buffer has been
+// played. Should the callback be stalled for longer than the buffer lasts
+// (see Stall), the device underruns: it plays silence until the callback
+// catches up. Everything played, including such silence, is written to the
+// writer.
+type Virtual struct {
+	config audio.Config
+	params VirtualParams
+	w      audio.Writer
+
+	// run is held while the device is playing, serializing the playback of
+	// the simulated and wall clocks.
+	run   sync.Mutex
+	cb    Callback
+	queue audio.F64Samples // buffered frames, not yet played
+	buf   audio.F64Samples // a single period
+	zero  audio.F64Samples // a single period of silence
+	stall int64            // the frame until which the callback is stalled
+	under bool             // whether the device is currently underrunning
+
+	access    sync.Mutex
+	running   bool
+	played    int64 // frames played
+	pulls     int
+	underruns int
+	err       error
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// Config implements the Device interface.
+func (v *Virtual) Config() audio.Config {
+	return v.config
+}
+
+// Params implements the Device interface.
+func (v *Virtual) Params() Params {
+	return v.params.Params
+}
+
+// Latency implements the Device interface. It is the buffering latency plus
+// the simulated hardware latency.
+func (v *Virtual) Latency() time.Duration {
+	p := v.params
+	return duration(int64(p.Period*p.Periods), v.config.SampleRate) + p.Latency
+}
+
+// Position implements the Device interface. Audio played is not heard until
+// the simulated hardware latency has passed.
+func (v *Virtual) Position() int64 {
+	v.access.Lock()
+	defer v.access.Unlock()
+	n := v.played - frames(v.params.Latency, v.config.SampleRate)
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// Played returns the number of frames played (and written) since the device
+// was created, including silence played during underruns.
+func (v *Virtual) Played() int64 {
+	v.access.Lock()
+	defer v.access.Unlock()
+	return v.played
+}
+
+// Pulls returns the number of times the callback has been called.
+func (v *Virtual) Pulls() int {
+	v.access.Lock()
+	defer v.access.Unlock()
+	return v.pulls
+}
+
+// Underruns returns the number of times the device has underrun.
+func (v *Virtual) Underruns() int {
+	v.access.Lock()
+	defer v.access.Unlock()
+	return v.underruns
+}
+
+// Err returns the error which writing to the writer failed with, if any,
+// after which nothing more is written.
+func (v *Virtual) Err() error {
+	v.access.Lock()
+	defer v.access.Unlock()
+	return v.err
+}
+
+// pull calls the callback for the next period, and queues it; v.run must be
+// held.
+func (v *Virtual) pull(now int64) {
+	for i := range v.buf {
+		v.buf[i] = 0
+	}
+	start := time.Now()
+	v.cb(v.buf)
+	if v.params.Clock == WallClock {
+		// A slow callback delays the next, as it would on a real device.
+		if stall := now + frames(time.Since(start), v.config.SampleRate); stall > v.stall {
+			v.stall = stall
+		}
+	}
+	v.queue = append(v.queue, v.buf...)
+	v.access.Lock()
+	v.pulls++
+	v.access.Unlock()
+}
+
+// play plays the frames of b (or silence, if nil) of n frames; v.run must be
+// held.
+func (v *Virtual) play(b audio.F64Samples, n int) {
+	v.access.Lock()
+	defer v.access.Unlock()
+	v.played += int64(n)
+	if v.w == nil || v.err != nil {
+		return
+	}
+	if b != nil {
+		if _, err := v.w.Write(b); err != nil {
+			v.err = err
+		}
+		return
+	}
+	// Write the silence a period at a time.
+	for left := n * v.config.Channels; left > 0; {
+		k := len(v.zero)
+		if k > left {
+			k = left
+		}
+		if _, err := v.w.Write(v.zero[:k]); err != nil {
+			v.err = err
+			return
+		}
+		left -= k
+	}
+}
+
+// fill calls the callback while a period fits in the buffer, unless it is
+// stalled; v.run must be held.
+func (v *Virtual) fill(now int64) {
+	room := (v.params.Periods - 1) * v.params.Period
+	for now >= v.stall && len(v.queue)/v.config.Channels <= room {
+		v.pull(now)
+	}
+}
+
+// advanceTo plays until the given frame; v.run must be held.
+func (v *Virtual) advanceTo(target int64) {
+	ch := v.config.Channels
+	room := (v.params.Periods - 1) * v.params.Period // queued frames at which a period fits
+	now := v.Played()
+	for now < target {
+		v.fill(now)
+		queued := len(v.queue) / ch
+		step := target - now
+		if queued > 0 {
+			// Play until the next period fits, or the buffer runs out.
+			if fits := int64(queued - room); fits > 0 && fits < step {
+				step = fits
+			}
+			if int64(queued) < step {
+				step = int64(queued)
+			}
+			if now < v.stall && v.stall-now < step {
+				step = v.stall - now
+			}
+			n := int(step) * ch
+			v.play(v.queue[:n], int(step))
+			v.queue = append(v.queue[:0], v.queue[n:]...)
+			v.under = false
+		} else {
+			// An underrun; silence until the callback catches up.
+			if !v.under {
+				v.under = true
+				v.access.Lock()
+				v.underruns++
+				v.access.Unlock()
+			}
+			if v.stall-now < step {
+				step = v.stall - now
+			}
+			v.play(nil, int(step))
+		}
+		now += step
+	}
+	v.fill(now)
+}
+
+// Advance advances the simulated clock by d, playing audio and calling the
+// callback as needed from the calling goroutine. It is a no-op if the device
+// is not running.
+//
+// It panics if the device does not have a simulated clock.
+func (v *Virtual) Advance(d time.Duration) {
+	if v.params.Clock != SimulatedClock {
+		panic("device: Advance requires a simulated clock")
+	}
+	v.run.Lock()
+	defer v.run.Unlock()
+	if v.cb == nil {
+		return
+	}
+	v.advanceTo(v.Played() + frames(d, v.config.SampleRate))
+}
+
+// Stall stalls the callback for the duration d from the current time, as if
+// it (or the thread calling it) were busy; should the buffer run out in the
+// meantime, the device underruns. Stalls are typically simulated with a
+// simulated clock, but work with either.
+func (v *Virtual) Stall(d time.Duration) {
+	v.run.Lock()
+	v.stall = v.Played() + frames(d, v.config.SampleRate)
+	v.run.Unlock()
+}
+
+// tick plays in real time until stopped.
+func (v *Virtual) tick(stop, done chan struct{}) {
+	defer close(done)
+	start, base := time.Now(), v.Played()
+	period := duration(int64(v.params.Period), v.config.SampleRate)
+	t := time.NewTicker(period / 2)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			v.run.Lock()
+			v.advanceTo(base + frames(time.Since(start), v.config.SampleRate))
+			v.run.Unlock()
+		}
+	}
+}
+
+// Start implements the Device interface. The buffer is filled immediately,
+// from the calling goroutine.
+func (v *Virtual) Start(cb Callback) error {
+	v.access.Lock()
+	if v.running {
+		v.access.Unlock()
+		return ErrRunning
+	}
+	v.running = true
+	v.access.Unlock()
+
+	v.run.Lock()
+	v.cb = cb
+	v.queue = v.queue[:0]
+	v.stall, v.under = 0, false
+	v.fill(v.Played())
+	v.run.Unlock()
+
+	if v.params.Clock == WallClock {
+		stop, done := make(chan struct{}), make(chan struct{})
+		v.access.Lock()
+		v.stop, v.done = stop, done
+		v.access.Unlock()
+		go v.tick(stop, done)
+	}
+	return nil
+}
+
+// Stop implements the Device interface. Any buffered audio not yet played is
+// discarded. It must not be called from the callback.
+func (v *Virtual) Stop() error {
+	v.access.Lock()
+	if !v.running {
+		v.access.Unlock()
+		return nil
+	}
+	v.running = false
+	stop, done := v.stop, v.done
+	v.stop, v.done = nil, nil
+	v.access.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	v.run.Lock()
+	v.cb = nil
+	v.queue = v.queue[:0]
+	v.run.Unlock()
+	return nil
+}
+
+// NewVirtual returns a new virtual device with the given audio configuration
+// and parameters, which writes everything it plays to w (which may be nil).
+//
+// It panics if the audio configuration is invalid, or the period or number
+// of periods are not positive.
+func NewVirtual(c audio.Config, p VirtualParams, w audio.Writer) *Virtual {
+	if c.SampleRate < 1 || c.Channels < 1 {
+		panic("device: invalid audio configuration")
+	}
+	if p.Period < 1 || p.Periods < 1 {
+		panic("device: invalid buffering parameters")
+	}
+	return &Virtual{
+		config: c,
+		params: p,
+		w:      w,
+		queue:  make(audio.F64Samples, 0, p.Period*(p.Periods+1)*c.Channels),
+		buf:    make(audio.F64Samples, p.Period*c.Channels),
+		zero:   make(audio.F64Samples, p.Period*c.Channels),
+	}
+}
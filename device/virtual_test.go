@@ -0,0 +1,197 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package device
+
+import (
+	"testing"
+	"time"
+
+	"azul3d.org/audio.v1"
+)
+
+var mono = audio.Config{SampleRate: 1000, Channels: 1}
+
+// counter returns a callback producing the frame count, starting at one.
+func counter() Callback {
+	var n audio.F64
+	return func(b audio.Slice) {
+		for i := 0; i < b.Len(); i++ {
+			n++
+			b.Set(i, n)
+		}
+	}
+}
+
+func newVirtual(p VirtualParams) (*Virtual, *audio.Buffer) {
+	if p.Params == (Params{}) {
+		p.Params = Params{Period: 100, Periods: 2}
+	}
+	rec := audio.NewBuffer(make(audio.F64Samples, 0, 4096))
+	return NewVirtual(mono, p, rec), rec
+}
+
+func recording(rec *audio.Buffer) audio.F64Samples {
+	return rec.Samples().(audio.F64Samples)
+}
+
+func TestVirtual(t *testing.T) {
+	v, rec := newVirtual(VirtualParams{})
+	if err := v.Start(counter()); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Start(counter()); err != ErrRunning {
+		t.Errorf("second Start() = %v, want ErrRunning", err)
+	}
+	if v.Pulls() != 2 || v.Played() != 0 {
+		t.Fatalf("after Start: Pulls() = %d, Played() = %d", v.Pulls(), v.Played())
+	}
+	v.Advance(50 * time.Millisecond)
+	if v.Pulls() != 2 {
+		t.Errorf("pulled before a period was played: %d", v.Pulls())
+	}
+	v.Advance(50 * time.Millisecond)
+	if v.Pulls() != 3 {
+		t.Errorf("Pulls() = %d after a period, want 3", v.Pulls())
+	}
+	v.Advance(900 * time.Millisecond)
+	out := recording(rec)
+	if len(out) != 1000 || v.Played() != 1000 || v.Pulls() != 12 || v.Underruns() != 0 {
+		t.Fatalf("len = %d, Played() = %d, Pulls() = %d, Underruns() = %d", len(out), v.Played(), v.Pulls(), v.Underruns())
+	}
+	for i, x := range out {
+		if x != audio.F64(i+1) {
+			t.Fatalf("sample %d = %v, want %v", i, x, i+1)
+		}
+	}
+
+	// Once stopped, the clock no longer advances.
+	v.Stop()
+	v.Advance(time.Second)
+	if v.Played() != 1000 || v.Pulls() != 12 {
+		t.Errorf("stopped device played %d, pulled %d", v.Played(), v.Pulls())
+	}
+}
+
+// maxWriter records the number of samples written, and the largest write.
+type maxWriter struct {
+	n, max int
+}
+
+func (w *maxWriter) Write(b audio.Slice) (int, error) {
+	w.n += b.Len()
+	if b.Len() > w.max {
+		w.max = b.Len()
+	}
+	return b.Len(), nil
+}
+
+func TestVirtualLongUnderrun(t *testing.T) {
+	// The silence of a long underrun is written a period at a time.
+	w := &maxWriter{}
+	v := NewVirtual(mono, VirtualParams{Params: Params{Period: 100, Periods: 2}}, w)
+	v.Start(counter())
+	v.Stall(time.Minute)
+	v.Advance(time.Minute)
+	if w.n != 60000 || w.max > 100 {
+		t.Errorf("wrote %d samples, at most %d at once", w.n, w.max)
+	}
+}
+
+func TestVirtualUnderrun(t *testing.T) {
+	v, rec := newVirtual(VirtualParams{})
+	v.Start(counter())
+	v.Advance(100 * time.Millisecond)
+
+	// Two periods are buffered, so a stall of 150 ms is survived, but one of
+	// 250 ms underruns for 50 ms.
+	v.Stall(150 * time.Millisecond)
+	v.Advance(300 * time.Millisecond)
+	if v.Underruns() != 0 {
+		t.Fatalf("Underruns() = %d after a short stall", v.Underruns())
+	}
+	v.Stall(250 * time.Millisecond)
+	v.Advance(400 * time.Millisecond)
+	if v.Underruns() != 1 {
+		t.Fatalf("Underruns() = %d, want 1", v.Underruns())
+	}
+	out := recording(rec)
+	if len(out) != 800 {
+		t.Fatalf("recorded %d frames, want 800", len(out))
+	}
+	// No audio is lost, but it is delayed by the silence of the underrun.
+	for i, x := range out {
+		want := audio.F64(i + 1)
+		switch {
+		case i >= 600 && i < 650:
+			want = 0
+		case i >= 650:
+			want = audio.F64(i + 1 - 50)
+		}
+		if x != want {
+			t.Fatalf("sample %d = %v, want %v", i, x, want)
+		}
+	}
+}
+
+func TestVirtualLatency(t *testing.T) {
+	v, _ := newVirtual(VirtualParams{Latency: 30 * time.Millisecond})
+	if l := v.Latency(); l != 230*time.Millisecond {
+		t.Errorf("Latency() = %v, want 230ms", l)
+	}
+	v.Start(counter())
+	v.Advance(20 * time.Millisecond)
+	if p := v.Position(); p != 0 {
+		t.Errorf("Position() = %d before the latency, want 0", p)
+	}
+	v.Advance(80 * time.Millisecond)
+	if p := v.Position(); p != 70 {
+		t.Errorf("Position() = %d, want 70", p)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(b audio.Slice) (int, error) {
+	return 0, audio.ErrShortWrite
+}
+
+func TestVirtualWriteError(t *testing.T) {
+	v := NewVirtual(mono, VirtualParams{Params: DefaultParams}, failingWriter{})
+	v.Start(counter())
+	v.Advance(time.Second)
+	if v.Err() != audio.ErrShortWrite || v.Played() != 1000 {
+		t.Errorf("Err() = %v, Played() = %d", v.Err(), v.Played())
+	}
+}
+
+func TestVirtualWallClock(t *testing.T) {
+	v, rec := newVirtual(VirtualParams{
+		Params: Params{Period: 10, Periods: 3},
+		Clock:  WallClock,
+	})
+	v.Start(counter())
+	time.Sleep(100 * time.Millisecond)
+	v.Stop()
+	played := v.Played()
+	if played < 20 || v.Pulls() < 5 {
+		t.Errorf("played %d frames in 100ms, with %d pulls", played, v.Pulls())
+	}
+	time.Sleep(20 * time.Millisecond)
+	if v.Played() != played {
+		t.Error("played after Stop")
+	}
+	out := recording(rec)
+	if int64(len(out)) != played {
+		t.Fatalf("recorded %d frames, played %d", len(out), played)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Advance did not panic")
+			}
+		}()
+		v.Advance(time.Second)
+	}()
+}
@@ -0,0 +1,346 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"sync"
+	"time"
+
+	"azul3d.org/audio.v1"
+)
+
+// FilterType represents a single type of biquad filter.
+type FilterType uint8
+
+const (
+	// LowPass passes frequencies below the cutoff frequency.
+	LowPass FilterType = iota
+
+	// HighPass passes frequencies above the cutoff frequency.
+	HighPass
+
+	// BandPass passes frequencies around the center frequency, with a
+	// constant 0 dB peak gain.
+	BandPass
+
+	// Notch rejects frequencies around the center frequency.
+	Notch
+
+	// AllPass passes all frequencies, but alters their phase.
+	AllPass
+
+	// Peaking boosts or cuts frequencies around the center frequency by the
+	// filter's gain (i.e. a parametric bell).
+	Peaking
+
+	// LowShelf boosts or cuts frequencies below the corner frequency by the
+	// filter's gain.
+	LowShelf
+
+	// HighShelf boosts or cuts frequencies above the corner frequency by the
+	// filter's gain.
+	HighShelf
+)
+
+// String returns a string representation of the filter type.
+func (t FilterType) String() string {
+	switch t {
+	case LowPass:
+		return "LowPass"
+	case HighPass:
+		return "HighPass"
+	case BandPass:
+		return "BandPass"
+	case Notch:
+		return "Notch"
+	case AllPass:
+		return "AllPass"
+	case Peaking:
+		return "Peaking"
+	case LowShelf:
+		return "LowShelf"
+	case HighShelf:
+		return "HighShelf"
+	}
+	return fmt.Sprintf("FilterType(%d)", uint8(t))
+}
+
+// BiquadParams describes the parameters of a biquad filter.
+type BiquadParams struct {
+	// Type is the type of filter.
+	Type FilterType
+
+	// Freq is the cutoff, center or corner frequency of the filter in Hz. It
+	// is limited to just below the Nyquist frequency of the stream.
+	Freq float64
+
+	// Q is the quality factor of the filter, which controls the bandwidth (or
+	// the shelf slope, for shelving filters). A Q of 1/sqrt(2) gives a
+	// maximally flat (Butterworth) response. If zero, 1/sqrt(2) is used.
+	Q float64
+
+	// Gain is the gain of the filter in decibels. It is only used by the
+	// Peaking, LowShelf and HighShelf filter types.
+	Gain float64
+}
+
+// lerp interpolates between the parameters p and q by t; the frequency is
+// interpolated logarithmically.
+func (p BiquadParams) lerp(q BiquadParams, t float64) BiquadParams {
+	r := q
+	if p.Freq > 0 && q.Freq > 0 {
+		r.Freq = p.Freq * math.Pow(q.Freq/p.Freq, t)
+	}
+	r.Q = p.q() + (q.q()-p.q())*t
+	r.Gain = p.Gain + (q.Gain-p.Gain)*t
+	return r
+}
+
+// q returns the quality factor, substituting the default for zero.
+func (p BiquadParams) q() float64 {
+	if p.Q <= 0 {
+		return 1 / math.Sqrt2
+	}
+	return p.Q
+}
+
+// Coefficients returns the normalized filter coefficients for the given
+// sample rate, as described by the RBJ audio EQ cookbook:
+//
+//	http://www.musicdsp.org/files/Audio-EQ-Cookbook.txt
+func (p BiquadParams) Coefficients(sampleRate int) Coefficients {
+	fs := float64(sampleRate)
+	f := p.Freq
+	if max := fs * 0.499; f > max {
+		f = max
+	}
+	if f < 1e-3 {
+		f = 1e-3
+	}
+	q := p.q()
+
+	w0 := 2 * math.Pi * f / fs
+	cos, sin := math.Cos(w0), math.Sin(w0)
+	alpha := sin / (2 * q)
+	a := math.Pow(10, p.Gain/40)
+
+	var b0, b1, b2, a0, a1, a2 float64
+	switch p.Type {
+	case LowPass:
+		b0, b1, b2 = (1-cos)/2, 1-cos, (1-cos)/2
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	case HighPass:
+		b0, b1, b2 = (1+cos)/2, -(1 + cos), (1+cos)/2
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	case BandPass:
+		b0, b1, b2 = alpha, 0, -alpha
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	case Notch:
+		b0, b1, b2 = 1, -2*cos, 1
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	case AllPass:
+		b0, b1, b2 = 1-alpha, -2*cos, 1+alpha
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	case Peaking:
+		b0, b1, b2 = 1+alpha*a, -2*cos, 1-alpha*a
+		a0, a1, a2 = 1+alpha/a, -2*cos, 1-alpha/a
+	case LowShelf:
+		sq := 2 * math.Sqrt(a) * alpha
+		b0 = a * ((a + 1) - (a-1)*cos + sq)
+		b1 = 2 * a * ((a - 1) - (a+1)*cos)
+		b2 = a * ((a + 1) - (a-1)*cos - sq)
+		a0 = (a + 1) + (a-1)*cos + sq
+		a1 = -2 * ((a - 1) + (a+1)*cos)
+		a2 = (a + 1) + (a-1)*cos - sq
+	case HighShelf:
+		sq := 2 * math.Sqrt(a) * alpha
+		b0 = a * ((a + 1) + (a-1)*cos + sq)
+		b1 = -2 * a * ((a - 1) + (a+1)*cos)
+		b2 = a * ((a + 1) + (a-1)*cos - sq)
+		a0 = (a + 1) - (a-1)*cos + sq
+		a1 = 2 * ((a - 1) - (a+1)*cos)
+		a2 = (a + 1) - (a-1)*cos - sq
+	default:
+		panic("dsp: invalid filter type")
+	}
+	return Coefficients{
+		B0: b0 / a0,
+		B1: b1 / a0,
+		B2: b2 / a0,
+		A1: a1 / a0,
+		A2: a2 / a0,
+	}
+}
+
+// Coefficients represents the normalized coefficients of a biquad filter,
+// whose transfer function is:
+//
+//	H(z) = (B0 + B1*z^-1 + B2*z^-2) / (1 + A1*z^-1 + A2*z^-2)
+type Coefficients struct {
+	B0, B1, B2, A1, A2 float64
+}
+
+// Response returns the complex frequency response of the filter at the given
+// frequency in Hz, for the given sample rate. Its absolute value is the
+// magnitude response and its argument is the phase response.
+func (c Coefficients) Response(freq float64, sampleRate int) complex128 {
+	w := 2 * math.Pi * freq / float64(sampleRate)
+	z1 := cmplx.Exp(complex(0, -w)) // z^-1
+	z2 := z1 * z1                   // z^-2
+	num := complex(c.B0, 0) + complex(c.B1, 0)*z1 + complex(c.B2, 0)*z2
+	den := 1 + complex(c.A1, 0)*z1 + complex(c.A2, 0)*z2
+	return num / den
+}
+
+// lerp linearly interpolates between the coefficients c and d by t.
+func (c Coefficients) lerp(d Coefficients, t float64) Coefficients {
+	return Coefficients{
+		B0: c.B0 + (d.B0-c.B0)*t,
+		B1: c.B1 + (d.B1-c.B1)*t,
+		B2: c.B2 + (d.B2-c.B2)*t,
+		A1: c.A1 + (d.A1-c.A1)*t,
+		A2: c.A2 + (d.A2-c.A2)*t,
+	}
+}
+
+// biquadState is the per-channel history of a biquad filter.
+type biquadState struct {
+	x1, x2, y1, y2 float64
+}
+
+// DefaultSmoothing is the default duration over which a Biquad filter
+// interpolates its coefficients when its parameters are changed.
+const DefaultSmoothing = 10 * time.Millisecond
+
+// Biquad is a second-order IIR filter that processes each channel of an
+// interleaved audio stream independently. It implements the Processor
+// interface. Biquads must be allocated via the NewBiquad function.
+//
+// It is safe to change the filter's parameters from another goroutine while
+// audio is being processed; to avoid audible clicks the filter coefficients
+// are then interpolated over a short period of time.
+type Biquad struct {
+	access    sync.Mutex
+	params    BiquadParams
+	smoothing time.Duration
+	dirty     bool
+
+	config       audio.Config
+	cur, target  Coefficients
+	start        Coefficients // coefficients at the start of a transition
+	from, to     BiquadParams // parameters at the start and end of a transition
+	ramp, ramped int          // transition length and progress in frames
+	state        []biquadState
+}
+
+// Params returns the current parameters of the filter.
+func (b *Biquad) Params() BiquadParams {
+	b.access.Lock()
+	defer b.access.Unlock()
+	return b.params
+}
+
+// SetParams sets the parameters of the filter. The filter will smoothly
+// transition to the new parameters over the smoothing duration.
+func (b *Biquad) SetParams(p BiquadParams) {
+	b.access.Lock()
+	b.params = p
+	b.dirty = true
+	b.access.Unlock()
+}
+
+// SetSmoothing sets the duration over which the filter coefficients are
+// interpolated when the parameters are changed. A duration of zero disables
+// interpolation.
+func (b *Biquad) SetSmoothing(d time.Duration) {
+	b.access.Lock()
+	b.smoothing = d
+	b.access.Unlock()
+}
+
+// Reset clears the filter's history, as if no audio had been processed yet.
+func (b *Biquad) Reset() {
+	b.access.Lock()
+	for i := range b.state {
+		b.state[i] = biquadState{}
+	}
+	b.access.Unlock()
+}
+
+// Implements the Processor interface.
+func (b *Biquad) Process(s audio.Slice, c audio.Config) {
+	b.access.Lock()
+	defer b.access.Unlock()
+
+	if c != b.config {
+		// The stream configuration changed, start over.
+		b.config = c
+		b.state = make([]biquadState, c.Channels)
+		b.cur = b.params.Coefficients(c.SampleRate)
+		b.target = b.cur
+		b.to = b.params
+		b.ramp, b.ramped = 0, 0
+		b.dirty = false
+	}
+	if b.dirty {
+		b.dirty = false
+		b.start = b.cur
+		from := b.to
+		if b.ramped < b.ramp {
+			// Interrupted transition; begin from where it left off.
+			from = b.from.lerp(b.to, float64(b.ramped)/float64(b.ramp))
+		}
+		b.from, b.to = from, b.params
+		b.target = b.params.Coefficients(c.SampleRate)
+		b.ramp = int(b.smoothing.Seconds() * float64(c.SampleRate))
+		b.ramped = 0
+		if b.ramp == 0 {
+			b.cur = b.target
+		}
+	}
+
+	frames := s.Len() / c.Channels
+	for f := 0; f < frames; f++ {
+		if b.ramped < b.ramp {
+			b.ramped++
+			t := float64(b.ramped) / float64(b.ramp)
+			if b.from.Type == b.to.Type {
+				// Interpolating the parameters, rather than the
+				// coefficients, keeps the filter stable throughout.
+				b.cur = b.from.lerp(b.to, t).Coefficients(c.SampleRate)
+			} else {
+				b.cur = b.start.lerp(b.target, t)
+			}
+		}
+		k := b.cur
+		for ch := range b.state {
+			i := f*c.Channels + ch
+			st := &b.state[ch]
+			x := float64(s.At(i))
+			y := k.B0*x + k.B1*st.x1 + k.B2*st.x2 - k.A1*st.y1 - k.A2*st.y2
+			st.x2, st.x1 = st.x1, x
+			st.y2, st.y1 = st.y1, y
+			s.Set(i, audio.F64(y))
+		}
+	}
+}
+
+// NewBiquad returns a new biquad filter with the given parameters.
+func NewBiquad(p BiquadParams) *Biquad {
+	return &Biquad{
+		params:    p,
+		smoothing: DefaultSmoothing,
+	}
+}
+
+// Process is short-hand for filtering the interleaved audio slice s in-place
+// with a new biquad filter of the given parameters; i.e. the filter begins
+// with no history.
+func Process(s audio.Slice, c audio.Config, p BiquadParams) {
+	NewBiquad(p).Process(s, c)
+}
@@ -0,0 +1,105 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"testing"
+
+	"azul3d.org/audio.v1"
+)
+
+// sine returns an interleaved sine wave of the given frequency, duplicated
+// across all channels.
+func sine(c audio.Config, freq float64, frames int) audio.F64Samples {
+	s := make(audio.F64Samples, frames*c.Channels)
+	for f := 0; f < frames; f++ {
+		v := audio.F64(math.Sin(2 * math.Pi * freq * float64(f) / float64(c.SampleRate)))
+		for ch := 0; ch < c.Channels; ch++ {
+			s[f*c.Channels+ch] = v
+		}
+	}
+	return s
+}
+
+// peak returns the peak absolute sample value in s, skipping the first skip
+// samples.
+func peak(s audio.Slice, skip int) float64 {
+	var p float64
+	for i := skip; i < s.Len(); i++ {
+		p = math.Max(p, math.Abs(float64(s.At(i))))
+	}
+	return p
+}
+
+func TestBiquadResponse(t *testing.T) {
+	c := audio.Config{SampleRate: 44100, Channels: 2}
+	tests := []struct {
+		p        BiquadParams
+		freq     float64
+		min, max float64
+	}{
+		{BiquadParams{Type: LowPass, Freq: 1000}, 100, 0.95, 1.05},
+		{BiquadParams{Type: LowPass, Freq: 1000}, 10000, 0, 0.02},
+		{BiquadParams{Type: HighPass, Freq: 1000}, 100, 0, 0.02},
+		{BiquadParams{Type: HighPass, Freq: 1000}, 10000, 0.95, 1.05},
+		{BiquadParams{Type: Notch, Freq: 1000, Q: 2}, 1000, 0, 0.05},
+		{BiquadParams{Type: Peaking, Freq: 1000, Q: 1, Gain: 6}, 1000, 1.9, 2.1},
+		{BiquadParams{Type: LowShelf, Freq: 1000, Gain: -6}, 50, 0.45, 0.55},
+		{BiquadParams{Type: HighShelf, Freq: 1000, Gain: 6}, 15000, 1.9, 2.1},
+	}
+	for _, tst := range tests {
+		s := sine(c, tst.freq, 8192)
+		Process(s, c, tst.p)
+		if p := peak(s, 4096*c.Channels); p < tst.min || p > tst.max {
+			t.Errorf("%v at %v Hz: peak %v, want [%v, %v]", tst.p, tst.freq, p, tst.min, tst.max)
+		}
+	}
+}
+
+func TestBiquadReader(t *testing.T) {
+	// Filtering via a Reader in odd-sized chunks must be identical to
+	// processing the whole stream at once.
+	c := audio.Config{SampleRate: 48000, Channels: 3}
+	p := BiquadParams{Type: BandPass, Freq: 3000, Q: 4}
+	want := sine(c, 2500, 1000)
+	in := make(audio.F64Samples, len(want))
+	copy(in, want)
+	Process(want, c, p)
+
+	r := NewReader(audio.NewBuffer(in), c, NewBiquad(p))
+	got := audio.NewBuffer(make(audio.F64Samples, 0, len(want)))
+	buf := make(audio.F64Samples, 97)
+	for {
+		n, err := r.Read(buf)
+		got.Write(buf[:n])
+		if err == audio.EOS {
+			break
+		}
+	}
+	if got.Len() != len(want) {
+		t.Fatalf("read %d samples, want %d", got.Len(), len(want))
+	}
+	for i := range want {
+		if got.Samples().At(i) != want[i] {
+			t.Fatalf("sample %d: got %v, want %v", i, got.Samples().At(i), want[i])
+		}
+	}
+}
+
+func TestBiquadSmoothing(t *testing.T) {
+	// Abruptly changing parameters must not cause a discontinuity.
+	c := audio.Config{SampleRate: 44100, Channels: 1}
+	b := NewBiquad(BiquadParams{Type: LowPass, Freq: 200})
+	s := sine(c, 100, 4410)
+	b.Process(s[:2205], c)
+	b.SetParams(BiquadParams{Type: LowPass, Freq: 8000})
+	b.Process(s[2205:], c)
+	for i := 1; i < len(s); i++ {
+		if d := math.Abs(float64(s[i] - s[i-1])); d > 0.05 {
+			t.Fatalf("discontinuity of %v at sample %d", d, i)
+		}
+	}
+}
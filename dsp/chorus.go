@@ -0,0 +1,306 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"azul3d.org/audio.v1"
+)
+
+// modDelay is the state of a modulated delay effect: one delay line per
+// channel, read by one or more voices whose delays are swept by an LFO.
+type modDelay struct {
+	config audio.Config
+	lines  []*DelayLine
+	lfo    lfo
+}
+
+// modParams are the parameters of a modulated delay, with times in samples.
+type modParams struct {
+	voices       int
+	delay, depth float64 // delay sweeps from delay to delay+depth
+	rate, spread float64
+	feedback     float64
+	wet, dry     float64
+}
+
+func (m *modDelay) process(s audio.Slice, c audio.Config, p modParams) {
+	if c != m.config || m.lines == nil {
+		m.config = c
+		m.lines = make([]*DelayLine, c.Channels)
+		for ch := range m.lines {
+			m.lines[ch] = NewDelayLine(1)
+		}
+	}
+	if max := int(math.Ceil(p.delay+p.depth)) + 1; max > m.lines[0].Len() {
+		for _, l := range m.lines {
+			l.Resize(max)
+		}
+	}
+	if p.voices < 1 {
+		p.voices = 1
+	}
+
+	frames := s.Len() / c.Channels
+	for f := 0; f < frames; f++ {
+		for ch, l := range m.lines {
+			i := f*c.Channels + ch
+			x := float64(s.At(i))
+			var w float64
+			for v := 0; v < p.voices; v++ {
+				// Voices are evenly spaced in phase, and each channel is
+				// offset by the spread.
+				off := float64(ch)*p.spread + float64(v)/float64(p.voices)
+				w += l.Read(p.delay + p.depth*(1+m.lfo.at(off))/2)
+			}
+			w /= float64(p.voices)
+			l.Write(x + p.feedback*w)
+			s.Set(i, audio.F64(p.dry*x+p.wet*w))
+		}
+		m.lfo.advance(p.rate, c.SampleRate)
+	}
+}
+
+// ChorusParams describes the parameters of a chorus.
+type ChorusParams struct {
+	// Voices is the number of delayed copies of the signal, e.g. 3.
+	Voices int
+
+	// Delay is the minimum delay of each voice (e.g. 15ms), and Depth the
+	// amount by which it is swept (e.g. 5ms).
+	Delay, Depth time.Duration
+
+	// Rate is the frequency at which the delays are swept in Hz, e.g. 0.8.
+	Rate float64
+
+	// Spread is the offset, in cycles, of each channel's sweep relative to
+	// the previous channel's, e.g. 0.25 to widen the stereo image.
+	Spread float64
+
+	// Wet and Dry are the linear gains of the voices and of the dry signal.
+	Wet, Dry float64
+}
+
+// Chorus is a chorus effect, which thickens the sound by mixing it with
+// slightly delayed and detuned copies of itself. It implements both the
+// audio.Reader and Processor interfaces. Choruses must be allocated via the
+// NewChorus function.
+//
+// It is safe to change the parameters from another goroutine while audio is
+// being processed.
+type Chorus struct {
+	*Reader
+
+	access sync.Mutex
+	params ChorusParams
+	mod    modDelay
+}
+
+// Config returns the audio configuration of the stream.
+func (e *Chorus) Config() audio.Config {
+	return e.mod.config
+}
+
+// Params returns the current parameters.
+func (e *Chorus) Params() ChorusParams {
+	e.access.Lock()
+	defer e.access.Unlock()
+	return e.params
+}
+
+// SetParams sets the parameters.
+func (e *Chorus) SetParams(p ChorusParams) {
+	e.access.Lock()
+	e.params = p
+	e.access.Unlock()
+}
+
+// Implements the Processor interface.
+func (e *Chorus) Process(s audio.Slice, c audio.Config) {
+	e.access.Lock()
+	defer e.access.Unlock()
+	p := e.params
+	e.mod.process(s, c, modParams{
+		voices: p.Voices,
+		delay:  samples(p.Delay, c.SampleRate),
+		depth:  samples(p.Depth, c.SampleRate),
+		rate:   p.Rate,
+		spread: p.Spread,
+		wet:    p.Wet,
+		dry:    p.Dry,
+	})
+}
+
+// NewChorus returns a new chorus with the given parameters, which reads from
+// r, whose samples are laid out according to the given audio configuration.
+//
+// If the chorus is only to be used as a Processor, r may be nil.
+func NewChorus(r audio.Reader, c audio.Config, p ChorusParams) *Chorus {
+	e := &Chorus{params: p}
+	e.mod.config = c
+	if r != nil {
+		e.Reader = NewReader(r, c, e)
+	}
+	return e
+}
+
+// FlangerParams describes the parameters of a flanger.
+type FlangerParams struct {
+	// Delay is the minimum delay (e.g. 1ms), and Depth the amount by which
+	// it is swept (e.g. 3ms).
+	Delay, Depth time.Duration
+
+	// Rate is the frequency at which the delay is swept in Hz, e.g. 0.25.
+	Rate float64
+
+	// Feedback is the gain of the delayed signal fed back into the delay,
+	// in the range of -1 to +1 (exclusive), which deepens the effect.
+	Feedback float64
+
+	// Spread is the offset, in cycles, of each channel's sweep relative to
+	// the previous channel's.
+	Spread float64
+
+	// Wet and Dry are the linear gains of the delayed and of the dry signal;
+	// equal gains give the deepest notches.
+	Wet, Dry float64
+}
+
+// Flanger is a flanging effect, which sweeps a comb filter through the sound
+// by mixing it with a copy of itself under a short, varying delay. It
+// implements both the audio.Reader and Processor interfaces. Flangers must be
+// allocated via the NewFlanger function.
+//
+// It is safe to change the parameters from another goroutine while audio is
+// being processed.
+type Flanger struct {
+	*Reader
+
+	access sync.Mutex
+	params FlangerParams
+	mod    modDelay
+}
+
+// Config returns the audio configuration of the stream.
+func (e *Flanger) Config() audio.Config {
+	return e.mod.config
+}
+
+// Params returns the current parameters.
+func (e *Flanger) Params() FlangerParams {
+	e.access.Lock()
+	defer e.access.Unlock()
+	return e.params
+}
+
+// SetParams sets the parameters.
+func (e *Flanger) SetParams(p FlangerParams) {
+	e.access.Lock()
+	e.params = p
+	e.access.Unlock()
+}
+
+// Implements the Processor interface.
+func (e *Flanger) Process(s audio.Slice, c audio.Config) {
+	e.access.Lock()
+	defer e.access.Unlock()
+	p := e.params
+	e.mod.process(s, c, modParams{
+		voices:   1,
+		delay:    samples(p.Delay, c.SampleRate),
+		depth:    samples(p.Depth, c.SampleRate),
+		rate:     p.Rate,
+		spread:   p.Spread,
+		feedback: p.Feedback,
+		wet:      p.Wet,
+		dry:      p.Dry,
+	})
+}
+
+// NewFlanger returns a new flanger with the given parameters, which reads from
+// r, whose samples are laid out according to the given audio configuration.
+//
+// If the flanger is only to be used as a Processor, r may be nil.
+func NewFlanger(r audio.Reader, c audio.Config, p FlangerParams) *Flanger {
+	e := &Flanger{params: p}
+	e.mod.config = c
+	if r != nil {
+		e.Reader = NewReader(r, c, e)
+	}
+	return e
+}
+
+// VibratoParams describes the parameters of a vibrato.
+type VibratoParams struct {
+	// Depth is the amount by which the delay is swept, e.g. 2ms; the pitch
+	// deviation is proportional to both the depth and the rate.
+	Depth time.Duration
+
+	// Rate is the frequency of the vibrato in Hz, e.g. 5.
+	Rate float64
+}
+
+// Vibrato is a vibrato effect, which periodically modulates the pitch of the
+// sound by sweeping a delay. It implements both the audio.Reader and Processor
+// interfaces. Vibratos must be allocated via the NewVibrato function.
+//
+// It is safe to change the parameters from another goroutine while audio is
+// being processed.
+type Vibrato struct {
+	*Reader
+
+	access sync.Mutex
+	params VibratoParams
+	mod    modDelay
+}
+
+// Config returns the audio configuration of the stream.
+func (e *Vibrato) Config() audio.Config {
+	return e.mod.config
+}
+
+// Params returns the current parameters.
+func (e *Vibrato) Params() VibratoParams {
+	e.access.Lock()
+	defer e.access.Unlock()
+	return e.params
+}
+
+// SetParams sets the parameters.
+func (e *Vibrato) SetParams(p VibratoParams) {
+	e.access.Lock()
+	e.params = p
+	e.access.Unlock()
+}
+
+// Implements the Processor interface.
+func (e *Vibrato) Process(s audio.Slice, c audio.Config) {
+	e.access.Lock()
+	defer e.access.Unlock()
+	p := e.params
+	e.mod.process(s, c, modParams{
+		voices: 1,
+		delay:  1, // the minimum delay of a DelayLine
+		depth:  samples(p.Depth, c.SampleRate),
+		rate:   p.Rate,
+		wet:    1,
+	})
+}
+
+// NewVibrato returns a new vibrato with the given parameters, which reads from
+// r, whose samples are laid out according to the given audio configuration.
+//
+// If the vibrato is only to be used as a Processor, r may be nil.
+func NewVibrato(r audio.Reader, c audio.Config, p VibratoParams) *Vibrato {
+	e := &Vibrato{params: p}
+	e.mod.config = c
+	if r != nil {
+		e.Reader = NewReader(r, c, e)
+	}
+	return e
+}
@@ -0,0 +1,110 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"azul3d.org/audio.v1"
+)
+
+func TestChorusDelay(t *testing.T) {
+	// Without modulation every voice is a plain delay.
+	c := audio.Config{SampleRate: 48000, Channels: 2}
+	in := sine(c, 440, 4800)
+	s := make(audio.F64Samples, len(in))
+	copy(s, in)
+	e := NewChorus(nil, c, ChorusParams{Voices: 3, Delay: 10 * time.Millisecond, Rate: 1, Wet: 1})
+	e.Process(s, c)
+	for i := range s {
+		var want audio.F64
+		if i >= 480*2 {
+			want = in[i-480*2]
+		}
+		if math.Abs(float64(s[i]-want)) > 1e-12 {
+			t.Fatalf("sample %d = %v, want %v", i, s[i], want)
+		}
+	}
+}
+
+func TestChorusSpread(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 2}
+	p := ChorusParams{Voices: 2, Delay: 15 * time.Millisecond, Depth: 5 * time.Millisecond, Rate: 0.8, Wet: 0.7, Dry: 0.7}
+	differ := func(spread float64) bool {
+		p.Spread = spread
+		s := sine(c, 440, 48000)
+		r := NewChorus(audio.NewBuffer(s), c, p)
+		if r.Params() != p {
+			t.Fatalf("Params() = %+v, want %+v", r.Params(), p)
+		}
+		out := make(audio.F64Samples, len(s))
+		if _, err := r.Read(out); err != nil {
+			t.Fatal(err)
+		}
+		for f := 0; f < len(out)/2; f++ {
+			if out[f*2] != out[f*2+1] {
+				return true
+			}
+		}
+		return false
+	}
+	if differ(0) {
+		t.Error("channels differ without spread")
+	}
+	if !differ(0.25) {
+		t.Error("channels identical with spread")
+	}
+}
+
+func TestFlanger(t *testing.T) {
+	// With a fixed 1ms delay the flanger is a comb filter with notches at
+	// 500Hz, 1500Hz, etc. and peaks at 1kHz, 2kHz, etc.
+	c := audio.Config{SampleRate: 48000, Channels: 1}
+	for _, tst := range []struct {
+		feedback, freq, want float64
+	}{
+		{0, 500, 0},
+		{0, 1000, 1},
+		{0.5, 500, 0.5 - 0.5/1.5},
+		{0.5, 1000, 1.5},
+	} {
+		s := sine(c, tst.freq, 24000)
+		e := NewFlanger(nil, c, FlangerParams{Delay: time.Millisecond, Rate: 0.25, Feedback: tst.feedback, Wet: 0.5, Dry: 0.5})
+		e.Process(s, c)
+		if got := peak(s, 12000); math.Abs(got-tst.want) > 0.01 {
+			t.Errorf("feedback %v, %vHz: peak %v, want %v", tst.feedback, tst.freq, got, tst.want)
+		}
+	}
+}
+
+func TestVibrato(t *testing.T) {
+	// A delay swept by depth D at rate R shifts the frequency by up to
+	// pi*D*R.
+	c := audio.Config{SampleRate: 48000, Channels: 1}
+	s := sine(c, 1000, 48000)
+	e := NewVibrato(nil, c, VibratoParams{Depth: 2 * time.Millisecond, Rate: 5})
+	e.Process(s, c)
+
+	// Measure the frequency of each cycle from its rising zero crossings.
+	lo, hi := math.Inf(1), math.Inf(-1)
+	last := -1.0
+	for i := 1000; i < len(s); i++ {
+		a, b := float64(s[i-1]), float64(s[i])
+		if a < 0 && b >= 0 {
+			x := float64(i-1) + a/(a-b)
+			if last >= 0 {
+				freq := float64(c.SampleRate) / (x - last)
+				lo, hi = math.Min(lo, freq), math.Max(hi, freq)
+			}
+			last = x
+		}
+	}
+	dev := 1000 * math.Pi * 0.002 * 5
+	if math.Abs(lo-(1000-dev)) > 2 || math.Abs(hi-(1000+dev)) > 2 {
+		t.Fatalf("frequency range %.1f to %.1fHz, want %.1f to %.1fHz", lo, hi, 1000-dev, 1000+dev)
+	}
+}
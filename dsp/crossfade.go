@@ -0,0 +1,163 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import "azul3d.org/audio.v1"
+
+// Crossfade is an audio reader which transitions from one stream to another.
+// Crossfades must be allocated via the NewCrossfade function.
+//
+// Before the crossfade begins only the first stream is read. During the
+// crossfade both streams are read and mixed, with the first stream fading out
+// and the second fading in. After the crossfade only the second stream is read
+// and the crossfade ends when it does. If either stream ends early it is
+// treated as silence, such that the timing of the crossfade is unchanged.
+type Crossfade struct {
+	a, b          *audio.FrameReader
+	config        audio.Config
+	start, length int64
+	curve         Curve
+
+	pos        int64 // position in frames
+	aEOS       bool  // whether a has ended
+	bufA, bufB audio.F64Samples
+}
+
+// Config returns the audio configuration of the stream.
+func (x *Crossfade) Config() audio.Config {
+	return x.config
+}
+
+// readFull fills buf with samples read from r, padding it with silence if r
+// ends, and returns whether r has ended.
+func readFull(r *audio.FrameReader, buf audio.F64Samples) (eos bool, err error) {
+	var got int
+	for got < len(buf) {
+		var n int
+		n, err = r.Read(buf[got:])
+		got += n
+		if err == audio.EOS {
+			eos, err = true, nil
+			break
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	for i := got; i < len(buf); i++ {
+		buf[i] = 0
+	}
+	return eos, nil
+}
+
+// Read implements the audio.Reader interface.
+func (x *Crossfade) Read(b audio.Slice) (n int, err error) {
+	c := x.config.Channels
+	frames := int64(b.Len() / c)
+	if frames == 0 {
+		return 0, nil
+	}
+	end := x.start + x.length
+	switch {
+	case x.pos >= end:
+		// After the crossfade.
+		n, err = x.b.Read(b.Slice(0, int(frames)*c))
+		x.pos += int64(n / c)
+		return n, err
+
+	case x.pos < x.start:
+		// Before the crossfade.
+		if rem := x.start - x.pos; frames > rem {
+			frames = rem
+		}
+		n = int(frames) * c
+		if x.aEOS {
+			for i := 0; i < n; i++ {
+				b.Set(i, 0)
+			}
+			x.pos += frames
+			return n, nil
+		}
+		n, err = x.a.Read(b.Slice(0, n))
+		x.pos += int64(n / c)
+		if err == audio.EOS {
+			x.aEOS, err = true, nil
+		}
+		return n, err
+	}
+
+	// During the crossfade.
+	if rem := end - x.pos; frames > rem {
+		frames = rem
+	}
+	n = int(frames) * c
+	if cap(x.bufA) < n {
+		x.bufA = make(audio.F64Samples, n)
+		x.bufB = make(audio.F64Samples, n)
+	}
+	bufA, bufB := x.bufA[:n], x.bufB[:n]
+	if x.aEOS {
+		for i := range bufA {
+			bufA[i] = 0
+		}
+	} else if x.aEOS, err = readFull(x.a, bufA); err != nil {
+		return 0, err
+	}
+	if _, err = readFull(x.b, bufB); err != nil {
+		return 0, err
+	}
+	for f := 0; f < int(frames); f++ {
+		t := float64(x.pos-x.start) / float64(x.length)
+		ga, gb := audio.F64(x.curve.At(1-t)), audio.F64(x.curve.At(t))
+		for ch := 0; ch < c; ch++ {
+			i := f*c + ch
+			b.Set(i, bufA[i]*ga+bufB[i]*gb)
+		}
+		x.pos++
+	}
+	return n, nil
+}
+
+// current returns the stream which is dominant at the current position: the
+// first stream until the middle of the crossfade, the second thereafter.
+func (x *Crossfade) current() *audio.FrameReader {
+	if x.pos < x.start+x.length/2 {
+		return x.a
+	}
+	return x.b
+}
+
+// SourcePosition implements the audio.Positioner interface. It reports the
+// position in whichever stream is currently dominant (see TotalLatency).
+func (x *Crossfade) SourcePosition() uint64 {
+	return x.current().SourcePosition()
+}
+
+// TotalLatency implements the audio.Positioner interface. It reports the
+// latency of the first stream until the middle of the crossfade, and of the
+// second stream thereafter.
+func (x *Crossfade) TotalLatency() int {
+	return x.current().TotalLatency()
+}
+
+// NewCrossfade returns a new crossfade from the stream a to the stream b,
+// both of whose samples are laid out according to the given audio
+// configuration. The crossfade, of the given shape, begins at the start frame
+// (relative to the start of a) and lasts length frames.
+//
+// It panics if start or length is negative.
+func NewCrossfade(a, b audio.Reader, c audio.Config, start, length int64, curve Curve) *Crossfade {
+	if start < 0 || length < 0 {
+		panic("dsp: invalid crossfade position")
+	}
+	return &Crossfade{
+		a:      audio.NewFrameReader(a, c),
+		b:      audio.NewFrameReader(b, c),
+		config: c,
+		start:  start,
+		length: length,
+		curve:  curve,
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"testing"
+
+	"azul3d.org/audio.v1"
+)
+
+// readAll reads the entire stream r in chunks of the given size.
+func readAll(t *testing.T, r audio.Reader, chunk int) audio.F64Samples {
+	var all audio.F64Samples
+	buf := make(audio.F64Samples, chunk)
+	for {
+		n, err := r.Read(buf)
+		all = append(all, buf[:n]...)
+		if err == audio.EOS {
+			return all
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCrossfade(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 2}
+	a := audio.NewBuffer(constant(c, 1, 300))
+	b := audio.NewBuffer(constant(c, -1, 400))
+	x := NewCrossfade(a, b, c, 200, 100, EqualPower)
+
+	got := readAll(t, x, 58)
+	// 200 frames of a, 100 frames of crossfade, 300 frames of b.
+	if len(got) != 600*2 {
+		t.Fatalf("read %d samples, want %d", len(got), 600*2)
+	}
+	for f := 0; f < 600; f++ {
+		var want float64
+		switch {
+		case f < 200:
+			want = 1
+		case f < 300:
+			p := float64(f-200) / 100
+			want = EqualPower.At(1-p) - EqualPower.At(p)
+		default:
+			want = -1
+		}
+		for ch := 0; ch < 2; ch++ {
+			if v := float64(got[f*2+ch]); math.Abs(v-want) > 1e-12 {
+				t.Fatalf("frame %d channel %d = %v, want %v", f, ch, v, want)
+			}
+		}
+	}
+}
+
+func TestCrossfadeEarlyEOS(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 1}
+	a := audio.NewBuffer(constant(c, 1, 50))
+	b := audio.NewBuffer(constant(c, 1, 150))
+	got := readAll(t, NewCrossfade(a, b, c, 100, 100, Linear), 64)
+
+	// a ends at 50 frames; silence until the crossfade at 100 frames, where
+	// b fades in until it ends at 250 frames.
+	if len(got) != 250 {
+		t.Fatalf("read %d samples, want 250", len(got))
+	}
+	for f, v := range got {
+		var want float64
+		switch {
+		case f < 50:
+			want = 1
+		case f < 100:
+			want = 0
+		case f < 200:
+			want = float64(f-100) / 100
+		default:
+			want = 1
+		}
+		if math.Abs(float64(v)-want) > 1e-12 {
+			t.Fatalf("frame %d = %v, want %v", f, v, want)
+		}
+	}
+}
+
+func TestCrossfadeSourcePosition(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 2}
+	a := audio.NewBuffer(constant(c, 1, 300))
+	b := audio.NewBuffer(constant(c, -1, 400))
+	x := NewCrossfade(a, b, c, 200, 100, EqualPower)
+	buf := make(audio.F64Samples, 2)
+	tests := []struct {
+		frames int
+		want   uint64
+	}{
+		{100, 100 * 2}, // in a
+		{140, 240 * 2}, // in a, during the crossfade
+		{20, 60 * 2},   // in b, past the middle of the crossfade
+		{100, 160 * 2}, // in b
+	}
+	for _, tst := range tests {
+		for f := 0; f < tst.frames; f++ {
+			x.Read(buf)
+		}
+		if p := x.SourcePosition(); p != tst.want {
+			t.Fatalf("SourcePosition got %d, want %d", p, tst.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"time"
+)
+
+// DelayLine is a single channel delay line with fractional (interpolated)
+// reads, the building block of delay-based effects. DelayLines must be
+// allocated via the NewDelayLine function.
+//
+// A DelayLine is not safe for concurrent use by multiple goroutines.
+type DelayLine struct {
+	buf []float64
+	pos int // index of the next write
+	max int
+}
+
+// Len returns the maximum delay in samples.
+func (d *DelayLine) Len() int {
+	return d.max
+}
+
+// Write writes a single sample into the delay line.
+func (d *DelayLine) Write(v float64) {
+	d.buf[d.pos] = v
+	if d.pos++; d.pos == len(d.buf) {
+		d.pos = 0
+	}
+}
+
+// at returns the sample written k samples ago, where at(1) is the most
+// recently written sample.
+func (d *DelayLine) at(k int) float64 {
+	i := d.pos - k
+	if i < 0 {
+		i += len(d.buf)
+	}
+	return d.buf[i]
+}
+
+// Read returns the sample written the given (fractional) number of samples
+// ago, such that Read(1) returns the most recently written sample. Fractional
+// delays are interpolated using a cubic Hermite spline. The delay is clamped
+// to the range of 1 to Len().
+func (d *DelayLine) Read(delay float64) float64 {
+	if !(delay >= 1) {
+		delay = 1
+	} else if delay > float64(d.max) {
+		delay = float64(d.max)
+	}
+	k := int(delay)
+	t := delay - float64(k)
+	if t == 0 {
+		return d.at(k)
+	}
+	// The points either side of the read position; the newest sample is
+	// repeated in place of the (unwritten) one before it.
+	y0 := d.at(k - 1)
+	if k == 1 {
+		y0 = d.at(1)
+	}
+	y1, y2, y3 := d.at(k), d.at(k+1), d.at(k+2)
+	c1 := 0.5 * (y2 - y0)
+	c2 := y0 - 2.5*y1 + 2*y2 - 0.5*y3
+	c3 := 0.5*(y3-y0) + 1.5*(y1-y2)
+	return ((c3*t+c2)*t+c1)*t + y1
+}
+
+// Resize changes the maximum delay in samples, keeping the most recently
+// written samples.
+func (d *DelayLine) Resize(max int) {
+	if max < 1 {
+		max = 1
+	}
+	buf := make([]float64, max+3)
+	for k := 1; k <= len(buf) && k <= len(d.buf); k++ {
+		buf[len(buf)-k] = d.at(k)
+	}
+	d.buf, d.pos, d.max = buf, 0, max
+}
+
+// Reset clears the delay line to silence.
+func (d *DelayLine) Reset() {
+	for i := range d.buf {
+		d.buf[i] = 0
+	}
+}
+
+// NewDelayLine returns a new delay line, initially silent, with the given
+// maximum delay in samples.
+func NewDelayLine(max int) *DelayLine {
+	d := &DelayLine{}
+	d.Resize(max)
+	return d
+}
+
+// samples returns the given duration in (fractional) samples at the sample
+// rate.
+func samples(d time.Duration, sampleRate int) float64 {
+	return d.Seconds() * float64(sampleRate)
+}
+
+// lfo is a sine wave low frequency oscillator, for modulating effects.
+type lfo struct {
+	phase float64 // in cycles
+}
+
+// at returns the value of the oscillator, in the range of -1 to +1, with the
+// given phase offset in cycles.
+func (o *lfo) at(offset float64) float64 {
+	return math.Sin(2 * math.Pi * (o.phase + offset))
+}
+
+// advance advances the oscillator by one sample at the given rate in Hz.
+func (o *lfo) advance(rate float64, sampleRate int) {
+	o.phase += rate / float64(sampleRate)
+	o.phase -= math.Floor(o.phase)
+}
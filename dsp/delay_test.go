@@ -0,0 +1,58 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDelayLine(t *testing.T) {
+	d := NewDelayLine(100)
+	if d.Len() != 100 {
+		t.Fatalf("Len() = %d, want 100", d.Len())
+	}
+	for i := 0; i < 250; i++ {
+		d.Write(float64(i))
+	}
+	for _, k := range []int{1, 2, 37, 100} {
+		if got, want := d.Read(float64(k)), float64(250-k); got != want {
+			t.Errorf("Read(%d) = %v, want %v", k, got, want)
+		}
+	}
+
+	// Delays are clamped to the valid range.
+	if got := d.Read(0.2); got != 249 {
+		t.Errorf("Read(0.2) = %v, want 249", got)
+	}
+	if got := d.Read(1000); got != 150 {
+		t.Errorf("Read(1000) = %v, want 150", got)
+	}
+
+	// Resizing keeps the most recent samples.
+	d.Resize(200)
+	d.Write(250)
+	for _, k := range []int{1, 50, 101} {
+		if got, want := d.Read(float64(k)), float64(251-k); got != want {
+			t.Errorf("after Resize, Read(%d) = %v, want %v", k, got, want)
+		}
+	}
+}
+
+func TestDelayLineInterpolation(t *testing.T) {
+	// Interpolated reads of a sine wave lie on the sine wave (delays of
+	// less than two are less accurate, as the newest sample is repeated).
+	const w = 2 * math.Pi * 1000 / 48000
+	d := NewDelayLine(64)
+	for i := 0; i < 128; i++ {
+		d.Write(math.Sin(w * float64(i)))
+	}
+	for delay := 2.0; delay < 60; delay += 0.37 {
+		want := math.Sin(w * (128 - delay))
+		if got := d.Read(delay); math.Abs(got-want) > 1e-4 {
+			t.Fatalf("Read(%v) = %v, want %v", delay, got, want)
+		}
+	}
+}
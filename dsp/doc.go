@@ -0,0 +1,11 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package dsp implements digital signal processing of audio streams, like
+// filtering.
+//
+// Processors operate in-place on interleaved audio slices of any type, and
+// can be applied to an audio stream by wrapping its audio.Reader via the
+// NewReader function.
+package dsp
@@ -0,0 +1,90 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import "azul3d.org/audio.v1"
+
+// Processor is a generic interface which describes any type who can process
+// interleaved audio samples in-place.
+type Processor interface {
+	// Process processes the interleaved audio samples of s in-place, where s
+	// is laid out according to the given audio configuration and begins at
+	// the start of a frame.
+	//
+	// Processors keep state between calls (e.g. filter history), such that
+	// calling Process on consecutive slices of a stream is equivalent to
+	// calling it once on the whole stream. Any trailing partial frame in s
+	// is left untouched.
+	Process(s audio.Slice, c audio.Config)
+}
+
+// Reader is an audio reader which applies a processor to all of the audio
+// samples that are read through it. Readers must be allocated via the
+// NewReader function.
+type Reader struct {
+	r      *audio.FrameReader
+	config audio.Config
+	p      Processor
+}
+
+// Config returns the audio configuration of the stream.
+func (r *Reader) Config() audio.Config {
+	return r.config
+}
+
+// Read implements the audio.Reader interface. It reads only whole frames from
+// the underlying reader, and processes them in-place before returning.
+func (r *Reader) Read(b audio.Slice) (n int, err error) {
+	n, err = r.r.Read(b)
+	if n > 0 {
+		r.p.Process(b.Slice(0, n), r.config)
+	}
+	return
+}
+
+// SourcePosition implements the audio.Positioner interface. If the processor
+// has a Latency method returning its latency in sample frames (e.g. the
+// lookahead of Dynamics) then it is compensated for.
+//
+// If the reader is nil (e.g. the embedded reader of a processor which is only
+// used as a Processor) it returns zero.
+func (r *Reader) SourcePosition() uint64 {
+	if r == nil {
+		return 0
+	}
+	return r.r.SourcePositionAt(-r.latency())
+}
+
+// TotalLatency implements the audio.Positioner interface. It is the latency
+// of the underlying reader plus that of the processor. If the reader is nil it
+// returns zero.
+func (r *Reader) TotalLatency() int {
+	if r == nil {
+		return 0
+	}
+	return r.r.TotalLatency() + r.latency()*r.config.Channels
+}
+
+// latency returns the latency of the processor in sample frames, if it reports
+// one.
+func (r *Reader) latency() int {
+	if l, ok := r.p.(interface {
+		Latency() int
+	}); ok {
+		return l.Latency()
+	}
+	return 0
+}
+
+// NewReader returns a new reader which reads from r, whose samples are laid
+// out according to the given audio configuration, and applies the processor
+// p to all of the samples before returning them.
+func NewReader(r audio.Reader, c audio.Config, p Processor) *Reader {
+	return &Reader{
+		r:      audio.NewFrameReader(r, c),
+		config: c,
+		p:      p,
+	}
+}
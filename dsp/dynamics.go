@@ -0,0 +1,400 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"azul3d.org/audio.v1"
+)
+
+// DynamicsMode represents a single type of dynamics processor.
+type DynamicsMode uint8
+
+const (
+	// Compress reduces the level of signals above the threshold by the
+	// ratio.
+	Compress DynamicsMode = iota
+
+	// Limit prevents signals from exceeding the threshold. Combined with a
+	// lookahead it acts as a brickwall limiter.
+	Limit
+
+	// Expand reduces the level of signals below the threshold by the ratio
+	// (i.e. downward expansion).
+	Expand
+
+	// Gate mutes signals below the threshold.
+	Gate
+)
+
+// String returns a string representation of the dynamics mode.
+func (m DynamicsMode) String() string {
+	switch m {
+	case Compress:
+		return "Compress"
+	case Limit:
+		return "Limit"
+	case Expand:
+		return "Expand"
+	case Gate:
+		return "Gate"
+	}
+	return fmt.Sprintf("DynamicsMode(%d)", uint8(m))
+}
+
+// DynamicsParams describes the parameters of a dynamics processor.
+type DynamicsParams struct {
+	// Mode is the type of dynamics processing.
+	Mode DynamicsMode
+
+	// Threshold is the level, in decibels relative to full scale, above
+	// which (or below which, for Expand and Gate) the gain is altered.
+	Threshold float64
+
+	// Ratio is the ratio of input to output level change beyond the
+	// threshold, e.g. 4 for 4:1 compression, or 2 for 1:2 expansion. It is
+	// ignored by the Limit and Gate modes, which use an infinite ratio.
+	Ratio float64
+
+	// Knee is the width in decibels of the soft knee around the threshold,
+	// or zero for a hard knee.
+	Knee float64
+
+	// Attack and Release are the time constants of the gain smoothing. For
+	// Compress and Limit the attack applies to increasing gain reduction, for
+	// Expand and Gate it applies to the gain opening back up.
+	Attack, Release time.Duration
+
+	// MakeupGain is a gain in decibels applied after the gain reduction.
+	MakeupGain float64
+
+	// Range is the maximum gain reduction in decibels applied by the Expand
+	// and Gate modes (e.g. 80), or zero for no limit.
+	Range float64
+
+	// Lookahead delays the audio such that the gain reduction can react to
+	// a signal before it happens. The processor's latency is equal to the
+	// lookahead.
+	Lookahead time.Duration
+
+	// Linked causes a single gain to be computed from the loudest channel of
+	// each frame and applied to all channels (preserving the stereo image),
+	// rather than processing each channel independently.
+	Linked bool
+}
+
+// gain returns the static gain in decibels (zero or negative) for a detected
+// level in decibels.
+func (p DynamicsParams) gain(x float64) float64 {
+	t, w := p.Threshold, p.Knee
+	switch p.Mode {
+	case Compress, Limit:
+		slope := -1.0 // 1/ratio - 1 for an infinite ratio
+		if p.Mode == Compress && p.Ratio > 1 {
+			slope = 1/p.Ratio - 1
+		} else if p.Mode == Compress {
+			return 0
+		}
+		d := x - t
+		switch {
+		case 2*d < -w:
+			return 0
+		case w > 0 && 2*d <= w:
+			// Within the soft knee; a hard knee has none.
+			k := d + w/2
+			return slope * k * k / (2 * w)
+		}
+		return slope * d
+	case Expand, Gate:
+		slope := 1000.0 // ratio - 1 for a (practically) infinite ratio
+		if p.Mode == Expand && p.Ratio > 1 {
+			slope = p.Ratio - 1
+		} else if p.Mode == Expand {
+			return 0
+		}
+		var g float64
+		d := x - t
+		switch {
+		case 2*d > w:
+			g = 0
+		case w > 0 && 2*d >= -w:
+			k := d - w/2
+			g = -slope * k * k / (2 * w)
+		default:
+			g = slope * d
+		}
+		if p.Range > 0 && g < -p.Range {
+			g = -p.Range
+		}
+		return g
+	}
+	panic("dsp: invalid dynamics mode")
+}
+
+// timeCoeff returns the one-pole smoothing coefficient for the given time
+// constant at the given sample rate.
+func timeCoeff(d time.Duration, sampleRate int) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return math.Exp(-1 / (d.Seconds() * float64(sampleRate)))
+}
+
+// minWindow is a sliding window minimum, implemented as a monotonic deque.
+type minWindow struct {
+	vals []float64
+	pos  []int
+	head int
+	size int // window length
+	n    int // number of values pushed
+}
+
+func (m *minWindow) push(v float64) float64 {
+	for len(m.vals) > m.head && m.vals[len(m.vals)-1] >= v {
+		m.vals = m.vals[:len(m.vals)-1]
+		m.pos = m.pos[:len(m.pos)-1]
+	}
+	m.vals = append(m.vals, v)
+	m.pos = append(m.pos, m.n)
+	m.n++
+	for m.pos[m.head] <= m.n-1-m.size {
+		m.head++
+	}
+	if m.head > 1024 {
+		// Reclaim space at the start of the deque.
+		m.vals = append(m.vals[:0], m.vals[m.head:]...)
+		m.pos = append(m.pos[:0], m.pos[m.head:]...)
+		m.head = 0
+	}
+	return m.vals[m.head]
+}
+
+// detector is the state of a single level detector.
+type detector struct {
+	env  float64 // smoothed gain in decibels
+	look minWindow
+}
+
+// Dynamics is a dynamics processor (compressor, limiter, expander or noise
+// gate). It implements both the audio.Reader and Processor interfaces.
+// Dynamics processors must be allocated via the NewDynamics function.
+//
+// It is safe to change the parameters, and to read the gain reduction meter,
+// from another goroutine while audio is being processed.
+type Dynamics struct {
+	*Reader
+	reduction uint64 // float64 bits, accessed atomically
+
+	access    sync.Mutex
+	params    DynamicsParams
+	sidechain *audio.FrameReader
+	dirty     bool
+
+	config    audio.Config
+	detectors []detector
+	delay     [][]float64 // per channel lookahead delay line
+	delayPos  int
+	key       audio.F64Samples // sidechain input
+}
+
+// Config returns the audio configuration of the stream.
+func (d *Dynamics) Config() audio.Config {
+	return d.config
+}
+
+// Params returns the current parameters.
+func (d *Dynamics) Params() DynamicsParams {
+	d.access.Lock()
+	defer d.access.Unlock()
+	return d.params
+}
+
+// SetParams sets the parameters. Changing the lookahead or the linking of
+// channels resets the processor's state.
+func (d *Dynamics) SetParams(p DynamicsParams) {
+	d.access.Lock()
+	if p.Lookahead != d.params.Lookahead || p.Linked != d.params.Linked {
+		d.dirty = true
+	}
+	d.params = p
+	d.access.Unlock()
+}
+
+// SetSidechain sets an external sidechain input whose level controls the gain
+// reduction instead of the processed audio itself, e.g. for ducking music
+// under dialogue. The sidechain must have the same audio configuration as the
+// processed stream; once it reaches EOS it is treated as silence. A nil
+// sidechain restores internal detection.
+func (d *Dynamics) SetSidechain(r audio.Reader) {
+	d.access.Lock()
+	if r == nil {
+		d.sidechain = nil
+	} else {
+		d.sidechain = audio.NewFrameReader(r, d.config)
+	}
+	d.access.Unlock()
+}
+
+// GainReduction returns the highest gain reduction, in (positive) decibels,
+// applied during the most recent call to Process; for display by a meter.
+func (d *Dynamics) GainReduction() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&d.reduction))
+}
+
+// Latency returns the latency introduced by the lookahead, in sample frames.
+func (d *Dynamics) Latency() int {
+	d.access.Lock()
+	defer d.access.Unlock()
+	return d.lookaheadFrames()
+}
+
+func (d *Dynamics) lookaheadFrames() int {
+	return int(d.params.Lookahead.Seconds() * float64(d.config.SampleRate))
+}
+
+// reset allocates the processing state for the current configuration.
+func (d *Dynamics) reset() {
+	d.dirty = false
+	n := d.config.Channels
+	if d.params.Linked {
+		n = 1
+	}
+	look := d.lookaheadFrames()
+	d.detectors = make([]detector, n)
+	for i := range d.detectors {
+		d.detectors[i].look.size = look + 1
+	}
+	d.delay = make([][]float64, d.config.Channels)
+	for ch := range d.delay {
+		d.delay[ch] = make([]float64, look)
+	}
+	d.delayPos = 0
+}
+
+// readKey fills the sidechain input buffer with n samples.
+func (d *Dynamics) readKey(n int) audio.F64Samples {
+	if cap(d.key) < n {
+		d.key = make(audio.F64Samples, n)
+	}
+	key := d.key[:n]
+	var got int
+	for got < n {
+		m, err := d.sidechain.Read(key[got:])
+		got += m
+		if err != nil {
+			break
+		}
+	}
+	for i := got; i < n; i++ {
+		key[i] = 0
+	}
+	return key
+}
+
+// Implements the Processor interface.
+func (d *Dynamics) Process(s audio.Slice, c audio.Config) {
+	d.access.Lock()
+	defer d.access.Unlock()
+	if c != d.config || d.detectors == nil || d.dirty {
+		d.config = c
+		d.reset()
+	}
+	p := d.params
+	attack := timeCoeff(p.Attack, c.SampleRate)
+	release := timeCoeff(p.Release, c.SampleRate)
+	makeup := p.MakeupGain
+	ceiling := math.Pow(10, p.Threshold/20)
+	look := len(d.delay[0])
+
+	frames := s.Len() / c.Channels
+	var key audio.Slice = s
+	if d.sidechain != nil {
+		key = d.readKey(frames * c.Channels)
+	}
+
+	var maxReduction float64
+	for f := 0; f < frames; f++ {
+		base := f * c.Channels
+		for i := range d.detectors {
+			det := &d.detectors[i]
+
+			// Detect the peak level of this detector's channel(s).
+			var level float64
+			if p.Linked {
+				for ch := 0; ch < c.Channels; ch++ {
+					level = math.Max(level, math.Abs(float64(key.At(base+ch))))
+				}
+			} else {
+				level = math.Abs(float64(key.At(base + i)))
+			}
+			db := -200.0
+			if level > 1e-10 {
+				db = 20 * math.Log10(level)
+			}
+
+			target := p.gain(db)
+			if look > 0 {
+				target = det.look.push(target)
+			}
+
+			// Smooth the gain; decreasing gain is an attack for compressors
+			// and limiters, while increasing gain is an attack for expanders
+			// and gates.
+			coeff := release
+			if (target < det.env) == (p.Mode == Compress || p.Mode == Limit) {
+				coeff = attack
+			}
+			det.env = target + coeff*(det.env-target)
+			if -det.env > maxReduction {
+				maxReduction = -det.env
+			}
+		}
+
+		for ch := 0; ch < c.Channels; ch++ {
+			det := &d.detectors[0]
+			if !p.Linked {
+				det = &d.detectors[ch]
+			}
+			x := float64(s.At(base + ch))
+			if look > 0 {
+				dl := d.delay[ch]
+				x, dl[d.delayPos] = dl[d.delayPos], x
+			}
+			y := x * math.Pow(10, (det.env+makeup)/20)
+			if p.Mode == Limit && look > 0 {
+				// The smoothed gain may not have fully settled; guarantee
+				// the ceiling is never exceeded.
+				limit := ceiling * math.Pow(10, makeup/20)
+				y = math.Max(-limit, math.Min(limit, y))
+			}
+			s.Set(base+ch, audio.F64(y))
+		}
+		if look > 0 {
+			d.delayPos = (d.delayPos + 1) % look
+		}
+	}
+	atomic.StoreUint64(&d.reduction, math.Float64bits(maxReduction))
+}
+
+// NewDynamics returns a new dynamics processor with the given parameters,
+// which reads from r, whose samples are laid out according to the given audio
+// configuration.
+//
+// If the processor is only to be used as a Processor, r may be nil.
+func NewDynamics(r audio.Reader, c audio.Config, p DynamicsParams) *Dynamics {
+	d := &Dynamics{
+		config: c,
+		params: p,
+	}
+	if r != nil {
+		d.Reader = NewReader(r, c, d)
+	}
+	return d
+}
@@ -0,0 +1,151 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"azul3d.org/audio.v1"
+)
+
+// constant returns a stream of a constant (DC) level.
+func constant(c audio.Config, level float64, frames int) audio.F64Samples {
+	s := make(audio.F64Samples, frames*c.Channels)
+	for i := range s {
+		s[i] = audio.F64(level)
+	}
+	return s
+}
+
+func db(v float64) float64 {
+	return 20 * math.Log10(math.Abs(v))
+}
+
+func TestDynamicsStaticCurve(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 2}
+	tests := []struct {
+		p        DynamicsParams
+		inDB     float64
+		wantDB   float64
+		maxError float64
+	}{
+		// 4:1 above -20 dB: -8 dB in -> -20 + 12/4 = -17 dB out.
+		{DynamicsParams{Mode: Compress, Threshold: -20, Ratio: 4}, -8, -17, 0.01},
+		// Below the threshold nothing happens.
+		{DynamicsParams{Mode: Compress, Threshold: -20, Ratio: 4}, -30, -30, 0.01},
+		// Make-up gain.
+		{DynamicsParams{Mode: Compress, Threshold: -20, Ratio: 4, MakeupGain: 3}, -8, -14, 0.01},
+		// Limiter.
+		{DynamicsParams{Mode: Limit, Threshold: -6}, 0, -6, 0.01},
+		// 1:2 expansion below -40 dB: -50 dB in -> -60 dB out.
+		{DynamicsParams{Mode: Expand, Threshold: -40, Ratio: 2}, -50, -60, 0.01},
+		// Gate with a 40 dB range.
+		{DynamicsParams{Mode: Gate, Threshold: -40, Range: 40}, -50, -90, 0.01},
+	}
+	for _, tst := range tests {
+		s := constant(c, math.Pow(10, tst.inDB/20), 1000)
+		d := NewDynamics(nil, c, tst.p)
+		d.Process(s, c)
+		if got := db(float64(s[len(s)-1])); math.Abs(got-tst.wantDB) > tst.maxError {
+			t.Errorf("%+v: %v dB in, got %v dB out, want %v dB", tst.p, tst.inDB, got, tst.wantDB)
+		}
+	}
+}
+
+func TestDynamicsHardKneeAtThreshold(t *testing.T) {
+	// A full-scale level is exactly at a threshold of 0 dB.
+	c := audio.Config{SampleRate: 48000, Channels: 1}
+	for _, p := range []DynamicsParams{
+		{Mode: Compress, Threshold: 0, Ratio: 4},
+		{Mode: Limit, Threshold: 0},
+		{Mode: Expand, Threshold: 0, Ratio: 2},
+		{Mode: Gate, Threshold: 0},
+	} {
+		s := constant(c, 1, 1000)
+		NewDynamics(nil, c, p).Process(s, c)
+		for i, v := range s {
+			if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+				t.Fatalf("%v: sample %d is %v", p.Mode, i, v)
+			}
+		}
+	}
+}
+
+func TestDynamicsBrickwall(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 1}
+	in := make(audio.F64Samples, 4800)
+	for i := range in {
+		in[i] = audio.F64(0.1 * math.Sin(float64(i)*0.05))
+	}
+	// A sudden full-scale transient.
+	for i := 2400; i < 2500; i++ {
+		in[i] = 1
+	}
+	p := DynamicsParams{
+		Mode:      Limit,
+		Threshold: -6,
+		Attack:    time.Millisecond,
+		Release:   50 * time.Millisecond,
+		Lookahead: time.Millisecond,
+	}
+	d := NewDynamics(audio.NewBuffer(in), c, p)
+	if d.Latency() != 48 {
+		t.Fatalf("Latency got %d, want 48", d.Latency())
+	}
+	out := make(audio.F64Samples, len(in))
+	d.Read(out)
+	ceiling := math.Pow(10, -6.0/20)
+	for i, v := range out {
+		if math.Abs(float64(v)) > ceiling+1e-12 {
+			t.Fatalf("sample %d: %v exceeds the ceiling %v", i, v, ceiling)
+		}
+	}
+	if r := d.GainReduction(); r < 5 {
+		t.Fatalf("GainReduction got %v dB, want at least 5 dB", r)
+	}
+}
+
+func TestDynamicsSidechain(t *testing.T) {
+	// Ducking: loud dialogue on the sidechain reduces the music level.
+	c := audio.Config{SampleRate: 48000, Channels: 2}
+	music := constant(c, 0.5, 4800)
+	dialogue := constant(c, 0, 4800)
+	for i := 2400 * 2; i < len(dialogue); i++ {
+		dialogue[i] = 1
+	}
+	p := DynamicsParams{
+		Mode:      Compress,
+		Threshold: -30,
+		Ratio:     10,
+		Linked:    true,
+		Attack:    time.Millisecond,
+		Release:   100 * time.Millisecond,
+	}
+	d := NewDynamics(audio.NewBuffer(music), c, p)
+	d.SetSidechain(audio.NewBuffer(dialogue))
+	out := make(audio.F64Samples, len(music))
+	d.Read(out)
+	if v := out[2000*2]; v != 0.5 {
+		t.Fatalf("before dialogue got %v, want 0.5", v)
+	}
+	if v := out[len(out)-1]; db(float64(v)) > db(0.5)-20 {
+		t.Fatalf("during dialogue got %v (%v dB), want at least 20 dB of ducking", v, db(float64(v)))
+	}
+}
+
+func TestDynamicsSourcePosition(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 2}
+	p := DynamicsParams{Mode: Limit, Lookahead: time.Millisecond}
+	d := NewDynamics(audio.NewBuffer(constant(c, 0.5, 4800)), c, p)
+	d.Read(make(audio.F64Samples, 1000*2))
+	if pos := d.SourcePosition(); pos != (1000-48)*2 {
+		t.Fatalf("SourcePosition got %d, want %d", pos, (1000-48)*2)
+	}
+	if l := d.TotalLatency(); l != 48*2 {
+		t.Fatalf("TotalLatency got %d, want %d", l, 48*2)
+	}
+}
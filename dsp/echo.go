@@ -0,0 +1,156 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"azul3d.org/audio.v1"
+)
+
+// EchoParams describes the parameters of an echo.
+type EchoParams struct {
+	// Delay is the time between each echo.
+	Delay time.Duration
+
+	// Feedback is the gain of each echo relative to the last, which must be
+	// less than one in magnitude for the echoes to die away.
+	Feedback float64
+
+	// Damping, in the range of 0 to 1, is the amount of lowpass filtering of
+	// each echo, such that later echoes are successively duller.
+	Damping float64
+
+	// Wet and Dry are the linear gains of the echoes and of the dry signal.
+	Wet, Dry float64
+
+	// PingPong causes the echoes to bounce between channels: the channels
+	// are mixed into the first channel's echo, whose echo is then fed into
+	// the second channel's, and so on around all of the channels.
+	PingPong bool
+}
+
+// Echo is a delay effect which repeats the audio after a delay, optionally
+// bouncing the repeats between channels (ping-pong delay). It implements both
+// the audio.Reader and Processor interfaces. Echoes must be allocated via the
+// NewEcho function.
+//
+// It is safe to change the parameters from another goroutine while audio is
+// being processed; to avoid clicks changes of the delay are crossfaded over
+// the DefaultSmoothing duration.
+type Echo struct {
+	*Reader
+
+	access sync.Mutex
+	params EchoParams
+
+	config audio.Config
+	lines  []*DelayLine
+	store  []float64 // damping filter state
+	delay  float64   // current delay in samples
+	next   float64   // delay being crossfaded to
+	fade   int       // remaining crossfade length in frames
+}
+
+// Config returns the audio configuration of the stream.
+func (e *Echo) Config() audio.Config {
+	return e.config
+}
+
+// Params returns the current parameters.
+func (e *Echo) Params() EchoParams {
+	e.access.Lock()
+	defer e.access.Unlock()
+	return e.params
+}
+
+// SetParams sets the parameters.
+func (e *Echo) SetParams(p EchoParams) {
+	e.access.Lock()
+	e.params = p
+	e.access.Unlock()
+}
+
+// Implements the Processor interface.
+func (e *Echo) Process(s audio.Slice, c audio.Config) {
+	e.access.Lock()
+	defer e.access.Unlock()
+	p := e.params
+	target := samples(p.Delay, c.SampleRate)
+	if c != e.config || e.lines == nil {
+		e.config = c
+		e.lines = make([]*DelayLine, c.Channels)
+		for ch := range e.lines {
+			e.lines[ch] = NewDelayLine(int(target) + 1)
+		}
+		e.store = make([]float64, c.Channels)
+		e.delay, e.fade = target, 0
+	}
+	if max := int(math.Ceil(target)); max > e.lines[0].Len() {
+		for _, l := range e.lines {
+			l.Resize(max)
+		}
+	}
+	fadeLen := int(samples(DefaultSmoothing, c.SampleRate))
+	last := c.Channels - 1
+
+	frames := s.Len() / c.Channels
+	for f := 0; f < frames; f++ {
+		base := f * c.Channels
+		if e.fade == 0 && target != e.delay {
+			// Begin a crossfade to the new delay; further changes wait
+			// for it to complete.
+			e.next, e.fade = target, fadeLen+1
+		}
+		for ch, l := range e.lines {
+			v := l.Read(e.delay)
+			if e.fade > 0 {
+				t := 1 - float64(e.fade-1)/float64(fadeLen+1)
+				v += (l.Read(e.next) - v) * t
+			}
+			e.store[ch] = v*(1-p.Damping) + e.store[ch]*p.Damping
+		}
+		if e.fade > 0 {
+			if e.fade--; e.fade == 0 {
+				e.delay = e.next
+			}
+		}
+		if p.PingPong && c.Channels > 1 {
+			var sum float64
+			for ch := 0; ch < c.Channels; ch++ {
+				sum += float64(s.At(base + ch))
+			}
+			e.lines[0].Write(sum + p.Feedback*e.store[last])
+			for ch := 1; ch < c.Channels; ch++ {
+				e.lines[ch].Write(p.Feedback * e.store[ch-1])
+			}
+		} else {
+			for ch, l := range e.lines {
+				l.Write(float64(s.At(base+ch)) + p.Feedback*e.store[ch])
+			}
+		}
+		for ch := 0; ch < c.Channels; ch++ {
+			x := float64(s.At(base + ch))
+			s.Set(base+ch, audio.F64(p.Dry*x+p.Wet*e.store[ch]))
+		}
+	}
+}
+
+// NewEcho returns a new echo with the given parameters, which reads from r,
+// whose samples are laid out according to the given audio configuration.
+//
+// If the echo is only to be used as a Processor, r may be nil.
+func NewEcho(r audio.Reader, c audio.Config, p EchoParams) *Echo {
+	e := &Echo{
+		params: p,
+		config: c,
+	}
+	if r != nil {
+		e.Reader = NewReader(r, c, e)
+	}
+	return e
+}
@@ -0,0 +1,122 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"azul3d.org/audio.v1"
+)
+
+func TestEcho(t *testing.T) {
+	c := audio.Config{SampleRate: 1000, Channels: 1}
+	e := NewEcho(nil, c, EchoParams{
+		Delay:    100 * time.Millisecond,
+		Feedback: 0.5,
+		Wet:      1,
+		Dry:      1,
+	})
+	s := make(audio.F64Samples, 450)
+	s[0] = 1
+	e.Process(s, c)
+	for i, got := range s {
+		want := 0.0
+		if i%100 == 0 {
+			want = math.Pow(0.5, float64(i/100))
+			if i > 0 {
+				want *= 2 // the first echo has unity gain
+			}
+		}
+		if math.Abs(float64(got)-want) > 1e-12 {
+			t.Fatalf("sample %d = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestEchoPingPong(t *testing.T) {
+	c := audio.Config{SampleRate: 1000, Channels: 2}
+	e := NewEcho(nil, c, EchoParams{
+		Delay:    50 * time.Millisecond,
+		Feedback: 0.5,
+		Wet:      1,
+		PingPong: true,
+	})
+	s := make(audio.F64Samples, 200*2)
+	s[1] = 1 // an impulse in the right channel
+	e.Process(s, c)
+	for f := 0; f < 200; f++ {
+		l, r := float64(s[f*2]), float64(s[f*2+1])
+		wantL, wantR := 0.0, 0.0
+		switch f {
+		case 50:
+			wantL = 1
+		case 100:
+			wantR = 0.5
+		case 150:
+			wantL = 0.25
+		}
+		if math.Abs(l-wantL) > 1e-12 || math.Abs(r-wantR) > 1e-12 {
+			t.Fatalf("frame %d = (%v, %v), want (%v, %v)", f, l, r, wantL, wantR)
+		}
+	}
+}
+
+func TestEchoDamping(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 1}
+	p := EchoParams{Delay: 10 * time.Millisecond, Feedback: 0.9, Wet: 1}
+	level := func(damping, freq float64) float64 {
+		p.Damping = damping
+		s := sine(c, freq, 48000)
+		NewEcho(nil, c, p).Process(s, c)
+		return peak(s, 24000)
+	}
+	if lo, hi := level(0.5, 100), level(0.5, 10000); hi > lo/4 {
+		t.Fatalf("damped levels: %v at 100Hz, %v at 10kHz", lo, hi)
+	}
+}
+
+func TestEchoDelayChange(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 1}
+	p := EchoParams{Delay: 5 * time.Millisecond, Wet: 1}
+	e := NewEcho(audio.NewBuffer(sine(c, 200, 48000)), c, p)
+	buf := make(audio.F64Samples, 1000)
+	if _, err := e.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// Lengthening the delay glides smoothly, rather than jumping.
+	p.Delay = 500 * time.Millisecond
+	e.SetParams(p)
+	if e.Params() != p {
+		t.Fatalf("Params() = %+v, want %+v", e.Params(), p)
+	}
+	prev := buf[len(buf)-1]
+	for i := 0; i < 20; i++ {
+		if _, err := e.Read(buf); err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range buf {
+			// The largest step of a 200Hz sine at 48kHz is about 0.026.
+			if math.Abs(float64(v-prev)) > 0.1 {
+				t.Fatalf("discontinuity of %v", v-prev)
+			}
+			prev = v
+		}
+	}
+}
+
+func TestEchoProcessorSourcePosition(t *testing.T) {
+	// An effect used only as a processor has no position.
+	c := audio.Config{SampleRate: 1000, Channels: 1}
+	e := NewEcho(nil, c, EchoParams{Delay: 100 * time.Millisecond, Wet: 1})
+	if p, ok := audio.SourcePosition(e); p != 0 || !ok {
+		t.Fatalf("SourcePosition got (%d, %v), want (0, true)", p, ok)
+	}
+	if l := audio.TotalLatency(e); l != 0 {
+		t.Fatalf("TotalLatency got %d, want 0", l)
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"azul3d.org/audio.v1"
+)
+
+// Curve represents the shape of a transition between two gains, such as a
+// fade.
+type Curve uint8
+
+const (
+	// Linear changes the gain linearly.
+	Linear Curve = iota
+
+	// Exponential changes the gain linearly in decibels (over a range of 60
+	// dB), which is perceived as an even change in loudness.
+	Exponential
+
+	// EqualPower follows a quarter sine wave, such that a crossfade between
+	// uncorrelated signals keeps a constant power.
+	EqualPower
+
+	// SCurve follows a half cosine wave, starting and ending gently.
+	SCurve
+)
+
+// String returns a string representation of the curve.
+func (c Curve) String() string {
+	switch c {
+	case Linear:
+		return "Linear"
+	case Exponential:
+		return "Exponential"
+	case EqualPower:
+		return "EqualPower"
+	case SCurve:
+		return "SCurve"
+	}
+	return fmt.Sprintf("Curve(%d)", uint8(c))
+}
+
+// At returns the gain of a fade-in of this shape at t, in the range of 0 to 1,
+// through the fade. It returns 0 at t=0 and 1 at t=1. The gain of the
+// corresponding fade-out is At(1-t).
+func (c Curve) At(t float64) float64 {
+	switch {
+	case t <= 0:
+		return 0
+	case t >= 1:
+		return 1
+	}
+	switch c {
+	case Linear:
+		return t
+	case Exponential:
+		const floor = 0.001 // -60 dB
+		return (math.Pow(10, 3*(t-1)) - floor) / (1 - floor)
+	case EqualPower:
+		return math.Sin(t * math.Pi / 2)
+	case SCurve:
+		return 0.5 - 0.5*math.Cos(t*math.Pi)
+	}
+	panic("dsp: invalid curve")
+}
+
+// Point is a single breakpoint of an automation envelope.
+type Point struct {
+	// Frame is the position of the point in the stream, in sample frames.
+	Frame int64
+
+	// Value is the linear gain at the point.
+	Value float64
+
+	// Curve is the shape of the transition from the previous point to this
+	// one. Rising transitions follow the curve's fade-in shape, and falling
+	// transitions follow its fade-out shape.
+	Curve Curve
+}
+
+// interpolate returns the value at frame f, which lies between the points p
+// and q.
+func interpolate(p, q Point, f int64) float64 {
+	if q.Frame <= p.Frame {
+		return q.Value
+	}
+	t := float64(f-p.Frame) / float64(q.Frame-p.Frame)
+	if q.Value >= p.Value {
+		return p.Value + (q.Value-p.Value)*q.Curve.At(t)
+	}
+	return q.Value + (p.Value-q.Value)*q.Curve.At(1-t)
+}
+
+// FadeIn returns the points of a fade-in from silence to unity gain, of the
+// given shape, beginning at the start frame and lasting length frames.
+func FadeIn(start, length int64, c Curve) []Point {
+	return []Point{
+		{Frame: start, Value: 0},
+		{Frame: start + length, Value: 1, Curve: c},
+	}
+}
+
+// FadeOut returns the points of a fade-out from unity gain to silence, of the
+// given shape, beginning at the start frame and lasting length frames.
+func FadeOut(start, length int64, c Curve) []Point {
+	return []Point{
+		{Frame: start, Value: 1},
+		{Frame: start + length, Value: 0, Curve: c},
+	}
+}
+
+// Envelope applies a sample-accurate automation envelope of gain to an audio
+// stream. It implements both the audio.Reader and Processor interfaces.
+// Envelopes must be allocated via the NewEnvelope function.
+//
+// The gain is interpolated between the points of the envelope. Before the
+// first point the gain is the value of the first point, and after the last
+// point it is the value of the last point; an envelope without points has
+// unity gain.
+//
+// It is safe to change the points from another goroutine while audio is being
+// processed.
+type Envelope struct {
+	*Reader
+
+	access sync.Mutex
+	config audio.Config
+	points []Point
+	pos    int64 // position in frames
+	seg    int   // index of the first point after pos, as a search hint
+}
+
+// Config returns the audio configuration of the stream.
+func (e *Envelope) Config() audio.Config {
+	return e.config
+}
+
+// Points returns a copy of the points of the envelope, in order.
+func (e *Envelope) Points() []Point {
+	e.access.Lock()
+	defer e.access.Unlock()
+	return append([]Point(nil), e.points...)
+}
+
+// SetPoints replaces the points of the envelope. They need not be in order.
+func (e *Envelope) SetPoints(points ...Point) {
+	p := append([]Point(nil), points...)
+	sort.Stable(byFrame(p))
+	e.access.Lock()
+	e.points = p
+	e.seg = 0
+	e.access.Unlock()
+}
+
+// AddPoints adds points to the envelope. Points at the same frame as an
+// existing point are placed after it.
+func (e *Envelope) AddPoints(points ...Point) {
+	e.access.Lock()
+	p := append(e.points, points...)
+	sort.Stable(byFrame(p))
+	e.points = p
+	e.seg = 0
+	e.access.Unlock()
+}
+
+// Value returns the gain of the envelope at the given frame.
+func (e *Envelope) Value(frame int64) float64 {
+	e.access.Lock()
+	defer e.access.Unlock()
+	return e.value(frame)
+}
+
+func (e *Envelope) value(f int64) float64 {
+	p := e.points
+	if len(p) == 0 {
+		return 1
+	}
+	// Find the first point after f; usually the hint is correct.
+	i := e.seg
+	if i > len(p) || (i > 0 && p[i-1].Frame > f) {
+		i = 0
+	}
+	for i < len(p) && p[i].Frame <= f {
+		i++
+	}
+	e.seg = i
+	switch i {
+	case 0:
+		return p[0].Value
+	case len(p):
+		return p[len(p)-1].Value
+	}
+	return interpolate(p[i-1], p[i], f)
+}
+
+// Position returns the position of the envelope in the stream, in sample
+// frames; i.e. the number of frames processed so far.
+func (e *Envelope) Position() int64 {
+	e.access.Lock()
+	defer e.access.Unlock()
+	return e.pos
+}
+
+// SetPosition sets the position of the envelope in the stream, in sample
+// frames; e.g. after seeking the underlying stream.
+func (e *Envelope) SetPosition(frame int64) {
+	e.access.Lock()
+	e.pos = frame
+	e.access.Unlock()
+}
+
+// Implements the Processor interface.
+func (e *Envelope) Process(s audio.Slice, c audio.Config) {
+	e.access.Lock()
+	defer e.access.Unlock()
+	e.config = c
+	frames := s.Len() / c.Channels
+	for f := 0; f < frames; f++ {
+		g := audio.F64(e.value(e.pos))
+		e.pos++
+		for ch := 0; ch < c.Channels; ch++ {
+			i := f*c.Channels + ch
+			s.Set(i, s.At(i)*g)
+		}
+	}
+}
+
+// NewEnvelope returns a new automation envelope with the given points (which
+// need not be in order), which reads from r, whose samples are laid out
+// according to the given audio configuration.
+//
+// If the envelope is only to be used as a Processor, r may be nil.
+func NewEnvelope(r audio.Reader, c audio.Config, points ...Point) *Envelope {
+	e := &Envelope{config: c}
+	e.SetPoints(points...)
+	if r != nil {
+		e.Reader = NewReader(r, c, e)
+	}
+	return e
+}
+
+// byFrame sorts points by frame.
+type byFrame []Point
+
+func (p byFrame) Len() int           { return len(p) }
+func (p byFrame) Less(i, j int) bool { return p[i].Frame < p[j].Frame }
+func (p byFrame) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
@@ -0,0 +1,121 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"testing"
+
+	"azul3d.org/audio.v1"
+)
+
+func TestCurves(t *testing.T) {
+	for _, c := range []Curve{Linear, Exponential, EqualPower, SCurve} {
+		if c.At(0) != 0 || c.At(1) != 1 {
+			t.Errorf("%v: At(0) = %v, At(1) = %v, want 0 and 1", c, c.At(0), c.At(1))
+		}
+		prev := 0.0
+		for i := 1; i <= 100; i++ {
+			v := c.At(float64(i) / 100)
+			if v < prev {
+				t.Fatalf("%v: not monotonic at %v", c, float64(i)/100)
+			}
+			prev = v
+		}
+	}
+
+	// Equal power: the powers of a fade-in and fade-out sum to one.
+	for i := 0; i <= 10; i++ {
+		x := float64(i) / 10
+		in, out := EqualPower.At(x), EqualPower.At(1-x)
+		if p := in*in + out*out; math.Abs(p-1) > 1e-12 {
+			t.Errorf("EqualPower: power at %v = %v, want 1", x, p)
+		}
+	}
+
+	// Linear and S-curve: the gains of a fade-in and fade-out sum to one.
+	for _, c := range []Curve{Linear, SCurve} {
+		for i := 0; i <= 10; i++ {
+			x := float64(i) / 10
+			if g := c.At(x) + c.At(1-x); math.Abs(g-1) > 1e-12 {
+				t.Errorf("%v: gain at %v = %v, want 1", c, x, g)
+			}
+		}
+	}
+
+	// Exponential: (approximately) linear in decibels.
+	if got := GainToDB(Exponential.At(0.5)); math.Abs(got+30) > 0.5 {
+		t.Errorf("Exponential: At(0.5) = %v dB, want -30 dB", got)
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 2}
+	points := append(FadeIn(100, 100, Linear), FadeOut(300, 100, EqualPower)...)
+	e := NewEnvelope(audio.NewBuffer(constant(c, 1, 500)), c, points...)
+
+	// Read in odd sized chunks; the envelope must be sample accurate.
+	var got audio.F64Samples
+	buf := make(audio.F64Samples, 74)
+	for {
+		n, err := e.Read(buf)
+		got = append(got, buf[:n]...)
+		if err == audio.EOS {
+			break
+		}
+	}
+	if len(got) != 1000 {
+		t.Fatalf("read %d samples, want 1000", len(got))
+	}
+	for f := 0; f < 500; f++ {
+		var want float64
+		switch {
+		case f < 100:
+			want = 0
+		case f < 200:
+			want = float64(f-100) / 100
+		case f < 300:
+			want = 1
+		case f < 400:
+			want = math.Cos(float64(f-300) / 100 * math.Pi / 2)
+		default:
+			want = 0
+		}
+		for ch := 0; ch < 2; ch++ {
+			if v := float64(got[f*2+ch]); math.Abs(v-want) > 1e-12 {
+				t.Fatalf("frame %d channel %d = %v, want %v", f, ch, v, want)
+			}
+		}
+	}
+	if e.Position() != 500 {
+		t.Errorf("Position() = %d, want 500", e.Position())
+	}
+}
+
+func TestEnvelopeValue(t *testing.T) {
+	e := NewEnvelope(nil, audio.Config{SampleRate: 48000, Channels: 1},
+		Point{Frame: 200, Value: 0.5, Curve: Linear},
+		Point{Frame: 100, Value: 1},
+	)
+	tests := []struct {
+		frame int64
+		want  float64
+	}{
+		{0, 1},
+		{150, 0.75},
+		{100, 1},
+		{200, 0.5},
+		{1000, 0.5},
+		{50, 1}, // backwards
+	}
+	for _, tst := range tests {
+		if got := e.Value(tst.frame); math.Abs(got-tst.want) > 1e-12 {
+			t.Errorf("Value(%d) = %v, want %v", tst.frame, got, tst.want)
+		}
+	}
+	if got := NewEnvelope(nil, audio.Config{}).Value(10); got != 1 {
+		t.Errorf("empty envelope: Value = %v, want 1", got)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+	"sync"
+
+	"azul3d.org/audio.v1"
+)
+
+var (
+	// ISOOctaveBands are the center frequencies, in Hz, of the ten ISO 266
+	// octave bands used by 10-band graphic equalizers.
+	ISOOctaveBands = []float64{
+		31.5, 63, 125, 250, 500, 1000, 2000, 4000, 8000, 16000,
+	}
+
+	// ISOThirdOctaveBands are the center frequencies, in Hz, of the 31 ISO
+	// 266 third-octave bands used by 31-band graphic equalizers.
+	ISOThirdOctaveBands = []float64{
+		20, 25, 31.5, 40, 50, 63, 80, 100, 125, 160, 200, 250, 315, 400, 500,
+		630, 800, 1000, 1250, 1600, 2000, 2500, 3150, 4000, 5000, 6300, 8000,
+		10000, 12500, 16000, 20000,
+	}
+)
+
+// GraphicBands returns flat (0 dB) peaking bands for a graphic equalizer at
+// the given center frequencies, which must be in ascending order and evenly
+// spaced on a logarithmic scale (e.g. ISOOctaveBands). The Q of each band is
+// chosen such that its bandwidth matches the spacing of the bands.
+func GraphicBands(freqs []float64) []BiquadParams {
+	q := 1 / math.Sqrt2
+	if n := len(freqs); n > 1 {
+		// Average ratio between adjacent bands, e.g. 2 for octave bands.
+		r := math.Pow(freqs[n-1]/freqs[0], 1/float64(n-1))
+		q = math.Sqrt(r) / (r - 1)
+	}
+	bands := make([]BiquadParams, len(freqs))
+	for i, f := range freqs {
+		bands[i] = BiquadParams{Type: Peaking, Freq: f, Q: q}
+	}
+	return bands
+}
+
+// FrequencyResponse describes the response of a filter at a single
+// frequency.
+type FrequencyResponse struct {
+	// Freq is the frequency in Hz.
+	Freq float64
+
+	// Magnitude is the magnitude response in decibels.
+	Magnitude float64
+
+	// Phase is the phase response in radians, in the range of -Pi to +Pi.
+	Phase float64
+}
+
+// Equalizer is a multi-band equalizer, made of a cascade of biquad filters.
+// It implements both the audio.Reader and Processor interfaces. Equalizers
+// must be allocated via the NewEqualizer function.
+//
+// It is safe to adjust the bands of the equalizer from another goroutine
+// while audio is being processed; changes to a band's parameters are applied
+// smoothly (see Biquad).
+type Equalizer struct {
+	*Reader
+	config audio.Config
+
+	access sync.RWMutex
+	bands  []*Biquad
+}
+
+// Config returns the audio configuration of the stream.
+func (e *Equalizer) Config() audio.Config {
+	return e.config
+}
+
+// Len returns the number of bands in the equalizer.
+func (e *Equalizer) Len() int {
+	e.access.RLock()
+	defer e.access.RUnlock()
+	return len(e.bands)
+}
+
+// Band returns the parameters of the band at index i.
+func (e *Equalizer) Band(i int) BiquadParams {
+	e.access.RLock()
+	defer e.access.RUnlock()
+	return e.bands[i].Params()
+}
+
+// Bands returns the parameters of all of the bands.
+func (e *Equalizer) Bands() []BiquadParams {
+	e.access.RLock()
+	defer e.access.RUnlock()
+	p := make([]BiquadParams, len(e.bands))
+	for i, b := range e.bands {
+		p[i] = b.Params()
+	}
+	return p
+}
+
+// SetBand sets the parameters of the band at index i.
+func (e *Equalizer) SetBand(i int, p BiquadParams) {
+	e.access.RLock()
+	defer e.access.RUnlock()
+	e.bands[i].SetParams(p)
+}
+
+// SetGain sets the gain, in decibels, of the band at index i. It is
+// short-hand for changing just the gain of the band via SetBand, as is
+// typical with graphic equalizers.
+func (e *Equalizer) SetGain(i int, gain float64) {
+	e.access.RLock()
+	defer e.access.RUnlock()
+	p := e.bands[i].Params()
+	p.Gain = gain
+	e.bands[i].SetParams(p)
+}
+
+// AddBand appends a new band with the given parameters, and returns its
+// index.
+func (e *Equalizer) AddBand(p BiquadParams) int {
+	e.access.Lock()
+	defer e.access.Unlock()
+	e.bands = append(e.bands, NewBiquad(p))
+	return len(e.bands) - 1
+}
+
+// RemoveBand removes the band at index i. The indices of all subsequent bands
+// are shifted down by one.
+func (e *Equalizer) RemoveBand(i int) {
+	e.access.Lock()
+	defer e.access.Unlock()
+	e.bands = append(e.bands[:i], e.bands[i+1:]...)
+}
+
+// Implements the Processor interface.
+func (e *Equalizer) Process(s audio.Slice, c audio.Config) {
+	e.access.RLock()
+	defer e.access.RUnlock()
+	for _, b := range e.bands {
+		b.Process(s, c)
+	}
+}
+
+// Response returns the combined frequency response of all the bands of the
+// equalizer at each of the given frequencies, for the sample rate of the
+// stream. It reflects the current parameters of the bands, not any in-progress
+// smooth transition.
+func (e *Equalizer) Response(freqs []float64) []FrequencyResponse {
+	bands := e.Bands()
+	coeffs := make([]Coefficients, len(bands))
+	for i, p := range bands {
+		coeffs[i] = p.Coefficients(e.config.SampleRate)
+	}
+	r := make([]FrequencyResponse, len(freqs))
+	for i, f := range freqs {
+		h := complex(1, 0)
+		for _, c := range coeffs {
+			h *= c.Response(f, e.config.SampleRate)
+		}
+		r[i] = FrequencyResponse{
+			Freq:      f,
+			Magnitude: 20 * math.Log10(cmplx.Abs(h)),
+			Phase:     cmplx.Phase(h),
+		}
+	}
+	return r
+}
+
+// NewEqualizer returns a new equalizer with the given bands, which reads from
+// r, whose samples are laid out according to the given audio configuration.
+//
+// If the equalizer is only to be used as a Processor, r may be nil.
+func NewEqualizer(r audio.Reader, c audio.Config, bands ...BiquadParams) *Equalizer {
+	e := &Equalizer{
+		config: c,
+		bands:  make([]*Biquad, len(bands)),
+	}
+	for i, p := range bands {
+		e.bands[i] = NewBiquad(p)
+	}
+	if r != nil {
+		e.Reader = NewReader(r, c, e)
+	}
+	return e
+}
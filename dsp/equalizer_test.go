@@ -0,0 +1,62 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"sync"
+	"testing"
+
+	"azul3d.org/audio.v1"
+)
+
+func TestEqualizerResponse(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 2}
+	e := NewEqualizer(nil, c, GraphicBands(ISOOctaveBands)...)
+	for _, r := range e.Response([]float64{50, 1000, 10000}) {
+		if math.Abs(r.Magnitude) > 1e-9 {
+			t.Fatalf("flat EQ at %v Hz: got %v dB, want 0 dB", r.Freq, r.Magnitude)
+		}
+	}
+
+	e.SetGain(5, 6) // 1 kHz
+	r := e.Response([]float64{1000, 30})
+	if math.Abs(r[0].Magnitude-6) > 0.5 {
+		t.Fatalf("boosted band: got %v dB, want ~6 dB", r[0].Magnitude)
+	}
+	if math.Abs(r[1].Magnitude) > 0.1 {
+		t.Fatalf("distant band: got %v dB, want ~0 dB", r[1].Magnitude)
+	}
+
+	// The response must match the measured output of the equalizer.
+	s := sine(c, 1000, 9600)
+	e.Process(s, c)
+	want := math.Pow(10, r[0].Magnitude/20)
+	if p := peak(s, 4800*c.Channels); math.Abs(p-want) > 0.02 {
+		t.Fatalf("measured peak %v, want %v", p, want)
+	}
+}
+
+func TestEqualizerConcurrent(t *testing.T) {
+	c := audio.Config{SampleRate: 44100, Channels: 2}
+	src := audio.NewBuffer(sine(c, 440, 44100))
+	e := NewEqualizer(src, c, GraphicBands(ISOThirdOctaveBands)...)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			e.SetGain(i%e.Len(), float64(i%12-6))
+		}
+	}()
+	buf := make(audio.F32Samples, 512)
+	for {
+		if _, err := e.Read(buf); err != nil {
+			break
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,137 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"azul3d.org/audio.v1"
+)
+
+// DBToGain converts a level in decibels to a linear gain factor. Levels at or
+// below -200 dB are treated as silence.
+func DBToGain(db float64) float64 {
+	if db <= -200 {
+		return 0
+	}
+	return math.Pow(10, db/20)
+}
+
+// GainToDB converts a linear gain factor to a level in decibels, limited to a
+// minimum of -200 dB.
+func GainToDB(g float64) float64 {
+	g = math.Abs(g)
+	if g < 1e-10 {
+		return -200
+	}
+	return 20 * math.Log10(g)
+}
+
+// Gain applies a gain in decibels to an audio stream. It implements both the
+// audio.Reader and Processor interfaces. Gains must be allocated via the
+// NewGain function.
+//
+// It is safe to change the gain from another goroutine while audio is being
+// processed; to avoid audible clicks the gain is then ramped over a short
+// period of time.
+type Gain struct {
+	*Reader
+
+	access    sync.Mutex
+	db        float64
+	smoothing time.Duration
+	dirty     bool
+
+	config      audio.Config
+	cur, target float64 // linear gains
+	step        float64 // per frame change of cur while ramping
+	ramp        int     // remaining ramp length in frames
+}
+
+// Config returns the audio configuration of the stream.
+func (g *Gain) Config() audio.Config {
+	return g.config
+}
+
+// Gain returns the gain in decibels.
+func (g *Gain) Gain() float64 {
+	g.access.Lock()
+	defer g.access.Unlock()
+	return g.db
+}
+
+// SetGain sets the gain in decibels. The gain ramps to the new value over the
+// smoothing duration.
+func (g *Gain) SetGain(db float64) {
+	g.access.Lock()
+	g.db = db
+	g.dirty = true
+	g.access.Unlock()
+}
+
+// SetSmoothing sets the duration over which the gain is ramped when it is
+// changed. A duration of zero disables ramping.
+func (g *Gain) SetSmoothing(d time.Duration) {
+	g.access.Lock()
+	g.smoothing = d
+	g.access.Unlock()
+}
+
+// Implements the Processor interface.
+func (g *Gain) Process(s audio.Slice, c audio.Config) {
+	g.access.Lock()
+	defer g.access.Unlock()
+	if g.dirty {
+		g.dirty = false
+		g.target = DBToGain(g.db)
+		g.ramp = int(g.smoothing.Seconds() * float64(c.SampleRate))
+		if g.ramp == 0 {
+			g.cur = g.target
+		} else {
+			g.step = (g.target - g.cur) / float64(g.ramp)
+		}
+	}
+	g.config = c
+
+	frames := s.Len() / c.Channels
+	for f := 0; f < frames; f++ {
+		if g.ramp > 0 {
+			g.ramp--
+			g.cur += g.step
+			if g.ramp == 0 {
+				g.cur = g.target
+			}
+		}
+		if g.cur == 1 && g.ramp == 0 {
+			// Unity gain for the remainder; nothing to do.
+			return
+		}
+		for ch := 0; ch < c.Channels; ch++ {
+			i := f*c.Channels + ch
+			s.Set(i, s.At(i)*audio.F64(g.cur))
+		}
+	}
+}
+
+// NewGain returns a new gain of the given level in decibels, which reads from
+// r, whose samples are laid out according to the given audio configuration.
+//
+// If the gain is only to be used as a Processor, r may be nil.
+func NewGain(r audio.Reader, c audio.Config, db float64) *Gain {
+	lin := DBToGain(db)
+	g := &Gain{
+		db:        db,
+		smoothing: DefaultSmoothing,
+		config:    c,
+		cur:       lin,
+		target:    lin,
+	}
+	if r != nil {
+		g.Reader = NewReader(r, c, g)
+	}
+	return g
+}
@@ -0,0 +1,70 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"azul3d.org/audio.v1"
+)
+
+func TestDBToGain(t *testing.T) {
+	tests := []struct {
+		db, gain float64
+	}{
+		{0, 1},
+		{-6.020599913279624, 0.5},
+		{20, 10},
+		{-200, 0},
+	}
+	for _, tst := range tests {
+		if got := DBToGain(tst.db); math.Abs(got-tst.gain) > 1e-12 {
+			t.Errorf("DBToGain(%v) = %v, want %v", tst.db, got, tst.gain)
+		}
+		if got := GainToDB(tst.gain); math.Abs(got-tst.db) > 1e-9 {
+			t.Errorf("GainToDB(%v) = %v, want %v", tst.gain, got, tst.db)
+		}
+	}
+}
+
+func TestGain(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 2}
+	g := NewGain(audio.NewBuffer(constant(c, 0.5, 1000)), c, -6.020599913279624)
+	buf := make(audio.F64Samples, 2000)
+	n, err := g.Read(buf)
+	if n != len(buf) || err != nil {
+		t.Fatalf("Read: got %d, %v", n, err)
+	}
+	for i, v := range buf {
+		if math.Abs(float64(v)-0.25) > 1e-12 {
+			t.Fatalf("sample %d = %v, want 0.25", i, v)
+		}
+	}
+}
+
+func TestGainRamp(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 1}
+	g := NewGain(nil, c, 0)
+	g.SetSmoothing(10 * time.Millisecond) // 480 frames
+	g.SetGain(-200)
+	s := constant(c, 1, 1000)
+	g.Process(s, c)
+	for i := 1; i < len(s); i++ {
+		if s[i] > s[i-1] {
+			t.Fatalf("gain increased at frame %d", i)
+		}
+		if d := s[i-1] - s[i]; d > 1.0/480+1e-9 {
+			t.Fatalf("gain step of %v at frame %d", d, i)
+		}
+	}
+	if s[479] != 0 || s[len(s)-1] != 0 {
+		t.Errorf("gain did not reach silence: %v, %v", s[479], s[len(s)-1])
+	}
+	if s[478] == 0 {
+		t.Errorf("gain reached silence early")
+	}
+}
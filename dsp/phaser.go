@@ -0,0 +1,144 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"sync"
+
+	"azul3d.org/audio.v1"
+)
+
+// PhaserParams describes the parameters of a phaser.
+type PhaserParams struct {
+	// Stages is the number of first order allpass filters, e.g. 6; each pair
+	// of stages creates one notch in the spectrum.
+	Stages int
+
+	// MinFreq and MaxFreq are the range in Hz over which the allpass filters
+	// are swept, e.g. 200 to 2000.
+	MinFreq, MaxFreq float64
+
+	// Rate is the frequency at which the filters are swept in Hz, e.g. 0.5.
+	Rate float64
+
+	// Feedback is the gain of the filtered signal fed back into the filters,
+	// in the range of -1 to +1 (exclusive), which sharpens the notches.
+	Feedback float64
+
+	// Spread is the offset, in cycles, of each channel's sweep relative to
+	// the previous channel's.
+	Spread float64
+
+	// Wet and Dry are the linear gains of the filtered and of the dry signal;
+	// equal gains give the deepest notches.
+	Wet, Dry float64
+}
+
+// allpass is the state of a single first order allpass filter.
+type allpass struct {
+	x1, y1 float64
+}
+
+// Phaser is a phasing effect, which sweeps notches through the spectrum by
+// mixing the sound with a copy of itself passed through a series of allpass
+// filters. It implements both the audio.Reader and Processor interfaces.
+// Phasers must be allocated via the NewPhaser function.
+//
+// It is safe to change the parameters from another goroutine while audio is
+// being processed.
+type Phaser struct {
+	*Reader
+
+	access sync.Mutex
+	params PhaserParams
+
+	config audio.Config
+	stages [][]allpass // per channel filter state
+	last   []float64   // per channel filter output, for feedback
+	lfo    lfo
+}
+
+// Config returns the audio configuration of the stream.
+func (e *Phaser) Config() audio.Config {
+	return e.config
+}
+
+// Params returns the current parameters.
+func (e *Phaser) Params() PhaserParams {
+	e.access.Lock()
+	defer e.access.Unlock()
+	return e.params
+}
+
+// SetParams sets the parameters.
+func (e *Phaser) SetParams(p PhaserParams) {
+	e.access.Lock()
+	e.params = p
+	e.access.Unlock()
+}
+
+// Implements the Processor interface.
+func (e *Phaser) Process(s audio.Slice, c audio.Config) {
+	e.access.Lock()
+	defer e.access.Unlock()
+	p := e.params
+	if c != e.config || e.stages == nil || len(e.stages[0]) != p.Stages {
+		e.config = c
+		e.stages = make([][]allpass, c.Channels)
+		for ch := range e.stages {
+			e.stages[ch] = make([]allpass, p.Stages)
+		}
+		e.last = make([]float64, c.Channels)
+	}
+	lo, hi := p.MinFreq, p.MaxFreq
+	if lo <= 0 {
+		lo = 1
+	}
+	if hi < lo {
+		hi = lo
+	}
+	nyquist := float64(c.SampleRate) / 2
+
+	frames := s.Len() / c.Channels
+	for f := 0; f < frames; f++ {
+		for ch, stages := range e.stages {
+			// The break frequency is swept exponentially, such that the
+			// sweep is perceptually even.
+			t := (1 + e.lfo.at(float64(ch)*p.Spread)) / 2
+			freq := math.Min(lo*math.Pow(hi/lo, t), nyquist*0.99)
+			tan := math.Tan(math.Pi * freq / float64(c.SampleRate))
+			a := (tan - 1) / (tan + 1)
+
+			i := f*c.Channels + ch
+			x := float64(s.At(i))
+			y := x + p.Feedback*e.last[ch]
+			for j := range stages {
+				st := &stages[j]
+				out := a*y + st.x1 - a*st.y1
+				st.x1, st.y1 = y, out
+				y = out
+			}
+			e.last[ch] = y
+			s.Set(i, audio.F64(p.Dry*x+p.Wet*y))
+		}
+		e.lfo.advance(p.Rate, c.SampleRate)
+	}
+}
+
+// NewPhaser returns a new phaser with the given parameters, which reads from
+// r, whose samples are laid out according to the given audio configuration.
+//
+// If the phaser is only to be used as a Processor, r may be nil.
+func NewPhaser(r audio.Reader, c audio.Config, p PhaserParams) *Phaser {
+	e := &Phaser{
+		params: p,
+		config: c,
+	}
+	if r != nil {
+		e.Reader = NewReader(r, c, e)
+	}
+	return e
+}
@@ -0,0 +1,52 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"testing"
+
+	"azul3d.org/audio.v1"
+)
+
+func TestPhaserAllpass(t *testing.T) {
+	// The filtered signal alone has the same magnitude as the input.
+	c := audio.Config{SampleRate: 48000, Channels: 2}
+	for _, freq := range []float64{100, 1000, 8000} {
+		s := sine(c, freq, 24000)
+		e := NewPhaser(nil, c, PhaserParams{Stages: 6, MinFreq: 200, MaxFreq: 2000, Rate: 0.5, Spread: 0.5, Wet: 1})
+		e.Process(s, c)
+		if got := peak(s, 4800); math.Abs(got-1) > 0.01 {
+			t.Errorf("%vHz: peak %v, want 1", freq, got)
+		}
+	}
+}
+
+func TestPhaserNotch(t *testing.T) {
+	// Without sweeping, two stages shift the phase by 180 degrees at the
+	// break frequency, creating a notch when mixed equally with the input.
+	c := audio.Config{SampleRate: 48000, Channels: 1}
+	p := PhaserParams{Stages: 2, MinFreq: 1000, MaxFreq: 1000, Rate: 1, Wet: 0.5, Dry: 0.5}
+	for _, tst := range []struct {
+		freq, min, max float64
+	}{
+		{1000, 0, 0.01},
+		{50, 0.95, 1.01},
+		{20000, 0.95, 1.01},
+	} {
+		s := sine(c, tst.freq, 24000)
+		e := NewPhaser(audio.NewBuffer(s), c, p)
+		if e.Params() != p {
+			t.Fatalf("Params() = %+v, want %+v", e.Params(), p)
+		}
+		out := make(audio.F64Samples, len(s))
+		if _, err := e.Read(out); err != nil {
+			t.Fatal(err)
+		}
+		if got := peak(out, 12000); got < tst.min || got > tst.max {
+			t.Errorf("%vHz: peak %v, want %v to %v", tst.freq, got, tst.min, tst.max)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"sync"
+
+	"azul3d.org/audio.v1"
+)
+
+const (
+	// sincZeros is the number of zero crossings on each side of the
+	// resampling kernel.
+	sincZeros = 16
+
+	// sincRes is the number of kernel table entries per zero crossing.
+	sincRes = 256
+
+	// maxRatio is the maximum resampling ratio; it bounds the width of the
+	// kernel when downsampling.
+	maxRatio = 16
+
+	// cutoff is the cutoff frequency of the kernel when downsampling,
+	// relative to the output Nyquist frequency; it leaves room for the
+	// transition band of the kernel.
+	cutoff = 0.9
+)
+
+// sincTable holds one side of the Blackman windowed sinc resampling kernel,
+// sampled sincRes times per zero crossing.
+var sincTable = func() []float64 {
+	t := make([]float64, sincZeros*sincRes+2)
+	for i := range t {
+		x := float64(i) / sincRes
+		if x >= sincZeros {
+			break
+		}
+		v := 1.0
+		if x > 0 {
+			v = math.Sin(math.Pi*x) / (math.Pi * x)
+		}
+		w := math.Pi * (x/sincZeros + 1) // window phase, Pi at the center
+		t[i] = v * (0.42 - 0.5*math.Cos(w) + 0.08*math.Cos(2*w))
+	}
+	return t
+}()
+
+// kernel returns the resampling kernel at x (in zero crossings).
+func kernel(x float64) float64 {
+	x = math.Abs(x) * sincRes
+	i := int(x)
+	if i >= sincZeros*sincRes {
+		return 0
+	}
+	f := x - float64(i)
+	return sincTable[i] + (sincTable[i+1]-sincTable[i])*f
+}
+
+// Resampler is an audio reader which resamples a stream by a variable ratio
+// using band-limited (windowed sinc) interpolation. It can be used for sample
+// rate conversion, or to change the playback speed (and therefore the pitch)
+// of a stream, e.g. for Doppler shifts. Resamplers must be allocated via the
+// NewResampler or NewRateConverter functions.
+//
+// It is safe to change the ratio from another goroutine while audio is being
+// read.
+type Resampler struct {
+	r      *audio.FrameReader
+	in     audio.Config // configuration of the input stream
+	out    audio.Config // configuration of the output stream
+	access sync.Mutex
+	ratio  float64
+
+	hist   audio.F64Samples // interleaved input frames, beginning at base
+	base   int64            // index of the first frame of hist
+	origin float64          // read position in input frames when the ratio was last changed
+	steps  int64            // output frames since the ratio was last changed
+	cur    float64          // ratio in use since origin
+	end    int64            // total input frames, or -1 until EOS
+	buf    audio.F64Samples
+}
+
+// Config returns the audio configuration of the resampled stream.
+func (s *Resampler) Config() audio.Config {
+	return s.out
+}
+
+// Ratio returns the resampling ratio.
+func (s *Resampler) Ratio() float64 {
+	s.access.Lock()
+	defer s.access.Unlock()
+	return s.ratio
+}
+
+// SetRatio sets the resampling ratio: the number of input sample frames
+// consumed per output sample frame. For example a ratio of 2 plays the stream
+// twice as fast (an octave higher), and 0.5 plays it half as fast (an octave
+// lower).
+//
+// It panics if the ratio is not in the range of 1/16 to 16.
+func (s *Resampler) SetRatio(ratio float64) {
+	if !(ratio >= 1.0/maxRatio && ratio <= maxRatio) {
+		panic("dsp: resampling ratio out of range")
+	}
+	s.access.Lock()
+	s.ratio = ratio
+	s.access.Unlock()
+}
+
+// fill ensures that the input frames up to and including frame i are buffered
+// (or that the input has ended).
+func (s *Resampler) fill(i int64) error {
+	c := s.in.Channels
+	for s.end < 0 && s.base+int64(len(s.hist)/c) <= i {
+		n, err := s.r.Read(s.buf)
+		s.hist = append(s.hist, s.buf[:n]...)
+		if err == audio.EOS {
+			s.end = s.base + int64(len(s.hist)/c)
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pos returns the read position in input frames.
+func (s *Resampler) pos() float64 {
+	return s.origin + float64(s.steps)*s.cur
+}
+
+// at returns input sample i (in frames) of the given channel; samples outside
+// of the stream are silent.
+func (s *Resampler) at(i int64, ch int) float64 {
+	c := s.in.Channels
+	j := i - s.base
+	if i < 0 || j < 0 || j >= int64(len(s.hist)/c) {
+		return 0
+	}
+	return float64(s.hist[j*int64(c)+int64(ch)])
+}
+
+// maxWidth is the half-width of the interpolation kernel at the maximum ratio.
+var _, maxWidth = kernelSize(maxRatio)
+
+// kernelSize returns the scale of the interpolation kernel, which lowers the
+// cutoff when downsampling, and its half-width in input frames for the given
+// ratio.
+func kernelSize(ratio float64) (scale float64, width int64) {
+	scale = 1.0
+	if ratio > 1 {
+		scale = cutoff / ratio
+	}
+	return scale, int64(math.Ceil(sincZeros / scale))
+}
+
+// SourcePosition implements the audio.Positioner interface. The read position
+// is mapped back to the source position of the input frame at it, such that it
+// is accurate even though the input is read ahead of the output.
+func (s *Resampler) SourcePosition() uint64 {
+	read := s.base + int64(len(s.hist)/s.in.Channels) // input frames read
+	return s.r.SourcePositionAt(int(int64(math.Floor(s.pos()+0.5)) - read))
+}
+
+// Latency returns the latency of the resampler in sample frames of the
+// resampled stream: the half-width of the interpolation kernel, which must be
+// read ahead of each output frame, at the current ratio.
+func (s *Resampler) Latency() int {
+	s.access.Lock()
+	ratio := s.ratio
+	s.access.Unlock()
+	_, width := kernelSize(ratio)
+	return int(float64(width)/ratio + 0.5)
+}
+
+// TotalLatency implements the audio.Positioner interface. It is the latency
+// of the input stream, converted to output samples by the current ratio, plus
+// that of the resampler.
+func (s *Resampler) TotalLatency() int {
+	c := s.in.Channels
+	in := float64(s.r.TotalLatency()/c) / s.Ratio()
+	return (int(in+0.5) + s.Latency()) * c
+}
+
+// Read implements the audio.Reader interface.
+func (s *Resampler) Read(b audio.Slice) (n int, err error) {
+	s.access.Lock()
+	ratio := s.ratio
+	s.access.Unlock()
+
+	if ratio != s.cur {
+		// Computing the position from the origin, rather than accumulating
+		// it, avoids drift.
+		s.origin = s.pos()
+		s.steps = 0
+		s.cur = ratio
+	}
+
+	c := s.in.Channels
+	frames := b.Len() / c
+	scale, width := kernelSize(ratio)
+	for f := 0; f < frames; f++ {
+		pos := s.pos()
+		center := int64(math.Floor(pos))
+		if err = s.fill(center + width); err != nil {
+			return n, err
+		}
+		if s.end >= 0 && pos > float64(s.end)-1e-6 {
+			if n == 0 {
+				return 0, audio.EOS
+			}
+			return n, nil
+		}
+		if pos == float64(center) && ratio == 1 {
+			// Exactly on an input frame; no interpolation needed.
+			for ch := 0; ch < c; ch++ {
+				b.Set(n+ch, audio.F64(s.at(center, ch)))
+			}
+		} else {
+			for ch := 0; ch < c; ch++ {
+				var v float64
+				for i := center - width + 1; i <= center+width; i++ {
+					v += s.at(i, ch) * kernel((float64(i)-pos)*scale)
+				}
+				b.Set(n+ch, audio.F64(v*scale))
+			}
+		}
+		n += c
+		s.steps++
+
+		// Discard input frames which are no longer needed, keeping enough
+		// behind the center for the widest kernel (in case the ratio rises).
+		if drop := center - maxWidth - s.base; drop > 4096 {
+			s.hist = append(s.hist[:0], s.hist[drop*int64(c):]...)
+			s.base += drop
+		}
+	}
+	return n, nil
+}
+
+// NewResampler returns a new resampler of the stream r, whose samples are laid
+// out according to the given audio configuration, with the given initial
+// ratio (see SetRatio). The resampled stream has the same audio configuration.
+func NewResampler(r audio.Reader, c audio.Config, ratio float64) *Resampler {
+	s := &Resampler{
+		r:   audio.NewFrameReader(r, c),
+		in:  c,
+		out: c,
+		end: -1,
+		buf: make(audio.F64Samples, 1024*c.Channels),
+	}
+	s.SetRatio(ratio)
+	s.cur = ratio
+	return s
+}
+
+// NewRateConverter returns a new resampler which converts the stream r, whose
+// samples are laid out according to the given audio configuration, to the
+// given sample rate.
+func NewRateConverter(r audio.Reader, c audio.Config, sampleRate int) *Resampler {
+	s := NewResampler(r, c, float64(c.SampleRate)/float64(sampleRate))
+	s.out.SampleRate = sampleRate
+	return s
+}
@@ -0,0 +1,94 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"testing"
+
+	"azul3d.org/audio.v1"
+)
+
+func TestResamplerUnity(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 2}
+	in := sine(c, 1000, 1000)
+	out := readAll(t, NewResampler(audio.NewBuffer(append(audio.F64Samples(nil), in...)), c, 1), 100)
+	if len(out) != len(in) {
+		t.Fatalf("read %d samples, want %d", len(out), len(in))
+	}
+	for i := range in {
+		if out[i] != in[i] {
+			t.Fatalf("sample %d = %v, want %v", i, out[i], in[i])
+		}
+	}
+}
+
+func TestRateConverter(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 1}
+	for _, rate := range []int{44100, 96000, 22050} {
+		in := sine(c, 1000, 48000)
+		rc := NewRateConverter(audio.NewBuffer(in), c, rate)
+		if rc.Config().SampleRate != rate {
+			t.Fatalf("Config().SampleRate = %d, want %d", rc.Config().SampleRate, rate)
+		}
+		out := readAll(t, rc, 512)
+		if len(out) != rate {
+			t.Errorf("%d Hz: read %d samples, want %d", rate, len(out), rate)
+		}
+		// Compare against an ideal sine at the new rate, away from the
+		// edges.
+		var maxErr float64
+		for i := rate / 10; i < rate*9/10; i++ {
+			want := math.Sin(2 * math.Pi * 1000 * float64(i) / float64(rate))
+			maxErr = math.Max(maxErr, math.Abs(float64(out[i])-want))
+		}
+		if maxErr > 1e-3 {
+			t.Errorf("%d Hz: maximum error %v", rate, maxErr)
+		}
+	}
+}
+
+func TestResamplerAntiAliasing(t *testing.T) {
+	// Playing a 15 kHz tone twice as fast would alias it to 18 kHz; it must
+	// be filtered out instead.
+	c := audio.Config{SampleRate: 48000, Channels: 1}
+	out := readAll(t, NewResampler(audio.NewBuffer(sine(c, 15000, 48000)), c, 2), 512)
+	if p := peak(out.Slice(0, len(out)-1000), 1000); p > 0.01 {
+		t.Errorf("aliased tone at %v, want below 0.01", p)
+	}
+}
+
+func TestRateConverterSourcePosition(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 2}
+	rc := NewRateConverter(audio.NewBuffer(sine(c, 1000, 48000)), c, 24000)
+	g := NewGain(rc, rc.Config(), -6)
+	buf := make(audio.F64Samples, 300*2)
+	for i := 0; i < 10; i++ {
+		g.Read(buf)
+		// Each output frame advances the source by two frames.
+		if want := uint64(i+1) * 300 * 2 * 2; g.SourcePosition() != want {
+			t.Fatalf("read %d: SourcePosition got %d, want %d", i, g.SourcePosition(), want)
+		}
+	}
+	if l := g.TotalLatency(); l <= 0 || l%2 != 0 {
+		t.Fatalf("TotalLatency got %d, want a positive whole number of frames", l)
+	}
+}
+
+func TestResamplerMaxRatio(t *testing.T) {
+	// At the maximum ratio the kernel is at its widest; enough history must
+	// be kept behind it.
+	c := audio.Config{SampleRate: 48000, Channels: 2}
+	in := sine(c, 100, 48000*2)
+	out := readAll(t, NewResampler(audio.NewBuffer(in), c, maxRatio), 256)
+	if want := len(in) / maxRatio; len(out) < want-2 || len(out) > want+2 {
+		t.Fatalf("read %d samples, want %d", len(out), want)
+	}
+	for i, v := range out {
+		if math.IsNaN(float64(v)) || math.Abs(float64(v)) > 1.1 {
+			t.Fatalf("sample %d = %v", i, v)
+		}
+	}
+}
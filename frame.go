@@ -0,0 +1,309 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audio
+
+import "math"
+
+// FrameView is a view of an interleaved audio slice as a series of frames,
+// where each frame holds exactly one sample for each channel.
+//
+// For example a stereo slice [L0, R0, L1, R1] viewed with Channels == 2 has
+// two frames, and Channel(1, 0) is the sample L1.
+type FrameView struct {
+	// Slice is the interleaved audio slice that is being viewed.
+	Slice Slice
+
+	// Channels is the number of interleaved channels in the slice.
+	Channels int
+}
+
+// Len returns the number of whole frames in the slice. Any trailing partial
+// frame is not counted.
+func (v FrameView) Len() int {
+	return v.Slice.Len() / v.Channels
+}
+
+// Frame returns a slice of the samples that make up the frame at index i.
+func (v FrameView) Frame(i int) Slice {
+	return v.Slice.Slice(i*v.Channels, (i+1)*v.Channels)
+}
+
+// Channel returns the sample for the channel ch in the frame at index i.
+func (v FrameView) Channel(i, ch int) F64 {
+	return v.Slice.At(i*v.Channels + ch)
+}
+
+// SetChannel sets the sample for the channel ch in the frame at index i.
+func (v FrameView) SetChannel(i, ch int, s F64) {
+	v.Slice.Set(i*v.Channels+ch, s)
+}
+
+// Frames returns a view of the frames from low to high, like the slice
+// expression v[low:high] but in units of frames rather than samples.
+func (v FrameView) Frames(low, high int) FrameView {
+	return FrameView{
+		Slice:    v.Slice.Slice(low*v.Channels, high*v.Channels),
+		Channels: v.Channels,
+	}
+}
+
+// FrameReader wraps a Reader such that every Read transfers only whole frames
+// of audio; i.e. the number of samples read is always a multiple of the
+// number of channels. FrameReaders must be allocated via the NewFrameReader
+// function.
+type FrameReader struct {
+	r        Reader
+	config   Config
+	channels int
+	rem      F64Samples // partial frame left over from the last read
+	err      error      // deferred error
+	read     uint64     // number of samples returned by Read
+	marks    []posMark  // recent source positions, see SourcePositionAt
+}
+
+// maxMarks is the maximum number of source positions remembered by a
+// FrameReader.
+const maxMarks = 64
+
+// posMark records the source position of a stream at a frame boundary.
+type posMark struct {
+	frame int64
+	pos   uint64
+}
+
+// at returns the source position at frame i, interpolated (or extrapolated)
+// along the line between the marks a and b.
+func (a posMark) at(b posMark, i int64) uint64 {
+	pa, pb := float64(a.pos), float64(b.pos)
+	p := pa + (pb-pa)*float64(i-a.frame)/float64(b.frame-a.frame)
+	if p < 0 {
+		return 0
+	}
+	return uint64(math.Floor(p + 0.5))
+}
+
+// Config returns the configuration that the reader was created with.
+func (f *FrameReader) Config() Config {
+	return f.config
+}
+
+// Read reads whole frames into b, which may hold at max b.Len() / channels
+// frames. The returned number of samples is always a multiple of the number
+// of channels.
+//
+// If b is too small to hold a single frame, Read returns zero and a nil error.
+// If the underlying reader hits EOS in the middle of a frame then the
+// incomplete frame is discarded and ErrUnexpectedEOS is returned.
+func (f *FrameReader) Read(b Slice) (n int, err error) {
+	max := b.Len() - b.Len()%f.channels
+	if max == 0 {
+		return 0, nil
+	}
+	f.mark()
+	n = f.rem.CopyTo(b)
+	f.rem = f.rem[:0]
+	for (n == 0 || n%f.channels != 0) && f.err == nil {
+		var m int
+		m, f.err = f.r.Read(b.Slice(n, max))
+		n += m
+	}
+	if partial := n % f.channels; partial != 0 {
+		n -= partial
+		f.rem = f.rem[:partial]
+		b.Slice(n, n+partial).CopyTo(f.rem)
+	}
+	if n > 0 {
+		// Report the error, if any, with the next read.
+		f.read += uint64(n)
+		f.mark()
+		return n, nil
+	}
+	err = f.err
+	if err == EOS && len(f.rem) > 0 {
+		f.rem = f.rem[:0]
+		err = ErrUnexpectedEOS
+	}
+	return n, err
+}
+
+// SourcePosition implements the Positioner interface. If the underlying
+// reader is not a Positioner, it is treated as the source and the position is
+// the number of samples read from the FrameReader.
+func (f *FrameReader) SourcePosition() uint64 {
+	p, ok := f.r.(Positioner)
+	if !ok {
+		return f.read
+	}
+	// The partial frame has been read from the underlying reader, but not yet
+	// returned.
+	pos := p.SourcePosition()
+	if rem := uint64(len(f.rem)); pos > rem {
+		return pos - rem
+	}
+	return 0
+}
+
+// mark records the current source position, such that it may later be
+// retrieved by SourcePositionAt. Positions which follow on linearly from the
+// previous ones (e.g. when the underlying reader is the source) extend the
+// last mark instead, so few marks are typically needed.
+func (f *FrameReader) mark() {
+	m := posMark{int64(f.read) / int64(f.channels), f.SourcePosition()}
+	n := len(f.marks)
+	switch {
+	case n > 0 && f.marks[n-1].frame == m.frame:
+		// E.g. the underlying reader was seeked since the last read.
+		f.marks[n-1] = m
+		return
+	case n > 1 && f.marks[n-1].pos >= f.marks[n-2].pos && m.pos >= f.marks[n-1].pos:
+		a, b := f.marks[n-2], f.marks[n-1]
+		if (b.pos-a.pos)*uint64(m.frame-b.frame) == (m.pos-b.pos)*uint64(b.frame-a.frame) {
+			f.marks[n-1] = m
+			return
+		}
+	}
+	if n == maxMarks {
+		n = copy(f.marks, f.marks[1:])
+		f.marks = f.marks[:n]
+	}
+	f.marks = append(f.marks, m)
+}
+
+// SourcePositionAt returns the source position (see the Positioner interface)
+// of the frame the given number of frames after the next frame to be read; if
+// negative, of a frame that has already been read. Readers which delay the
+// audio that they read use it to compensate for their latency in terms of the
+// source stream, whose sample rate and channels may differ from their own.
+//
+// The position of frames already read is as it was when they were read, for
+// up to the last 64 reads, and is extrapolated for frames not yet read.
+func (f *FrameReader) SourcePositionAt(frames int) uint64 {
+	cur := posMark{int64(f.read) / int64(f.channels), f.SourcePosition()}
+	if frames == 0 {
+		return cur.pos
+	}
+	i := cur.frame + int64(frames)
+	m := f.marks
+	n := len(m)
+	switch {
+	case n < 2:
+		// Assume that the source has the same layout.
+		p := int64(cur.pos) + int64(frames)*int64(f.channels)
+		if p < 0 {
+			return 0
+		}
+		return uint64(p)
+	case i > cur.frame:
+		// Not yet read; extrapolate from the current position at the rate of
+		// the latest read.
+		a, b := m[n-2], m[n-1]
+		if b.pos < a.pos {
+			return cur.pos
+		}
+		rate := float64(b.pos-a.pos) / float64(b.frame-a.frame)
+		return cur.pos + uint64(math.Floor(float64(frames)*rate+0.5))
+	}
+	j := 1
+	for j < n-1 && m[j].frame < i {
+		j++
+	}
+	return m[j-1].at(m[j], i)
+}
+
+// TotalLatency implements the Positioner interface. A FrameReader introduces
+// no latency of its own.
+func (f *FrameReader) TotalLatency() int {
+	return TotalLatency(f.r)
+}
+
+// ReadFrames is short-hand for Read, except the returned count is in frames
+// instead of samples.
+func (f *FrameReader) ReadFrames(b Slice) (frames int, err error) {
+	n, err := f.Read(b)
+	return n / f.channels, err
+}
+
+// NewFrameReader returns a new reader that reads only whole frames of audio
+// from r, whose samples are interleaved according to the given configuration.
+//
+// It panics if c.Channels is less than one.
+func NewFrameReader(r Reader, c Config) *FrameReader {
+	if c.Channels < 1 {
+		panic("audio.NewFrameReader: invalid channel count")
+	}
+	return &FrameReader{
+		r:        r,
+		config:   c,
+		channels: c.Channels,
+		rem:      make(F64Samples, 0, c.Channels),
+	}
+}
+
+// FrameWriter wraps a Writer such that only whole frames of audio are ever
+// written to it; i.e. the number of samples written to the underlying writer
+// is always a multiple of the number of channels. FrameWriters must be
+// allocated via the NewFrameWriter function.
+type FrameWriter struct {
+	w        Writer
+	channels int
+	rem      F64Samples // partial frame waiting to be completed
+}
+
+// Write writes the samples in b to the underlying writer. Any trailing
+// partial frame is held back until a subsequent Write completes it.
+//
+// The returned count includes held back samples, such that on success it is
+// always b.Len().
+func (f *FrameWriter) Write(b Slice) (n int, err error) {
+	if len(f.rem) > 0 {
+		// Complete the pending partial frame first.
+		m := b.CopyTo(f.rem[len(f.rem):f.channels])
+		f.rem = f.rem[:len(f.rem)+m]
+		n += m
+		if len(f.rem) < f.channels {
+			return n, nil
+		}
+		if _, err = f.w.Write(f.rem); err != nil {
+			return n, err
+		}
+		f.rem = f.rem[:0]
+	}
+
+	whole := n + (b.Len()-n)/f.channels*f.channels
+	if whole > n {
+		var m int
+		m, err = f.w.Write(b.Slice(n, whole))
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+
+	// Hold back the trailing partial frame.
+	f.rem = f.rem[:b.Len()-whole]
+	n += b.Slice(whole, b.Len()).CopyTo(f.rem)
+	return n, nil
+}
+
+// Buffered returns the number of samples of the partial frame that is held
+// back waiting to be completed by a subsequent Write.
+func (f *FrameWriter) Buffered() int {
+	return len(f.rem)
+}
+
+// NewFrameWriter returns a new writer that writes only whole frames of audio
+// to w, whose samples are interleaved according to the given configuration.
+//
+// It panics if c.Channels is less than one.
+func NewFrameWriter(w Writer, c Config) *FrameWriter {
+	if c.Channels < 1 {
+		panic("audio.NewFrameWriter: invalid channel count")
+	}
+	return &FrameWriter{
+		w:        w,
+		channels: c.Channels,
+		rem:      make(F64Samples, 0, c.Channels),
+	}
+}
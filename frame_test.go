@@ -0,0 +1,219 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audio
+
+import "testing"
+
+// oddReader reads at most max samples per Read call from r.
+type oddReader struct {
+	r   Reader
+	max int
+}
+
+func (o *oddReader) Read(b Slice) (int, error) {
+	if b.Len() > o.max {
+		b = b.Slice(0, o.max)
+	}
+	return o.r.Read(b)
+}
+
+func rampSamples(n int) F64Samples {
+	s := make(F64Samples, n)
+	for i := range s {
+		s[i] = F64(i)
+	}
+	return s
+}
+
+func TestFrameReader(t *testing.T) {
+	src := NewBuffer(rampSamples(30))
+	fr := NewFrameReader(&oddReader{src, 5}, Config{Channels: 3})
+	buf := make(F64Samples, 8)
+	var got F64Samples
+	for {
+		n, err := fr.Read(buf)
+		if n%3 != 0 {
+			t.Fatalf("Read returned %d samples, not a whole number of frames", n)
+		}
+		got = append(got, buf[:n]...)
+		if err == EOS {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, s := range got {
+		if s != F64(i) {
+			t.Fatalf("sample %d: got %v", i, s)
+		}
+	}
+	if len(got) != 30 {
+		t.Fatalf("got %d samples, want 30", len(got))
+	}
+}
+
+// oddPositioner is an oddReader of a buffer, which reports the source
+// position of the buffer.
+type oddPositioner struct {
+	*Buffer
+	max int
+}
+
+func (o *oddPositioner) Read(b Slice) (int, error) {
+	if b.Len() > o.max {
+		b = b.Slice(0, o.max)
+	}
+	return o.Buffer.Read(b)
+}
+
+// doubler is a source whose position advances by two samples for each one
+// that is read, as if it were resampled to half its rate.
+type doubler struct {
+	pos uint64
+}
+
+func (d *doubler) Read(b Slice) (int, error) {
+	d.pos += 2 * uint64(b.Len())
+	return b.Len(), nil
+}
+
+func (d *doubler) SourcePosition() uint64 { return d.pos }
+func (d *doubler) TotalLatency() int      { return 7 }
+
+func TestFrameReaderSourcePosition(t *testing.T) {
+	src := NewBuffer(rampSamples(30))
+	src.Seek(3)
+	fr := NewFrameReader(&oddPositioner{src, 5}, Config{Channels: 3})
+	fr.Read(make(F64Samples, 12))
+	if p := fr.SourcePosition(); p != 15 {
+		t.Fatalf("SourcePosition got %d, want 15", p)
+	}
+
+	// Readers which are not Positioners are treated as the source.
+	fr = NewFrameReader(&oddReader{NewBuffer(rampSamples(30)), 5}, Config{Channels: 3})
+	fr.Read(make(F64Samples, 12))
+	if p, l := fr.SourcePosition(), fr.TotalLatency(); p != 12 || l != 0 {
+		t.Fatalf("SourcePosition, TotalLatency got (%d, %d), want (12, 0)", p, l)
+	}
+}
+
+func TestFrameReaderSourcePositionAt(t *testing.T) {
+	fr := NewFrameReader(NewBuffer(rampSamples(100)), Config{Channels: 2})
+	buf := make(F64Samples, 8)
+	for i := 0; i < 3; i++ {
+		fr.Read(buf)
+	}
+	tests := []struct {
+		frames int
+		want   uint64
+	}{
+		{0, 24},
+		{-5, 14},
+		{-12, 0},
+		{-20, 0},
+		{3, 30},
+	}
+	for _, tst := range tests {
+		if p := fr.SourcePositionAt(tst.frames); p != tst.want {
+			t.Errorf("SourcePositionAt(%d) got %d, want %d", tst.frames, p, tst.want)
+		}
+	}
+
+	// The position of a source at a different rate is interpolated.
+	fr = NewFrameReader(&doubler{}, Config{Channels: 2})
+	for i := 0; i < 3; i++ {
+		fr.Read(buf)
+	}
+	if p := fr.SourcePositionAt(-5); p != 28 {
+		t.Errorf("SourcePositionAt(-5) got %d, want 28", p)
+	}
+	if p := fr.SourcePositionAt(2); p != 56 {
+		t.Errorf("SourcePositionAt(2) got %d, want 56", p)
+	}
+	if l := fr.TotalLatency(); l != 7 {
+		t.Errorf("TotalLatency got %d, want 7", l)
+	}
+}
+
+func TestFrameReaderUnexpectedEOS(t *testing.T) {
+	fr := NewFrameReader(NewBuffer(rampSamples(7)), Config{Channels: 2})
+	buf := make(F64Samples, 16)
+	if n, err := fr.Read(buf); n != 6 || err != nil {
+		t.Fatalf("Read got (%d, %v), want (6, nil)", n, err)
+	}
+	if _, err := fr.Read(buf); err != ErrUnexpectedEOS {
+		t.Fatalf("Read got %v, want ErrUnexpectedEOS", err)
+	}
+}
+
+func TestFrameWriter(t *testing.T) {
+	dst := NewBuffer(F64Samples{})
+	fw := NewFrameWriter(dst, Config{Channels: 2})
+	src := rampSamples(9)
+	for _, chunk := range [][2]int{{0, 1}, {1, 4}, {4, 9}} {
+		n, err := fw.Write(src[chunk[0]:chunk[1]])
+		if n != chunk[1]-chunk[0] || err != nil {
+			t.Fatalf("Write got (%d, %v)", n, err)
+		}
+		if dst.Len()%2 != 0 {
+			t.Fatalf("partial frame written: %d samples", dst.Len())
+		}
+	}
+	if dst.Len() != 8 || fw.Buffered() != 1 {
+		t.Fatalf("got %d written and %d buffered, want 8 and 1", dst.Len(), fw.Buffered())
+	}
+}
+
+func TestFrameView(t *testing.T) {
+	v := FrameView{Slice: rampSamples(7), Channels: 2}
+	if v.Len() != 3 {
+		t.Fatalf("Len got %d, want 3", v.Len())
+	}
+	if s := v.Channel(2, 1); s != 5 {
+		t.Fatalf("Channel(2, 1) got %v, want 5", s)
+	}
+	v.SetChannel(1, 0, 42)
+	if s := v.Frame(1).At(0); s != 42 {
+		t.Fatalf("Frame(1).At(0) got %v, want 42", s)
+	}
+	if s := v.Frames(1, 3).Channel(0, 0); s != 42 {
+		t.Fatalf("Frames(1, 3).Channel(0, 0) got %v, want 42", s)
+	}
+}
+
+func TestBufferReadFrames(t *testing.T) {
+	b := NewBuffer(rampSamples(7))
+	if b.LenFrames(2) != 3 {
+		t.Fatalf("LenFrames got %d, want 3", b.LenFrames(2))
+	}
+	buf := make(F64Samples, 5)
+	if frames, err := b.ReadFrames(buf, 2); frames != 2 || err != nil {
+		t.Fatalf("ReadFrames got (%d, %v), want (2, nil)", frames, err)
+	}
+	if frames, err := b.ReadFrames(buf, 2); frames != 1 || err != nil {
+		t.Fatalf("ReadFrames got (%d, %v), want (1, nil)", frames, err)
+	}
+	if _, err := b.ReadFrames(buf, 2); err != EOS {
+		t.Fatalf("ReadFrames got %v, want EOS", err)
+	}
+}
+
+func TestBufferInvalidChannels(t *testing.T) {
+	b := NewBuffer(rampSamples(4))
+	for name, f := range map[string]func(){
+		"LenFrames":  func() { b.LenFrames(0) },
+		"ReadFrames": func() { b.ReadFrames(make(F64Samples, 4), 0) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic for zero channels", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
@@ -0,0 +1,13 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package gen implements deterministic audio signal generators.
+//
+// Each generator is an audio.ReadSeeker producing a signal of a given audio
+// configuration, amplitude and duration: band-limited oscillators (sine,
+// square, triangle and saw), seeded white, pink and brown noise, linear and
+// exponential sine sweeps, impulses and silence. They are useful for test
+// signals and placeholder sounds, as the same parameters always produce the
+// same samples.
+package gen
@@ -0,0 +1,165 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gen
+
+import (
+	"time"
+
+	"azul3d.org/audio.v1"
+)
+
+// source is a generator of sample frames.
+type source interface {
+	// frame generates the sample frame at index n into f, which has a length
+	// of one sample per channel. It is called for consecutive frames, except
+	// after a call to seek.
+	frame(n int64, f []float64)
+
+	// seek prepares the source such that the next call to frame has the
+	// index n.
+	seek(n int64)
+}
+
+// Generator is a signal generator. It implements the audio.ReadSeeker
+// interface. Generators must be allocated via one of the New* functions of
+// this package (e.g. NewSine).
+type Generator struct {
+	config audio.Config
+	amp    float64
+	frames int64 // length in frames, or -1 if infinite
+	src    source
+
+	pos   int64     // index of the next sample frame
+	cur   []float64 // current sample frame
+	ch    int       // channel of the next sample within cur, if non-zero
+	ready bool      // whether src is prepared for frame pos
+}
+
+// Config returns the audio configuration of the generated stream.
+func (g *Generator) Config() audio.Config {
+	return g.config
+}
+
+// Duration returns the duration of the generated stream, or zero if it is
+// infinite.
+func (g *Generator) Duration() time.Duration {
+	if g.frames < 0 {
+		return 0
+	}
+	return time.Duration(g.frames) * time.Second / time.Duration(g.config.SampleRate)
+}
+
+// Read implements the audio.Reader interface. Once the duration of the
+// generator has been reached it returns EOS.
+func (g *Generator) Read(b audio.Slice) (n int, err error) {
+	if !g.ready {
+		g.src.seek(g.pos)
+		g.ready = true
+	}
+	for n < b.Len() {
+		if g.ch == 0 {
+			if g.frames >= 0 && g.pos >= g.frames {
+				return n, audio.EOS
+			}
+			g.src.frame(g.pos, g.cur)
+		}
+		b.Set(n, audio.F64(g.amp*g.cur[g.ch]))
+		n++
+		if g.ch++; g.ch == len(g.cur) {
+			g.ch = 0
+			g.pos++
+		}
+	}
+	return n, nil
+}
+
+// Seek implements the audio.ReadSeeker interface. Seeking to a sample beyond
+// the end of the stream returns EOS.
+//
+// Seeking is constant time for all generators except noise, which must
+// regenerate its random sequence up to the sample for it to be deterministic.
+func (g *Generator) Seek(sample uint64) error {
+	c := uint64(g.config.Channels)
+	if g.frames >= 0 && sample > uint64(g.frames)*c {
+		return audio.EOS
+	}
+	frame, ch := int64(sample/c), int(sample%c)
+	g.pos, g.ch, g.ready = frame, 0, false
+	if ch != 0 {
+		// Seeking into the middle of a frame; generate it now.
+		g.src.seek(frame)
+		g.src.frame(frame, g.cur)
+		g.ch, g.ready = ch, true
+	}
+	return nil
+}
+
+// SourcePosition implements the audio.Positioner interface. It returns the
+// number of the sample that will be read next.
+func (g *Generator) SourcePosition() uint64 {
+	return uint64(g.pos)*uint64(g.config.Channels) + uint64(g.ch)
+}
+
+// TotalLatency implements the audio.Positioner interface. It always returns
+// zero.
+func (g *Generator) TotalLatency() int {
+	return 0
+}
+
+// newGenerator returns a new generator of the given source.
+func newGenerator(c audio.Config, amp float64, d time.Duration, src source) *Generator {
+	if c.SampleRate < 1 || c.Channels < 1 {
+		panic("gen: invalid audio configuration")
+	}
+	frames := int64(-1)
+	if d > 0 {
+		frames = int64(d.Seconds()*float64(c.SampleRate) + 0.5)
+	}
+	return &Generator{
+		config: c,
+		amp:    amp,
+		frames: frames,
+		src:    src,
+		cur:    make([]float64, c.Channels),
+	}
+}
+
+// mono is a stateless source producing the same value on all channels.
+type mono func(n int64) float64
+
+func (m mono) frame(n int64, f []float64) {
+	v := m(n)
+	for ch := range f {
+		f[ch] = v
+	}
+}
+
+func (m mono) seek(n int64) {}
+
+// NewSilence returns a new generator of silence with the given duration, or
+// an infinite duration if zero.
+func NewSilence(c audio.Config, d time.Duration) *Generator {
+	return newGenerator(c, 0, d, mono(func(n int64) float64 { return 0 }))
+}
+
+// NewImpulse returns a new generator of an impulse train: single sample
+// impulses of the given amplitude, repeating at the given frequency in Hz,
+// with the given duration, or an infinite duration if zero. If the frequency
+// is zero only a single impulse, at the start of the stream, is generated.
+func NewImpulse(c audio.Config, freq, amp float64, d time.Duration) *Generator {
+	period := int64(0)
+	if freq > 0 {
+		period = int64(float64(c.SampleRate)/freq + 0.5)
+		if period < 1 {
+			period = 1
+		}
+	}
+	return newGenerator(c, amp, d, mono(func(n int64) float64 {
+		if n == 0 || (period > 0 && n%period == 0) {
+			return 1
+		}
+		return 0
+	}))
+}
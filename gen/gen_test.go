@@ -0,0 +1,132 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gen
+
+import (
+	"testing"
+	"time"
+
+	"azul3d.org/audio.v1"
+)
+
+var (
+	mono48k   = audio.Config{SampleRate: 48000, Channels: 1}
+	stereo48k = audio.Config{SampleRate: 48000, Channels: 2}
+)
+
+// readAll reads the entire stream r.
+func readAll(t *testing.T, r audio.Reader) audio.F64Samples {
+	buf := audio.NewBuffer(make(audio.F64Samples, 0, 1024))
+	if _, err := audio.Copy(buf, r); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Samples().(audio.F64Samples)
+}
+
+// generators returns one of each generator type.
+func generators(c audio.Config, d time.Duration) map[string]*Generator {
+	return map[string]*Generator{
+		"Silence":     NewSilence(c, d),
+		"Impulse":     NewImpulse(c, 100, 1, d),
+		"Sine":        NewSine(c, 440, 0.5, d),
+		"Square":      NewSquare(c, 440, 0.5, d),
+		"Triangle":    NewTriangle(c, 440, 0.5, d),
+		"Saw":         NewSaw(c, 440, 0.5, d),
+		"WhiteNoise":  NewWhiteNoise(c, 0.5, 1, d),
+		"PinkNoise":   NewPinkNoise(c, 0.5, 1, d),
+		"BrownNoise":  NewBrownNoise(c, 0.5, 1, d),
+		"LinearSweep": NewLinearSweep(c, 20, 20000, 0.5, d),
+		"ExpSweep":    NewExpSweep(c, 20, 20000, 0.5, d),
+	}
+}
+
+func TestGeneratorDuration(t *testing.T) {
+	for name, g := range generators(stereo48k, 250*time.Millisecond) {
+		if got := len(readAll(t, g)); got != 12000*2 {
+			t.Errorf("%s: read %d samples, want %d", name, got, 12000*2)
+		}
+		if got := g.Duration(); got != 250*time.Millisecond {
+			t.Errorf("%s: Duration() = %v, want 250ms", name, got)
+		}
+	}
+}
+
+func TestGeneratorSeek(t *testing.T) {
+	for name, g := range generators(stereo48k, 100*time.Millisecond) {
+		all := readAll(t, g)
+		// Seek to a frame boundary, and into the middle of a frame.
+		for _, seek := range []int{0, 1000, 3001, len(all)} {
+			if err := g.Seek(uint64(seek)); err != nil {
+				t.Fatalf("%s: Seek(%d): %v", name, seek, err)
+			}
+			got := readAll(t, g)
+			if len(got) != len(all)-seek {
+				t.Fatalf("%s: Seek(%d): read %d samples, want %d", name, seek, len(got), len(all)-seek)
+			}
+			for i, v := range got {
+				if v != all[seek+i] {
+					t.Fatalf("%s: Seek(%d): sample %d = %v, want %v", name, seek, seek+i, v, all[seek+i])
+				}
+			}
+		}
+		if err := g.Seek(uint64(len(all) + 1)); err != audio.EOS {
+			t.Errorf("%s: Seek beyond the end: got %v, want EOS", name, err)
+		}
+	}
+}
+
+func TestGeneratorInfinite(t *testing.T) {
+	g := NewSine(mono48k, 440, 1, 0)
+	buf := make(audio.F64Samples, 4096)
+	for i := 0; i < 100; i++ {
+		if n, err := g.Read(buf); n != len(buf) || err != nil {
+			t.Fatalf("Read: got %d, %v", n, err)
+		}
+	}
+	if g.Duration() != 0 {
+		t.Errorf("Duration() = %v, want 0", g.Duration())
+	}
+}
+
+func TestSilence(t *testing.T) {
+	for i, v := range readAll(t, NewSilence(stereo48k, time.Second)) {
+		if v != 0 {
+			t.Fatalf("sample %d = %v, want 0", i, v)
+		}
+	}
+}
+
+func TestImpulse(t *testing.T) {
+	s := readAll(t, NewImpulse(stereo48k, 1000, 0.5, 10*time.Millisecond))
+	for i, v := range s {
+		want := audio.F64(0)
+		if (i/2)%48 == 0 {
+			want = 0.5
+		}
+		if v != want {
+			t.Fatalf("sample %d = %v, want %v", i, v, want)
+		}
+	}
+
+	// A single impulse.
+	s = readAll(t, NewImpulse(mono48k, 0, 1, 10*time.Millisecond))
+	for i, v := range s {
+		if (i == 0) != (v == 1) {
+			t.Fatalf("sample %d = %v", i, v)
+		}
+	}
+}
+
+func TestGeneratorSourcePosition(t *testing.T) {
+	g := NewSine(stereo48k, 440, 1, time.Second)
+	g.Read(make(audio.F64Samples, 5))
+	if p := g.SourcePosition(); p != 5 {
+		t.Fatalf("SourcePosition got %d, want 5", p)
+	}
+	g.Seek(3001)
+	if p := g.SourcePosition(); p != 3001 {
+		t.Fatalf("SourcePosition after Seek got %d, want 3001", p)
+	}
+}
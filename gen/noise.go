@@ -0,0 +1,99 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gen
+
+import (
+	"math/rand"
+	"time"
+
+	"azul3d.org/audio.v1"
+)
+
+// noiseColor represents a type of noise.
+type noiseColor uint8
+
+const (
+	white noiseColor = iota
+	pink
+	brown
+)
+
+// noiseState is the per-channel filter state of pink and brown noise.
+type noiseState [7]float64
+
+// noise is a seeded noise source. Each channel is independent.
+type noise struct {
+	color noiseColor
+	seed  int64
+	rng   *rand.Rand
+	state []noiseState
+}
+
+func (s *noise) frame(n int64, f []float64) {
+	for ch := range f {
+		w := s.rng.Float64()*2 - 1
+		b := &s.state[ch]
+		switch s.color {
+		case white:
+			f[ch] = w
+		case pink:
+			// Paul Kellet's refined pink noise filter.
+			b[0] = 0.99886*b[0] + w*0.0555179
+			b[1] = 0.99332*b[1] + w*0.0750759
+			b[2] = 0.96900*b[2] + w*0.1538520
+			b[3] = 0.86650*b[3] + w*0.3104856
+			b[4] = 0.55000*b[4] + w*0.5329522
+			b[5] = -0.7616*b[5] - w*0.0168980
+			f[ch] = (b[0] + b[1] + b[2] + b[3] + b[4] + b[5] + b[6] + w*0.5362) * 0.11
+			b[6] = w * 0.115926
+		case brown:
+			// Leaky integration of white noise.
+			b[0] = (b[0] + 0.02*w) / 1.02
+			f[ch] = b[0] * 3.5
+		}
+	}
+}
+
+func (s *noise) seek(n int64) {
+	s.rng = rand.New(rand.NewSource(s.seed))
+	for ch := range s.state {
+		s.state[ch] = noiseState{}
+	}
+	f := make([]float64, len(s.state))
+	for i := int64(0); i < n; i++ {
+		s.frame(i, f)
+	}
+}
+
+func newNoise(c audio.Config, color noiseColor, amp float64, seed int64, d time.Duration) *Generator {
+	return newGenerator(c, amp, d, &noise{
+		color: color,
+		seed:  seed,
+		state: make([]noiseState, c.Channels),
+	})
+}
+
+// NewWhiteNoise returns a new generator of uniformly distributed white noise
+// in the range of -amp to +amp, with the given duration, or an infinite
+// duration if zero. The same seed always generates the same noise.
+func NewWhiteNoise(c audio.Config, amp float64, seed int64, d time.Duration) *Generator {
+	return newNoise(c, white, amp, seed, d)
+}
+
+// NewPinkNoise returns a new generator of pink noise (with a -3 dB per octave
+// spectrum), approximately in the range of -amp to +amp, with the given
+// duration, or an infinite duration if zero. The same seed always generates
+// the same noise.
+func NewPinkNoise(c audio.Config, amp float64, seed int64, d time.Duration) *Generator {
+	return newNoise(c, pink, amp, seed, d)
+}
+
+// NewBrownNoise returns a new generator of brown noise (with a -6 dB per
+// octave spectrum), approximately in the range of -amp to +amp, with the given
+// duration, or an infinite duration if zero. The same seed always generates
+// the same noise.
+func NewBrownNoise(c audio.Config, amp float64, seed int64, d time.Duration) *Generator {
+	return newNoise(c, brown, amp, seed, d)
+}
@@ -0,0 +1,101 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gen
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+	"time"
+
+	"azul3d.org/audio.v1/spectral"
+)
+
+func TestNoiseDeterministic(t *testing.T) {
+	news := map[string]func(seed int64) *Generator{
+		"White": func(seed int64) *Generator { return NewWhiteNoise(stereo48k, 1, seed, time.Second) },
+		"Pink":  func(seed int64) *Generator { return NewPinkNoise(stereo48k, 1, seed, time.Second) },
+		"Brown": func(seed int64) *Generator { return NewBrownNoise(stereo48k, 1, seed, time.Second) },
+	}
+	for name, n := range news {
+		a, b, c := readAll(t, n(1)), readAll(t, n(1)), readAll(t, n(2))
+		var same, differ, channels int
+		for i := range a {
+			if a[i] == b[i] {
+				same++
+			}
+			if a[i] != c[i] {
+				differ++
+			}
+			if i%2 == 0 && a[i] != a[i+1] {
+				channels++
+			}
+		}
+		if same != len(a) {
+			t.Errorf("%s: same seed gave %d of %d equal samples", name, same, len(a))
+		}
+		if differ < len(a)*9/10 {
+			t.Errorf("%s: different seeds gave only %d of %d differing samples", name, differ, len(a))
+		}
+		if channels < len(a)/2*9/10 {
+			t.Errorf("%s: channels are not independent", name)
+		}
+		for i, v := range a {
+			if math.Abs(float64(v)) > 1.5 {
+				t.Fatalf("%s: sample %d = %v, out of range", name, i, v)
+			}
+		}
+	}
+}
+
+// octaveTilt returns the change in power, in dB, from the octave band of 2-4
+// kHz to the octave band of 4-8 kHz of the signal s, sampled at 48 kHz,
+// averaging the power spectra of consecutive blocks.
+func octaveTilt(s []float64) float64 {
+	const n = 4096
+	fft := spectral.NewFFT(n)
+	window := spectral.Hann(n)
+	seg := make([]float64, n)
+	spec := make([]complex128, n/2+1)
+	var low, high float64
+	for i := 0; i+n <= len(s); i += n {
+		for j := range seg {
+			seg[j] = s[i+j] * window[j]
+		}
+		fft.TransformReal(spec, seg)
+		for bin, v := range spec {
+			e := cmplx.Abs(v) * cmplx.Abs(v)
+			switch f := spectral.BinFrequency(bin, n, 48000); {
+			case f >= 2000 && f < 4000:
+				low += e
+			case f >= 4000 && f < 8000:
+				high += e
+			}
+		}
+	}
+	return 10 * math.Log10(high/low)
+}
+
+func TestNoiseSpectrum(t *testing.T) {
+	tests := []struct {
+		name string
+		g    *Generator
+		want float64
+	}{
+		{"White", NewWhiteNoise(mono48k, 1, 1, 10*time.Second), 3},
+		{"Pink", NewPinkNoise(mono48k, 1, 1, 10*time.Second), 0},
+		{"Brown", NewBrownNoise(mono48k, 1, 1, 10*time.Second), -3},
+	}
+	for _, tst := range tests {
+		s := readAll(t, tst.g)
+		buf := make([]float64, len(s))
+		for i, v := range s {
+			buf[i] = float64(v)
+		}
+		if got := octaveTilt(buf); math.Abs(got-tst.want) > 0.5 {
+			t.Errorf("%s: power changes %.2f dB per octave, want %v", tst.name, got, tst.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gen
+
+import (
+	"math"
+	"time"
+
+	"azul3d.org/audio.v1"
+)
+
+// polyBLEP returns the polynomial band-limited step residual for the phase t
+// (in the range [0, 1)) and phase increment dt, which smooths the
+// discontinuity of a step of 2 at phase zero.
+func polyBLEP(t, dt float64) float64 {
+	switch {
+	case t < dt:
+		t /= dt
+		return t + t - t*t - 1
+	case t > 1-dt:
+		t = (t - 1) / dt
+		return t*t + t + t + 1
+	}
+	return 0
+}
+
+// polyBLAMP returns the polynomial band-limited ramp residual for the phase t
+// (in the range [0, 1)) and phase increment dt, which smooths the
+// discontinuity of a change in slope of 2 (per sample) at phase zero.
+func polyBLAMP(t, dt float64) float64 {
+	switch {
+	case t < dt:
+		t = t/dt - 1
+		return -t * t * t / 3
+	case t > 1-dt:
+		t = (t-1)/dt + 1
+		return t * t * t / 3
+	}
+	return 0
+}
+
+// phase returns the phase, in the range [0, 1), of an oscillator of the given
+// frequency at frame n. It is computed directly from n, rather than
+// accumulated, such that seeking is exact.
+func phase(n int64, freq float64, sampleRate int) float64 {
+	_, t := math.Modf(float64(n) * freq / float64(sampleRate))
+	if t < 0 {
+		t++
+	}
+	return t
+}
+
+// wrap returns t wrapped into the range [0, 1).
+func wrap(t float64) float64 {
+	return t - math.Floor(t)
+}
+
+// oscillator returns a new generator for the waveform function w, which is
+// given the phase and phase increment of each frame.
+func oscillator(c audio.Config, freq, amp float64, d time.Duration, w func(t, dt float64) float64) *Generator {
+	dt := math.Abs(freq) / float64(c.SampleRate)
+	return newGenerator(c, amp, d, mono(func(n int64) float64 {
+		return w(phase(n, freq, c.SampleRate), dt)
+	}))
+}
+
+// NewSine returns a new sine wave generator of the given frequency in Hz,
+// amplitude and duration, or an infinite duration if zero.
+func NewSine(c audio.Config, freq, amp float64, d time.Duration) *Generator {
+	return oscillator(c, freq, amp, d, func(t, dt float64) float64 {
+		return math.Sin(2 * math.Pi * t)
+	})
+}
+
+// NewSquare returns a new band-limited square wave generator of the given
+// frequency in Hz, amplitude and duration, or an infinite duration if zero.
+func NewSquare(c audio.Config, freq, amp float64, d time.Duration) *Generator {
+	return oscillator(c, freq, amp, d, func(t, dt float64) float64 {
+		v := -1.0
+		if t < 0.5 {
+			v = 1
+		}
+		return v + polyBLEP(t, dt) - polyBLEP(wrap(t+0.5), dt)
+	})
+}
+
+// NewTriangle returns a new band-limited triangle wave generator of the given
+// frequency in Hz, amplitude and duration, or an infinite duration if zero.
+func NewTriangle(c audio.Config, freq, amp float64, d time.Duration) *Generator {
+	return oscillator(c, freq, amp, d, func(t, dt float64) float64 {
+		// The slope changes by -8 per period (-8*dt per sample) at the peak,
+		// and by +8 per period at the trough.
+		v := 4*math.Abs(t-0.5) - 1
+		return v - 4*dt*polyBLAMP(t, dt) + 4*dt*polyBLAMP(wrap(t+0.5), dt)
+	})
+}
+
+// NewSaw returns a new band-limited (rising) sawtooth wave generator of the
+// given frequency in Hz, amplitude and duration, or an infinite duration if
+// zero.
+func NewSaw(c audio.Config, freq, amp float64, d time.Duration) *Generator {
+	return oscillator(c, freq, amp, d, func(t, dt float64) float64 {
+		return 2*t - 1 - polyBLEP(t, dt)
+	})
+}
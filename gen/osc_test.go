@@ -0,0 +1,82 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gen
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+	"time"
+
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/spectral"
+)
+
+func TestSine(t *testing.T) {
+	s := readAll(t, NewSine(stereo48k, 1000, 0.5, time.Second))
+	for i := 0; i < len(s); i += 2 {
+		want := 0.5 * math.Sin(2*math.Pi*1000*float64(i/2)/48000)
+		if math.Abs(float64(s[i])-want) > 1e-9 || s[i+1] != s[i] {
+			t.Fatalf("frame %d = %v, %v, want %v", i/2, s[i], s[i+1], want)
+		}
+	}
+}
+
+// aliasing returns the fraction of the energy of the periodic signal s, whose
+// fundamental lies exactly on bin k of a transform of its length, that is not
+// harmonic (i.e. that is aliased).
+func aliasing(s []float64, k int) float64 {
+	spec := make([]complex128, len(s)/2+1)
+	spectral.NewFFT(len(s)).TransformReal(spec, s)
+	var total, aliased float64
+	for bin, v := range spec[1:] {
+		e := cmplx.Abs(v) * cmplx.Abs(v)
+		total += e
+		if (bin+1)%k != 0 {
+			aliased += e
+		}
+	}
+	return aliased / total
+}
+
+func TestBandLimited(t *testing.T) {
+	const (
+		n = 8192
+		k = 261 // fundamental bin, ~1529 Hz
+	)
+	freq := 48000.0 * k / n
+	naive := map[string]func(t float64) float64{
+		"Square": func(t float64) float64 {
+			if t < 0.5 {
+				return 1
+			}
+			return -1
+		},
+		"Triangle": func(t float64) float64 { return 4*math.Abs(t-0.5) - 1 },
+		"Saw":      func(t float64) float64 { return 2*t - 1 },
+	}
+	gens := map[string]*Generator{
+		"Square":   NewSquare(mono48k, freq, 1, 0),
+		"Triangle": NewTriangle(mono48k, freq, 1, 0),
+		"Saw":      NewSaw(mono48k, freq, 1, 0),
+	}
+	for name, g := range gens {
+		s := make(audio.F64Samples, n)
+		g.Read(s)
+		buf := make([]float64, n)
+		ref := make([]float64, n)
+		for i := range buf {
+			buf[i] = float64(s[i])
+			ref[i] = naive[name](phase(int64(i), freq, 48000))
+			if math.Abs(buf[i]) > 1.1 {
+				t.Fatalf("%s: sample %d = %v, out of range", name, i, buf[i])
+			}
+		}
+		got, want := aliasing(buf, k), aliasing(ref, k)
+		if 10*math.Log10(got/want) > -6 {
+			t.Errorf("%s: aliased energy %.2e, naive %.2e; want at least 6 dB less", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gen
+
+import (
+	"math"
+	"time"
+
+	"azul3d.org/audio.v1"
+)
+
+// sweep returns a new sine sweep generator, given the phase (in periods) at
+// time t seconds into the sweep.
+func sweep(c audio.Config, amp float64, d time.Duration, phase func(t float64) float64) *Generator {
+	if d <= 0 {
+		panic("gen: sweep duration must be positive")
+	}
+	return newGenerator(c, amp, d, mono(func(n int64) float64 {
+		_, p := math.Modf(phase(float64(n) / float64(c.SampleRate)))
+		return math.Sin(2 * math.Pi * p)
+	}))
+}
+
+// NewLinearSweep returns a new generator of a sine wave whose frequency
+// changes linearly from one frequency to another (in Hz) over the given
+// duration.
+//
+// It panics if the duration is not positive.
+func NewLinearSweep(c audio.Config, from, to, amp float64, d time.Duration) *Generator {
+	T := d.Seconds()
+	return sweep(c, amp, d, func(t float64) float64 {
+		return from*t + (to-from)*t*t/(2*T)
+	})
+}
+
+// NewExpSweep returns a new generator of a sine wave whose frequency changes
+// exponentially from one frequency to another (in Hz) over the given duration,
+// i.e. spending equal time in each octave. Such sweeps are commonly used to
+// measure impulse responses.
+//
+// It panics if the duration is not positive, or if either frequency is not
+// positive.
+func NewExpSweep(c audio.Config, from, to, amp float64, d time.Duration) *Generator {
+	if from <= 0 || to <= 0 {
+		panic("gen: exponential sweep frequencies must be positive")
+	}
+	T := d.Seconds()
+	if from == to {
+		return NewSine(c, from, amp, d)
+	}
+	k := math.Log(to / from)
+	return sweep(c, amp, d, func(t float64) float64 {
+		return from * T / k * (math.Exp(t*k/T) - 1)
+	})
+}
@@ -0,0 +1,50 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gen
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// frequency estimates the frequency of the mono signal s around frame n by
+// counting rising zero crossings in the window of ±w frames.
+func frequency(s []float64, n, w, sampleRate int) float64 {
+	var first, last, count int
+	for i := n - w + 1; i < n+w; i++ {
+		if s[i-1] < 0 && s[i] >= 0 {
+			if count == 0 {
+				first = i
+			}
+			last = i
+			count++
+		}
+	}
+	return float64(count-1) * float64(sampleRate) / float64(last-first)
+}
+
+func TestSweeps(t *testing.T) {
+	const d = 10 * time.Second
+	tests := []struct {
+		name string
+		g    *Generator
+		mid  float64 // frequency at the middle of the sweep
+	}{
+		{"Linear", NewLinearSweep(mono48k, 100, 2100, 1, d), 1100},
+		{"Exp", NewExpSweep(mono48k, 100, 1600, 1, d), 400},
+	}
+	for _, tst := range tests {
+		s := readAll(t, tst.g)
+		buf := make([]float64, len(s))
+		for i, v := range s {
+			buf[i] = float64(v)
+		}
+		n := len(buf) / 2
+		if got := frequency(buf, n, 2400, 48000); math.Abs(got-tst.mid)/tst.mid > 0.02 {
+			t.Errorf("%s: frequency at the middle %.1f Hz, want %v", tst.name, got, tst.mid)
+		}
+	}
+}
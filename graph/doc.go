@@ -0,0 +1,43 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package graph implements audio processing graphs.
+//
+// A Graph connects nodes, e.g. sources reading from an audio.Reader, effects
+// applying a dsp.Processor and buses mixing other nodes together, by their
+// ports. Each port has an audio configuration, and only ports of the same
+// configuration may be connected. The graph is itself an audio.Reader of its
+// output bus; reading it pulls audio through the nodes upstream of the
+// output, in blocks of a fixed size.
+//
+// Every input port sums all of the outputs connected to it, each by an Edge
+// of its own gain, and every output port may feed any number of inputs
+// while being computed only once per block. Sub-mixes, aux sends and returns
+// are then simply connections:
+//
+//	g := graph.NewGraph(c, 256)
+//	music := g.AddSource(musicStream, c)
+//	voices := g.AddBus(c)
+//	echo := g.AddEffect(dsp.NewEcho(nil, c, dsp.EchoParams{
+//		Delay:    300 * time.Millisecond,
+//		Feedback: 0.4,
+//		Wet:      1,
+//	}), c)
+//
+//	// A sub-mix bus of voices, at half volume.
+//	fader, _ := g.Connect(voices.Output(0), g.Output().Input(0))
+//	fader.SetGain(0.5)
+//
+//	// An aux send of the voices to the echo, and its return.
+//	send, _ := g.Connect(voices.Output(0), echo.Input(0))
+//	send.SetGain(0.3)
+//	g.Connect(echo.Output(0), g.Output().Input(0))
+//
+//	g.Connect(music.Output(0), g.Output().Input(0))
+//
+// Connections which would create a cycle are refused. The graph may be edited
+// from any goroutine while it is being read; changes take effect at the start
+// of the next block, with new connections faded in and removed ones faded out
+// (and gain changes ramped) over the block to avoid clicks.
+package graph
@@ -0,0 +1,343 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package graph
+
+import (
+	"errors"
+	"sync"
+
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/dsp"
+)
+
+// ErrCycle is returned by Connect when the connection would create a cycle.
+var ErrCycle = errors.New("graph: connection would create a cycle")
+
+// ErrMismatch is returned by Connect when the audio configurations of the
+// ports differ.
+var ErrMismatch = errors.New("graph: port audio configurations differ")
+
+// feed is an output mixed into an input for a single block, at a gain ramped
+// across the block.
+type feed struct {
+	src      audio.F64Samples
+	from, to float64
+}
+
+// step is the processing of a single node for a block.
+type step struct {
+	node  *Node
+	feeds [][]feed // per input port
+}
+
+// Graph is an audio processing graph. It implements the audio.Reader
+// interface, reading the audio of its output bus. Graphs must be allocated
+// via the NewGraph function.
+//
+// The output never ends: nodes whose streams have ended output silence.
+//
+// It is safe to add, connect and remove nodes from other goroutines while
+// audio is being read; changes take effect at the start of the next block.
+type Graph struct {
+	config audio.Config
+	block  int
+	output *Node
+
+	access sync.Mutex
+	dirty  bool // whether the schedule must be rebuilt
+	epoch  int  // incremented each time the schedule is rebuilt
+
+	// Used only while reading.
+	steps []step
+	pos   int // frames of the output block already read
+}
+
+// Config returns the audio configuration of the output of the graph.
+func (g *Graph) Config() audio.Config {
+	return g.config
+}
+
+// BlockSize returns the number of frames processed at once.
+func (g *Graph) BlockSize() int {
+	return g.block
+}
+
+// Output returns the output bus of the graph, whose input is read from the
+// graph.
+func (g *Graph) Output() *Node {
+	return g.output
+}
+
+// newPorts returns new ports of a node with the given configurations.
+func (g *Graph) newPorts(n *Node, configs []audio.Config, output bool) ([]*Port, []audio.F64Samples) {
+	ports := make([]*Port, len(configs))
+	bufs := make([]audio.F64Samples, len(configs))
+	for i, c := range configs {
+		if c.SampleRate != g.config.SampleRate || c.Channels < 1 {
+			panic("graph: invalid port audio configuration")
+		}
+		bufs[i] = make(audio.F64Samples, g.block*c.Channels)
+		ports[i] = &Port{node: n, index: i, output: output, config: c, buf: bufs[i]}
+	}
+	return ports, bufs
+}
+
+// Add adds a node to the graph which processes audio with p, with input and
+// output ports of the given audio configurations.
+//
+// It panics if any configuration does not have the sample rate of the graph,
+// or has no channels.
+func (g *Graph) Add(p Processor, inputs, outputs []audio.Config) *Node {
+	n := &Node{g: g, proc: p}
+	n.inputs, n.inBufs = g.newPorts(n, inputs, false)
+	n.outputs, n.outBufs = g.newPorts(n, outputs, true)
+	return n
+}
+
+// AddSource adds a node with a single output port, which reads the stream r
+// whose samples are laid out according to the given audio configuration.
+// Once the stream ends (see Node.Done) the node outputs silence.
+//
+// The stream is read only while the node is connected (directly or not) to
+// the output of the graph.
+func (g *Graph) AddSource(r audio.Reader, c audio.Config) *Node {
+	s := &source{r: audio.NewFrameReader(r, c)}
+	n := g.Add(s, nil, []audio.Config{c})
+	s.n = n
+	return n
+}
+
+// AddEffect adds a node with a single input and output port, which applies
+// the processor p to its input.
+func (g *Graph) AddEffect(p dsp.Processor, c audio.Config) *Node {
+	return g.Add(&effect{p: p, config: c}, []audio.Config{c}, []audio.Config{c})
+}
+
+// AddBus adds a node with a single input and output port, which outputs the
+// mix of all of the outputs connected to its input; e.g. a sub-mix or aux
+// bus.
+func (g *Graph) AddBus(c audio.Config) *Node {
+	return g.Add(bus{}, []audio.Config{c}, []audio.Config{c})
+}
+
+// reaches tells if the node to is downstream of the node from, following only
+// connected edges.
+func reaches(from, to *Node) bool {
+	if from == to {
+		return true
+	}
+	for _, p := range from.outputs {
+		for _, e := range p.edges {
+			if !e.removed && reaches(e.to.node, to) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Connect connects the output port from to the input port to, returning the
+// edge between them at a gain of one. If the ports are already connected,
+// the existing edge is returned.
+//
+// It returns ErrMismatch if the ports have different audio configurations,
+// and ErrCycle if the connection would create a cycle.
+//
+// It panics if from is not an output port or to is not an input port, if
+// they belong to different graphs, or if either node has been removed.
+func (g *Graph) Connect(from, to *Port) (*Edge, error) {
+	if !from.output || to.output {
+		panic("graph: must connect an output port to an input port")
+	}
+	if from.node.g != g || to.node.g != g {
+		panic("graph: port belongs to another graph")
+	}
+	if from.config != to.config {
+		return nil, ErrMismatch
+	}
+	g.access.Lock()
+	defer g.access.Unlock()
+	if from.node.removed || to.node.removed {
+		panic("graph: connect to a removed node")
+	}
+	for _, e := range from.edges {
+		if e.to == to && !e.removed {
+			return e, nil
+		}
+	}
+	if reaches(to.node, from.node) {
+		return nil, ErrCycle
+	}
+	e := &Edge{from: from, to: to, gain: 1, cur: 1}
+	if from.node.live && to.node.live {
+		// Both nodes are already playing, so fade the connection in.
+		e.cur = 0
+	}
+	from.edges = append(from.edges, e)
+	to.edges = append(to.edges, e)
+	g.dirty = true
+	return e, nil
+}
+
+// removeEdge removes the edge e from the edges of the port p.
+func removeEdge(p *Port, e *Edge) {
+	for i, pe := range p.edges {
+		if pe == e {
+			p.edges = append(p.edges[:i], p.edges[i+1:]...)
+			return
+		}
+	}
+}
+
+// disconnect removes the edge e. If the edge is currently being played it is
+// kept until it has been faded out. The lock must be held.
+func (g *Graph) disconnect(e *Edge) {
+	if e.removed {
+		return
+	}
+	e.removed = true
+	e.gain = 0
+	g.dirty = true
+	if e.to.node.visited != g.epoch {
+		removeEdge(e.from, e)
+		removeEdge(e.to, e)
+	}
+}
+
+// visit appends the node n, preceded by all of the nodes upstream of it in
+// topological order, to the schedule.
+func (g *Graph) visit(n *Node) {
+	if n.visited == g.epoch || n.visiting {
+		// Already scheduled, or (via an edge being faded out, which Connect
+		// does not consider) a cycle: the edge then reads the output of the
+		// previous block.
+		return
+	}
+	n.visiting = true
+	for _, p := range n.inputs {
+		for _, e := range p.edges {
+			g.visit(e.from.node)
+		}
+	}
+	n.visiting = false
+	n.visited = g.epoch
+	g.steps = append(g.steps, step{node: n})
+}
+
+// schedule rebuilds the schedule of nodes to process for the next block. The
+// lock must be held.
+func (g *Graph) schedule() {
+	g.epoch++
+	g.steps = g.steps[:0]
+	g.visit(g.output)
+	ramping := false
+	for i := range g.steps {
+		st := &g.steps[i]
+		n := st.node
+		n.live = true
+		st.feeds = make([][]feed, len(n.inputs))
+		for j, p := range n.inputs {
+			edges := p.edges[:0]
+			for _, e := range p.edges {
+				st.feeds[j] = append(st.feeds[j], feed{e.from.buf, e.cur, e.gain})
+				if e.cur != e.gain {
+					ramping = true
+				}
+				e.cur = e.gain
+				if e.removed {
+					// Faded out by this block.
+					removeEdge(e.from, e)
+					continue
+				}
+				edges = append(edges, e)
+			}
+			p.edges = edges
+		}
+	}
+	// Rebuild the schedule again once ramps have completed.
+	g.dirty = ramping
+}
+
+// process processes the next block.
+func (g *Graph) process() {
+	g.access.Lock()
+	if g.dirty {
+		g.schedule()
+	}
+	g.access.Unlock()
+
+	frames := g.block
+	for _, st := range g.steps {
+		n := st.node
+		for i, p := range n.inputs {
+			buf := n.inBufs[i]
+			for j := range buf {
+				buf[j] = 0
+			}
+			channels := p.config.Channels
+			for _, fd := range st.feeds[i] {
+				if fd.from == fd.to {
+					for j, x := range fd.src {
+						buf[j] += audio.F64(fd.to) * x
+					}
+					continue
+				}
+				for f := 0; f < frames; f++ {
+					gain := audio.F64(fd.from + (fd.to-fd.from)*float64(f+1)/float64(frames))
+					for ch := f * channels; ch < (f+1)*channels; ch++ {
+						buf[ch] += gain * fd.src[ch]
+					}
+				}
+			}
+		}
+		n.proc.Process(n.inBufs, n.outBufs)
+	}
+}
+
+// Read implements the audio.Reader interface. It always fills b with whole
+// frames of audio, and never returns EOS.
+func (g *Graph) Read(b audio.Slice) (n int, err error) {
+	channels := g.config.Channels
+	frames := b.Len() / channels
+	out := g.output.outBufs[0]
+	for f := 0; f < frames; {
+		if g.pos == g.block {
+			g.process()
+			g.pos = 0
+		}
+		k := g.block - g.pos
+		if k > frames-f {
+			k = frames - f
+		}
+		out[g.pos*channels : (g.pos+k)*channels].CopyTo(b.Slice(f*channels, (f+k)*channels))
+		g.pos += k
+		f += k
+	}
+	return frames * channels, nil
+}
+
+// NewGraph returns a new graph, with an output bus of the given audio
+// configuration, which processes blocks of the given size in frames (e.g.
+// 256).
+//
+// It panics if the block size is not positive, or the audio configuration is
+// invalid.
+func NewGraph(c audio.Config, blockSize int) *Graph {
+	if blockSize < 1 {
+		panic("graph: invalid block size")
+	}
+	if c.SampleRate < 1 || c.Channels < 1 {
+		panic("graph: invalid audio configuration")
+	}
+	g := &Graph{
+		config: c,
+		block:  blockSize,
+		dirty:  true,
+		epoch:  1, // never the epoch of a new node
+		pos:    blockSize,
+	}
+	g.output = g.AddBus(c)
+	return g
+}
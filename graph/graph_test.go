@@ -0,0 +1,218 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package graph
+
+import (
+	"math"
+	"sync"
+	"testing"
+
+	"azul3d.org/audio.v1"
+)
+
+var mono = audio.Config{SampleRate: 8000, Channels: 1}
+
+// constant returns an endless stream of the constant v.
+type constant float64
+
+func (c constant) Read(b audio.Slice) (n int, err error) {
+	for i := 0; i < b.Len(); i++ {
+		b.Set(i, audio.F64(c))
+	}
+	return b.Len(), nil
+}
+
+// counter counts the blocks it processes, and passes its input through.
+type counter struct {
+	blocks int
+}
+
+func (c *counter) Process(in, out []audio.F64Samples) {
+	c.blocks++
+	copy(out[0], in[0])
+}
+
+// read reads n frames from g.
+func read(t *testing.T, g *Graph, n int) audio.F64Samples {
+	b := make(audio.F64Samples, n*g.Config().Channels)
+	if got, err := g.Read(b); got != len(b) || err != nil {
+		t.Fatalf("Read() = %d, %v", got, err)
+	}
+	return b
+}
+
+func connect(t *testing.T, g *Graph, from, to *Port) *Edge {
+	e, err := g.Connect(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestGraphMix(t *testing.T) {
+	g := NewGraph(mono, 64)
+	a := g.AddSource(constant(0.25), mono)
+	b := g.AddSource(constant(0.5), mono)
+	connect(t, g, a.Output(0), g.Output().Input(0))
+	connect(t, g, b.Output(0), g.Output().Input(0)).SetGain(2)
+	for i, v := range read(t, g, 100) {
+		if v != 1.25 {
+			t.Fatalf("sample %d = %v, want 1.25", i, v)
+		}
+	}
+}
+
+func TestGraphFanOut(t *testing.T) {
+	g := NewGraph(mono, 32)
+	src := g.AddSource(constant(1), mono)
+	c := &counter{}
+	n := g.Add(c, []audio.Config{mono}, []audio.Config{mono})
+	connect(t, g, src.Output(0), n.Input(0))
+	// The node feeds the output both directly and through two buses.
+	for i := 0; i < 2; i++ {
+		b := g.AddBus(mono)
+		connect(t, g, n.Output(0), b.Input(0))
+		connect(t, g, b.Output(0), g.Output().Input(0))
+	}
+	connect(t, g, n.Output(0), g.Output().Input(0))
+	out := read(t, g, 32*10)
+	if c.blocks != 10 {
+		t.Errorf("processed %d blocks, want 10", c.blocks)
+	}
+	for i, v := range out {
+		if v != 3 {
+			t.Fatalf("sample %d = %v, want 3", i, v)
+		}
+	}
+}
+
+// affine applies x*mul + add to its input.
+type affine struct {
+	mul, add audio.F64
+}
+
+func (a affine) Process(s audio.Slice, c audio.Config) {
+	for i := 0; i < s.Len(); i++ {
+		s.Set(i, s.At(i)*a.mul+a.add)
+	}
+}
+
+func TestGraphOrder(t *testing.T) {
+	// Nodes are processed after the nodes upstream of them, regardless of
+	// the order they were added or connected in.
+	g := NewGraph(mono, 16)
+	add := g.AddEffect(affine{1, 1}, mono)
+	mul := g.AddEffect(affine{2, 0}, mono)
+	src := g.AddSource(constant(3), mono)
+	connect(t, g, add.Output(0), g.Output().Input(0))
+	connect(t, g, mul.Output(0), add.Input(0))
+	connect(t, g, src.Output(0), mul.Input(0))
+	for i, v := range read(t, g, 40) {
+		if v != 7 {
+			t.Fatalf("sample %d = %v, want 7", i, v)
+		}
+	}
+}
+
+func TestGraphConnectErrors(t *testing.T) {
+	g := NewGraph(mono, 16)
+	a, b, c := g.AddBus(mono), g.AddBus(mono), g.AddBus(mono)
+	connect(t, g, a.Output(0), b.Input(0))
+	connect(t, g, b.Output(0), c.Input(0))
+	if _, err := g.Connect(c.Output(0), a.Input(0)); err != ErrCycle {
+		t.Errorf("cycle: got %v, want ErrCycle", err)
+	}
+	if _, err := g.Connect(a.Output(0), a.Input(0)); err != ErrCycle {
+		t.Errorf("self loop: got %v, want ErrCycle", err)
+	}
+	stereo := g.AddBus(audio.Config{SampleRate: 8000, Channels: 2})
+	if _, err := g.Connect(a.Output(0), stereo.Input(0)); err != ErrMismatch {
+		t.Errorf("mismatch: got %v, want ErrMismatch", err)
+	}
+	e1 := connect(t, g, a.Output(0), c.Input(0))
+	if e2 := connect(t, g, a.Output(0), c.Input(0)); e1 != e2 {
+		t.Error("connecting twice returned a new edge")
+	}
+
+	// Once disconnected, the cycle is allowed.
+	g.Read(make(audio.F64Samples, 16))
+	for _, e := range c.Input(0).edges {
+		e.Disconnect()
+	}
+	connect(t, g, c.Output(0), a.Input(0))
+}
+
+func TestGraphRewire(t *testing.T) {
+	const block = 50
+	g := NewGraph(mono, block)
+	src := g.AddSource(constant(1), mono)
+	e := connect(t, g, src.Output(0), g.Output().Input(0))
+	for i, v := range read(t, g, block) {
+		if v != 1 {
+			t.Fatalf("sample %d = %v, want 1", i, v)
+		}
+	}
+
+	// Changes are ramped over a single block.
+	check := func(from, to float64) {
+		out := read(t, g, 2*block)
+		for i, v := range out {
+			want := to
+			if i < block {
+				want = from + (to-from)*float64(i+1)/block
+			}
+			if math.Abs(float64(v)-want) > 1e-12 {
+				t.Fatalf("sample %d = %v, want %v", i, v, want)
+			}
+		}
+	}
+	e.SetGain(0.5)
+	check(1, 0.5)
+	e.Disconnect()
+	check(0.5, 0)
+	if len(src.Output(0).edges) != 0 {
+		t.Error("disconnected edge was not removed")
+	}
+	e = connect(t, g, src.Output(0), g.Output().Input(0))
+	check(0, 1)
+	src.Remove()
+	check(1, 0)
+}
+
+func TestGraphConcurrent(t *testing.T) {
+	g := NewGraph(mono, 32)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	stop := make(chan struct{})
+	go func() {
+		defer wg.Done()
+		bus := g.AddBus(mono)
+		g.Connect(bus.Output(0), g.Output().Input(0))
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			src := g.AddSource(constant(0.5), mono)
+			e, _ := g.Connect(src.Output(0), bus.Input(0))
+			e.SetGain(float64(i%3) / 2)
+			if i%2 == 0 {
+				e.Disconnect()
+			} else {
+				src.Remove()
+			}
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		for _, v := range read(t, g, 20) {
+			if v < 0 || v > 0.5 {
+				t.Fatalf("sample out of range: %v", v)
+			}
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
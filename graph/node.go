@@ -0,0 +1,235 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package graph
+
+import (
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/dsp"
+)
+
+// Processor processes the audio of a node, one block at a time. Processors
+// are only used by the goroutine reading the graph.
+type Processor interface {
+	// Process processes a single block of audio. The samples of each input
+	// port (the sum of the outputs connected to it, or silence) are in, and
+	// the samples of each output port must be stored in out. Each holds the
+	// same number of frames, interleaved according to the audio
+	// configuration of its port.
+	Process(in, out []audio.F64Samples)
+}
+
+// Port is an input or output port of a node, carrying audio of a single audio
+// configuration.
+type Port struct {
+	node   *Node
+	index  int
+	output bool
+	config audio.Config
+	buf    audio.F64Samples // the samples of the current block
+
+	edges []*Edge // connected edges, including those fading out
+}
+
+// Node returns the node that the port belongs to.
+func (p *Port) Node() *Node {
+	return p.node
+}
+
+// Config returns the audio configuration of the port.
+func (p *Port) Config() audio.Config {
+	return p.config
+}
+
+// IsOutput tells if the port is an output port, rather than an input port.
+func (p *Port) IsOutput() bool {
+	return p.output
+}
+
+// Edge is a connection from an output port to an input port, with a linear
+// gain.
+type Edge struct {
+	from, to *Port
+	gain     float64 // the gain to apply, zero once removed
+	cur      float64 // the gain applied at the end of the last block
+	removed  bool
+}
+
+// From returns the output port that the edge connects from.
+func (e *Edge) From() *Port {
+	return e.from
+}
+
+// To returns the input port that the edge connects to.
+func (e *Edge) To() *Port {
+	return e.to
+}
+
+// Gain returns the linear gain of the edge.
+func (e *Edge) Gain() float64 {
+	g := e.from.node.g
+	g.access.Lock()
+	defer g.access.Unlock()
+	return e.gain
+}
+
+// SetGain sets the linear gain of the edge. Once the edge is playing, the
+// gain is ramped to the new value over the next block.
+func (e *Edge) SetGain(gain float64) {
+	g := e.from.node.g
+	g.access.Lock()
+	if !e.removed {
+		e.gain = gain
+		if !e.from.node.live || !e.to.node.live {
+			// Not yet playing, so there is nothing to ramp from.
+			e.cur = gain
+		}
+		g.dirty = true
+	}
+	g.access.Unlock()
+}
+
+// Disconnect removes the edge from the graph, fading it out over the next
+// block. It is a no-op if the edge was already removed.
+func (e *Edge) Disconnect() {
+	g := e.from.node.g
+	g.access.Lock()
+	g.disconnect(e)
+	g.access.Unlock()
+}
+
+// Node is a node of a graph, which processes the audio of its input ports
+// into its output ports. Nodes are created by the Add methods of a graph.
+type Node struct {
+	g        *Graph
+	proc     Processor
+	inputs   []*Port
+	outputs  []*Port
+	inBufs   []audio.F64Samples
+	outBufs  []audio.F64Samples
+	removed  bool
+	live     bool  // whether the node has been processed
+	done     bool  // whether the source of the node has ended
+	err      error // the error of the source of the node, if any
+	visiting bool  // used while scheduling the graph
+	visited  int   // the epoch of the schedule the node was last in
+}
+
+// Graph returns the graph that the node belongs to.
+func (n *Node) Graph() *Graph {
+	return n.g
+}
+
+// Processor returns the processor of the node.
+func (n *Node) Processor() Processor {
+	return n.proc
+}
+
+// NumInputs returns the number of input ports of the node.
+func (n *Node) NumInputs() int {
+	return len(n.inputs)
+}
+
+// NumOutputs returns the number of output ports of the node.
+func (n *Node) NumOutputs() int {
+	return len(n.outputs)
+}
+
+// Input returns the input port of the node with the given index.
+func (n *Node) Input(i int) *Port {
+	return n.inputs[i]
+}
+
+// Output returns the output port of the node with the given index.
+func (n *Node) Output(i int) *Port {
+	return n.outputs[i]
+}
+
+// Done tells if the node is a source whose stream has ended (or failed, see
+// Err), after which it outputs silence.
+func (n *Node) Done() bool {
+	n.g.access.Lock()
+	defer n.g.access.Unlock()
+	return n.done
+}
+
+// Err returns the error, other than EOS, which the stream of the node (if it
+// is a source) failed with, if any.
+func (n *Node) Err() error {
+	n.g.access.Lock()
+	defer n.g.access.Unlock()
+	return n.err
+}
+
+// Remove removes the node from its graph, fading out all of its connections
+// over the next block. It panics if the node is the output of the graph, and
+// is a no-op if it was already removed.
+func (n *Node) Remove() {
+	g := n.g
+	if n == g.output {
+		panic("graph: cannot remove the output node")
+	}
+	g.access.Lock()
+	defer g.access.Unlock()
+	if n.removed {
+		return
+	}
+	n.removed = true
+	for _, ports := range [2][]*Port{n.inputs, n.outputs} {
+		for _, p := range ports {
+			for _, e := range p.edges {
+				g.disconnect(e)
+			}
+		}
+	}
+}
+
+// source reads a stream into its output.
+type source struct {
+	n *Node
+	r *audio.FrameReader
+}
+
+func (s *source) Process(in, out []audio.F64Samples) {
+	b := out[0]
+	n := 0
+	s.n.g.access.Lock()
+	done := s.n.done
+	s.n.g.access.Unlock()
+	for !done && n < len(b) {
+		m, err := s.r.Read(b[n:])
+		n += m
+		if err != nil || m == 0 {
+			s.n.g.access.Lock()
+			if err != audio.EOS {
+				s.n.err = err
+			}
+			// A reader making no progress is treated as ended, rather than
+			// stalling the graph.
+			s.n.done, done = true, true
+			s.n.g.access.Unlock()
+		}
+	}
+	for i := n; i < len(b); i++ {
+		b[i] = 0
+	}
+}
+
+// effect applies a dsp.Processor to its input.
+type effect struct {
+	p      dsp.Processor
+	config audio.Config
+}
+
+func (e *effect) Process(in, out []audio.F64Samples) {
+	copy(out[0], in[0])
+	e.p.Process(out[0], e.config)
+}
+
+// bus passes its (summed) input through to its output.
+type bus struct{}
+
+func (bus) Process(in, out []audio.F64Samples) {
+	copy(out[0], in[0])
+}
@@ -0,0 +1,93 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package graph
+
+import (
+	"errors"
+	"testing"
+
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/dsp"
+)
+
+func TestSourceEnd(t *testing.T) {
+	g := NewGraph(mono, 16)
+	in := make(audio.F64Samples, 20)
+	for i := range in {
+		in[i] = 1
+	}
+	src := g.AddSource(audio.NewBuffer(in), mono)
+	connect(t, g, src.Output(0), g.Output().Input(0))
+	out := read(t, g, 40)
+	for i, v := range out {
+		want := audio.F64(0)
+		if i < 20 {
+			want = 1
+		}
+		if v != want {
+			t.Fatalf("sample %d = %v, want %v", i, v, want)
+		}
+	}
+	if !src.Done() || src.Err() != nil {
+		t.Errorf("Done() = %v, Err() = %v", src.Done(), src.Err())
+	}
+}
+
+type failing struct{}
+
+var errFailing = errors.New("failing")
+
+func (failing) Read(b audio.Slice) (n int, err error) {
+	return 0, errFailing
+}
+
+func TestSourceError(t *testing.T) {
+	g := NewGraph(mono, 16)
+	src := g.AddSource(failing{}, mono)
+	connect(t, g, src.Output(0), g.Output().Input(0))
+	read(t, g, 16)
+	if !src.Done() || src.Err() != errFailing {
+		t.Errorf("Done() = %v, Err() = %v", src.Done(), src.Err())
+	}
+}
+
+func TestSourceUnconnected(t *testing.T) {
+	// Sources are only read while they are connected to the output.
+	g := NewGraph(mono, 16)
+	in := make(audio.F64Samples, 100)
+	buf := audio.NewBuffer(in)
+	src := g.AddSource(buf, mono)
+	bus := g.AddBus(mono)
+	connect(t, g, src.Output(0), bus.Input(0))
+	read(t, g, 32)
+	if buf.Len() != 100 {
+		t.Errorf("unconnected source was read: %d samples left", buf.Len())
+	}
+}
+
+func TestAuxSend(t *testing.T) {
+	g := NewGraph(mono, 16)
+	src := g.AddSource(constant(1), mono)
+	// An aux bus with a -6 dB gain effect, fed by a send and returned to the
+	// output along with the dry signal.
+	aux := g.AddEffect(dsp.NewGain(nil, mono, -6.0206), mono)
+	connect(t, g, src.Output(0), g.Output().Input(0))
+	connect(t, g, src.Output(0), aux.Input(0)).SetGain(0.5)
+	connect(t, g, aux.Output(0), g.Output().Input(0))
+	for i, v := range read(t, g, 48) {
+		if want := 1.25; float64(v) < want-1e-4 || float64(v) > want+1e-4 {
+			t.Fatalf("sample %d = %v, want %v", i, v, want)
+		}
+	}
+}
+
+func TestRemoveOutput(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+	NewGraph(mono, 16).Output().Remove()
+}
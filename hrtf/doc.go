@@ -0,0 +1,21 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package hrtf implements binaural 3D audio for headphones using head-related
+// transfer functions (HRTFs).
+//
+// A Set holds head-related impulse responses (HRIRs) measured from a number of
+// directions around a listener, and interpolates between them for any other
+// direction. Sets may be loaded from AES69 SOFA files or from a simple
+// built-in format (see Load), or modelled on a spherical head (see
+// SphericalHead).
+//
+// A Panner renders the mono sources of a spatial.Mixer into a stereo stream
+// by convolving each with the HRIRs of its direction:
+//
+//	set, err := hrtf.Load(file)
+//	...
+//	mixer := spatial.NewMixer(audio.Config{SampleRate: 44100, Channels: 2})
+//	mixer.SetPanner(hrtf.NewPanner(set))
+package hrtf
@@ -0,0 +1,140 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hrtf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"math"
+
+	"azul3d.org/audio.v1"
+)
+
+// magic is the magic string of the built-in format.
+const magic = "HRIR"
+
+// maxMeasurements and maxLength limit the size of sets decoded from the
+// built-in format, such that corrupt data cannot cause huge allocations.
+const (
+	maxMeasurements = 1 << 16
+	maxLength       = 1 << 16
+)
+
+// Encode writes the set s to w in the built-in format, a simple
+// little-endian binary format:
+//
+//	magic        [4]byte  "HRIR"
+//	sampleRate   uint32
+//	measurements uint32
+//	length       uint32   the length of each impulse response
+//
+// Followed by each measurement:
+//
+//	azimuth      float32  in degrees (see Measurement)
+//	elevation    float32  in degrees
+//	left, right  [length]float32
+//
+// Impulse responses shorter than s.Len() are padded with zeros.
+func Encode(w io.Writer, s *Set) error {
+	ms := s.Measurements()
+	n := s.Len()
+	buf := make([]byte, 16, 16+len(ms)*(8+8*n))
+	copy(buf, magic)
+	le := binary.LittleEndian
+	le.PutUint32(buf[4:], uint32(s.SampleRate()))
+	le.PutUint32(buf[8:], uint32(len(ms)))
+	le.PutUint32(buf[12:], uint32(n))
+	put := func(v float64) {
+		var b [4]byte
+		le.PutUint32(b[:], math.Float32bits(float32(v)))
+		buf = append(buf, b[:]...)
+	}
+	for _, m := range ms {
+		put(m.Azimuth * 180 / math.Pi)
+		put(m.Elevation * 180 / math.Pi)
+		for _, ir := range [2][]float64{m.Left, m.Right} {
+			for i := 0; i < n; i++ {
+				var v float64
+				if i < len(ir) {
+					v = ir[i]
+				}
+				put(v)
+			}
+		}
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// Decode reads a set from r in the built-in format (see Encode).
+//
+// It returns audio.ErrFormat if the data is not in the built-in format, and
+// audio.ErrInvalidData if it is corrupt.
+func Decode(r io.Reader) (*Set, error) {
+	var hdr [16]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, audio.ErrFormat
+		}
+		return nil, err
+	}
+	if string(hdr[:4]) != magic {
+		return nil, audio.ErrFormat
+	}
+	le := binary.LittleEndian
+	sampleRate := le.Uint32(hdr[4:])
+	count := le.Uint32(hdr[8:])
+	n := le.Uint32(hdr[12:])
+	if sampleRate < 1 || sampleRate > math.MaxInt32 || count < 1 || count > maxMeasurements || n < 1 || n > maxLength {
+		return nil, audio.ErrInvalidData
+	}
+
+	ms := make([]Measurement, count)
+	buf := make([]byte, 8+8*n)
+	at := func(i int) float64 {
+		return float64(math.Float32frombits(le.Uint32(buf[i*4:])))
+	}
+	for i := range ms {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil, audio.ErrInvalidData
+			}
+			return nil, err
+		}
+		m := Measurement{
+			Azimuth:   at(0) * math.Pi / 180,
+			Elevation: at(1) * math.Pi / 180,
+			Left:      make([]float64, n),
+			Right:     make([]float64, n),
+		}
+		for j := range m.Left {
+			m.Left[j] = at(2 + j)
+			m.Right[j] = at(2 + int(n) + j)
+		}
+		ms[i] = m
+	}
+	return NewSet(int(sampleRate), ms), nil
+}
+
+// Load reads a set from r, either an AES69 SOFA file (see ReadSOFA) or the
+// built-in format (see Decode), detected by its magic string.
+//
+// It returns audio.ErrFormat if the data is in neither format.
+func Load(r io.Reader) (*Set, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, []byte(magic)) {
+		return Decode(bytes.NewReader(data))
+	}
+	ra := bytes.NewReader(data)
+	if _, ok := findSuperblock(ra, ra.Size()); ok {
+		return ReadSOFA(ra, ra.Size())
+	}
+	return nil, audio.ErrFormat
+}
@@ -0,0 +1,55 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hrtf
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"azul3d.org/audio.v1"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	s := NewSet(44100, testSet())
+	var buf bytes.Buffer
+	if err := Encode(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	for _, load := range []func() (*Set, error){
+		func() (*Set, error) { return Decode(bytes.NewReader(data)) },
+		func() (*Set, error) { return Load(bytes.NewReader(data)) },
+	} {
+		got, err := load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.SampleRate() != 44100 || got.Len() != s.Len() {
+			t.Fatalf("sample rate %d, length %d, want 44100, %d", got.SampleRate(), got.Len(), s.Len())
+		}
+		for i, m := range s.Measurements() {
+			g := got.Measurements()[i]
+			if math.Abs(g.Azimuth-m.Azimuth) > 1e-6 || math.Abs(g.Elevation-m.Elevation) > 1e-6 {
+				t.Errorf("measurement %d direction (%v, %v), want (%v, %v)", i, g.Azimuth, g.Elevation, m.Azimuth, m.Elevation)
+			}
+			for j := range m.Left {
+				if math.Abs(g.Left[j]-m.Left[j]) > 1e-6 || math.Abs(g.Right[j]-m.Right[j]) > 1e-6 {
+					t.Fatalf("measurement %d sample %d differs", i, j)
+				}
+			}
+		}
+	}
+
+	if _, err := Decode(bytes.NewReader(data[:len(data)-1])); err != audio.ErrInvalidData {
+		t.Errorf("truncated data: got %v, want audio.ErrInvalidData", err)
+	}
+	if _, err := Decode(bytes.NewReader([]byte("RIFF0000"))); err != audio.ErrFormat {
+		t.Errorf("wrong magic: got %v, want audio.ErrFormat", err)
+	}
+	if _, err := Load(bytes.NewReader([]byte("RIFF0000WAVE"))); err != audio.ErrFormat {
+		t.Errorf("Load of unknown format: got %v, want audio.ErrFormat", err)
+	}
+}
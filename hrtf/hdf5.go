@@ -0,0 +1,1101 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hrtf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/bits"
+
+	"azul3d.org/audio.v1"
+)
+
+// This file implements a reader for the subset of HDF5 used by SOFA files (as
+// written by netCDF-4 and the SOFA APIs): superblocks of versions 0 to 3,
+// object headers of versions 1 and 2, old-style (symbol table), compact and
+// dense groups, and numeric datasets with compact, contiguous or chunked
+// layouts (indexed by a B-tree, a single chunk, an implicit index or an
+// unpaged fixed array), optionally compressed by the deflate and shuffle
+// filters. Checksums are not verified.
+
+// signature is the signature of an HDF5 superblock.
+const signature = "\x89HDF\r\n\x1a\n"
+
+// maxDataSize limits the size of datasets read, such that corrupt files cannot
+// cause huge allocations.
+const maxDataSize = 1 << 28
+
+// Object header message types.
+const (
+	msgDataspace    = 0x01
+	msgLinkInfo     = 0x02
+	msgDatatype     = 0x03
+	msgLink         = 0x06
+	msgLayout       = 0x08
+	msgFilters      = 0x0b
+	msgAttribute    = 0x0c
+	msgContinuation = 0x10
+	msgSymbolTable  = 0x11
+)
+
+// Datatype classes.
+const (
+	classFixed    = 0
+	classFloat    = 1
+	classString   = 3
+	classVariable = 9
+)
+
+// Filters.
+const (
+	filterDeflate    = 1
+	filterShuffle    = 2
+	filterFletcher32 = 3
+)
+
+// h5error is an error panicked by the reader, and recovered at its API
+// boundary.
+type h5error struct {
+	err error
+}
+
+// fail aborts reading with the given error.
+func fail(err error) {
+	panic(h5error{err})
+}
+
+// h5file is an HDF5 file being read.
+type h5file struct {
+	r                io.ReaderAt
+	size             int64
+	base             uint64
+	offSize, lenSize int
+	root             uint64 // address of the root group's object header
+}
+
+// read reads n bytes at the address addr.
+func (f *h5file) read(addr uint64, n uint64) []byte {
+	a := f.base + addr
+	if a < f.base || a > uint64(f.size) || n > uint64(f.size)-a || n > maxDataSize {
+		fail(audio.ErrInvalidData)
+	}
+	b := make([]byte, n)
+	if k, err := f.r.ReadAt(b, int64(a)); k < len(b) {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		fail(err)
+	}
+	return b
+}
+
+// at returns a buffer of up to n bytes at the address addr, for structures
+// whose size is not known in advance.
+func (f *h5file) at(addr uint64, n uint64) *h5buf {
+	a := f.base + addr
+	if a < f.base || a > uint64(f.size) {
+		fail(audio.ErrInvalidData)
+	}
+	if max := uint64(f.size) - a; n > max {
+		n = max
+	}
+	return &h5buf{f: f, b: f.read(addr, n)}
+}
+
+// undefined tells if the address a is the undefined address.
+func (f *h5file) undefined(a uint64) bool {
+	return a == math.MaxUint64>>uint(64-8*f.offSize)
+}
+
+// h5buf reads the fields of a structure.
+type h5buf struct {
+	f *h5file
+	b []byte
+}
+
+func (b *h5buf) next(n uint64) []byte {
+	if n > uint64(len(b.b)) {
+		fail(audio.ErrInvalidData)
+	}
+	p := b.b[:n]
+	b.b = b.b[n:]
+	return p
+}
+
+func (b *h5buf) skip(n int)           { b.next(uint64(n)) }
+func (b *h5buf) u8() uint8            { return b.next(1)[0] }
+func (b *h5buf) u16() uint16          { return binary.LittleEndian.Uint16(b.next(2)) }
+func (b *h5buf) u32() uint32          { return binary.LittleEndian.Uint32(b.next(4)) }
+func (b *h5buf) offset() uint64       { return b.uint(b.f.offSize) }
+func (b *h5buf) length() uint64       { return b.uint(b.f.lenSize) }
+func (b *h5buf) signature(sig string) { b.expect(string(b.next(uint64(len(sig)))) == sig) }
+
+// uint reads a little-endian unsigned integer of n bytes.
+func (b *h5buf) uint(n int) uint64 {
+	if n > 8 {
+		fail(UnsupportedError("integer fields wider than 64 bits"))
+	}
+	var v uint64
+	for i, c := range b.next(uint64(n)) {
+		v |= uint64(c) << uint(8*i)
+	}
+	return v
+}
+
+// expect fails with audio.ErrInvalidData unless ok.
+func (b *h5buf) expect(ok bool) {
+	if !ok {
+		fail(audio.ErrInvalidData)
+	}
+}
+
+// findSuperblock returns the position of the superblock of the HDF5 file r,
+// which is at the start of the file or at any power of two multiple of 512
+// bytes.
+func findSuperblock(r io.ReaderAt, size int64) (int64, bool) {
+	var sig [len(signature)]byte
+	for at := int64(0); at+int64(len(sig)) <= size; {
+		if _, err := r.ReadAt(sig[:], at); err != nil {
+			return 0, false
+		}
+		if string(sig[:]) == signature {
+			return at, true
+		}
+		if at == 0 {
+			at = 512
+		} else {
+			at *= 2
+		}
+	}
+	return 0, false
+}
+
+// newH5File reads the superblock of the HDF5 file r.
+func newH5File(r io.ReaderAt, size int64) *h5file {
+	at, ok := findSuperblock(r, size)
+	if !ok {
+		fail(audio.ErrFormat)
+	}
+	f := &h5file{r: r, size: size, base: uint64(at), offSize: 8, lenSize: 8}
+	b := f.at(uint64(len(signature)), 256)
+	version := b.u8()
+	switch version {
+	case 0, 1:
+		b.skip(4) // free-space, root group and shared header versions
+		f.offSize, f.lenSize = int(b.u8()), int(b.u8())
+		b.skip(1 + 4 + 4) // group K values and flags
+		if version == 1 {
+			b.skip(4) // indexed storage K and reserved
+		}
+		b.expect(validSize(f.offSize) && validSize(f.lenSize))
+		base := b.offset()
+		b.skip(3 * f.offSize) // free-space, end of file and driver addresses
+
+		// The root group's symbol table entry.
+		b.offset()
+		f.root = b.offset()
+		f.base = base
+	case 2, 3:
+		f.offSize, f.lenSize = int(b.u8()), int(b.u8())
+		b.skip(1) // flags
+		b.expect(validSize(f.offSize) && validSize(f.lenSize))
+		base := b.offset()
+		b.skip(2 * f.offSize) // superblock extension and end of file addresses
+		f.root = b.offset()
+		f.base = base
+	default:
+		fail(UnsupportedError("superblock version"))
+	}
+	return f
+}
+
+// validSize tells if n is a valid size of offsets or lengths.
+func validSize(n int) bool {
+	return n == 2 || n == 4 || n == 8
+}
+
+// h5msg is a single object header message.
+type h5msg struct {
+	typ   int
+	flags uint8
+	data  []byte
+}
+
+// h5object is an object (a group or dataset) of the file.
+type h5object struct {
+	f    *h5file
+	msgs []h5msg
+}
+
+// object reads the header of the object at the address addr.
+func (f *h5file) object(addr uint64) *h5object {
+	type block struct {
+		addr, length uint64
+	}
+	o := &h5object{f: f}
+	var blocks []block
+	b := f.at(addr, 16)
+	v2 := bytes.HasPrefix(b.b, []byte("OHDR"))
+	creationOrder := false
+	if v2 {
+		b.skip(4)
+		b.expect(b.u8() == 2)
+		flags := b.u8()
+		creationOrder = flags&0x04 != 0
+		n := 6
+		if flags&0x20 != 0 {
+			n += 16 // times
+		}
+		if flags&0x10 != 0 {
+			n += 4 // attribute phase change values
+		}
+		n += 1 << (flags & 3)
+		b = f.at(addr+6, uint64(n-6))
+		if flags&0x20 != 0 {
+			b.skip(16)
+		}
+		if flags&0x10 != 0 {
+			b.skip(4)
+		}
+		blocks = append(blocks, block{addr + uint64(n), b.uint(1 << (flags & 3))})
+	} else {
+		b.expect(b.u8() == 1)
+		b.skip(1 + 2 + 4) // reserved, number of messages and reference count
+		blocks = append(blocks, block{addr + 16, uint64(b.u32())})
+	}
+
+	for i := 0; i < len(blocks); i++ {
+		if i > 1024 {
+			fail(audio.ErrInvalidData)
+		}
+		bl := blocks[i]
+		b := &h5buf{f: f, b: f.read(bl.addr, bl.length)}
+		hdr := 8
+		if v2 {
+			if i > 0 {
+				b.signature("OCHK")
+				b.expect(len(b.b) >= 4)
+				b.b = b.b[:len(b.b)-4] // checksum
+			}
+			hdr = 4
+			if creationOrder {
+				hdr = 6
+			}
+		}
+		for len(b.b) >= hdr {
+			var m h5msg
+			var size uint64
+			if v2 {
+				m.typ = int(b.u8())
+				size = uint64(b.u16())
+				m.flags = b.u8()
+				if creationOrder {
+					b.skip(2)
+				}
+			} else {
+				m.typ = int(b.u16())
+				size = uint64(b.u16())
+				m.flags = b.u8()
+				b.skip(3)
+			}
+			m.data = b.next(size)
+			if m.typ == msgContinuation {
+				c := &h5buf{f: f, b: m.data}
+				blocks = append(blocks, block{c.offset(), c.length()})
+			}
+			o.msgs = append(o.msgs, m)
+		}
+	}
+	return o
+}
+
+// message returns a buffer of the first message of the given type, or nil if
+// there is none.
+func (o *h5object) message(typ int) *h5buf {
+	for _, m := range o.msgs {
+		if m.typ == typ {
+			if m.flags&0x02 != 0 {
+				fail(UnsupportedError("shared object header messages"))
+			}
+			return &h5buf{f: o.f, b: m.data}
+		}
+	}
+	return nil
+}
+
+// links returns the addresses of the objects hard linked to by the group o,
+// by name.
+func (o *h5object) links() map[string]uint64 {
+	f := o.f
+	links := make(map[string]uint64)
+	for _, m := range o.msgs {
+		b := &h5buf{f: f, b: m.data}
+		switch m.typ {
+		case msgSymbolTable:
+			tree := b.offset()
+			heap := b.offset()
+			f.symbolTable(tree, f.localHeap(heap), links, 0)
+		case msgLink:
+			f.link(b, links)
+		case msgLinkInfo:
+			b.skip(1) // version
+			if b.u8()&0x01 != 0 {
+				b.skip(8) // maximum creation index
+			}
+			heap, index := b.offset(), b.offset()
+			if !f.undefined(heap) {
+				f.denseLinks(heap, index, links)
+			}
+		}
+	}
+	return links
+}
+
+// link parses a link message into links, if it is a hard link.
+func (f *h5file) link(b *h5buf, links map[string]uint64) {
+	b.expect(b.u8() == 1)
+	flags := b.u8()
+	typ := uint8(0)
+	if flags&0x08 != 0 {
+		typ = b.u8()
+	}
+	if flags&0x04 != 0 {
+		b.skip(8) // creation order
+	}
+	if flags&0x10 != 0 {
+		b.skip(1) // character set
+	}
+	name := string(b.next(b.uint(1 << (flags & 3))))
+	if typ == 0 {
+		links[name] = b.offset()
+	}
+}
+
+// localHeap returns the data segment of the local heap at the address addr.
+func (f *h5file) localHeap(addr uint64) []byte {
+	b := f.at(addr, uint64(8+2*f.lenSize+f.offSize))
+	b.signature("HEAP")
+	b.skip(4) // version and reserved
+	size := b.length()
+	b.length() // free list offset
+	return f.read(b.offset(), size)
+}
+
+// symbolTable parses the group B-tree node at the address addr, whose names
+// are stored in the local heap data, into links.
+func (f *h5file) symbolTable(addr uint64, heap []byte, links map[string]uint64, depth int) {
+	if depth > 64 {
+		fail(audio.ErrInvalidData)
+	}
+	b := f.at(addr, uint64(8+2*f.offSize))
+	b.signature("TREE")
+	b.expect(b.u8() == 0) // group node
+	level := b.u8()
+	entries := uint64(b.u16())
+	b = f.at(addr+uint64(8+2*f.offSize), entries*uint64(f.lenSize+f.offSize)+uint64(f.lenSize))
+	for i := uint64(0); i < entries; i++ {
+		b.length() // key
+		child := b.offset()
+		if level > 0 {
+			f.symbolTable(child, heap, links, depth+1)
+			continue
+		}
+
+		// A symbol table node.
+		n := f.at(child, 8)
+		n.signature("SNOD")
+		n.skip(2) // version and reserved
+		symbols := uint64(n.u16())
+		n = f.at(child+8, symbols*uint64(2*f.offSize+24))
+		for j := uint64(0); j < symbols; j++ {
+			name := n.offset()
+			obj := n.offset()
+			n.skip(24) // cache type, reserved and scratch-pad
+			b.expect(name < uint64(len(heap)))
+			s := heap[name:]
+			if k := bytes.IndexByte(s, 0); k >= 0 {
+				s = s[:k]
+			}
+			links[string(s)] = obj
+		}
+	}
+}
+
+// fractalHeap is a fractal heap, storing the link messages of a dense group.
+type fractalHeap struct {
+	f                     *h5file
+	width                 uint64
+	start, maxDirect      uint64
+	root                  uint64
+	rows                  uint64
+	offBytes, lenBytes    int
+	maxDirectRows         uint64
+	filtered, rootIsBlock bool
+}
+
+// newFractalHeap reads the header of the fractal heap at the address addr.
+func (f *h5file) newFractalHeap(addr uint64) *fractalHeap {
+	b := f.at(addr, 256)
+	b.signature("FRHP")
+	b.skip(1 + 2) // version and heap ID length
+	h := &fractalHeap{f: f}
+	h.filtered = b.u16() > 0
+	b.skip(1) // flags
+	maxManaged := uint64(b.u32())
+	b.skip(f.lenSize + f.offSize + f.lenSize + f.offSize + 8*f.lenSize)
+	h.width = uint64(b.u16())
+	h.start = b.length()
+	h.maxDirect = b.length()
+	maxHeapBits := int(b.u16())
+	b.skip(2) // starting number of rows
+	h.root = b.offset()
+	h.rows = uint64(b.u16())
+	b.expect(h.width > 0 && h.start > 0 && h.maxDirect >= h.start)
+
+	h.offBytes = (maxHeapBits + 7) / 8
+	m := h.maxDirect
+	if maxManaged < m {
+		m = maxManaged
+	}
+	h.lenBytes = (bits.Len64(m) + 7) / 8
+	h.maxDirectRows = uint64(bits.Len64(h.maxDirect)-bits.Len64(h.start)) + 2
+	return h
+}
+
+// object returns the object of the heap with the given heap ID.
+func (h *fractalHeap) object(id []byte) []byte {
+	b := &h5buf{f: h.f, b: id}
+	flags := b.u8()
+	switch (flags >> 4) & 3 {
+	case 0: // managed
+	case 2: // tiny
+		return b.next(uint64(flags&0x0f) + 1)
+	default:
+		fail(UnsupportedError("huge fractal heap objects"))
+	}
+	if h.filtered {
+		fail(UnsupportedError("filtered fractal heaps"))
+	}
+	offset := b.uint(h.offBytes)
+	length := b.uint(h.lenBytes)
+	if h.rows == 0 {
+		// The root is a direct block.
+		return h.f.read(h.root+offset, length)
+	}
+
+	// The root is an indirect block; find the direct block holding the
+	// object, by its row and column of the doubling table.
+	var start uint64
+	for row := uint64(0); row < h.rows; row++ {
+		size := h.start
+		if row > 1 {
+			size <<= row - 1
+		}
+		if offset >= start+size*h.width {
+			start += size * h.width
+			continue
+		}
+		if row >= h.maxDirectRows {
+			fail(UnsupportedError("nested fractal heap indirect blocks"))
+		}
+		col := (offset - start) / size
+		entry := uint64(4+1+h.f.offSize+h.offBytes) + (row*h.width+col)*uint64(h.f.offSize)
+		block := h.f.at(h.root+entry, uint64(h.f.offSize)).offset()
+		return h.f.read(block+offset-start-col*size, length)
+	}
+	fail(audio.ErrInvalidData)
+	return nil
+}
+
+// denseLinks parses the links of a dense group, stored in the fractal heap at
+// the address heap and indexed by name by the B-tree at the address index,
+// into links.
+func (f *h5file) denseLinks(heap, index uint64, links map[string]uint64) {
+	h := f.newFractalHeap(heap)
+	b := f.at(index, uint64(22+f.offSize+f.lenSize))
+	b.signature("BTHD")
+	b.skip(1) // version
+	typ := b.u8()
+	b.skip(4) // node size
+	size, depth := uint64(b.u16()), b.u16()
+	b.expect(typ == 5 && size > 4) // a link name index
+	if depth != 0 {
+		fail(UnsupportedError("deep link name indices"))
+	}
+	b.skip(2) // split and merge percentages
+	root := b.offset()
+	records := uint64(b.u16())
+	if records == 0 {
+		return
+	}
+	b = f.at(root, 6+records*size)
+	b.signature("BTLF")
+	b.skip(2) // version and type
+	for i := uint64(0); i < records; i++ {
+		r := &h5buf{f: f, b: b.next(size)}
+		r.skip(4) // name hash
+		f.link(&h5buf{f: f, b: h.object(r.b)}, links)
+	}
+}
+
+// h5type is a datatype.
+type h5type struct {
+	class             int
+	size              uint64
+	bigEndian, signed bool
+	vlenString        bool
+}
+
+// parseType parses a datatype message.
+func parseType(b *h5buf) h5type {
+	cv := b.u8()
+	flags := b.u8()
+	b.skip(2)
+	t := h5type{class: int(cv & 0x0f), size: uint64(b.u32())}
+	switch t.class {
+	case classFixed:
+		t.bigEndian = flags&0x01 != 0
+		t.signed = flags&0x08 != 0
+	case classFloat:
+		if flags&0x40 != 0 {
+			fail(UnsupportedError("VAX floating-point"))
+		}
+		t.bigEndian = flags&0x01 != 0
+	case classVariable:
+		t.vlenString = flags&0x0f == 1
+	}
+	return t
+}
+
+// order returns the byte order of the datatype.
+func (t h5type) order() binary.ByteOrder {
+	if t.bigEndian {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// floats converts the raw data of elements of the datatype to floats.
+func (t h5type) floats(raw []byte) []float64 {
+	if t.size == 0 {
+		fail(audio.ErrInvalidData)
+	}
+	o := t.order()
+	out := make([]float64, uint64(len(raw))/t.size)
+	for i := range out {
+		p := raw[uint64(i)*t.size:]
+		switch {
+		case t.class == classFloat && t.size == 4:
+			out[i] = float64(math.Float32frombits(o.Uint32(p)))
+		case t.class == classFloat && t.size == 8:
+			out[i] = math.Float64frombits(o.Uint64(p))
+		case t.class == classFixed && t.size == 1:
+			out[i] = float64(p[0])
+			if t.signed {
+				out[i] = float64(int8(p[0]))
+			}
+		case t.class == classFixed && t.size == 2:
+			out[i] = float64(o.Uint16(p))
+			if t.signed {
+				out[i] = float64(int16(o.Uint16(p)))
+			}
+		case t.class == classFixed && t.size == 4:
+			out[i] = float64(o.Uint32(p))
+			if t.signed {
+				out[i] = float64(int32(o.Uint32(p)))
+			}
+		case t.class == classFixed && t.size == 8:
+			out[i] = float64(o.Uint64(p))
+			if t.signed {
+				out[i] = float64(int64(o.Uint64(p)))
+			}
+		default:
+			fail(UnsupportedError("non-numeric datatype"))
+		}
+	}
+	return out
+}
+
+// parseSpace parses a dataspace message, returning its dimensions (none for
+// a scalar) and number of elements.
+func parseSpace(b *h5buf) (dims []uint64, n uint64) {
+	version := b.u8()
+	rank := int(b.u8())
+	b.skip(1) // flags
+	switch version {
+	case 1:
+		b.skip(5)
+	case 2:
+		if b.u8() == 2 {
+			// A null dataspace.
+			return nil, 0
+		}
+	default:
+		fail(UnsupportedError("dataspace version"))
+	}
+	n = 1
+	for i := 0; i < rank; i++ {
+		d := b.length()
+		if d != 0 && n > maxDataSize/d {
+			fail(audio.ErrInvalidData)
+		}
+		dims = append(dims, d)
+		n *= d
+	}
+	return dims, n
+}
+
+// attribute returns the value of the string attribute of the object with the
+// given name, if it is stored in the object's header.
+func (o *h5object) attribute(name string) (string, bool) {
+	for _, m := range o.msgs {
+		if m.typ != msgAttribute {
+			continue
+		}
+		b := &h5buf{f: o.f, b: m.data}
+		version := b.u8()
+		flags := b.u8()
+		nameSize := uint64(b.u16())
+		typeSize := uint64(b.u16())
+		spaceSize := uint64(b.u16())
+		pad := func(n uint64) uint64 { return n }
+		switch version {
+		case 1:
+			pad = func(n uint64) uint64 { return (n + 7) &^ 7 }
+		case 2:
+		case 3:
+			b.skip(1) // encoding
+		default:
+			fail(UnsupportedError("attribute version"))
+		}
+		n := string(bytes.TrimRight(b.next(pad(nameSize)), "\x00"))
+		if n != name {
+			continue
+		}
+		if flags&0x03 != 0 {
+			fail(UnsupportedError("shared attribute datatypes"))
+		}
+		t := parseType(&h5buf{f: o.f, b: b.next(pad(typeSize))})
+		_, count := parseSpace(&h5buf{f: o.f, b: b.next(pad(spaceSize))})
+		if count == 0 {
+			return "", true
+		}
+		switch {
+		case t.class == classString:
+			return string(bytes.TrimRight(b.next(t.size), "\x00 ")), true
+		case t.class == classVariable && t.vlenString:
+			length := uint64(b.u32())
+			return string(o.f.globalHeapObject(b.offset(), b.u32(), length)), true
+		}
+		return "", false
+	}
+	return "", false
+}
+
+// globalHeapObject returns the first length bytes of the object with the
+// given index of the global heap collection at the address addr.
+func (f *h5file) globalHeapObject(addr uint64, index uint32, length uint64) []byte {
+	b := f.at(addr, uint64(8+f.lenSize))
+	b.signature("GCOL")
+	b.skip(4) // version and reserved
+	size := b.length()
+	b = f.at(addr, size)
+	b.skip(8 + f.lenSize)
+	for len(b.b) >= 8+f.lenSize {
+		i := uint32(b.u16())
+		b.skip(6) // reference count and reserved
+		n := b.length()
+		data := b.next((n + 7) &^ 7)
+		if i == 0 {
+			break // free space
+		}
+		if i == index {
+			b.expect(length <= n)
+			return data[:length]
+		}
+	}
+	fail(audio.ErrInvalidData)
+	return nil
+}
+
+// filter is a single filter of a pipeline.
+type filter struct {
+	id     uint16
+	values []uint32
+}
+
+// parseFilters parses a filter pipeline message.
+func parseFilters(b *h5buf) []filter {
+	version := b.u8()
+	n := int(b.u8())
+	if version == 1 {
+		b.skip(6)
+	}
+	var fs []filter
+	for i := 0; i < n; i++ {
+		var f filter
+		f.id = b.u16()
+		var nameLen uint64
+		if version == 1 || f.id >= 256 {
+			nameLen = uint64(b.u16())
+		}
+		b.skip(2) // flags
+		values := int(b.u16())
+		if version == 1 {
+			nameLen = (nameLen + 7) &^ 7
+		}
+		b.next(nameLen)
+		for j := 0; j < values; j++ {
+			f.values = append(f.values, b.u32())
+		}
+		if version == 1 && values%2 == 1 {
+			b.skip(4)
+		}
+		fs = append(fs, f)
+	}
+	return fs
+}
+
+// unfilter reverses the filters of the pipeline fs, other than those masked,
+// on the raw data of a chunk whose elements are of the given size.
+func unfilter(raw []byte, fs []filter, mask uint32, size uint64) []byte {
+	for i := len(fs) - 1; i >= 0; i-- {
+		if mask&(1<<uint(i)) != 0 {
+			continue
+		}
+		switch fs[i].id {
+		case filterDeflate:
+			r, err := zlib.NewReader(bytes.NewReader(raw))
+			if err != nil {
+				fail(audio.ErrInvalidData)
+			}
+			out, err := ioutil.ReadAll(io.LimitReader(r, maxDataSize))
+			if err != nil {
+				fail(audio.ErrInvalidData)
+			}
+			raw = out
+		case filterShuffle:
+			// The bytes of the elements were grouped by their significance.
+			n := uint64(len(raw)) / size
+			out := make([]byte, len(raw))
+			for j := uint64(0); j < size; j++ {
+				for k := uint64(0); k < n; k++ {
+					out[k*size+j] = raw[j*n+k]
+				}
+			}
+			copy(out[n*size:], raw[n*size:])
+			raw = out
+		case filterFletcher32:
+			if len(raw) < 4 {
+				fail(audio.ErrInvalidData)
+			}
+			raw = raw[:len(raw)-4]
+		default:
+			fail(UnsupportedError("filters other than deflate and shuffle"))
+		}
+	}
+	return raw
+}
+
+// chunk is a single chunk of a chunked dataset.
+type chunk struct {
+	addr, size uint64
+	mask       uint32
+	offset     []uint64
+}
+
+// chunkTree appends the chunks indexed by the B-tree node at the address addr,
+// for a dataset of the given rank, to chunks.
+func (f *h5file) chunkTree(addr uint64, rank int, chunks []chunk, depth int) []chunk {
+	if depth > 64 {
+		fail(audio.ErrInvalidData)
+	}
+	b := f.at(addr, uint64(8+2*f.offSize))
+	b.signature("TREE")
+	b.expect(b.u8() == 1) // chunk node
+	level := b.u8()
+	entries := uint64(b.u16())
+	key := uint64(8 + 8*(rank+1))
+	b = f.at(addr+uint64(8+2*f.offSize), entries*(key+uint64(f.offSize))+key)
+	for i := uint64(0); i < entries; i++ {
+		c := chunk{size: uint64(b.u32()), mask: b.u32()}
+		for d := 0; d <= rank; d++ {
+			o := b.uint(8)
+			if d < rank {
+				c.offset = append(c.offset, o)
+			}
+		}
+		c.addr = b.offset()
+		if level > 0 {
+			chunks = f.chunkTree(c.addr, rank, chunks, depth+1)
+		} else {
+			chunks = append(chunks, c)
+		}
+	}
+	return chunks
+}
+
+// gridChunks returns the chunks of a dataset with the given dimensions and
+// chunk dimensions at the given addresses, stored in row-major order of their
+// position in the dataset.
+func gridChunks(dims, cdims []uint64, addrs []uint64, size uint64) []chunk {
+	var chunks []chunk
+	pos := make([]uint64, len(dims))
+	for _, addr := range addrs {
+		c := chunk{addr: addr, size: size, offset: make([]uint64, len(dims))}
+		for d := range dims {
+			c.offset[d] = pos[d] * cdims[d]
+		}
+		chunks = append(chunks, c)
+		for d := len(dims) - 1; d >= 0; d-- {
+			pos[d]++
+			if pos[d]*cdims[d] < dims[d] {
+				break
+			}
+			pos[d] = 0
+		}
+	}
+	return chunks
+}
+
+// copyChunk copies the elements of the given size of a chunk, with the chunk
+// dimensions cdims and at the given offset, into the data dst of a dataset
+// with the dimensions dims.
+func copyChunk(dst []byte, dims []uint64, src []byte, cdims, offset []uint64, size uint64) {
+	rank := len(dims)
+	if rank == 0 {
+		copy(dst, src[:size])
+		return
+	}
+	last := rank - 1
+	if offset[last] >= dims[last] {
+		return
+	}
+	run := cdims[last]
+	if r := dims[last] - offset[last]; r < run {
+		run = r
+	}
+	idx := make([]uint64, rank)
+	for {
+		var g, c uint64
+		inside := true
+		for d := 0; d < rank; d++ {
+			p := offset[d] + idx[d]
+			if p >= dims[d] {
+				inside = false
+			}
+			g = g*dims[d] + p
+			c = c*cdims[d] + idx[d]
+		}
+		if inside {
+			copy(dst[g*size:(g+run)*size], src[c*size:(c+run)*size])
+		}
+		d := last - 1
+		for ; d >= 0; d-- {
+			if idx[d]++; idx[d] < cdims[d] {
+				break
+			}
+			idx[d] = 0
+		}
+		if d < 0 {
+			return
+		}
+	}
+}
+
+// floats reads the numeric dataset o, returning its elements in row-major
+// order and its dimensions.
+func (o *h5object) floats() ([]float64, []uint64) {
+	f := o.f
+	space, typ, layout := o.message(msgDataspace), o.message(msgDatatype), o.message(msgLayout)
+	if space == nil || typ == nil || layout == nil {
+		fail(audio.ErrInvalidData)
+	}
+	dims, n := parseSpace(space)
+	t := parseType(typ)
+	if t.size == 0 || n > maxDataSize/t.size {
+		fail(audio.ErrInvalidData)
+	}
+	size := n * t.size
+	var fs []filter
+	if b := o.message(msgFilters); b != nil {
+		fs = parseFilters(b)
+	}
+
+	var (
+		raw    []byte
+		cdims  []uint64
+		chunks []chunk
+	)
+	version := layout.u8()
+	class := layout.u8()
+	if version < 3 {
+		// Versions 1 and 2 store the dimensionality before the class.
+		rank := int(class)
+		class = layout.u8()
+		layout.skip(5)
+		addr := uint64(0)
+		if class != 0 {
+			addr = layout.offset()
+		}
+		for i := 0; i < rank; i++ {
+			cdims = append(cdims, uint64(layout.u32()))
+		}
+		switch class {
+		case 0:
+			raw = layout.next(uint64(layout.u32()))
+		case 1:
+			raw = f.read(addr, size)
+		case 2:
+			if len(cdims) > len(dims) {
+				cdims = cdims[:len(dims)]
+			}
+			chunks = f.chunkTree(addr, len(cdims), nil, 0)
+		}
+	} else {
+		switch class {
+		case 0:
+			raw = layout.next(uint64(layout.u16()))
+		case 1:
+			addr := layout.offset()
+			if f.undefined(addr) {
+				raw = make([]byte, size)
+			} else {
+				raw = f.read(addr, size)
+			}
+		case 2:
+			flags := uint8(0)
+			if version == 4 {
+				flags = layout.u8()
+			}
+			rank := int(layout.u8())
+			enc := 4
+			if version == 4 {
+				enc = int(layout.u8())
+			}
+			var addr uint64
+			if version == 3 {
+				addr = layout.offset()
+			}
+			for i := 0; i < rank; i++ {
+				cdims = append(cdims, layout.uint(enc))
+			}
+			layout.expect(rank > 0)
+			cdims = cdims[:rank-1]
+			chunks = o.chunks(layout, version, flags, addr, dims, cdims, t.size)
+		default:
+			fail(UnsupportedError("virtual datasets"))
+		}
+	}
+
+	if raw == nil {
+		// Assemble the chunks.
+		if len(cdims) != len(dims) {
+			fail(audio.ErrInvalidData)
+		}
+		chunkSize := t.size
+		for _, d := range cdims {
+			if d == 0 || chunkSize > maxDataSize/d {
+				fail(audio.ErrInvalidData)
+			}
+			chunkSize *= d
+		}
+		raw = make([]byte, size)
+		for _, c := range chunks {
+			if f.undefined(c.addr) {
+				continue
+			}
+			data := unfilter(f.read(c.addr, c.size), fs, c.mask, t.size)
+			if uint64(len(data)) < chunkSize || len(c.offset) != len(dims) {
+				fail(audio.ErrInvalidData)
+			}
+			copyChunk(raw, dims, data, cdims, c.offset, t.size)
+		}
+	}
+	if uint64(len(raw)) < size {
+		fail(audio.ErrInvalidData)
+	}
+	return t.floats(raw[:size]), dims
+}
+
+// chunks returns the chunks of a chunked dataset, whose layout message
+// (version 3 or 4) has been read up to its chunk index.
+func (o *h5object) chunks(layout *h5buf, version, flags uint8, addr uint64, dims, cdims []uint64, size uint64) []chunk {
+	f := o.f
+	if len(cdims) != len(dims) {
+		fail(audio.ErrInvalidData)
+	}
+	if version == 3 {
+		return f.chunkTree(addr, len(cdims), nil, 0)
+	}
+	chunkSize := size
+	grid := uint64(1)
+	for i, d := range cdims {
+		if d == 0 {
+			fail(audio.ErrInvalidData)
+		}
+		chunkSize *= d
+		grid *= (dims[i] + d - 1) / d
+	}
+	switch layout.u8() {
+	case 1: // single chunk
+		c := chunk{size: chunkSize, offset: make([]uint64, len(cdims))}
+		if flags&0x02 != 0 {
+			c.size = layout.length()
+			c.mask = layout.u32()
+		}
+		c.addr = layout.offset()
+		return []chunk{c}
+	case 2: // implicit
+		addr := layout.offset()
+		addrs := make([]uint64, grid)
+		for i := range addrs {
+			addrs[i] = addr + uint64(i)*chunkSize
+		}
+		return gridChunks(dims, cdims, addrs, chunkSize)
+	case 3: // fixed array
+		layout.skip(1) // page bits
+		addr := layout.offset()
+		b := f.at(addr, uint64(12+f.lenSize+f.offSize))
+		b.signature("FAHD")
+		b.skip(1) // version
+		filtered := b.u8() == 1
+		entry := uint64(b.u8())
+		pageBits := b.u8()
+		entries := b.length()
+		block := b.offset()
+		if entries > 1<<pageBits {
+			fail(UnsupportedError("paged fixed array chunk indices"))
+		}
+		b.expect(entries <= grid && entry >= uint64(f.offSize))
+		b = f.at(block, uint64(6+f.offSize)+entries*entry)
+		b.signature("FADB")
+		b.skip(2) // version and client ID
+		b.offset()
+		var chunks []chunk
+		addrs := make([]uint64, entries)
+		for i := range addrs {
+			e := &h5buf{f: f, b: b.next(entry)}
+			addrs[i] = e.offset()
+			if filtered {
+				chunks = append(chunks, chunk{
+					size: e.uint(int(entry) - f.offSize - 4),
+					mask: e.u32(),
+				})
+			}
+		}
+		grid := gridChunks(dims, cdims, addrs, chunkSize)
+		for i := range chunks {
+			grid[i].size, grid[i].mask = chunks[i].size, chunks[i].mask
+		}
+		return grid
+	}
+	fail(UnsupportedError("extensible array and B-tree chunk indices"))
+	return nil
+}
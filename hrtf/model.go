@@ -0,0 +1,95 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hrtf
+
+import (
+	"math"
+
+	"azul3d.org/audio.v1/spatial"
+)
+
+// HeadRadius is the radius of an average human head in meters, as used by
+// SphericalHead.
+const HeadRadius = 0.0875
+
+// sphericalEar returns the impulse response of an ear of a spherical head,
+// for a sound arriving at the angle theta (in radians) from the ear's axis.
+func sphericalEar(theta float64, sampleRate, length int) []float64 {
+	const (
+		alphaMin = 0.1
+		thetaMin = 150 * math.Pi / 180
+	)
+	a, c := HeadRadius, spatial.SpeedOfSound
+
+	// The delay of the sound around the head (Woodworth's formula), offset
+	// such that it is never negative.
+	delay := a / c
+	if theta < math.Pi/2 {
+		delay -= a / c * math.Cos(theta)
+	} else {
+		delay += a / c * (theta - math.Pi/2)
+	}
+	delay *= float64(sampleRate)
+
+	// The head shadow, a one-pole one-zero shelving filter, via the bilinear
+	// transform:
+	//
+	//	H(s) = (1 + alpha*s/(2*w0)) / (1 + s/(2*w0)), w0 = c/a
+	alpha := 1 + alphaMin/2 + (1-alphaMin/2)*math.Cos(theta/thetaMin*math.Pi)
+	beta, k := 2*c/a, 2*float64(sampleRate)
+	b0 := (beta + alpha*k) / (beta + k)
+	b1 := (beta - alpha*k) / (beta + k)
+	a1 := (beta - k) / (beta + k)
+
+	// An impulse at the fractional delay, filtered.
+	ir := make([]float64, length)
+	d := int(delay)
+	frac := delay - float64(d)
+	var x1, y1 float64
+	for i := range ir {
+		var x float64
+		switch i {
+		case d:
+			x = 1 - frac
+		case d + 1:
+			x = frac
+		}
+		y := b0*x + b1*x1 - a1*y1
+		ir[i], x1, y1 = y, x, y
+	}
+	return ir
+}
+
+// SphericalHead returns a set of impulse responses at the given sample rate,
+// modelled on a rigid sphere of radius HeadRadius with ears at either side
+// (after Brown and Duda, 1998).
+//
+// The model reproduces the interaural time and level differences, and the
+// head's shadowing of high frequencies, but not the spectral cues of the
+// outer ear; it is useful where no measured set is available, and for
+// testing. Directions are measured every 15 degrees of azimuth and elevation,
+// from 45 degrees below the listener to directly above.
+func SphericalHead(sampleRate int) *Set {
+	length := int(math.Ceil(0.004 * float64(sampleRate)))
+	left, right := spatial.Vec3{X: -1}, spatial.Vec3{X: 1}
+	var ms []Measurement
+	for el := -45; el <= 90; el += 15 {
+		for az := -180; az < 180; az += 15 {
+			if el == 90 && az != 0 {
+				// Directly above, azimuth is meaningless.
+				continue
+			}
+			m := Measurement{
+				Azimuth:   float64(az) * math.Pi / 180,
+				Elevation: float64(el) * math.Pi / 180,
+			}
+			dir := m.direction()
+			m.Left = sphericalEar(angle(dir, left), sampleRate, length)
+			m.Right = sphericalEar(angle(dir, right), sampleRate, length)
+			ms = append(ms, m)
+		}
+	}
+	return NewSet(sampleRate, ms)
+}
@@ -0,0 +1,187 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hrtf
+
+import (
+	"sync"
+
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/spatial"
+	"azul3d.org/audio.v1/spectral"
+)
+
+// Panner is a spatial.Panner which renders binaural stereo audio for
+// headphones, by convolving each source with the pair of impulse responses
+// of its direction. Panners must be allocated via the NewPanner function.
+//
+// When a source moves, the responses of its old and new directions are
+// crossfaded over each block of audio mixed, to avoid clicks.
+type Panner struct {
+	set *Set
+
+	access sync.Mutex
+	sets   map[int]*Set // resampled sets by sample rate
+}
+
+// Set returns the set of impulse responses used by the panner.
+func (p *Panner) Set() *Set {
+	return p.set
+}
+
+// NewVoice implements the spatial.Panner interface. The impulse responses are
+// resampled to the sample rate of the stream if needed.
+//
+// It panics if the stream is not stereo.
+func (p *Panner) NewVoice(c audio.Config) spatial.Voice {
+	if c.Channels != 2 {
+		panic("hrtf: binaural stream must be stereo")
+	}
+	p.access.Lock()
+	s, ok := p.sets[c.SampleRate]
+	if !ok {
+		s = p.set.Resample(c.SampleRate)
+		p.sets[c.SampleRate] = s
+	}
+	p.access.Unlock()
+
+	n := s.Len()
+	v := &voice{set: s, hist: make([]float64, n-1)}
+	for i := range v.ir {
+		v.ir[i] = make([]float64, n)
+	}
+	v.resize(spatial.BlockSize)
+	return v
+}
+
+// voice renders a single source binaurally, using overlap-save convolution in
+// the frequency domain. Each block of input is transformed once, preceded by
+// enough history to convolve it, such that the voice has no latency.
+type voice struct {
+	set     *Set
+	hist    []float64 // the last s.Len()-1 input samples
+	ir      [2][]float64
+	dir     spatial.Vec3
+	gain    float64
+	started bool
+
+	// Convolution state, sized for the longest block so far (at least
+	// spatial.BlockSize frames); shorter blocks are processed within it.
+	fft       *spectral.FFT
+	seg       []float64       // time domain segment of the FFT size
+	spec, acc []complex128    // input and output spectra
+	cur, next [2][]complex128 // spectra of the impulse responses of each ear
+	y         [2][]float64    // output of the current and next responses
+}
+
+// resize grows the convolution state for blocks of the given number of
+// frames, if needed. It returns whether the state was reallocated, in which
+// case the spectra of the impulse responses must be recomputed.
+func (v *voice) resize(frames int) bool {
+	if v.fft != nil && frames+len(v.hist) <= v.fft.Len() {
+		return false
+	}
+	size := 1
+	for size < frames+len(v.hist) {
+		size *= 2
+	}
+	bins := size/2 + 1
+	v.fft = spectral.NewFFT(size)
+	v.seg = make([]float64, size)
+	v.spec = make([]complex128, bins)
+	v.acc = make([]complex128, bins)
+	for i := range v.cur {
+		v.cur[i] = make([]complex128, bins)
+		v.next[i] = make([]complex128, bins)
+		v.y[i] = make([]float64, size)
+	}
+	return true
+}
+
+// lookup stores the spectra of the impulse responses of the direction into
+// dst.
+func (v *voice) lookup(dir spatial.Vec3, dst [2][]complex128) {
+	v.set.Lookup(dir, v.ir[0], v.ir[1])
+	for ch, ir := range v.ir {
+		n := copy(v.seg, ir)
+		for i := n; i < len(v.seg); i++ {
+			v.seg[i] = 0
+		}
+		v.fft.TransformReal(dst[ch], v.seg)
+	}
+}
+
+// convolve stores the convolution of the input spectrum with the response h
+// into y.
+func (v *voice) convolve(y []float64, h []complex128) {
+	for i, x := range v.spec {
+		v.acc[i] = x * h[i]
+	}
+	v.fft.InverseReal(y, v.acc)
+}
+
+func (v *voice) Mix(out audio.Slice, in audio.F64Samples, dir spatial.Vec3, gain float64) {
+	frames := len(in)
+	if frames == 0 {
+		return
+	}
+	if !v.started {
+		// Start at the target direction and gain, rather than fading in.
+		v.dir, v.gain = dir, gain
+	}
+	if v.resize(frames) || !v.started {
+		v.lookup(v.dir, v.cur)
+		v.started = true
+	}
+	fade := dir != v.dir
+	if fade {
+		v.lookup(dir, v.next)
+	}
+
+	// The input, preceded by the history. Older samples in the segment (if
+	// any) only affect outputs which are discarded.
+	size, h := len(v.seg), len(v.hist)
+	start := size - frames // index of the first input sample
+	for i := 0; i < start-h; i++ {
+		v.seg[i] = 0
+	}
+	copy(v.seg[start-h:], v.hist)
+	for i, x := range in {
+		v.seg[start+i] = float64(x)
+	}
+	copy(v.hist, v.seg[size-h:])
+	v.fft.TransformReal(v.spec, v.seg)
+
+	for ch := 0; ch < 2; ch++ {
+		cur, next := v.y[0][start:], v.y[1][start:]
+		v.convolve(v.y[0], v.cur[ch])
+		if fade {
+			v.convolve(v.y[1], v.next[ch])
+		}
+		for f := 0; f < frames; f++ {
+			t := float64(f+1) / float64(frames)
+			g := v.gain + (gain-v.gain)*t
+			y := cur[f]
+			if fade {
+				y += (next[f] - y) * t
+			}
+			j := f*2 + ch
+			out.Set(j, out.At(j)+audio.F64(g*y))
+		}
+	}
+
+	if fade {
+		v.cur, v.next = v.next, v.cur
+	}
+	v.dir, v.gain = dir, gain
+}
+
+// NewPanner returns a new binaural panner using the given set of impulse
+// responses.
+func NewPanner(s *Set) *Panner {
+	return &Panner{
+		set:  s,
+		sets: map[int]*Set{s.SampleRate(): s},
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hrtf
+
+import (
+	"math"
+	"testing"
+
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/gen"
+	"azul3d.org/audio.v1/spatial"
+)
+
+// rms returns the root mean square of channel ch of the interleaved stereo
+// samples s, skipping the first skip frames.
+func rms(s audio.F64Samples, ch, skip int) float64 {
+	var sum float64
+	n := 0
+	for i := skip*2 + ch; i < len(s); i += 2 {
+		sum += float64(s[i]) * float64(s[i])
+		n++
+	}
+	return math.Sqrt(sum / float64(n))
+}
+
+// render renders one second of a sine wave source of the given frequency
+// through a binaural mixer, calling move (if not nil) before each block of
+// 256 frames.
+func render(t *testing.T, set *Set, freq float64, pos spatial.Vec3, move func(e *spatial.Emitter, f int)) audio.F64Samples {
+	c := audio.Config{SampleRate: 44100, Channels: 2}
+	src := audio.Config{SampleRate: 44100, Channels: 1}
+	m := spatial.NewMixer(c)
+	m.SetPanner(NewPanner(set))
+	m.SetDoppler(spatial.SpeedOfSound, 0)
+	e := spatial.DefaultEmitter
+	e.Position = pos
+	s := m.Add(gen.NewSine(src, freq, 0.5, 0), src, e)
+
+	out := make(audio.F64Samples, 44100*2)
+	for f := 0; f < 44100; f += 256 {
+		if move != nil {
+			move(&e, f)
+			s.SetEmitter(e)
+		}
+		end := f + 256
+		if end > 44100 {
+			end = 44100
+		}
+		if _, err := m.Read(out[f*2 : end*2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return out
+}
+
+func TestPanner(t *testing.T) {
+	set := SphericalHead(48000) // resampled by the panner
+
+	// A source to the left is louder in the left ear, at high frequencies
+	// which are shadowed by the head.
+	out := render(t, set, 4000, spatial.Vec3{X: -1}, nil)
+	if l, r := rms(out, 0, 1000), rms(out, 1, 1000); l < 1.5*r {
+		t.Errorf("source to the left: left %v, right %v", l, r)
+	}
+
+	// A source in front is heard equally by both ears.
+	out = render(t, set, 4000, spatial.Vec3{Y: 1}, nil)
+	if l, r := rms(out, 0, 1000), rms(out, 1, 1000); math.Abs(l-r) > 0.01*l || l < 0.1 {
+		t.Errorf("source in front: left %v, right %v", l, r)
+	}
+}
+
+func TestPannerMoving(t *testing.T) {
+	// A source circling the listener twice a second must not click: the
+	// largest difference between successive samples stays close to that of
+	// the sine wave itself.
+	set := SphericalHead(44100)
+	out := render(t, set, 500, spatial.Vec3{Y: 1}, func(e *spatial.Emitter, f int) {
+		a := 2 * 2 * math.Pi * float64(f) / 44100
+		e.Position = spatial.Vec3{X: math.Sin(a), Y: math.Cos(a)}
+	})
+	var maxStep float64
+	for i := 4; i < len(out); i++ {
+		maxStep = math.Max(maxStep, math.Abs(float64(out[i]-out[i-2])))
+	}
+	// A 500Hz sine of amplitude 0.5 changes by at most 0.036 per sample;
+	// allow for the gain of the head shadow.
+	if rms(out, 0, 0) < 0.1 {
+		t.Fatal("moving source is silent")
+	}
+	if maxStep > 0.1 {
+		t.Errorf("largest step between samples %v, want at most 0.1", maxStep)
+	}
+}
+
+func TestPannerChannels(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewVoice of a mono stream did not panic")
+		}
+	}()
+	NewPanner(SphericalHead(44100)).NewVoice(audio.Config{SampleRate: 44100, Channels: 1})
+}
+
+func TestPannerConvolution(t *testing.T) {
+	// The output of a static source is exactly the input convolved with the
+	// responses of its direction, whatever the sizes of the blocks mixed.
+	set := SphericalHead(44100)
+	c := audio.Config{SampleRate: 44100, Channels: 2}
+	dir := spatial.Vec3{X: -1, Y: 1}
+	left, right := make([]float64, set.Len()), make([]float64, set.Len())
+	set.Lookup(dir, left, right)
+
+	in := make(audio.F64Samples, 3000)
+	for i := range in {
+		in[i] = audio.F64(math.Sin(float64(i) * 0.3))
+	}
+	out := make(audio.F64Samples, len(in)*2)
+	v := NewPanner(set).NewVoice(c)
+	for i, size := 0, 1; i < len(in); size = size*3 + 1 {
+		end := i + size
+		if end > len(in) {
+			end = len(in)
+		}
+		v.Mix(out[i*2:end*2], in[i:end], dir, 1)
+		i = end
+	}
+	for f := range in {
+		for ch, ir := range [][]float64{left, right} {
+			var want float64
+			for k, h := range ir {
+				if f-k >= 0 {
+					want += h * float64(in[f-k])
+				}
+			}
+			if got := float64(out[f*2+ch]); math.Abs(got-want) > 1e-9 {
+				t.Fatalf("frame %d channel %d = %v, want %v", f, ch, got, want)
+			}
+		}
+	}
+}
+
+func TestPannerAllocs(t *testing.T) {
+	c := audio.Config{SampleRate: 44100, Channels: 2}
+	v := NewPanner(SphericalHead(44100)).NewVoice(c)
+	in := make(audio.F64Samples, 256)
+	var out audio.Slice = make(audio.F64Samples, 512)
+	a := 0.0
+	v.Mix(out, in, spatial.Vec3{Y: 1}, 1)
+	allocs := testing.AllocsPerRun(10, func() {
+		// A moving source, in blocks of varying length as the mixer splits
+		// a read of 300 frames.
+		a += 0.1
+		v.Mix(out, in, spatial.Vec3{X: math.Sin(a), Y: math.Cos(a)}, 1)
+		v.Mix(out, in[:44], spatial.Vec3{X: math.Sin(a), Y: math.Cos(a)}, 1)
+	})
+	if allocs != 0 {
+		t.Errorf("%v allocations per block, want none", allocs)
+	}
+}
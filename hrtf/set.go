@@ -0,0 +1,270 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hrtf
+
+import (
+	"math"
+
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/dsp"
+	"azul3d.org/audio.v1/spatial"
+)
+
+// Measurement is a pair of head-related impulse responses, measured from a
+// single direction.
+type Measurement struct {
+	// Azimuth is the horizontal angle of the direction in radians,
+	// counter-clockwise from the front: positive angles are to the left and
+	// negative angles to the right.
+	Azimuth float64
+
+	// Elevation is the vertical angle of the direction in radians, positive
+	// angles being above the listener.
+	Elevation float64
+
+	// Left and Right are the impulse responses of the left and right ears.
+	Left, Right []float64
+}
+
+// direction returns the unit vector of the measurement's direction, in the
+// listener's frame of reference (see spatial.Listener.Local).
+func (m Measurement) direction() spatial.Vec3 {
+	return spatial.Direction(m.Azimuth, m.Elevation)
+}
+
+// angle returns the angle between the unit vectors a and b in radians.
+func angle(a, b spatial.Vec3) float64 {
+	return math.Acos(math.Max(-1, math.Min(1, a.Dot(b))))
+}
+
+// ear is an impulse response split into its onset delay, and the response
+// following it.
+type ear struct {
+	delay int
+	ir    []float64
+}
+
+// onsetThreshold is the level, relative to the peak of an impulse response,
+// above which its onset is detected.
+const onsetThreshold = 0.1
+
+// newEar splits the impulse response ir into an ear.
+func newEar(ir []float64) ear {
+	var peak float64
+	for _, v := range ir {
+		peak = math.Max(peak, math.Abs(v))
+	}
+	onset := 0
+	for i, v := range ir {
+		if math.Abs(v) >= peak*onsetThreshold {
+			// Keep a sample of the response before its onset.
+			onset = i - 1
+			break
+		}
+	}
+	if onset < 0 {
+		onset = 0
+	}
+	return ear{onset, ir[onset:]}
+}
+
+// Set is a set of head-related impulse responses, measured from a number of
+// directions around a listener. Sets must be allocated via the NewSet
+// function, and are safe for use by multiple goroutines at once.
+type Set struct {
+	sampleRate   int
+	measurements []Measurement
+	dirs         []spatial.Vec3
+	ears         [][2]ear
+	length       int
+}
+
+// SampleRate returns the sample rate of the impulse responses.
+func (s *Set) SampleRate() int {
+	return s.sampleRate
+}
+
+// Measurements returns the measurements of the set, which should not be
+// modified.
+func (s *Set) Measurements() []Measurement {
+	return s.measurements
+}
+
+// Len returns the length of the longest impulse response of the set in
+// samples.
+func (s *Set) Len() int {
+	return s.length
+}
+
+// neighbors is the number of nearest measurements interpolated by Lookup.
+const neighbors = 3
+
+// Lookup stores the pair of impulse responses for a sound arriving from the
+// given direction, in the listener's frame of reference (see
+// spatial.Listener.Local), in left and right, whose lengths must be at least
+// s.Len().
+//
+// Impulse responses are interpolated between the nearest measurements, which
+// are weighted by their angular distance from the direction such that the
+// result changes smoothly as the direction does. To avoid comb filtering the
+// onset delay of each response (chiefly the interaural time difference) is
+// interpolated separately from the response following it.
+func (s *Set) Lookup(dir spatial.Vec3, left, right []float64) {
+	dir = dir.Normalized()
+	if dir == (spatial.Vec3{}) {
+		dir = spatial.Vec3{Y: 1}
+	}
+
+	// Find the nearest measurements, and the distance of the next nearest
+	// beyond them.
+	var (
+		near [neighbors + 1]int
+		dist [neighbors + 1]float64
+		n    int
+	)
+	for i, d := range s.dirs {
+		a := angle(dir, d)
+		j := n
+		if n < len(near) {
+			n++
+		} else if a >= dist[j-1] {
+			continue
+		} else {
+			j--
+		}
+		for ; j > 0 && dist[j-1] > a; j-- {
+			near[j], dist[j] = near[j-1], dist[j-1]
+		}
+		near[j], dist[j] = i, a
+	}
+
+	// Weight the nearest measurements by Shepard's method, modified such
+	// that the weights fall to zero at the next nearest measurement (so that
+	// there is no discontinuity when the set of nearest measurements changes).
+	k, r := n, math.Pi
+	if n > neighbors {
+		k, r = neighbors, dist[neighbors]
+	}
+	var weights [neighbors]float64
+	var sum float64
+	for i := 0; i < k; i++ {
+		if dist[i] < 1e-9 {
+			// An exact match.
+			weights = [neighbors]float64{}
+			weights[i], sum, k = 1, 1, i+1
+			break
+		}
+		w := math.Max(0, r-dist[i]) / (r * dist[i])
+		weights[i] = w * w
+		sum += weights[i]
+	}
+	if sum == 0 {
+		weights[0], sum = 1, 1
+	}
+
+	for e, out := range [2][]float64{left, right} {
+		out = out[:s.length]
+		for i := range out {
+			out[i] = 0
+		}
+		var delay float64
+		for i := 0; i < k; i++ {
+			delay += float64(s.ears[near[i]][e].delay) * weights[i] / sum
+		}
+
+		// Sum the responses following the interpolated delay, which is
+		// fractional and so split between two samples.
+		d := int(delay)
+		frac := delay - float64(d)
+		for i := 0; i < k; i++ {
+			w := weights[i] / sum
+			if w == 0 {
+				continue
+			}
+			for j, v := range s.ears[near[i]][e].ir {
+				if d+j < len(out) {
+					out[d+j] += w * (1 - frac) * v
+				}
+				if d+j+1 < len(out) {
+					out[d+j+1] += w * frac * v
+				}
+			}
+		}
+	}
+}
+
+// Resample returns a copy of the set with its impulse responses resampled to
+// the given sample rate, or the set itself if it is already at that rate.
+func (s *Set) Resample(sampleRate int) *Set {
+	if sampleRate == s.sampleRate {
+		return s
+	}
+	c := audio.Config{SampleRate: s.sampleRate, Channels: 2}
+	ratio := float64(sampleRate) / float64(s.sampleRate)
+	ms := make([]Measurement, len(s.measurements))
+	for i, m := range s.measurements {
+		// Resample both ears at once, as a stereo stream.
+		n := len(m.Left)
+		if len(m.Right) > n {
+			n = len(m.Right)
+		}
+		in := make(audio.F64Samples, n*2)
+		for j, v := range m.Left {
+			in[j*2] = audio.F64(v)
+		}
+		for j, v := range m.Right {
+			in[j*2+1] = audio.F64(v)
+		}
+		rs := dsp.NewRateConverter(audio.NewBuffer(in), c, sampleRate)
+		out := audio.NewBuffer(make(audio.F64Samples, 0, int(float64(n)*ratio+1)*2))
+		// A Buffer never fails to read, nor to be written to.
+		audio.Copy(out, rs)
+		samples := out.Samples().(audio.F64Samples)
+
+		// Scale the responses such that their gain is preserved.
+		frames := len(samples) / 2
+		m.Left, m.Right = make([]float64, frames), make([]float64, frames)
+		for j := range m.Left {
+			m.Left[j] = float64(samples[j*2]) / ratio
+			m.Right[j] = float64(samples[j*2+1]) / ratio
+		}
+		ms[i] = m
+	}
+	return NewSet(sampleRate, ms)
+}
+
+// NewSet returns a new set of impulse responses, measured at the given sample
+// rate.
+//
+// It panics if the sample rate is not positive, or there are no measurements
+// or they are all empty.
+func NewSet(sampleRate int, measurements []Measurement) *Set {
+	if sampleRate < 1 {
+		panic("hrtf: invalid sample rate")
+	}
+	if len(measurements) == 0 {
+		panic("hrtf: no measurements")
+	}
+	s := &Set{
+		sampleRate:   sampleRate,
+		measurements: measurements,
+		dirs:         make([]spatial.Vec3, len(measurements)),
+		ears:         make([][2]ear, len(measurements)),
+	}
+	for i, m := range measurements {
+		s.dirs[i] = m.direction()
+		s.ears[i] = [2]ear{newEar(m.Left), newEar(m.Right)}
+		if len(m.Left) > s.length {
+			s.length = len(m.Left)
+		}
+		if len(m.Right) > s.length {
+			s.length = len(m.Right)
+		}
+	}
+	if s.length == 0 {
+		panic("hrtf: empty impulse responses")
+	}
+	return s
+}
@@ -0,0 +1,132 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hrtf
+
+import (
+	"math"
+	"testing"
+
+	"azul3d.org/audio.v1/spatial"
+)
+
+// direction returns the unit vector at the given azimuth and elevation in
+// degrees.
+func direction(azimuth, elevation float64) spatial.Vec3 {
+	m := Measurement{Azimuth: azimuth * math.Pi / 180, Elevation: elevation * math.Pi / 180}
+	return m.direction()
+}
+
+// lookup returns the impulse responses of s for the direction dir.
+func lookup(s *Set, dir spatial.Vec3) (left, right []float64) {
+	left, right = make([]float64, s.Len()), make([]float64, s.Len())
+	s.Lookup(dir, left, right)
+	return
+}
+
+// energy returns the sum of the squares of s.
+func energy(s []float64) float64 {
+	var e float64
+	for _, v := range s {
+		e += v * v
+	}
+	return e
+}
+
+// argmax returns the index of the largest absolute value of s.
+func argmax(s []float64) int {
+	max := 0
+	for i, v := range s {
+		if math.Abs(v) > math.Abs(s[max]) {
+			max = i
+		}
+	}
+	return max
+}
+
+func TestSphericalHead(t *testing.T) {
+	const rate = 44100
+	s := SphericalHead(rate)
+
+	// A source to the left: the left ear hears it sooner and louder.
+	left, right := lookup(s, direction(90, 0))
+	if ild := 10 * math.Log10(energy(left)/energy(right)); ild < 3 {
+		t.Errorf("interaural level difference %.2fdB, want at least 3dB", ild)
+	}
+	itd := float64(argmax(right)-argmax(left)) / rate
+	want := HeadRadius / spatial.SpeedOfSound * (math.Pi/2 + 1)
+	if math.Abs(itd-want) > 2.0/rate {
+		t.Errorf("interaural time difference %v, want %v", itd, want)
+	}
+
+	// A source in front: both ears are the same.
+	left, right = lookup(s, direction(0, 0))
+	for i := range left {
+		if math.Abs(left[i]-right[i]) > 1e-12 {
+			t.Fatalf("front source: sample %d differs between ears (%v, %v)", i, left[i], right[i])
+		}
+	}
+}
+
+func TestLookupExact(t *testing.T) {
+	s := SphericalHead(44100)
+	for _, m := range s.Measurements() {
+		left, right := lookup(s, m.direction().Scale(3))
+		for i := range m.Left {
+			if math.Abs(left[i]-m.Left[i]) > 1e-12 || math.Abs(right[i]-m.Right[i]) > 1e-12 {
+				t.Fatalf("(%v, %v): sample %d is (%v, %v), want (%v, %v)", m.Azimuth, m.Elevation, i, left[i], right[i], m.Left[i], m.Right[i])
+			}
+		}
+	}
+}
+
+func TestLookupSmooth(t *testing.T) {
+	// Sweeping the direction in steps, the largest change of the responses
+	// between steps must shrink in proportion to the step size; it would not
+	// if they were discontinuous.
+	s := SphericalHead(44100)
+	maxChange := func(step float64) (max float64) {
+		prevL, prevR := lookup(s, direction(-180, 10))
+		for az := -180 + step; az <= 180; az += step {
+			left, right := lookup(s, direction(az, 10))
+			for i := range left {
+				max = math.Max(max, math.Abs(left[i]-prevL[i]))
+				max = math.Max(max, math.Abs(right[i]-prevR[i]))
+			}
+			prevL, prevR = left, right
+		}
+		return
+	}
+	coarse, fine := maxChange(0.2), maxChange(0.05)
+	if fine > coarse*0.35 {
+		t.Errorf("largest change %v at 0.2 degree steps, %v at 0.05 degree steps", coarse, fine)
+	}
+}
+
+func TestResample(t *testing.T) {
+	s := SphericalHead(22050)
+	r := s.Resample(44100)
+	if r.SampleRate() != 44100 {
+		t.Fatalf("sample rate %d, want 44100", r.SampleRate())
+	}
+	if r.Len() < 2*s.Len()-4 || r.Len() > 2*s.Len()+4 {
+		t.Errorf("length %d, want about %d", r.Len(), 2*s.Len())
+	}
+	if s.Resample(22050) != s {
+		t.Error("resampling to the same rate returned a copy")
+	}
+
+	// The gain at low frequencies (the sum of the response) is preserved.
+	sum := func(s []float64) (v float64) {
+		for _, x := range s {
+			v += x
+		}
+		return
+	}
+	a, _ := lookup(s, direction(45, 0))
+	b, _ := lookup(r, direction(45, 0))
+	if ga, gb := sum(a), sum(b); math.Abs(gb-ga) > 0.05*math.Abs(ga) {
+		t.Errorf("gain %v after resampling, want %v", gb, ga)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hrtf
+
+import (
+	"io"
+	"math"
+	"strings"
+
+	"azul3d.org/audio.v1"
+)
+
+// UnsupportedError reports that a SOFA file is valid, but uses a feature (of
+// HDF5, or of the SOFA conventions) which is not supported.
+type UnsupportedError string
+
+func (e UnsupportedError) Error() string {
+	return "hrtf: unsupported SOFA file: " + string(e)
+}
+
+// ReadSOFA reads a set from r, an AES69 SOFA file of the given size in bytes
+// following the SimpleFreeFieldHRIR conventions (or any other with a
+// Data.IR variable of two receivers).
+//
+// The impulse responses are read from the Data.IR variable, delayed by the
+// Data.Delay variable (if any), and measured from the directions given by
+// the SourcePosition variable in either spherical or cartesian coordinates.
+// The listener is assumed to be at the origin, looking along the X axis with
+// the Z axis up, as is conventional.
+//
+// SOFA files are HDF5 (netCDF-4) files; only the subset of HDF5 used by the
+// common SOFA APIs is supported: see the UnsupportedError type. It returns
+// audio.ErrFormat if r is not an HDF5 file, and audio.ErrInvalidData if it is
+// corrupt.
+func ReadSOFA(r io.ReaderAt, size int64) (s *Set, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			h, ok := e.(h5error)
+			if !ok {
+				panic(e)
+			}
+			s, err = nil, h.err
+		}
+	}()
+	f := newH5File(r, size)
+	links := f.object(f.root).links()
+	variable := func(name string, required bool) *h5object {
+		addr, ok := links[name]
+		if !ok {
+			if required {
+				fail(UnsupportedError("no " + name + " variable"))
+			}
+			return nil
+		}
+		return f.object(addr)
+	}
+
+	// The impulse responses, of M measurements by R receivers by N samples.
+	ir, dims := variable("Data.IR", true).floats()
+	if len(dims) != 3 || dims[1] != 2 {
+		fail(UnsupportedError("Data.IR must have dimensions M, R=2, N"))
+	}
+	m, n := int(dims[0]), int(dims[2])
+	if m < 1 || n < 1 {
+		fail(audio.ErrInvalidData)
+	}
+
+	rate, _ := variable("Data.SamplingRate", true).floats()
+	if len(rate) < 1 || rate[0] < 1 || rate[0] > math.MaxInt32 {
+		fail(audio.ErrInvalidData)
+	}
+
+	// The source positions, either one per measurement or shared by all.
+	src := variable("SourcePosition", true)
+	pos, dims := src.floats()
+	if len(dims) != 2 || dims[1] != 3 || (dims[0] != 1 && int(dims[0]) != m) {
+		fail(audio.ErrInvalidData)
+	}
+	typ, _ := src.attribute("Type")
+	cartesian := strings.EqualFold(typ, "cartesian")
+
+	// The delays in samples, either per measurement or shared by all.
+	var delay []float64
+	if v := variable("Data.Delay", false); v != nil {
+		delay, dims = v.floats()
+		if len(dims) != 2 || dims[1] != 2 || (dims[0] != 1 && int(dims[0]) != m) {
+			fail(audio.ErrInvalidData)
+		}
+	}
+
+	ms := make([]Measurement, m)
+	for i := range ms {
+		p := pos[3*(i%(len(pos)/3)):]
+		var meas Measurement
+		if cartesian {
+			x, y, z := p[0], p[1], p[2]
+			meas.Azimuth = math.Atan2(y, x)
+			meas.Elevation = math.Atan2(z, math.Hypot(x, y))
+		} else {
+			meas.Azimuth = p[0] * math.Pi / 180
+			meas.Elevation = p[1] * math.Pi / 180
+		}
+		for r, dst := range [2]*[]float64{&meas.Left, &meas.Right} {
+			d := 0
+			if delay != nil {
+				d = int(math.Max(0, math.Min(maxLength, math.Floor(delay[2*(i%(len(delay)/2))+r]+0.5))))
+			}
+			*dst = make([]float64, d+n)
+			copy((*dst)[d:], ir[(2*i+r)*n:(2*i+r+1)*n])
+		}
+		ms[i] = meas
+	}
+	return NewSet(int(rate[0]), ms), nil
+}
@@ -0,0 +1,451 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hrtf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+
+	"azul3d.org/audio.v1"
+)
+
+// le is a little-endian encoder, for writing test HDF5 files.
+type le []byte
+
+func (b le) u8(v uint8) le   { return append(b, v) }
+func (b le) u16(v uint16) le { return append(b, byte(v), byte(v>>8)) }
+func (b le) u32(v uint32) le { return b.u16(uint16(v)).u16(uint16(v >> 16)) }
+func (b le) u64(v uint64) le { return b.u32(uint32(v)).u32(uint32(v >> 32)) }
+func (b le) str(s string) le { return append(b, s...) }
+func (b le) pad(n int) le {
+	for len(b)%n != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+const undef = math.MaxUint64
+
+// h5m is an object header message.
+type h5m struct {
+	typ  int
+	data le
+}
+
+// h5writer writes minimal HDF5 files laid out like SOFA files, in one of
+// three styles: a version 0 superblock with version 1 object headers and a
+// symbol table root group, or a version 2 superblock with version 2 object
+// headers and a compact or dense root group.
+type h5writer struct {
+	b            le
+	v2, dense    bool
+	links        []h5link
+	prefix, base int
+}
+
+type h5link struct {
+	name string
+	addr uint64
+}
+
+func (w *h5writer) put(data le) uint64 {
+	w.b = w.b.pad(8)
+	a := uint64(len(w.b))
+	w.b = append(w.b, data...)
+	return a
+}
+
+func (w *h5writer) patch64(at, v uint64) {
+	binary.LittleEndian.PutUint64(w.b[at:], v)
+}
+
+// messages encodes the messages of an object header.
+func (w *h5writer) messages(msgs []h5m) le {
+	var b le
+	for _, m := range msgs {
+		if w.v2 {
+			b = b.u8(uint8(m.typ)).u16(uint16(len(m.data))).u8(0)
+			b = append(b, m.data...)
+			continue
+		}
+		d := append(le(nil), m.data...).pad(8)
+		b = b.u16(uint16(m.typ)).u16(uint16(len(d))).u32(0)
+		b = append(b, d...)
+	}
+	return b
+}
+
+// object writes an object header, with all but the first two messages in a
+// continuation block.
+func (w *h5writer) object(msgs []h5m) uint64 {
+	if len(msgs) > 2 {
+		rest := w.messages(msgs[2:])
+		if w.v2 {
+			rest = append(le{}.str("OCHK"), rest...).u32(0)
+		}
+		addr := w.put(rest)
+		msgs = append(msgs[:2:2], h5m{0x10, le{}.u64(addr).u64(uint64(len(rest)))})
+	}
+	body := w.messages(msgs)
+	if w.v2 {
+		hdr := le{}.str("OHDR").u8(2).u8(0x02).u32(uint32(len(body)))
+		return w.put(append(hdr, body...).u32(0))
+	}
+	hdr := le{}.u8(1).u8(0).u16(uint16(len(msgs))).u32(1).u32(uint32(len(body))).u32(0)
+	return w.put(append(hdr, body...))
+}
+
+func (w *h5writer) space(dims ...uint64) h5m {
+	var b le
+	if w.v2 {
+		typ := uint8(1)
+		if len(dims) == 0 {
+			typ = 0
+		}
+		b = le{}.u8(2).u8(uint8(len(dims))).u8(0).u8(typ)
+	} else {
+		b = le{}.u8(1).u8(uint8(len(dims))).u8(0).u8(0).u32(0)
+	}
+	for _, d := range dims {
+		b = b.u64(d)
+	}
+	return h5m{0x01, b}
+}
+
+func float64Type() h5m {
+	return h5m{0x03, le{}.u8(0x11).u8(0x20).u8(63).u8(0).u32(8).
+		u16(0).u16(64).u8(52).u8(11).u8(0).u8(52).u32(1023)}
+}
+
+func floatBytes(data []float64) le {
+	var b le
+	for _, v := range data {
+		b = b.u64(math.Float64bits(v))
+	}
+	return b
+}
+
+// attribute returns a string attribute message.
+func (w *h5writer) attribute(name, value string) h5m {
+	if !w.v2 {
+		dt := le{}.u8(0x13).u8(0).u8(0).u8(0).u32(uint32(len(value)))
+		ds := w.space().data
+		b := le{}.u8(1).u8(0).u16(uint16(len(name) + 1)).u16(uint16(len(dt))).u16(uint16(len(ds)))
+		b = append(b.str(name).u8(0).pad(8), dt...).pad(8)
+		b = append(b, ds...).pad(8)
+		return h5m{0x0c, b.str(value)}
+	}
+
+	// A variable-length string, in a global heap collection.
+	obj := le{}.u16(1).u16(1).u32(0).u64(uint64(len(value))).str(value).pad(8)
+	gcol := le{}.str("GCOL").u8(1).u8(0).u8(0).u8(0).u64(uint64(16 + len(obj) + 16))
+	gcol = append(gcol, obj...).u16(0).u16(0).u32(0).u64(0)
+	addr := w.put(gcol)
+	dt := le{}.u8(0x19).u8(0x01).u8(0).u8(0).u32(16).u8(0x10).u8(0).u8(0).u8(0).u32(1).u16(0).u16(8)
+	ds := w.space().data
+	b := le{}.u8(3).u8(0).u16(uint16(len(name) + 1)).u16(uint16(len(dt))).u16(uint16(len(ds))).u8(0)
+	b = append(append(b.str(name).u8(0), dt...), ds...)
+	return h5m{0x0c, b.u32(uint32(len(value))).u64(addr).u32(1)}
+}
+
+// dataset writes a contiguous (or, if compact is true and using version 2
+// headers, compact) dataset of float64 values.
+func (w *h5writer) dataset(name string, dims []uint64, data []float64, compact bool, attrs ...h5m) {
+	raw := floatBytes(data)
+	var layout le
+	switch {
+	case w.v2 && compact:
+		layout = le{}.u8(4).u8(0).u16(uint16(len(raw)))
+		layout = append(layout, raw...)
+	case w.v2:
+		layout = le{}.u8(4).u8(1).u64(w.put(raw)).u64(uint64(len(raw)))
+	default:
+		layout = le{}.u8(3).u8(1).u64(w.put(raw)).u64(uint64(len(raw)))
+	}
+	msgs := []h5m{w.space(dims...), float64Type(), {0x08, layout}}
+	w.link(name, w.object(append(msgs, attrs...)))
+}
+
+// chunked writes a dataset of float64 values of three dimensions, in chunks
+// of one row of the first dimension, shuffled and deflated.
+func (w *h5writer) chunked(name string, dims []uint64, data []float64) {
+	row := int(dims[1] * dims[2])
+	var addrs, sizes []uint64
+	for i := 0; i < int(dims[0]); i++ {
+		raw := floatBytes(data[i*row : (i+1)*row])
+		shuffled := make(le, len(raw))
+		n := len(raw) / 8
+		for j := 0; j < 8; j++ {
+			for k := 0; k < n; k++ {
+				shuffled[j*n+k] = raw[k*8+j]
+			}
+		}
+		var buf bytes.Buffer
+		z := zlib.NewWriter(&buf)
+		z.Write(shuffled)
+		z.Close()
+		addrs = append(addrs, w.put(buf.Bytes()))
+		sizes = append(sizes, uint64(buf.Len()))
+	}
+
+	var filters, layout le
+	if w.v2 {
+		filters = le{}.u8(2).u8(2).u16(2).u16(0).u16(1).u32(8).u16(1).u16(0).u16(1).u32(6)
+
+		// A fixed array chunk index.
+		fahd := w.put(le{}.str("FAHD").u8(0).u8(1).u8(16).u8(10).u64(dims[0]).u64(0).u32(0))
+		fadb := le{}.str("FADB").u8(0).u8(1).u64(fahd)
+		for i := range addrs {
+			fadb = fadb.u64(addrs[i]).u32(uint32(sizes[i])).u32(0)
+		}
+		w.patch64(fahd+16, w.put(fadb.u32(0)))
+		layout = le{}.u8(4).u8(2).u8(0).u8(4).u8(4).u32(1).u32(uint32(dims[1])).u32(uint32(dims[2])).u32(8)
+		layout = layout.u8(3).u8(10).u64(fahd)
+	} else {
+		filters = le{}.u8(1).u8(2).u16(0).u32(0)
+		filters = filters.u16(2).u16(0).u16(0).u16(1).u32(8).u32(0)
+		filters = filters.u16(1).u16(0).u16(0).u16(1).u32(6).u32(0)
+
+		// A B-tree chunk index.
+		tree := le{}.str("TREE").u8(1).u8(0).u16(uint16(len(addrs))).u64(undef).u64(undef)
+		for i := range addrs {
+			tree = tree.u32(uint32(sizes[i])).u32(0).u64(uint64(i)).u64(0).u64(0).u64(0).u64(addrs[i])
+		}
+		tree = tree.u32(0).u32(0).u64(dims[0]).u64(0).u64(0).u64(0)
+		layout = le{}.u8(3).u8(2).u8(4).u64(w.put(tree)).u32(1).u32(uint32(dims[1])).u32(uint32(dims[2])).u32(8)
+	}
+	msgs := []h5m{w.space(dims...), float64Type(), {0x0b, filters}, {0x08, layout}}
+	w.link(name, w.object(msgs))
+}
+
+func (w *h5writer) link(name string, addr uint64) {
+	w.links = append(w.links, h5link{name, addr})
+}
+
+// linkMessage encodes a hard link message.
+func linkMessage(l h5link) le {
+	return le{}.u8(1).u8(0).u8(uint8(len(l.name))).str(l.name).u64(l.addr)
+}
+
+// finish writes the root group and superblock, and returns the file.
+func (w *h5writer) finish() []byte {
+	var sb le
+	switch {
+	case !w.v2:
+		// A local heap of the names, and a B-tree of a single symbol table
+		// node.
+		names := le{}.u64(0)
+		snod := le{}.str("SNOD").u8(1).u8(0).u16(uint16(len(w.links)))
+		for _, l := range w.links {
+			snod = snod.u64(uint64(len(names))).u64(l.addr).u32(0).u32(0).u64(0).u64(0)
+			names = names.str(l.name).u8(0).pad(8)
+		}
+		data := w.put(names)
+		heap := w.put(le{}.str("HEAP").u8(0).u8(0).u8(0).u8(0).u64(uint64(len(names))).u64(undef).u64(data))
+		node := w.put(snod)
+		tree := w.put(le{}.str("TREE").u8(0).u8(0).u16(1).u64(undef).u64(undef).u64(0).u64(node).u64(uint64(len(names) - 8)))
+		root := w.object([]h5m{{0x11, le{}.u64(tree).u64(heap)}})
+		sb = le{}.str(signature).u8(0).u8(0).u8(0).u8(0).u8(0).u8(8).u8(8).u8(0).u16(4).u16(16).u32(0)
+		sb = sb.u64(uint64(w.base)).u64(undef).u64(uint64(len(w.b))).u64(undef)
+		sb = sb.u64(0).u64(root).u32(1).u32(0).u64(tree).u64(heap)
+	case w.dense:
+		// A fractal heap of link messages in a single direct block, indexed
+		// by a B-tree of a single leaf.
+		frhp := le{}.str("FRHP").u8(0).u16(7).u16(0).u8(0).u32(4096)
+		frhp = frhp.u64(0).u64(undef).u64(0).u64(undef).u64(512).u64(512).u64(0)
+		frhp = frhp.u64(uint64(len(w.links))).u64(0).u64(0).u64(0).u64(0)
+		frhp = frhp.u16(4).u64(512).u64(65536).u16(32).u16(1)
+		heap := w.put(frhp)
+		w.b = w.b.u64(0).u16(0).u32(0)
+
+		block := le{}.str("FHDB").u8(0).u64(heap).u32(0)
+		leaf := le{}.str("BTLF").u8(0).u8(5)
+		for _, l := range w.links {
+			m := linkMessage(l)
+			leaf = leaf.u32(0).u8(0).u32(uint32(len(block))).u16(uint16(len(m)))
+			block = append(block, m...)
+		}
+		w.patch64(heap+uint64(len(frhp)), w.put(block.pad(512)))
+		index := w.put(le{}.str("BTHD").u8(0).u8(5).u32(512).u16(11).u16(0).u8(100).u8(40).
+			u64(w.put(leaf.u32(0))).u16(uint16(len(w.links))).u64(uint64(len(w.links))).u32(0))
+		root := w.object([]h5m{{0x02, le{}.u8(0).u8(0).u64(heap).u64(index)}})
+		sb = le{}.str(signature).u8(2).u8(8).u8(8).u8(0)
+		sb = sb.u64(uint64(w.base)).u64(undef).u64(uint64(len(w.b))).u64(root).u32(0)
+	default:
+		var msgs []h5m
+		for _, l := range w.links {
+			msgs = append(msgs, h5m{0x06, linkMessage(l)})
+		}
+		root := w.object(msgs)
+		sb = le{}.str(signature).u8(2).u8(8).u8(8).u8(0)
+		sb = sb.u64(uint64(w.base)).u64(undef).u64(uint64(len(w.b))).u64(root).u32(0)
+	}
+	copy(w.b, sb)
+	return append(make([]byte, w.prefix), w.b...)
+}
+
+// newH5Writer returns a writer, which reserves space for the superblock.
+func newH5Writer(v2, dense bool, prefix int) *h5writer {
+	// A user block of the given size precedes the superblock, which is at the
+	// base address.
+	w := &h5writer{v2: v2, dense: dense, prefix: prefix, base: prefix}
+	w.b = make(le, 96)
+	return w
+}
+
+// testSet returns a small set of measurements with random responses.
+func testSet() []Measurement {
+	rng := rand.New(rand.NewSource(1))
+	var ms []Measurement
+	for _, el := range []float64{-30, 0, 45} {
+		for az := -180.0; az < 180; az += 60 {
+			m := Measurement{
+				Azimuth:   az * math.Pi / 180,
+				Elevation: el * math.Pi / 180,
+				Left:      make([]float64, 24),
+				Right:     make([]float64, 24),
+			}
+			for i := range m.Left {
+				m.Left[i] = rng.Float64()*2 - 1
+				m.Right[i] = rng.Float64()*2 - 1
+			}
+			ms = append(ms, m)
+		}
+	}
+	return ms
+}
+
+// writeSOFA writes the measurements as a SOFA file, with a single delay for
+// all measurements and source positions in cartesian coordinates for version
+// 1 headers, and in spherical coordinates otherwise.
+func writeSOFA(ms []Measurement, v2, dense bool, delay [2]float64) []byte {
+	w := newH5Writer(v2, dense, 512)
+	m, n := uint64(len(ms)), uint64(len(ms[0].Left))
+	var ir, pos []float64
+	for _, meas := range ms {
+		ir = append(append(ir, meas.Left...), meas.Right...)
+		if v2 {
+			pos = append(pos, meas.Azimuth*180/math.Pi, meas.Elevation*180/math.Pi, 1.2)
+			continue
+		}
+		d := meas.direction().Scale(1.2)
+		pos = append(pos, d.Y, -d.X, d.Z)
+	}
+	w.dataset("M", []uint64{m}, make([]float64, m), false)
+	w.chunked("Data.IR", []uint64{m, 2, n}, ir)
+	w.dataset("Data.SamplingRate", []uint64{1}, []float64{48000}, true,
+		w.attribute("Units", "hertz"))
+	w.dataset("Data.Delay", []uint64{1, 2}, delay[:], true)
+	typ := "spherical"
+	if !v2 {
+		typ = "cartesian"
+	}
+	w.dataset("SourcePosition", []uint64{m, 3}, pos, false,
+		w.attribute("Type", typ), w.attribute("Units", "metre"))
+	return w.finish()
+}
+
+func TestReadSOFA(t *testing.T) {
+	ms := testSet()
+	for _, tst := range []struct {
+		name      string
+		v2, dense bool
+	}{
+		{"symbol table", false, false},
+		{"compact", true, false},
+		{"dense", true, true},
+	} {
+		delay := [2]float64{0, 3}
+		data := writeSOFA(ms, tst.v2, tst.dense, delay)
+		s, err := ReadSOFA(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("%s: %v", tst.name, err)
+		}
+		if s.SampleRate() != 48000 {
+			t.Errorf("%s: sample rate %d, want 48000", tst.name, s.SampleRate())
+		}
+		got := s.Measurements()
+		if len(got) != len(ms) {
+			t.Fatalf("%s: %d measurements, want %d", tst.name, len(got), len(ms))
+		}
+		for i, m := range ms {
+			g := got[i]
+			if angle(g.direction(), m.direction()) > 1e-9 {
+				t.Errorf("%s: measurement %d direction (%v, %v), want (%v, %v)", tst.name, i, g.Azimuth, g.Elevation, m.Azimuth, m.Elevation)
+			}
+			for e, want := range [2][]float64{m.Left, m.Right} {
+				ir := [2][]float64{g.Left, g.Right}[e]
+				d := int(delay[e])
+				if len(ir) != len(want)+d {
+					t.Fatalf("%s: measurement %d ear %d length %d, want %d", tst.name, i, e, len(ir), len(want)+d)
+				}
+				for j, v := range want {
+					if ir[j+d] != v {
+						t.Fatalf("%s: measurement %d ear %d sample %d = %v, want %v", tst.name, i, e, j, ir[j+d], v)
+					}
+				}
+			}
+		}
+
+		// Via Load, too.
+		if _, err := Load(bytes.NewReader(data)); err != nil {
+			t.Errorf("%s: Load: %v", tst.name, err)
+		}
+	}
+}
+
+func TestReadSOFAAttribute(t *testing.T) {
+	for _, v2 := range []bool{false, true} {
+		w := newH5Writer(v2, false, 0)
+		w.dataset("x", []uint64{1}, []float64{1}, false, w.attribute("Units", "metre"), w.attribute("Type", "cartesian"))
+		data := w.finish()
+		f := newH5File(bytes.NewReader(data), int64(len(data)))
+		o := f.object(f.object(f.root).links()["x"])
+		if v, ok := o.attribute("Type"); !ok || v != "cartesian" {
+			t.Errorf("v2=%v: attribute %q %v, want \"cartesian\"", v2, v, ok)
+		}
+		if _, ok := o.attribute("Missing"); ok {
+			t.Errorf("v2=%v: found missing attribute", v2)
+		}
+	}
+}
+
+func TestReadSOFAErrors(t *testing.T) {
+	data := writeSOFA(testSet(), false, false, [2]float64{})
+	read := func(d []byte) error {
+		_, err := ReadSOFA(bytes.NewReader(d), int64(len(d)))
+		return err
+	}
+
+	if err := read([]byte("not an HDF5 file")); err != audio.ErrFormat {
+		t.Errorf("non-HDF5 data: got %v, want audio.ErrFormat", err)
+	}
+
+	// A valid HDF5 file, without the variables of a SOFA file.
+	w := newH5Writer(true, false, 0)
+	w.dataset("x", []uint64{1}, []float64{1}, false)
+	if _, ok := read(w.finish()).(UnsupportedError); !ok {
+		t.Error("HDF5 file without Data.IR: want an UnsupportedError")
+	}
+
+	// Truncated and corrupt files must fail cleanly.
+	for n := 0; n < len(data); n += 97 {
+		if read(data[:n]) == nil {
+			t.Errorf("truncated to %d bytes: no error", n)
+		}
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		d := append([]byte(nil), data...)
+		for j := 0; j < 4; j++ {
+			d[rng.Intn(len(d))] = byte(rng.Intn(256))
+		}
+		read(d)
+	}
+}
@@ -51,6 +51,58 @@ type ReadSeeker interface {
 	Seek(sample uint64) error
 }
 
+// Positioner is an optional interface implemented by readers which can report
+// where, in the stream they ultimately read from (the source), the audio that
+// they are about to return comes from.
+//
+// Readers which wrap another reader (e.g. resamplers and effects) implement
+// it by propagating the position and latency of the wrapped reader, if it is
+// a Positioner, and otherwise treating the wrapped reader as the source. As
+// such the play position of a whole chain of readers is known by querying
+// only the last one.
+//
+// Readers which mix many sources into one stream (e.g. spatial.Mixer,
+// graph.Graph, voice.Manager and schedule.Scheduler) do not implement it:
+// sources are added and removed at any time, each with its own position and
+// latency, so there is no single source position to report. Instead query the
+// readers of the individual sources (or e.g. voice.Voice.Position).
+//
+// Like Read, the methods are not safe to call concurrently with Read (or with
+// each other). To display the position from another goroutine, query it from
+// the goroutine which reads (e.g. after each Read) and hand the result over.
+type Positioner interface {
+	// SourcePosition returns the current play position: the sample number,
+	// relative to the start of the source stream (i.e. as would be passed to
+	// Seek), of the audio that the next sample read corresponds to. The
+	// latency of any processing in between is compensated for.
+	SourcePosition() uint64
+
+	// TotalLatency returns the total latency, in samples of the reader's own
+	// stream, introduced by all of the processing in between the source and
+	// the reader. It is the amount of audio that is read from the source
+	// before the corresponding audio can be read from the reader.
+	TotalLatency() int
+}
+
+// SourcePosition returns the source position of r if it implements the
+// Positioner interface. Otherwise ok is false.
+func SourcePosition(r Reader) (sample uint64, ok bool) {
+	p, ok := r.(Positioner)
+	if !ok {
+		return 0, false
+	}
+	return p.SourcePosition(), true
+}
+
+// TotalLatency returns the total latency of r if it implements the Positioner
+// interface, or zero otherwise.
+func TotalLatency(r Reader) int {
+	if p, ok := r.(Positioner); ok {
+		return p.TotalLatency()
+	}
+	return 0
+}
+
 // Writer is a generic interface which describes any type who can have audio
 // samples written from an audio slice into it.
 type Writer interface {
@@ -13,4 +13,26 @@ func TestBufferIO(t *testing.T) {
 	_ = Writer(buf)
 	_ = WriterTo(buf)
 	_ = ReaderFrom(buf)
+	_ = Positioner(buf)
+}
+
+func TestBufferSourcePosition(t *testing.T) {
+	b := NewBuffer(rampSamples(10))
+	b.Read(make(F64Samples, 3))
+	b.ReadSample()
+	b.Next(2)
+	if p := b.SourcePosition(); p != 6 {
+		t.Fatalf("SourcePosition got %d, want 6", p)
+	}
+	b.Read(make(F64Samples, 8))
+	b.Write(rampSamples(4)) // reuses the buffer space
+	if p := b.SourcePosition(); p != 10 {
+		t.Fatalf("SourcePosition got %d, want 10", p)
+	}
+	if p, ok := SourcePosition(b); p != 10 || !ok {
+		t.Fatalf("SourcePosition(b) got (%d, %v), want (10, true)", p, ok)
+	}
+	if l := TotalLatency(b); l != 0 {
+		t.Fatalf("TotalLatency got %d, want 0", l)
+	}
 }
@@ -0,0 +1,11 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package loudness implements loudness measurement and normalization of audio
+// streams as described by ITU-R BS.1770-4 and EBU R128.
+//
+// It measures momentary, short-term and integrated loudness (in LUFS), the
+// loudness range (LRA, in LU, as per EBU Tech 3342) and the true-peak level
+// (in dBTP, using 4x oversampling).
+package loudness
@@ -0,0 +1,210 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package loudness
+
+import (
+	"math"
+	"testing"
+
+	"azul3d.org/audio.v1"
+)
+
+var stereo = audio.Config{SampleRate: 48000, Channels: 2}
+
+// segment describes a sine wave of the given level in dBFS and duration in
+// seconds.
+type segment struct {
+	dbfs, seconds float64
+}
+
+// signal generates a sine wave of the given frequency, whose level changes at
+// the end of each segment, on all channels; as used by the EBU Tech 3341 and
+// Tech 3342 test signals.
+func signal(c audio.Config, freq float64, segments ...segment) audio.F64Samples {
+	var s audio.F64Samples
+	var n int
+	for _, seg := range segments {
+		amp := math.Pow(10, seg.dbfs/20)
+		frames := int(seg.seconds * float64(c.SampleRate))
+		for f := 0; f < frames; f++ {
+			v := amp * math.Sin(2*math.Pi*freq*float64(n)/float64(c.SampleRate))
+			for ch := 0; ch < c.Channels; ch++ {
+				s = append(s, audio.F64(v))
+			}
+			n++
+		}
+	}
+	return s
+}
+
+// sliceReader is a seekable reader of an audio slice. Unlike audio.Buffer it
+// can seek back to the start after being drained.
+type sliceReader struct {
+	s   audio.Slice
+	pos int
+}
+
+func (r *sliceReader) Read(b audio.Slice) (n int, err error) {
+	if r.pos >= r.s.Len() {
+		return 0, audio.EOS
+	}
+	n = r.s.Slice(r.pos, r.s.Len()).CopyTo(b)
+	r.pos += n
+	return n, nil
+}
+
+func (r *sliceReader) Seek(sample uint64) error {
+	if sample > uint64(r.s.Len()) {
+		return audio.EOS
+	}
+	r.pos = int(sample)
+	return nil
+}
+
+func measure(t *testing.T, c audio.Config, s audio.F64Samples) *Meter {
+	m, err := Measure(audio.NewBuffer(s), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// EBU Tech 3341, minimum requirements tests 1 and 2.
+func TestTech3341Constant(t *testing.T) {
+	for _, level := range []float64{-23, -33} {
+		m := measure(t, stereo, signal(stereo, 1000, segment{level, 20}))
+		results := []struct {
+			name string
+			got  float64
+		}{
+			{"momentary", m.Momentary()},
+			{"short-term", m.ShortTerm()},
+			{"integrated", m.Integrated()},
+		}
+		for _, r := range results {
+			if math.Abs(r.got-level) > 0.1 {
+				t.Errorf("%v dBFS: %s loudness %.2f LUFS, want %v", level, r.name, r.got, level)
+			}
+		}
+	}
+}
+
+// EBU Tech 3341, minimum requirements tests 3, 4 and 5 (gating).
+func TestTech3341Integrated(t *testing.T) {
+	tests := [][]segment{
+		{{-36, 10}, {-23, 60}, {-36, 10}},
+		{{-72, 10}, {-36, 10}, {-23, 60}, {-36, 10}, {-72, 10}},
+		{{-26, 20}, {-20, 20.1}, {-26, 20}},
+	}
+	for i, segments := range tests {
+		m := measure(t, stereo, signal(stereo, 1000, segments...))
+		if got := m.Integrated(); math.Abs(got+23) > 0.1 {
+			t.Errorf("test %d: integrated loudness %.2f LUFS, want -23", i+3, got)
+		}
+	}
+}
+
+// EBU Tech 3342, loudness range tests 1, 2 and 3.
+func TestTech3342Range(t *testing.T) {
+	tests := []struct {
+		a, b, want float64
+	}{
+		{-20, -30, 10},
+		{-20, -15, 5},
+		{-40, -20, 20},
+	}
+	for i, tst := range tests {
+		m := measure(t, stereo, signal(stereo, 1000, segment{tst.a, 20}, segment{tst.b, 20}))
+		if got := m.Range(); math.Abs(got-tst.want) > 1 {
+			t.Errorf("test %d: loudness range %.2f LU, want %v", i+1, got, tst.want)
+		}
+	}
+}
+
+// EBU Tech 3341, minimum requirements tests 15 to 18 (true-peak).
+func TestTech3341TruePeak(t *testing.T) {
+	mono := audio.Config{SampleRate: 48000, Channels: 1}
+	tests := []struct {
+		div, phase float64 // frequency as a fraction of the sample rate; degrees
+	}{
+		{4, 0},
+		{4, 45},
+		{6, 60},
+		{8, 67.5},
+	}
+	for _, tst := range tests {
+		s := make(audio.F64Samples, mono.SampleRate)
+		for i := range s {
+			v := 0.5 * math.Sin(2*math.Pi*float64(i)/tst.div+tst.phase*math.Pi/180)
+			if fade := 480; i < fade {
+				// Fade in, as an abrupt start would overshoot.
+				v *= 0.5 - 0.5*math.Cos(math.Pi*float64(i)/float64(fade))
+			}
+			s[i] = audio.F64(v)
+		}
+		m := measure(t, mono, s)
+		if got := m.TruePeak(); got > -6+0.2 || got < -6-0.4 {
+			t.Errorf("fs/%v, %v degrees: true-peak %.2f dBTP, want -6", tst.div, tst.phase, got)
+		}
+	}
+}
+
+func TestSurroundWeights(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 6}
+	s := signal(c, 1000, segment{-23, 5})
+
+	// Silence all but the LFE channel; it is not measured.
+	lfe := make(audio.F64Samples, len(s))
+	for i := 3; i < len(s); i += 6 {
+		lfe[i] = s[i]
+	}
+	if got := measure(t, c, lfe).Integrated(); !math.IsInf(got, -1) {
+		t.Errorf("LFE only: integrated loudness %.2f LUFS, want -Inf", got)
+	}
+
+	// A surround channel alone is weighted by +1.5 dB relative to a front
+	// channel alone.
+	front := make(audio.F64Samples, len(s))
+	surround := make(audio.F64Samples, len(s))
+	for i := 0; i < len(s); i += 6 {
+		front[i] = s[i]
+		surround[i+4] = s[i+4]
+	}
+	diff := measure(t, c, surround).Integrated() - measure(t, c, front).Integrated()
+	if math.Abs(diff-1.5) > 0.01 {
+		t.Errorf("surround weighting %.3f dB, want 1.5", diff)
+	}
+}
+
+func TestSilence(t *testing.T) {
+	m := measure(t, stereo, make(audio.F64Samples, 2*48000*2))
+	if got := m.Integrated(); !math.IsInf(got, -1) {
+		t.Errorf("integrated loudness %v, want -Inf", got)
+	}
+	if got := m.Range(); got != 0 {
+		t.Errorf("loudness range %v, want 0", got)
+	}
+	if got := m.TruePeak(); !math.IsInf(got, -1) {
+		t.Errorf("true-peak %v, want -Inf", got)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	s := signal(stereo, 1000, segment{-30, 10})
+	r, gain, err := Normalize(&sliceReader{s: s}, stereo, TargetBroadcast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(gain-7) > 0.1 {
+		t.Errorf("gain %.2f dB, want 7", gain)
+	}
+	m, err := Measure(r, stereo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Integrated(); math.Abs(got-TargetBroadcast) > 0.05 {
+		t.Errorf("normalized loudness %.2f LUFS, want %v", got, TargetBroadcast)
+	}
+}
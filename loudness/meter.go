@@ -0,0 +1,337 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package loudness
+
+import (
+	"math"
+	"sort"
+
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/dsp"
+)
+
+const (
+	// absoluteGate is the absolute gating threshold in LUFS.
+	absoluteGate = -70
+
+	// relativeGate is the relative gating threshold for integrated loudness,
+	// in LU below the absolute-gated loudness.
+	relativeGate = -10
+
+	// rangeGate is the relative gating threshold for the loudness range, in
+	// LU below the absolute-gated loudness.
+	rangeGate = -20
+
+	// Sub-blocks of 100ms make up the 400ms momentary and 3s short-term
+	// windows.
+	momentaryBlocks = 4
+	shortTermBlocks = 30
+)
+
+// kWeighting returns the two stages of the K-weighting filter for the given
+// sample rate: a high shelf modelling the acoustic effect of the head, and
+// the RLB high-pass filter.
+func kWeighting(sampleRate int) [2]dsp.Coefficients {
+	fs := float64(sampleRate)
+
+	// Stage 1: high shelf.
+	f0 := 1681.974450955533
+	g := 3.999843853973347
+	q := 0.7071752369554196
+	k := math.Tan(math.Pi * f0 / fs)
+	vh := math.Pow(10, g/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := dsp.Coefficients{
+		B0: (vh + vb*k/q + k*k) / a0,
+		B1: 2 * (k*k - vh) / a0,
+		B2: (vh - vb*k/q + k*k) / a0,
+		A1: 2 * (k*k - 1) / a0,
+		A2: (1 - k/q + k*k) / a0,
+	}
+
+	// Stage 2: RLB high-pass.
+	f0 = 38.13547087602444
+	q = 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / fs)
+	a0 = 1 + k/q + k*k
+	highPass := dsp.Coefficients{
+		B0: 1,
+		B1: -2,
+		B2: 1,
+		A1: 2 * (k*k - 1) / a0,
+		A2: (1 - k/q + k*k) / a0,
+	}
+	return [2]dsp.Coefficients{shelf, highPass}
+}
+
+// filterState is the per-channel state of the K-weighting filter.
+type filterState [2]struct {
+	x1, x2, y1, y2 float64
+}
+
+func (s *filterState) process(k *[2]dsp.Coefficients, x float64) float64 {
+	for i := range k {
+		f, st := &k[i], &s[i]
+		y := f.B0*x + f.B1*st.x1 + f.B2*st.x2 - f.A1*st.y1 - f.A2*st.y2
+		st.x2, st.x1 = st.x1, x
+		st.y2, st.y1 = st.y1, y
+		x = y
+	}
+	return x
+}
+
+// channelWeight returns the BS.1770 weight of the given channel for the given
+// number of channels. For 5.1 streams (in the usual L, R, C, LFE, Ls, Rs
+// order) the LFE channel is excluded and the surround channels are weighted
+// by +1.5 dB.
+func channelWeight(ch, channels int) float64 {
+	if channels == 6 {
+		switch ch {
+		case 3:
+			return 0
+		case 4, 5:
+			return 1.41
+		}
+	}
+	return 1
+}
+
+// energyToLoudness converts a weighted mean square energy to LUFS.
+func energyToLoudness(e float64) float64 {
+	if e <= 0 {
+		return math.Inf(-1)
+	}
+	return -0.691 + 10*math.Log10(e)
+}
+
+// loudnessToEnergy converts LUFS to a weighted mean square energy.
+func loudnessToEnergy(l float64) float64 {
+	return math.Pow(10, (l+0.691)/10)
+}
+
+// Meter is a loudness meter. Audio samples are fed into the meter through its
+// Write method (it implements the audio.Writer interface), after which the
+// measurements reflect all of the audio written so far. Meters must be
+// allocated via the NewMeter function.
+//
+// A Meter is not safe for concurrent use by multiple goroutines.
+type Meter struct {
+	config  audio.Config
+	filter  [2]dsp.Coefficients
+	weights []float64
+	state   []filterState
+	peak    []truePeak
+
+	ch        int     // channel of the next sample
+	blockLen  int     // sub-block length in frames (100ms)
+	blockPos  int     // frames into the current sub-block
+	blockSum  float64 // weighted sum of squares in the current sub-block
+	subBlocks []float64
+
+	// Mean square energies of each 400ms gating block and each 3s
+	// short-term block (both every 100ms).
+	gating, shortTerm []float64
+}
+
+// Config returns the audio configuration of the measured stream.
+func (m *Meter) Config() audio.Config {
+	return m.config
+}
+
+// Write implements the audio.Writer interface by measuring the samples. It
+// always returns b.Len() and a nil error.
+func (m *Meter) Write(b audio.Slice) (n int, err error) {
+	for i := 0; i < b.Len(); i++ {
+		x := float64(b.At(i))
+		m.peak[m.ch].process(x)
+		y := m.state[m.ch].process(&m.filter, x)
+		m.blockSum += m.weights[m.ch] * y * y
+		m.ch++
+		if m.ch < m.config.Channels {
+			continue
+		}
+		m.ch = 0
+		m.blockPos++
+		if m.blockPos == m.blockLen {
+			m.endSubBlock()
+		}
+	}
+	return b.Len(), nil
+}
+
+// endSubBlock completes the current 100ms sub-block.
+func (m *Meter) endSubBlock() {
+	m.subBlocks = append(m.subBlocks, m.blockSum/float64(m.blockLen))
+	m.blockSum, m.blockPos = 0, 0
+
+	// Only the most recent sub-blocks are needed.
+	if n := len(m.subBlocks); n > 2*shortTermBlocks {
+		m.subBlocks = append(m.subBlocks[:0], m.subBlocks[n-shortTermBlocks:]...)
+	}
+	if e, ok := m.window(momentaryBlocks); ok {
+		m.gating = append(m.gating, e)
+	}
+	if e, ok := m.window(shortTermBlocks); ok {
+		m.shortTerm = append(m.shortTerm, e)
+	}
+}
+
+// window returns the mean energy of the last n sub-blocks, and whether that
+// many sub-blocks exist.
+func (m *Meter) window(n int) (float64, bool) {
+	if len(m.subBlocks) < n {
+		return 0, false
+	}
+	var sum float64
+	for _, e := range m.subBlocks[len(m.subBlocks)-n:] {
+		sum += e
+	}
+	return sum / float64(n), true
+}
+
+// partialWindow is like window, except when fewer than n sub-blocks exist it
+// averages those that do (for readings at the start of a stream).
+func (m *Meter) partialWindow(n int) float64 {
+	if len(m.subBlocks) < n {
+		n = len(m.subBlocks)
+	}
+	if n == 0 {
+		return 0
+	}
+	e, _ := m.window(n)
+	return e
+}
+
+// Momentary returns the momentary loudness in LUFS, i.e. the loudness of the
+// most recent 400ms of audio (measured in 100ms steps).
+func (m *Meter) Momentary() float64 {
+	return energyToLoudness(m.partialWindow(momentaryBlocks))
+}
+
+// ShortTerm returns the short-term loudness in LUFS, i.e. the loudness of the
+// most recent 3s of audio (measured in 100ms steps).
+func (m *Meter) ShortTerm() float64 {
+	return energyToLoudness(m.partialWindow(shortTermBlocks))
+}
+
+// gate returns the energies of blocks above the absolute gate, and above the
+// given relative gate.
+func gate(blocks []float64, relative float64) []float64 {
+	abs := loudnessToEnergy(absoluteGate)
+	var sum float64
+	var n int
+	for _, e := range blocks {
+		if e > abs {
+			sum += e
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	rel := loudnessToEnergy(energyToLoudness(sum/float64(n)) + relative)
+	var gated []float64
+	for _, e := range blocks {
+		if e > abs && e > rel {
+			gated = append(gated, e)
+		}
+	}
+	return gated
+}
+
+// Integrated returns the gated integrated loudness in LUFS of all the audio
+// written so far, or negative infinity if there is not enough (non-silent)
+// audio.
+func (m *Meter) Integrated() float64 {
+	gated := gate(m.gating, relativeGate)
+	if len(gated) == 0 {
+		return math.Inf(-1)
+	}
+	var sum float64
+	for _, e := range gated {
+		sum += e
+	}
+	return energyToLoudness(sum / float64(len(gated)))
+}
+
+// Range returns the loudness range (LRA) in LU of all the audio written so
+// far, as defined by EBU Tech 3342: the difference between the 10th and 95th
+// percentiles of the gated short-term loudness distribution.
+func (m *Meter) Range() float64 {
+	gated := gate(m.shortTerm, rangeGate)
+	if len(gated) == 0 {
+		return 0
+	}
+	l := make([]float64, len(gated))
+	for i, e := range gated {
+		l[i] = energyToLoudness(e)
+	}
+	sort.Float64s(l)
+	percentile := func(p float64) float64 {
+		return l[int(math.Floor(p*float64(len(l)-1)+0.5))]
+	}
+	return percentile(0.95) - percentile(0.10)
+}
+
+// TruePeak returns the maximum true-peak level in dBTP across all channels of
+// the audio written so far.
+func (m *Meter) TruePeak() float64 {
+	var p float64
+	for i := range m.peak {
+		p = math.Max(p, m.peak[i].max)
+	}
+	if p == 0 {
+		return math.Inf(-1)
+	}
+	return 20 * math.Log10(p)
+}
+
+// ChannelTruePeak returns the maximum true-peak level in dBTP of the given
+// channel of the audio written so far.
+func (m *Meter) ChannelTruePeak(ch int) float64 {
+	if m.peak[ch].max == 0 {
+		return math.Inf(-1)
+	}
+	return 20 * math.Log10(m.peak[ch].max)
+}
+
+// Reset resets the meter, discarding all measurements.
+func (m *Meter) Reset() {
+	*m = *NewMeter(m.config)
+}
+
+// NewMeter returns a new loudness meter for streams of the given audio
+// configuration.
+//
+// It panics if the sample rate is less than 10 Hz or there are no channels.
+func NewMeter(c audio.Config) *Meter {
+	if c.SampleRate < 10 || c.Channels < 1 {
+		panic("loudness: invalid audio configuration")
+	}
+	m := &Meter{
+		config:   c,
+		filter:   kWeighting(c.SampleRate),
+		weights:  make([]float64, c.Channels),
+		state:    make([]filterState, c.Channels),
+		peak:     make([]truePeak, c.Channels),
+		blockLen: c.SampleRate / 10,
+	}
+	for ch := range m.weights {
+		m.weights[ch] = channelWeight(ch, c.Channels)
+	}
+	return m
+}
+
+// Measure reads the audio stream r, whose samples are laid out according to
+// the given audio configuration, until EOS and returns a meter holding the
+// measurements of the entire stream.
+func Measure(r audio.Reader, c audio.Config) (*Meter, error) {
+	m := NewMeter(c)
+	if _, err := audio.Copy(m, r); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package loudness
+
+import (
+	"math"
+
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/dsp"
+)
+
+// Common integrated loudness targets in LUFS.
+const (
+	// EBU R128 broadcast target.
+	TargetBroadcast = -23.0
+
+	// Common target of music streaming services.
+	TargetMusic = -16.0
+)
+
+// Gain returns the gain in decibels which brings audio of the given
+// integrated loudness to the target loudness (both in LUFS). If the loudness
+// is negative infinity (i.e. silence) it returns zero.
+func Gain(loudness, target float64) float64 {
+	if math.IsInf(loudness, -1) {
+		return 0
+	}
+	return target - loudness
+}
+
+// Normalize measures the integrated loudness of the entire stream rs, whose
+// samples are laid out according to the given audio configuration, seeks back
+// to the start of the stream and returns a reader of the stream with the gain
+// applied which brings it to the target loudness in LUFS, along with that gain
+// in decibels.
+//
+// Note that a positive gain may cause the stream to clip; the true-peak level
+// after normalization is the measured true-peak plus the gain.
+func Normalize(rs audio.ReadSeeker, c audio.Config, target float64) (r *dsp.Reader, gain float64, err error) {
+	m, err := Measure(rs, c)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err = rs.Seek(0); err != nil {
+		return nil, 0, err
+	}
+	gain = Gain(m.Integrated(), target)
+	return dsp.NewReader(rs, c, dsp.NewGain(nil, c, gain)), gain, nil
+}
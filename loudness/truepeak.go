@@ -0,0 +1,56 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package loudness
+
+import "math"
+
+const (
+	// oversample is the oversampling factor of the true-peak meter.
+	oversample = 4
+
+	// peakTaps is the number of taps of each phase of the interpolation
+	// filter (the phase aligned with the input samples has one more).
+	peakTaps = 12
+)
+
+// peakFilter holds the interpolation filter of each oversampling phase: a
+// windowed-sinc low-pass filter of oversample*peakTaps+1 taps, split into its
+// polyphase components.
+var peakFilter = func() (f [oversample][]float64) {
+	n := oversample*peakTaps + 1
+	center := float64(n-1) / 2
+	for k := 0; k < n; k++ {
+		t := (float64(k) - center) / oversample
+		h := 1.0
+		if t != 0 {
+			h = math.Sin(math.Pi*t) / (math.Pi * t)
+		}
+		// Hann window.
+		h *= 0.5 + 0.5*math.Cos(math.Pi*(float64(k)-center)/(center+1))
+		f[k%oversample] = append(f[k%oversample], h)
+	}
+	return
+}()
+
+// truePeak is the state of the true-peak meter of a single channel.
+type truePeak struct {
+	hist [peakTaps + 1]float64 // most recent sample first
+	max  float64
+}
+
+// process feeds a single sample into the meter.
+func (p *truePeak) process(x float64) {
+	copy(p.hist[1:], p.hist[:peakTaps])
+	p.hist[0] = x
+	for _, h := range peakFilter {
+		var y float64
+		for j, v := range h {
+			y += v * p.hist[j]
+		}
+		if y = math.Abs(y); y > p.max {
+			p.max = y
+		}
+	}
+}
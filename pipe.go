@@ -0,0 +1,277 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audio
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrClosedPipe is the error used for read or write operations on a closed
+// pipe.
+var ErrClosedPipe = errors.New("audio: read/write on closed pipe")
+
+// A pipe is the shared pipe structure underlying PipeReader and PipeWriter.
+type pipe struct {
+	rl    sync.Mutex // gates readers one at a time
+	wl    sync.Mutex // gates writers one at a time
+	l     sync.Mutex // protects remaining fields
+	data  Slice      // data remaining in pending write (unbuffered only)
+	rwait sync.Cond  // waiting reader
+	wwait sync.Cond  // waiting writer
+	rerr  error      // if reader closed, error to give writes
+	werr  error      // if writer closed, error to give reads
+
+	// Ring buffer storage, only used by buffered pipes.
+	buf       Slice
+	off, size int
+}
+
+func (p *pipe) read(b Slice) (n int, err error) {
+	// One reader at a time.
+	p.rl.Lock()
+	defer p.rl.Unlock()
+
+	p.l.Lock()
+	defer p.l.Unlock()
+	if p.buf != nil {
+		return p.readBuffered(b)
+	}
+	for {
+		if p.rerr != nil {
+			return 0, ErrClosedPipe
+		}
+		if p.data != nil {
+			break
+		}
+		if p.werr != nil {
+			return 0, p.werr
+		}
+		p.rwait.Wait()
+	}
+	n = p.data.CopyTo(b)
+	p.data = p.data.Slice(n, p.data.Len())
+	if p.data.Len() == 0 {
+		p.data = nil
+		p.wwait.Signal()
+	}
+	return
+}
+
+func (p *pipe) readBuffered(b Slice) (n int, err error) {
+	for {
+		if p.rerr != nil {
+			return 0, ErrClosedPipe
+		}
+		if p.size > 0 || b.Len() == 0 {
+			break
+		}
+		if p.werr != nil {
+			return 0, p.werr
+		}
+		p.rwait.Wait()
+	}
+	for n < b.Len() && p.size > 0 {
+		// Copy the contiguous region starting at the read offset.
+		end := p.off + p.size
+		if end > p.buf.Len() {
+			end = p.buf.Len()
+		}
+		m := p.buf.Slice(p.off, end).CopyTo(b.Slice(n, b.Len()))
+		n += m
+		p.size -= m
+		p.off = (p.off + m) % p.buf.Len()
+	}
+	if p.size == 0 {
+		p.off = 0
+	}
+	p.wwait.Signal()
+	return
+}
+
+func (p *pipe) write(b Slice) (n int, err error) {
+	// pipe uses nil to mean not available
+	if b == nil {
+		b = F64Samples{}
+	}
+
+	// One writer at a time.
+	p.wl.Lock()
+	defer p.wl.Unlock()
+
+	p.l.Lock()
+	defer p.l.Unlock()
+	if p.buf != nil {
+		return p.writeBuffered(b)
+	}
+	if p.werr != nil {
+		err = ErrClosedPipe
+		return
+	}
+	p.data = b
+	p.rwait.Signal()
+	for {
+		if p.data == nil {
+			break
+		}
+		if p.rerr != nil {
+			err = p.rerr
+			break
+		}
+		if p.werr != nil {
+			err = ErrClosedPipe
+		}
+		p.wwait.Wait()
+	}
+	n = b.Len()
+	if p.data != nil {
+		n -= p.data.Len()
+	}
+	p.data = nil // in case of rerr or werr
+	return
+}
+
+func (p *pipe) writeBuffered(b Slice) (n int, err error) {
+	capacity := p.buf.Len()
+	for n < b.Len() {
+		if p.werr != nil {
+			return n, ErrClosedPipe
+		}
+		if p.rerr != nil {
+			return n, p.rerr
+		}
+		if p.size == capacity {
+			p.wwait.Wait()
+			continue
+		}
+		// Copy into the contiguous free region starting at the write offset.
+		start := (p.off + p.size) % capacity
+		end := capacity
+		if start < p.off {
+			end = p.off
+		}
+		m := b.Slice(n, b.Len()).CopyTo(p.buf.Slice(start, end))
+		n += m
+		p.size += m
+		p.rwait.Signal()
+	}
+	return
+}
+
+func (p *pipe) rclose(err error) {
+	if err == nil {
+		err = ErrClosedPipe
+	}
+	p.l.Lock()
+	defer p.l.Unlock()
+	p.rerr = err
+	p.rwait.Signal()
+	p.wwait.Signal()
+}
+
+func (p *pipe) wclose(err error) {
+	if err == nil {
+		err = EOS
+	}
+	p.l.Lock()
+	defer p.l.Unlock()
+	p.werr = err
+	p.rwait.Signal()
+	p.wwait.Signal()
+}
+
+// A PipeReader is the read half of a pipe.
+type PipeReader struct {
+	p *pipe
+}
+
+// Read implements the standard Read interface: it reads data from the pipe,
+// blocking until a writer arrives or the write end is closed. If the write end
+// is closed with an error, that error is returned as err; otherwise err is
+// EOS.
+func (r *PipeReader) Read(b Slice) (n int, err error) {
+	return r.p.read(b)
+}
+
+// Close closes the reader; subsequent writes to the write half of the pipe
+// will return the error ErrClosedPipe.
+func (r *PipeReader) Close() error {
+	return r.CloseWithError(nil)
+}
+
+// CloseWithError closes the reader; subsequent writes to the write half of the
+// pipe will return the error err.
+func (r *PipeReader) CloseWithError(err error) error {
+	r.p.rclose(err)
+	return nil
+}
+
+// A PipeWriter is the write half of a pipe.
+type PipeWriter struct {
+	p *pipe
+}
+
+// Write implements the standard Write interface: it writes data to the pipe,
+// blocking until readers have consumed all the data (or, for a buffered pipe,
+// until all of the data fits into the buffer) or the read end is closed. If
+// the read end is closed with an error, that err is returned as err; otherwise
+// err is ErrClosedPipe.
+func (w *PipeWriter) Write(b Slice) (n int, err error) {
+	return w.p.write(b)
+}
+
+// Close closes the writer; subsequent reads from the read half of the pipe
+// will return no samples and EOS.
+func (w *PipeWriter) Close() error {
+	return w.CloseWithError(nil)
+}
+
+// CloseWithError closes the writer; subsequent reads from the read half of the
+// pipe will return no samples and the error err, or EOS if err is nil.
+//
+// CloseWithError always returns nil.
+func (w *PipeWriter) CloseWithError(err error) error {
+	w.p.wclose(err)
+	return nil
+}
+
+func newPipe(buf Slice) (*PipeReader, *PipeWriter) {
+	p := &pipe{buf: buf}
+	p.rwait.L = &p.l
+	p.wwait.L = &p.l
+	r := &PipeReader{p}
+	w := &PipeWriter{p}
+	return r, w
+}
+
+// Pipe creates a synchronous in-memory pipe. It can be used to connect code
+// expecting an audio.Reader with code expecting an audio.Writer. Reads on one
+// end are matched with writes on the other, copying data directly between
+// the two; there is no internal buffering. The samples are copied using the
+// CopyTo method of the written slice, so the reader and writer may use any
+// (even differing) Slice types.
+//
+// It is safe to call Read and Write in parallel with each other or with Close.
+// Close will complete once pending I/O is done. Parallel calls to Read, and
+// parallel calls to Write, are also safe: the individual calls will be gated
+// sequentially.
+func Pipe() (*PipeReader, *PipeWriter) {
+	return newPipe(nil)
+}
+
+// BufferedPipe creates an in-memory pipe like Pipe, except that up to
+// buf.Len() samples may be written to the pipe before a Write blocks, which is
+// useful for absorbing jitter between the reading and writing goroutines. The
+// pipe will internally use the given slice, buf, which also defines the sample
+// storage type of the buffer.
+//
+// If buf.Len() is zero, the returned pipe is unbuffered (i.e. it is identical
+// to one created via Pipe).
+func BufferedPipe(buf Slice) (*PipeReader, *PipeWriter) {
+	if buf != nil && buf.Len() == 0 {
+		buf = nil
+	}
+	return newPipe(buf)
+}
@@ -0,0 +1,105 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audio
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPipeIO(t *testing.T) {
+	r, w := Pipe()
+	_ = Reader(r)
+	_ = Writer(w)
+}
+
+func testPipeCopy(t *testing.T, r *PipeReader, w *PipeWriter) {
+	src := make(F64Samples, 1000)
+	for i := range src {
+		src[i] = F64(i%200) / 200
+	}
+	go func() {
+		for i := 0; i < len(src); i += 70 {
+			end := i + 70
+			if end > len(src) {
+				end = len(src)
+			}
+			if _, err := w.Write(src[i:end]); err != nil {
+				t.Error(err)
+			}
+		}
+		w.Close()
+	}()
+
+	// Read into a differing slice type, in differing chunk sizes.
+	dst := make(F32Samples, 0, len(src))
+	buf := make(F32Samples, 33)
+	for {
+		n, err := r.Read(buf)
+		dst = append(dst, buf[:n]...)
+		if err == EOS {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(dst) != len(src) {
+		t.Fatalf("got %d samples, want %d", len(dst), len(src))
+	}
+	for i := range src {
+		if dst[i] != F32(src[i]) {
+			t.Fatalf("sample %d: got %v, want %v", i, dst[i], src[i])
+		}
+	}
+}
+
+func TestPipeCopy(t *testing.T) {
+	r, w := Pipe()
+	testPipeCopy(t, r, w)
+}
+
+func TestBufferedPipeCopy(t *testing.T) {
+	r, w := BufferedPipe(make(F32Samples, 128))
+	testPipeCopy(t, r, w)
+}
+
+func TestPipeCloseWithError(t *testing.T) {
+	errTest := errors.New("test error")
+	for _, buffered := range []bool{false, true} {
+		var r *PipeReader
+		var w *PipeWriter
+		if buffered {
+			r, w = BufferedPipe(make(F64Samples, 16))
+		} else {
+			r, w = Pipe()
+		}
+		w.CloseWithError(errTest)
+		if _, err := r.Read(make(F64Samples, 4)); err != errTest {
+			t.Fatalf("buffered=%v: Read got %v, want %v", buffered, err, errTest)
+		}
+
+		r.CloseWithError(errTest)
+		if _, err := w.Write(make(F64Samples, 4)); err == nil {
+			t.Fatalf("buffered=%v: Write on closed pipe got nil error", buffered)
+		}
+	}
+}
+
+func TestBufferedPipeNonBlocking(t *testing.T) {
+	// Writes that fit within the buffer must not block without a reader.
+	r, w := BufferedPipe(make(F64Samples, 8))
+	if n, err := w.Write(make(F64Samples, 8)); n != 8 || err != nil {
+		t.Fatalf("Write got (%d, %v), want (8, nil)", n, err)
+	}
+	w.Close()
+	n, err := r.Read(make(F64Samples, 16))
+	if n != 8 || err != nil {
+		t.Fatalf("Read got (%d, %v), want (8, nil)", n, err)
+	}
+	if _, err := r.Read(make(F64Samples, 16)); err != EOS {
+		t.Fatalf("Read got %v, want EOS", err)
+	}
+}
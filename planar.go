@@ -0,0 +1,393 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audio
+
+// Planar is a generic planar (i.e. non-interleaved) audio slice, where the
+// samples of each channel are stored in a separate slice.
+//
+// Through the Slice interface a planar slice acts as an interleaved view of
+// its channels, such that index i refers to the sample in channel
+// i % Channels() of frame i / Channels(). Slicing a planar slice at
+// frame-aligned indices returns a planar slice of the same type, otherwise an
+// (interleaved) view of the planar slice is returned.
+type Planar interface {
+	Slice
+
+	// Channels returns the number of channels in the planar slice.
+	Channels() int
+
+	// Channel returns the slice of samples of the given channel.
+	Channel(ch int) Slice
+}
+
+// planarView is an unaligned interleaved view of a planar slice; used when a
+// planar slice is sliced at indices that are not a multiple of the number of
+// channels.
+type planarView struct {
+	base      Planar // extends to the full capacity
+	low, high int
+}
+
+// Implements Slice interface.
+func (v planarView) Len() int {
+	return v.high - v.low
+}
+
+// Implements Slice interface.
+func (v planarView) Cap() int {
+	return v.base.Len() - v.low
+}
+
+// Implements Slice interface.
+func (v planarView) At(i int) F64 {
+	if i < 0 || i >= v.Len() {
+		panic("audio: planar view index out of range")
+	}
+	return v.base.At(v.low + i)
+}
+
+// Implements Slice interface.
+func (v planarView) Set(i int, s F64) {
+	if i < 0 || i >= v.Len() {
+		panic("audio: planar view index out of range")
+	}
+	v.base.Set(v.low+i, s)
+}
+
+// Implements Slice interface.
+func (v planarView) Slice(low, high int) Slice {
+	if low < 0 || high < low || high > v.Cap() {
+		panic("audio: planar view slice bounds out of range")
+	}
+	return v.base.Slice(v.low+low, v.low+high)
+}
+
+// Implements Slice interface.
+func (v planarView) Make(length, capacity int) Slice {
+	return v.base.Make(length, capacity)
+}
+
+// Implements Slice interface.
+func (v planarView) CopyTo(dst Slice) int {
+	return sliceCopy(dst, v)
+}
+
+// planarFrames returns the per-channel length needed to hold the given number
+// of interleaved samples.
+func planarFrames(samples, channels int) int {
+	return (samples + channels - 1) / channels
+}
+
+// PlanarF32 represents planar F32 encoded audio samples, with one slice of
+// samples per channel. All channels must have the same length and capacity.
+type PlanarF32 []F32Samples
+
+// NewPlanarF32 returns a new planar slice with the given number of channels,
+// each holding the given number of frames.
+func NewPlanarF32(channels, frames int) PlanarF32 {
+	p := make(PlanarF32, channels)
+	for ch := range p {
+		p[ch] = make(F32Samples, frames)
+	}
+	return p
+}
+
+// Implements Planar interface.
+func (p PlanarF32) Channels() int {
+	return len(p)
+}
+
+// Implements Planar interface.
+func (p PlanarF32) Channel(ch int) Slice {
+	return p[ch]
+}
+
+// Implements Slice interface.
+func (p PlanarF32) Len() int {
+	if len(p) == 0 {
+		return 0
+	}
+	return len(p) * len(p[0])
+}
+
+// Implements Slice interface.
+func (p PlanarF32) Cap() int {
+	if len(p) == 0 {
+		return 0
+	}
+	return len(p) * cap(p[0])
+}
+
+// Implements Slice interface.
+func (p PlanarF32) At(i int) F64 {
+	return F64(p[i%len(p)][i/len(p)])
+}
+
+// Implements Slice interface.
+func (p PlanarF32) Set(i int, s F64) {
+	p[i%len(p)][i/len(p)] = F32(s)
+}
+
+// Implements Slice interface.
+func (p PlanarF32) Slice(low, high int) Slice {
+	c := len(p)
+	if c == 0 {
+		return p
+	}
+	if low%c == 0 && high%c == 0 {
+		q := make(PlanarF32, c)
+		for ch := range p {
+			q[ch] = p[ch][low/c : high/c]
+		}
+		return q
+	}
+	if low < 0 || high < low || high > p.Cap() {
+		panic("audio: planar slice bounds out of range")
+	}
+	full := make(PlanarF32, c)
+	for ch := range p {
+		full[ch] = p[ch][:cap(p[ch])]
+	}
+	return planarView{base: full, low: low, high: high}
+}
+
+// Make implements the Slice interface. The returned slice has the same number
+// of channels; if length is not a multiple of the number of channels then an
+// unaligned view of a larger planar slice is returned.
+func (p PlanarF32) Make(length, capacity int) Slice {
+	c := len(p)
+	if c == 0 {
+		panic("audio: Make of planar slice with no channels")
+	}
+	q := make(PlanarF32, c)
+	frames := planarFrames(length, c)
+	for ch := range q {
+		q[ch] = make(F32Samples, frames, planarFrames(capacity, c))
+	}
+	if length%c != 0 {
+		return q.Slice(0, length)
+	}
+	return q
+}
+
+// Implements Slice interface.
+func (p PlanarF32) CopyTo(dst Slice) int {
+	if d, ok := dst.(PlanarF32); ok && len(d) == len(p) {
+		var n int
+		for ch := range p {
+			n = copy(d[ch], p[ch])
+		}
+		return n * len(p)
+	}
+	return Interleave(dst, p)
+}
+
+// PlanarF64 represents planar F64 encoded audio samples, with one slice of
+// samples per channel. All channels must have the same length and capacity.
+type PlanarF64 []F64Samples
+
+// NewPlanarF64 returns a new planar slice with the given number of channels,
+// each holding the given number of frames.
+func NewPlanarF64(channels, frames int) PlanarF64 {
+	p := make(PlanarF64, channels)
+	for ch := range p {
+		p[ch] = make(F64Samples, frames)
+	}
+	return p
+}
+
+// Implements Planar interface.
+func (p PlanarF64) Channels() int {
+	return len(p)
+}
+
+// Implements Planar interface.
+func (p PlanarF64) Channel(ch int) Slice {
+	return p[ch]
+}
+
+// Implements Slice interface.
+func (p PlanarF64) Len() int {
+	if len(p) == 0 {
+		return 0
+	}
+	return len(p) * len(p[0])
+}
+
+// Implements Slice interface.
+func (p PlanarF64) Cap() int {
+	if len(p) == 0 {
+		return 0
+	}
+	return len(p) * cap(p[0])
+}
+
+// Implements Slice interface.
+func (p PlanarF64) At(i int) F64 {
+	return p[i%len(p)][i/len(p)]
+}
+
+// Implements Slice interface.
+func (p PlanarF64) Set(i int, s F64) {
+	p[i%len(p)][i/len(p)] = s
+}
+
+// Implements Slice interface.
+func (p PlanarF64) Slice(low, high int) Slice {
+	c := len(p)
+	if c == 0 {
+		return p
+	}
+	if low%c == 0 && high%c == 0 {
+		q := make(PlanarF64, c)
+		for ch := range p {
+			q[ch] = p[ch][low/c : high/c]
+		}
+		return q
+	}
+	if low < 0 || high < low || high > p.Cap() {
+		panic("audio: planar slice bounds out of range")
+	}
+	full := make(PlanarF64, c)
+	for ch := range p {
+		full[ch] = p[ch][:cap(p[ch])]
+	}
+	return planarView{base: full, low: low, high: high}
+}
+
+// Make implements the Slice interface. The returned slice has the same number
+// of channels; if length is not a multiple of the number of channels then an
+// unaligned view of a larger planar slice is returned.
+func (p PlanarF64) Make(length, capacity int) Slice {
+	c := len(p)
+	if c == 0 {
+		panic("audio: Make of planar slice with no channels")
+	}
+	q := make(PlanarF64, c)
+	frames := planarFrames(length, c)
+	for ch := range q {
+		q[ch] = make(F64Samples, frames, planarFrames(capacity, c))
+	}
+	if length%c != 0 {
+		return q.Slice(0, length)
+	}
+	return q
+}
+
+// Implements Slice interface.
+func (p PlanarF64) CopyTo(dst Slice) int {
+	if d, ok := dst.(PlanarF64); ok && len(d) == len(p) {
+		var n int
+		for ch := range p {
+			n = copy(d[ch], p[ch])
+		}
+		return n * len(p)
+	}
+	return Interleave(dst, p)
+}
+
+// Interleave copies the samples of the planar slice src into the interleaved
+// slice dst, such that frame i of src is stored at dst[i*c:(i+1)*c] where c
+// is src.Channels(). It returns the number of samples copied, which is the
+// minimum of dst.Len() and src.Len().
+//
+// Copies between F32 and F64 encoded slices are performed without per-sample
+// interface method calls.
+func Interleave(dst Slice, src Planar) int {
+	n := src.Len()
+	if dst.Len() < n {
+		n = dst.Len()
+	}
+	c := src.Channels()
+	switch s := src.(type) {
+	case PlanarF64:
+		switch d := dst.(type) {
+		case F64Samples:
+			for ch, in := range s {
+				for i, o := 0, ch; o < n; i, o = i+1, o+c {
+					d[o] = in[i]
+				}
+			}
+			return n
+		case F32Samples:
+			for ch, in := range s {
+				for i, o := 0, ch; o < n; i, o = i+1, o+c {
+					d[o] = F32(in[i])
+				}
+			}
+			return n
+		}
+	case PlanarF32:
+		switch d := dst.(type) {
+		case F64Samples:
+			for ch, in := range s {
+				for i, o := 0, ch; o < n; i, o = i+1, o+c {
+					d[o] = F64(in[i])
+				}
+			}
+			return n
+		case F32Samples:
+			for ch, in := range s {
+				for i, o := 0, ch; o < n; i, o = i+1, o+c {
+					d[o] = in[i]
+				}
+			}
+			return n
+		}
+	}
+	return sliceCopy(dst.Slice(0, n), src)
+}
+
+// Deinterleave copies the samples of the interleaved slice src into the
+// planar slice dst, such that src[i*c:(i+1)*c] is stored as frame i of dst
+// where c is dst.Channels(). It returns the number of samples copied, which is
+// the minimum of dst.Len() and src.Len().
+//
+// Copies between F32 and F64 encoded slices are performed without per-sample
+// interface method calls.
+func Deinterleave(dst Planar, src Slice) int {
+	n := src.Len()
+	if dst.Len() < n {
+		n = dst.Len()
+	}
+	c := dst.Channels()
+	switch d := dst.(type) {
+	case PlanarF64:
+		switch s := src.(type) {
+		case F64Samples:
+			for ch, out := range d {
+				for i, o := 0, ch; o < n; i, o = i+1, o+c {
+					out[i] = s[o]
+				}
+			}
+			return n
+		case F32Samples:
+			for ch, out := range d {
+				for i, o := 0, ch; o < n; i, o = i+1, o+c {
+					out[i] = F64(s[o])
+				}
+			}
+			return n
+		}
+	case PlanarF32:
+		switch s := src.(type) {
+		case F64Samples:
+			for ch, out := range d {
+				for i, o := 0, ch; o < n; i, o = i+1, o+c {
+					out[i] = F32(s[o])
+				}
+			}
+			return n
+		case F32Samples:
+			for ch, out := range d {
+				for i, o := 0, ch; o < n; i, o = i+1, o+c {
+					out[i] = s[o]
+				}
+			}
+			return n
+		}
+	}
+	return sliceCopy(dst, src.Slice(0, n))
+}
@@ -0,0 +1,71 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audio
+
+import "testing"
+
+func TestPlanarInterleave(t *testing.T) {
+	src := F64Samples{0, 0.5, 0.125, 0.625, 0.25, 0.75}
+	for _, p := range []Planar{NewPlanarF64(2, 3), NewPlanarF32(2, 3)} {
+		if n := Deinterleave(p, src); n != 6 {
+			t.Fatalf("%T: Deinterleave got %d, want 6", p, n)
+		}
+		for i := 0; i < 3; i++ {
+			if l, r := p.Channel(0).At(i), p.Channel(1).At(i); l != F64(i)/8 || r != 0.5+F64(i)/8 {
+				t.Fatalf("%T: frame %d got (%v, %v)", p, i, l, r)
+			}
+		}
+		for i := range src {
+			if p.At(i) != src[i] {
+				t.Fatalf("%T: At(%d) got %v, want %v", p, i, p.At(i), src[i])
+			}
+		}
+
+		// Fast and generic interleaving paths.
+		for _, dst := range []Slice{make(F64Samples, 6), make(PCM32Samples, 6)} {
+			if n := Interleave(dst, p); n != 6 {
+				t.Fatalf("%T: Interleave got %d, want 6", p, n)
+			}
+			for i := range src {
+				if d := dst.At(i) - src[i]; d > 1e-6 || d < -1e-6 {
+					t.Fatalf("%T: sample %d got %v, want %v", dst, i, dst.At(i), src[i])
+				}
+			}
+		}
+	}
+}
+
+func TestPlanarSlice(t *testing.T) {
+	p := NewPlanarF64(2, 4)
+	for i := 0; i < p.Len(); i++ {
+		p.Set(i, F64(i))
+	}
+	if _, ok := p.Slice(2, 6).(PlanarF64); !ok {
+		t.Fatal("frame-aligned Slice did not return a PlanarF64")
+	}
+	v := p.Slice(1, 6)
+	if v.Len() != 5 || v.At(0) != 1 || v.At(4) != 5 {
+		t.Fatalf("unaligned Slice got Len=%d At(0)=%v At(4)=%v", v.Len(), v.At(0), v.At(4))
+	}
+}
+
+func TestPlanarBuffer(t *testing.T) {
+	// A Buffer using planar storage must accept unaligned writes.
+	buf := NewBuffer(NewPlanarF32(2, 0))
+	for i := 0; i < 7; i++ {
+		buf.WriteSample(F64(i))
+	}
+	buf.Write(F64Samples{7, 8, 9})
+	out := make(F64Samples, 16)
+	n, err := buf.Read(out)
+	if n != 10 || err != nil {
+		t.Fatalf("Read got (%d, %v), want (10, nil)", n, err)
+	}
+	for i := 0; i < n; i++ {
+		if out[i] != F64(i) {
+			t.Fatalf("sample %d got %v", i, out[i])
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package render
+
+import "image/color"
+
+// ColorMap maps a normalized intensity value in the range of 0 to 1 into a
+// color.
+type ColorMap func(v float64) color.RGBA
+
+// gradient returns a color map that linearly interpolates between the evenly
+// spaced color stops.
+func gradient(stops ...color.RGBA) ColorMap {
+	return func(v float64) color.RGBA {
+		switch {
+		case v <= 0:
+			return stops[0]
+		case v >= 1:
+			return stops[len(stops)-1]
+		}
+		x := v * float64(len(stops)-1)
+		i := int(x)
+		t := x - float64(i)
+		a, b := stops[i], stops[i+1]
+		lerp := func(a, b uint8) uint8 {
+			return uint8(float64(a) + (float64(b)-float64(a))*t + 0.5)
+		}
+		return color.RGBA{lerp(a.R, b.R), lerp(a.G, b.G), lerp(a.B, b.B), lerp(a.A, b.A)}
+	}
+}
+
+var (
+	// Grayscale maps intensities from black to white.
+	Grayscale = gradient(
+		color.RGBA{0, 0, 0, 255},
+		color.RGBA{255, 255, 255, 255},
+	)
+
+	// Heat maps intensities from black through purple, red and yellow to
+	// white, similar to the common "inferno" color map.
+	Heat = gradient(
+		color.RGBA{0, 0, 4, 255},
+		color.RGBA{87, 16, 110, 255},
+		color.RGBA{188, 55, 84, 255},
+		color.RGBA{249, 142, 9, 255},
+		color.RGBA{252, 255, 164, 255},
+	)
+)
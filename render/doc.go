@@ -0,0 +1,11 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package render renders audio streams into images, like waveform overviews
+// and spectrograms.
+//
+// All of the rendering functions consume an audio.Reader until EOS and return
+// an *image.RGBA, making them suitable for generating thumbnails of decoded
+// audio files.
+package render
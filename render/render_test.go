@@ -0,0 +1,118 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package render
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"azul3d.org/audio.v1"
+)
+
+func sine(c audio.Config, freq, amp float64, frames int) *audio.Buffer {
+	s := make(audio.F64Samples, frames*c.Channels)
+	for f := 0; f < frames; f++ {
+		v := audio.F64(amp * math.Sin(2*math.Pi*freq*float64(f)/float64(c.SampleRate)))
+		for ch := 0; ch < c.Channels; ch++ {
+			s[f*c.Channels+ch] = v
+		}
+	}
+	return audio.NewBuffer(s)
+}
+
+func TestWaveform(t *testing.T) {
+	c := audio.Config{SampleRate: 8000, Channels: 2}
+	peak := color.RGBA{255, 0, 0, 255}
+	img, err := Waveform(sine(c, 100, 0.5, 8000), c, WaveformOptions{
+		Width:  100,
+		Height: 100,
+		Peak:   peak,
+		RMS:    peak,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each lane is 50 pixels high, so a 0.5 amplitude sine spans the middle
+	// half of each lane.
+	for _, y := range []int{25, 14, 36, 75} {
+		if img.RGBAAt(50, y) != peak {
+			t.Errorf("pixel (50, %d) not drawn", y)
+		}
+	}
+	for _, y := range []int{5, 45, 55, 95} {
+		if img.RGBAAt(50, y) == peak {
+			t.Errorf("pixel (50, %d) drawn", y)
+		}
+	}
+}
+
+func TestSpectrogram(t *testing.T) {
+	c := audio.Config{SampleRate: 8000, Channels: 1}
+	for _, scale := range []FrequencyScale{LinearScale, LogScale, MelScale} {
+		img, err := Spectrogram(sine(c, 1000, 1, 16000), c, SpectrogramOptions{
+			Width:    64,
+			Height:   64,
+			Scale:    scale,
+			ColorMap: Grayscale,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Find the brightest row in the middle column.
+		var best, bestY int
+		for y := 0; y < 64; y++ {
+			if v := int(img.RGBAAt(32, y).R); v > best {
+				best, bestY = v, y
+			}
+		}
+		if best < 250 {
+			t.Fatalf("scale %v: brightest pixel %d, want ~255", scale, best)
+		}
+		lo, hi := scale.toScale(0), scale.toScale(4000)
+		if scale == LogScale {
+			lo = scale.toScale(20)
+		}
+		freq := scale.fromScale(hi - (hi-lo)*(float64(bestY)+0.5)/64)
+		if math.Abs(freq-1000)/1000 > 0.1 {
+			t.Fatalf("scale %v: brightest row at %v Hz, want ~1000 Hz", scale, freq)
+		}
+	}
+}
+
+func TestSpectrogramLong(t *testing.T) {
+	// Many more frames than columns: a tone for the first half of the stream,
+	// followed by silence.
+	c := audio.Config{SampleRate: 8000, Channels: 1}
+	s := make(audio.F64Samples, 8*8000)
+	for f := 0; f < len(s)/2; f++ {
+		s[f] = audio.F64(math.Sin(2 * math.Pi * 1000 * float64(f) / 8000))
+	}
+	img, err := Spectrogram(audio.NewBuffer(s), c, SpectrogramOptions{
+		Width:    8,
+		Height:   16,
+		ColorMap: Grayscale,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Find the brightest row in the first column.
+	var best uint8
+	var y int
+	for i := 0; i < 16; i++ {
+		if v := img.RGBAAt(0, i).R; v > best {
+			best, y = v, i
+		}
+	}
+	for x := 0; x < 8; x++ {
+		v := img.RGBAAt(x, y).R
+		if x < 3 && v < 250 {
+			t.Errorf("pixel (%d, %d) = %d, want ~255", x, y, v)
+		}
+		if x > 4 && v != 0 {
+			t.Errorf("pixel (%d, %d) = %d, want 0", x, y, v)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package render
+
+import (
+	"image"
+	"math"
+
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/spectral"
+)
+
+// FrequencyScale describes how frequencies are mapped onto the vertical axis
+// of a spectrogram.
+type FrequencyScale uint8
+
+const (
+	// LinearScale maps frequencies linearly.
+	LinearScale FrequencyScale = iota
+
+	// LogScale maps frequencies logarithmically, such that each octave has
+	// the same height.
+	LogScale
+
+	// MelScale maps frequencies according to the mel scale of perceived
+	// pitch.
+	MelScale
+)
+
+// toScale and fromScale convert a frequency in Hz to and from the scale.
+func (s FrequencyScale) toScale(f float64) float64 {
+	switch s {
+	case LogScale:
+		return math.Log2(f)
+	case MelScale:
+		return 2595 * math.Log10(1+f/700)
+	}
+	return f
+}
+
+func (s FrequencyScale) fromScale(v float64) float64 {
+	switch s {
+	case LogScale:
+		return math.Exp2(v)
+	case MelScale:
+		return 700 * (math.Pow(10, v/2595) - 1)
+	}
+	return v
+}
+
+// SpectrogramOptions describes how a spectrogram is rendered. The zero value
+// of each field selects a default.
+type SpectrogramOptions struct {
+	// Width and Height are the size of the image in pixels. Time runs left to
+	// right and frequency bottom to top. Defaults to 512x128.
+	Width, Height int
+
+	// Size and Hop are the FFT size and the hop size between transforms, in
+	// sample frames. Default to 1024 and Size/4.
+	Size, Hop int
+
+	// Window is the window function. Defaults to spectral.Hann(Size).
+	Window []float64
+
+	// Scale is the frequency scale of the vertical axis.
+	Scale FrequencyScale
+
+	// MinFreq and MaxFreq are the range of frequencies shown, in Hz. Default
+	// to zero (20 Hz for LogScale) and the Nyquist frequency.
+	MinFreq, MaxFreq float64
+
+	// MinDB and MaxDB are the range of levels mapped onto the color map, in
+	// decibels relative to a full scale sine wave. Default to -100 and 0.
+	MinDB, MaxDB float64
+
+	// ColorMap maps levels onto colors. Defaults to Heat.
+	ColorMap ColorMap
+}
+
+// Spectrogram renders a spectrogram of the audio stream r, whose samples are
+// laid out according to the given audio configuration, by reading it until
+// EOS. The spectra of all channels are averaged, and each pixel shows the
+// highest level of the time/frequency region it covers.
+func Spectrogram(r audio.Reader, c audio.Config, o SpectrogramOptions) (*image.RGBA, error) {
+	if o.Width <= 0 {
+		o.Width = 512
+	}
+	if o.Height <= 0 {
+		o.Height = 128
+	}
+	if o.Size <= 0 {
+		o.Size = 1024
+	}
+	if o.Hop <= 0 {
+		o.Hop = o.Size / 4
+		if o.Hop == 0 {
+			o.Hop = 1
+		}
+	}
+	if o.Window == nil {
+		o.Window = spectral.Hann(o.Size)
+	}
+	nyquist := float64(c.SampleRate) / 2
+	if o.MaxFreq <= 0 || o.MaxFreq > nyquist {
+		o.MaxFreq = nyquist
+	}
+	if o.MinFreq <= 0 && o.Scale == LogScale {
+		o.MinFreq = 20
+	}
+	if o.MinDB == 0 && o.MaxDB == 0 {
+		o.MinDB, o.MaxDB = -100, 0
+	}
+	if o.ColorMap == nil {
+		o.ColorMap = Heat
+	}
+
+	// Determine the range of FFT bins covered by each row, from the top.
+	bins := o.Size/2 + 1
+	binHz := float64(c.SampleRate) / float64(o.Size)
+	lo, hi := o.Scale.toScale(o.MinFreq), o.Scale.toScale(o.MaxFreq)
+	rowBins := make([][2]int, o.Height)
+	for y := range rowBins {
+		top := o.Scale.fromScale(hi - (hi-lo)*float64(y)/float64(o.Height))
+		bottom := o.Scale.fromScale(hi - (hi-lo)*float64(y+1)/float64(o.Height))
+		b0 := int(math.Floor(bottom/binHz + 0.5))
+		b1 := int(math.Floor(top/binHz + 0.5))
+		if b0 >= bins {
+			b0 = bins - 1
+		}
+		if b1 < b0 {
+			b1 = b0
+		}
+		if b1 >= bins {
+			b1 = bins - 1
+		}
+		rowBins[y] = [2]int{b0, b1}
+	}
+
+	// Normalize such that a full scale sine wave is at 0 dB.
+	var wsum float64
+	for _, w := range o.Window {
+		wsum += w
+	}
+	norm := 2 / wsum
+
+	// Analyze the stream, keeping the highest level of each row per column
+	// of colLen frames. Whenever there are too many columns, pairs are merged
+	// and colLen doubled; this bounds memory usage for long streams.
+	stft := spectral.NewSTFT(r, c, o.Size, o.Hop, o.Window)
+	cols := make([][]float64, 2*o.Width) // [column][row]
+	n, colLen, inCol := 0, 1, 0
+	for {
+		f, err := stft.Next()
+		if err == audio.EOS {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if inCol == 0 {
+			if cols[n] == nil {
+				cols[n] = make([]float64, o.Height)
+			}
+			for y := range cols[n] {
+				cols[n][y] = math.Inf(-1)
+			}
+		}
+		col := cols[n]
+		for y, rb := range rowBins {
+			var m float64
+			for bin := rb[0]; bin <= rb[1]; bin++ {
+				var sum float64
+				for ch := range f.Magnitude {
+					sum += f.Magnitude[ch][bin]
+				}
+				m = math.Max(m, sum/float64(len(f.Magnitude)))
+			}
+			col[y] = math.Max(col[y], spectral.Decibels(m*norm))
+		}
+		inCol++
+		if inCol < colLen {
+			continue
+		}
+		inCol = 0
+		n++
+		if n < len(cols) {
+			continue
+		}
+		// Too many columns; merge pairs and double the column length.
+		for i := 0; i < n/2; i++ {
+			for y := range cols[i] {
+				cols[i][y] = math.Max(cols[2*i][y], cols[2*i+1][y])
+			}
+		}
+		n /= 2
+		colLen *= 2
+	}
+	if inCol > 0 {
+		n++
+	}
+	levels := cols[:n]
+
+	img := image.NewRGBA(image.Rect(0, 0, o.Width, o.Height))
+	if len(levels) == 0 {
+		return img, nil
+	}
+	for x := 0; x < o.Width; x++ {
+		start := x * len(levels) / o.Width
+		end := (x + 1) * len(levels) / o.Width
+		if end <= start {
+			end = start + 1
+		}
+		for y := 0; y < o.Height; y++ {
+			db := math.Inf(-1)
+			for _, col := range levels[start:end] {
+				db = math.Max(db, col[y])
+			}
+			img.SetRGBA(x, y, o.ColorMap((db-o.MinDB)/(o.MaxDB-o.MinDB)))
+		}
+	}
+	return img, nil
+}
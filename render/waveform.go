@@ -0,0 +1,196 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"azul3d.org/audio.v1"
+)
+
+// WaveformOptions describes how a waveform overview is rendered. The zero
+// value of each field selects a default.
+type WaveformOptions struct {
+	// Width and Height are the size of the image in pixels. Each channel is
+	// drawn in its own horizontal lane. Defaults to 512x128.
+	Width, Height int
+
+	// Background is the background color. Defaults to transparent.
+	Background color.Color
+
+	// Peak is the color of the min/max envelope. Defaults to a dark blue.
+	Peak color.Color
+
+	// RMS is the color of the RMS envelope, drawn over the min/max envelope.
+	// Defaults to a light blue.
+	RMS color.Color
+}
+
+// waveBlock summarizes the samples of a single channel over a block of
+// sample frames.
+type waveBlock struct {
+	min, max, sumSq float64
+}
+
+func (b *waveBlock) add(v float64) {
+	b.min = math.Min(b.min, v)
+	b.max = math.Max(b.max, v)
+	b.sumSq += v * v
+}
+
+func (b *waveBlock) merge(o waveBlock) {
+	b.min = math.Min(b.min, o.min)
+	b.max = math.Max(b.max, o.max)
+	b.sumSq += o.sumSq
+}
+
+// maxWaveBlocks is the maximum number of blocks kept per channel while
+// summarizing a stream; it bounds memory usage for long streams.
+const maxWaveBlocks = 1 << 15
+
+// summarize reads the entire stream and summarizes each channel into blocks
+// of blockLen sample frames, returning the blocks (indexed [channel][block]),
+// the block length and the total number of sample frames read.
+func summarize(r audio.Reader, c audio.Config) (blocks [][]waveBlock, blockLen, frames int, err error) {
+	fr := audio.NewFrameReader(r, c)
+	buf := make(audio.F64Samples, 4096*c.Channels)
+	blocks = make([][]waveBlock, c.Channels)
+	cur := make([]waveBlock, c.Channels)
+	blockLen = 64
+	var inBlock int
+	for {
+		n, rerr := fr.Read(buf)
+		for f := 0; f < n/c.Channels; f++ {
+			if inBlock == 0 {
+				for ch := range cur {
+					cur[ch] = waveBlock{min: math.Inf(1), max: math.Inf(-1)}
+				}
+			}
+			for ch := range cur {
+				cur[ch].add(float64(buf[f*c.Channels+ch]))
+			}
+			frames++
+			inBlock++
+			if inBlock < blockLen {
+				continue
+			}
+			inBlock = 0
+			for ch := range blocks {
+				blocks[ch] = append(blocks[ch], cur[ch])
+			}
+			if len(blocks[0]) < maxWaveBlocks {
+				continue
+			}
+			// Too many blocks; merge pairs and double the block length.
+			for ch, b := range blocks {
+				for i := 0; i < len(b)/2; i++ {
+					b[i] = b[2*i]
+					b[i].merge(b[2*i+1])
+				}
+				blocks[ch] = b[:len(b)/2]
+			}
+			blockLen *= 2
+		}
+		if rerr == audio.EOS {
+			break
+		}
+		if rerr != nil {
+			return nil, 0, 0, rerr
+		}
+	}
+	if inBlock > 0 {
+		for ch := range blocks {
+			blocks[ch] = append(blocks[ch], cur[ch])
+		}
+	}
+	return blocks, blockLen, frames, nil
+}
+
+// Waveform renders a waveform overview of the audio stream r, whose samples
+// are laid out according to the given audio configuration, by reading it
+// until EOS. Each pixel column shows the minimum and maximum sample values,
+// and the RMS level, of the sample frames it covers.
+func Waveform(r audio.Reader, c audio.Config, o WaveformOptions) (*image.RGBA, error) {
+	if o.Width <= 0 {
+		o.Width = 512
+	}
+	if o.Height <= 0 {
+		o.Height = 128
+	}
+	if o.Background == nil {
+		o.Background = color.Transparent
+	}
+	if o.Peak == nil {
+		o.Peak = color.RGBA{35, 70, 140, 255}
+	}
+	if o.RMS == nil {
+		o.RMS = color.RGBA{110, 160, 230, 255}
+	}
+
+	blocks, blockLen, frames, err := summarize(r, c)
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(image.Rect(0, 0, o.Width, o.Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(o.Background), image.ZP, draw.Src)
+	if frames == 0 {
+		return img, nil
+	}
+
+	lane := float64(o.Height) / float64(c.Channels)
+	for ch, b := range blocks {
+		center := lane * (float64(ch) + 0.5)
+		toY := func(v float64) int {
+			v = math.Max(-1, math.Min(1, v))
+			y := int(center - v*lane/2)
+			if bottom := int(center + lane/2); y >= bottom {
+				// Stay within the lane of this channel.
+				y = bottom - 1
+			}
+			return y
+		}
+		for x := 0; x < o.Width; x++ {
+			// Blocks covered by this column, at least one.
+			start := x * frames / o.Width / blockLen
+			end := (x + 1) * frames / o.Width / blockLen
+			if end <= start {
+				end = start + 1
+			}
+			if end > len(b) {
+				end = len(b)
+			}
+			if start >= end {
+				start = end - 1
+			}
+			col := waveBlock{min: math.Inf(1), max: math.Inf(-1)}
+			for _, blk := range b[start:end] {
+				col.merge(blk)
+			}
+			n := float64((end - start) * blockLen)
+			if end == len(b) {
+				// The final block may be partial.
+				n -= float64(len(b)*blockLen - frames)
+			}
+			rms := math.Sqrt(col.sumSq / n)
+
+			vline(img, x, toY(col.max), toY(col.min), o.Peak)
+			vline(img, x, toY(rms), toY(-rms), o.RMS)
+		}
+	}
+	return img, nil
+}
+
+// vline draws a vertical line at x from y0 to y1 inclusive.
+func vline(img *image.RGBA, x, y0, y1 int, c color.Color) {
+	if y0 > y1 {
+		y0, y1 = y1, y0
+	}
+	for y := y0; y <= y1; y++ {
+		img.Set(x, y, c)
+	}
+}
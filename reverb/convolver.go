@@ -0,0 +1,281 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package reverb
+
+import (
+	"sync"
+
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/spectral"
+)
+
+// route is the convolution of a single input channel into a single output
+// channel.
+type route struct {
+	in, out int
+	parts   [][]complex128 // spectra of each partition of the response
+}
+
+// Convolver convolves an audio stream with an impulse response, using
+// uniformly partitioned overlap-save convolution in the frequency domain. It
+// implements both the audio.Reader and dsp.Processor interfaces. Convolvers
+// must be allocated via the NewConvolver function.
+//
+// The output is delayed by one block (see Latency); the dry signal is delayed
+// equally such that the two remain aligned. When used as an audio.Reader the
+// reverberation tail is rendered after the input stream ends.
+//
+// It is safe to change the mix from another goroutine while audio is being
+// processed.
+type Convolver struct {
+	r      *audio.FrameReader
+	config audio.Config
+	block  int
+	fft    *spectral.FFT
+	routes []route
+
+	access   sync.Mutex
+	wet, dry float64
+
+	// Processing state.
+	fill   int              // frames into the current block
+	inCur  [][]float64      // per channel input of the current block
+	inPrev [][]float64      // per channel input of the previous block
+	out    [][]float64      // per channel output of the current block
+	fdl    [][][]complex128 // per channel frequency domain delay line
+	fdlPos int              // index of the newest spectrum in fdl
+	seg    []float64        // time domain segment of two blocks
+	acc    []complex128     // accumulated output spectrum
+
+	// Reading state.
+	eos  bool
+	tail int // remaining tail frames, once the input has ended
+	past int // tail frames read, for SourcePosition
+}
+
+// Config returns the audio configuration of the stream.
+func (v *Convolver) Config() audio.Config {
+	return v.config
+}
+
+// Latency returns the latency of the convolver in sample frames, which is
+// equal to its block size.
+func (v *Convolver) Latency() int {
+	return v.block
+}
+
+// Mix returns the linear gains of the wet (convolved) and dry signals.
+func (v *Convolver) Mix() (wet, dry float64) {
+	v.access.Lock()
+	defer v.access.Unlock()
+	return v.wet, v.dry
+}
+
+// SetMix sets the linear gains of the wet (convolved) and dry signals.
+func (v *Convolver) SetMix(wet, dry float64) {
+	v.access.Lock()
+	v.wet, v.dry = wet, dry
+	v.access.Unlock()
+}
+
+// convolve computes the output of the block which was just completed.
+func (v *Convolver) convolve() {
+	b := v.block
+	parts := len(v.fdl[0])
+	v.fdlPos = (v.fdlPos + parts - 1) % parts
+	for ch := range v.inCur {
+		copy(v.seg, v.inPrev[ch])
+		copy(v.seg[b:], v.inCur[ch])
+		v.fft.TransformReal(v.fdl[ch][v.fdlPos], v.seg)
+		v.inPrev[ch], v.inCur[ch] = v.inCur[ch], v.inPrev[ch]
+	}
+	for ch := range v.out {
+		for i := range v.acc {
+			v.acc[i] = 0
+		}
+		for _, rt := range v.routes {
+			if rt.out != ch {
+				continue
+			}
+			for p, h := range rt.parts {
+				x := v.fdl[rt.in][(v.fdlPos+p)%parts]
+				for i, hv := range h {
+					v.acc[i] += x[i] * hv
+				}
+			}
+		}
+		v.fft.InverseReal(v.seg, v.acc)
+		copy(v.out[ch], v.seg[b:])
+	}
+}
+
+// Implements the dsp.Processor interface.
+func (v *Convolver) Process(s audio.Slice, c audio.Config) {
+	if c != v.config {
+		panic("reverb: Convolver audio configuration mismatch")
+	}
+	v.access.Lock()
+	wet, dry := v.wet, v.dry
+	v.access.Unlock()
+
+	frames := s.Len() / c.Channels
+	for f := 0; f < frames; f++ {
+		for ch := 0; ch < c.Channels; ch++ {
+			i := f*c.Channels + ch
+			x := float64(s.At(i))
+			y := dry*v.inPrev[ch][v.fill] + wet*v.out[ch][v.fill]
+			v.inCur[ch][v.fill] = x
+			s.Set(i, audio.F64(y))
+		}
+		if v.fill++; v.fill == v.block {
+			v.convolve()
+			v.fill = 0
+		}
+	}
+}
+
+// Read implements the audio.Reader interface. Once the input stream ends the
+// reverberation tail is rendered, after which EOS is returned.
+func (v *Convolver) Read(b audio.Slice) (n int, err error) {
+	if !v.eos {
+		n, err = v.r.Read(b)
+		if err == audio.EOS {
+			v.eos, err = true, nil
+		}
+		if n > 0 {
+			v.Process(b.Slice(0, n), v.config)
+		}
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+	c := v.config.Channels
+	frames := b.Len() / c
+	if v.tail == 0 {
+		return 0, audio.EOS
+	}
+	if frames > v.tail {
+		frames = v.tail
+	}
+	n = frames * c
+	for i := 0; i < n; i++ {
+		b.Set(i, 0)
+	}
+	v.Process(b.Slice(0, n), v.config)
+	v.tail -= frames
+	v.past += frames
+	return n, nil
+}
+
+// SourcePosition implements the audio.Positioner interface. The latency of
+// the convolver is compensated for, and the position continues past the end of
+// the input stream while the tail is rendered. If the convolver is only used
+// as a dsp.Processor it returns zero.
+func (v *Convolver) SourcePosition() uint64 {
+	if v.r == nil {
+		return 0
+	}
+	return v.r.SourcePositionAt(v.past - v.block)
+}
+
+// TotalLatency implements the audio.Positioner interface. It is the latency
+// of the input stream plus that of the convolver. If the convolver is only
+// used as a dsp.Processor it returns zero.
+func (v *Convolver) TotalLatency() int {
+	if v.r == nil {
+		return 0
+	}
+	return v.r.TotalLatency() + v.block*v.config.Channels
+}
+
+// NewConvolver returns a new convolver of the stream r, whose samples are
+// laid out according to the given audio configuration, with the impulse
+// response ir. The block size (e.g. 256), in sample frames, trades latency
+// for efficiency. Initially the mix is fully wet (a wet gain of one and a dry
+// gain of zero).
+//
+// If the convolver is only to be used as a dsp.Processor, r may be nil.
+//
+// It panics if the block size is not positive, or if the impulse response does
+// not match the sample rate of the stream or has an unsuitable number of
+// channels (see ReadIR to prepare one).
+func NewConvolver(r audio.Reader, c audio.Config, ir *IR, blockSize int) *Convolver {
+	if blockSize < 1 {
+		panic("reverb: invalid block size")
+	}
+	if !ir.compatible(c) {
+		panic("reverb: impulse response does not match audio configuration")
+	}
+	b := blockSize
+	bins := b + 1
+	v := &Convolver{
+		config: c,
+		block:  b,
+		fft:    spectral.NewFFT(2 * b),
+		wet:    1,
+		inCur:  make([][]float64, c.Channels),
+		inPrev: make([][]float64, c.Channels),
+		out:    make([][]float64, c.Channels),
+		fdl:    make([][][]complex128, c.Channels),
+		seg:    make([]float64, 2*b),
+		acc:    make([]complex128, bins),
+	}
+	if n := ir.Frames(); n > 0 {
+		v.tail = b + n - 1
+	}
+	if r != nil {
+		v.r = audio.NewFrameReader(r, c)
+	}
+
+	// Transform each partition of each response.
+	parts := (ir.Frames() + b - 1) / b
+	if parts == 0 {
+		parts = 1
+	}
+	addRoute := func(in, out, irCh int) {
+		h := ir.channel(irCh)
+		rt := route{in: in, out: out, parts: make([][]complex128, parts)}
+		for p := range rt.parts {
+			for i := range v.seg {
+				v.seg[i] = 0
+			}
+			if p*b < len(h) {
+				copy(v.seg, h[p*b:])
+				for i := b; i < len(v.seg); i++ {
+					v.seg[i] = 0
+				}
+			}
+			rt.parts[p] = make([]complex128, bins)
+			v.fft.TransformReal(rt.parts[p], v.seg)
+		}
+		v.routes = append(v.routes, rt)
+	}
+	switch n := ir.Config.Channels; {
+	case n == 4 && c.Channels == 2:
+		addRoute(0, 0, 0)
+		addRoute(0, 1, 1)
+		addRoute(1, 0, 2)
+		addRoute(1, 1, 3)
+	case n == 1:
+		for ch := 0; ch < c.Channels; ch++ {
+			addRoute(ch, ch, 0)
+		}
+	default:
+		for ch := 0; ch < c.Channels; ch++ {
+			addRoute(ch, ch, ch)
+		}
+	}
+
+	for ch := 0; ch < c.Channels; ch++ {
+		v.inCur[ch] = make([]float64, b)
+		v.inPrev[ch] = make([]float64, b)
+		v.out[ch] = make([]float64, b)
+		v.fdl[ch] = make([][]complex128, parts)
+		for p := range v.fdl[ch] {
+			v.fdl[ch][p] = make([]complex128, bins)
+		}
+	}
+	return v
+}
@@ -0,0 +1,221 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package reverb
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"azul3d.org/audio.v1"
+)
+
+// readAll reads the entire stream r in chunks of the given size.
+func readAll(t *testing.T, r audio.Reader, chunk int) audio.F64Samples {
+	var all audio.F64Samples
+	buf := make(audio.F64Samples, chunk)
+	for {
+		n, err := r.Read(buf)
+		all = append(all, buf[:n]...)
+		if err == audio.EOS {
+			return all
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// random returns n random samples in the range of -1 to +1.
+func random(rng *rand.Rand, n int) audio.F64Samples {
+	s := make(audio.F64Samples, n)
+	for i := range s {
+		s[i] = audio.F64(rng.Float64()*2 - 1)
+	}
+	return s
+}
+
+// convolve returns the direct convolution of the single channel signals x and
+// h.
+func convolve(x, h []float64) []float64 {
+	y := make([]float64, len(x)+len(h)-1)
+	for i, xv := range x {
+		for j, hv := range h {
+			y[i+j] += xv * hv
+		}
+	}
+	return y
+}
+
+// channel returns a single channel of the interleaved samples s.
+func channel(s audio.F64Samples, ch, channels int) []float64 {
+	out := make([]float64, len(s)/channels)
+	for i := range out {
+		out[i] = float64(s[i*channels+ch])
+	}
+	return out
+}
+
+// compare fails the test if got is not equal to want delayed by the given
+// number of frames.
+func compare(t *testing.T, name string, got, want []float64, delay int) {
+	if len(got) != len(want)+delay {
+		t.Fatalf("%s: got %d frames, want %d", name, len(got), len(want)+delay)
+	}
+	for i, v := range got {
+		var w float64
+		if i >= delay {
+			w = want[i-delay]
+		}
+		if math.Abs(v-w) > 1e-9 {
+			t.Fatalf("%s: frame %d = %v, want %v", name, i, v, w)
+		}
+	}
+}
+
+func TestConvolver(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	c := audio.Config{SampleRate: 48000, Channels: 2}
+	for _, tst := range []struct {
+		block, irFrames, frames, chunk int
+	}{
+		{64, 1000, 3000, 94},
+		{64, 64, 500, 2},
+		{128, 10, 777, 1000},
+		{32, 33, 31, 6},
+	} {
+		ir := &IR{
+			Config:  c,
+			Samples: random(rng, tst.irFrames*2),
+		}
+		in := random(rng, tst.frames*2)
+		src := make(audio.F64Samples, len(in))
+		copy(src, in)
+		v := NewConvolver(audio.NewBuffer(src), c, ir, tst.block)
+		if v.Latency() != tst.block {
+			t.Fatalf("Latency() = %d, want %d", v.Latency(), tst.block)
+		}
+		got := readAll(t, v, tst.chunk)
+		for ch := 0; ch < 2; ch++ {
+			want := convolve(channel(in, ch, 2), ir.channel(ch))
+			compare(t, "convolution", channel(got, ch, 2), want, tst.block)
+		}
+	}
+}
+
+func TestConvolverDirac(t *testing.T) {
+	c := audio.Config{SampleRate: 44100, Channels: 1}
+	ir := &IR{Config: c, Samples: audio.F64Samples{0, 0, 0, 0.5}}
+	in := random(rand.New(rand.NewSource(2)), 1000)
+	src := make(audio.F64Samples, len(in))
+	copy(src, in)
+	got := readAll(t, NewConvolver(audio.NewBuffer(src), c, ir, 16), 100)
+	want := make([]float64, len(in)+3)
+	for i, v := range in {
+		want[i+3] = 0.5 * float64(v)
+	}
+	compare(t, "dirac", channel(got, 0, 1), want, 16)
+}
+
+func TestConvolverMix(t *testing.T) {
+	c := audio.Config{SampleRate: 44100, Channels: 1}
+	ir := &IR{Config: c, Samples: audio.F64Samples{0, 1}}
+	v := NewConvolver(nil, c, ir, 8)
+	v.SetMix(0.25, 0.5)
+	if wet, dry := v.Mix(); wet != 0.25 || dry != 0.5 {
+		t.Fatalf("Mix() = %v, %v", wet, dry)
+	}
+	s := make(audio.F64Samples, 40)
+	s[0] = 1
+	v.Process(s, c)
+	for i, got := range s {
+		var want audio.F64
+		switch i {
+		case 8: // dry, delayed by a block
+			want = 0.5
+		case 9: // wet, delayed by one more frame
+			want = 0.25
+		}
+		if math.Abs(float64(got-want)) > 1e-12 {
+			t.Fatalf("frame %d = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestConvolverTrueStereo(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 2}
+	rng := rand.New(rand.NewSource(3))
+	ir := &IR{
+		Config:  audio.Config{SampleRate: 48000, Channels: 4},
+		Samples: random(rng, 200*4),
+	}
+	in := random(rng, 600*2)
+	src := make(audio.F64Samples, len(in))
+	copy(src, in)
+	got := readAll(t, NewConvolver(audio.NewBuffer(src), c, ir, 32), 50)
+
+	l, r := channel(in, 0, 2), channel(in, 1, 2)
+	for out := 0; out < 2; out++ {
+		fromL := convolve(l, ir.channel(out))
+		fromR := convolve(r, ir.channel(2+out))
+		for i := range fromL {
+			fromL[i] += fromR[i]
+		}
+		compare(t, "true stereo", channel(got, out, 2), fromL, 32)
+	}
+}
+
+func TestConvolverIncompatible(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 2}
+	for _, ir := range []*IR{
+		{Config: audio.Config{SampleRate: 44100, Channels: 2}, Samples: make(audio.F64Samples, 2)},
+		{Config: audio.Config{SampleRate: 48000, Channels: 3}, Samples: make(audio.F64Samples, 3)},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("no panic for %+v", ir.Config)
+				}
+			}()
+			NewConvolver(nil, c, ir, 64)
+		}()
+	}
+}
+
+func TestConvolverSourcePosition(t *testing.T) {
+	c := audio.Config{SampleRate: 44100, Channels: 1}
+	ir := &IR{Config: c, Samples: audio.F64Samples{0, 0, 0, 0.5}}
+	v := NewConvolver(audio.NewBuffer(make(audio.F64Samples, 1000)), c, ir, 16)
+	v.Read(make(audio.F64Samples, 100))
+	if p := v.SourcePosition(); p != 100-16 {
+		t.Fatalf("SourcePosition got %d, want %d", p, 100-16)
+	}
+	if l := v.TotalLatency(); l != 16 {
+		t.Fatalf("TotalLatency got %d, want 16", l)
+	}
+
+	// The position continues through the tail.
+	readAll(t, v, 100)
+	if p, want := v.SourcePosition(), uint64(1000+3); p != want {
+		t.Fatalf("SourcePosition at the end got %d, want %d", p, want)
+	}
+}
+
+func TestProcessorSourcePosition(t *testing.T) {
+	// Effects used only as processors have no position.
+	c := audio.Config{SampleRate: 44100, Channels: 1}
+	ir := &IR{Config: c, Samples: audio.F64Samples{0, 1}}
+	for _, r := range []audio.Reader{
+		NewConvolver(nil, c, ir, 16),
+		NewReverb(nil, c, Params{Mode: FDN}),
+	} {
+		if p, ok := audio.SourcePosition(r); p != 0 || !ok {
+			t.Errorf("%T: SourcePosition got (%d, %v), want (0, true)", r, p, ok)
+		}
+		if l := audio.TotalLatency(r); l != 0 {
+			t.Errorf("%T: TotalLatency got %d, want 0", r, l)
+		}
+	}
+}
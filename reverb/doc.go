@@ -0,0 +1,11 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package reverb implements reverberation effects for audio streams.
+//
+// A Convolver convolves a stream with a measured (or synthesized) impulse
+// response of a room, for realistic room acoustics. A Reverb is a far
+// cheaper algorithmic reverberation (a feedback delay network, or Freeverb),
+// suitable for use by many simultaneous voices.
+package reverb
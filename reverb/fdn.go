@@ -0,0 +1,134 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package reverb
+
+import "math"
+
+var (
+	// fdnLines are the lengths of the feedback delay network's delay lines
+	// in milliseconds; they are chosen to be mutually incommensurate such
+	// that their echoes do not coincide.
+	fdnLines = []float64{31.71, 37.11, 41.13, 43.67, 53.53, 59.11, 67.93, 73.41}
+
+	// fdnDiffusers are the lengths of the input diffusion allpass filters in
+	// milliseconds.
+	fdnDiffusers = []float64{4.77, 3.59, 12.73, 9.31}
+)
+
+const (
+	// fdnMinTime and fdnMaxTime are the reverberation times (in seconds)
+	// of the smallest and largest room sizes.
+	fdnMinTime = 0.2
+	fdnMaxTime = 10
+
+	// fdnMaxDamp is the lowpass coefficient of the largest damping.
+	fdnMaxDamp = 0.8
+
+	// fdnMaxDiffusion is the allpass coefficient of the largest diffusion.
+	fdnMaxDiffusion = 0.75
+)
+
+// reverbTime returns the reverberation time (RT60) in seconds of a feedback
+// delay network with the given room size.
+func reverbTime(roomSize float64) float64 {
+	return fdnMinTime * math.Pow(fdnMaxTime/fdnMinTime, roomSize)
+}
+
+// ms returns the given number of milliseconds in samples at the sample rate.
+func ms(v float64, sampleRate int) int {
+	return int(math.Floor(v*float64(sampleRate)/1000 + 0.5))
+}
+
+// fdn is a tank implementing an eight line feedback delay network, with a
+// Householder feedback matrix and a lowpass filter in each line for damping.
+// The input is diffused by a series of allpass filters, and each channel is
+// output through an orthogonal (Hadamard) combination of the lines.
+type fdn struct {
+	lines     []delay
+	store     []float64 // lowpass filter state of each line
+	gains     []float64 // per line feedback gain
+	diffusers []delay
+	vals      []float64
+	rate      int
+
+	damp, diffusion float64
+}
+
+func (f *fdn) set(p Params) {
+	rt := reverbTime(p.RoomSize) * float64(f.rate)
+	for i, l := range f.lines {
+		// Each line attenuates by 60dB over the reverberation time.
+		f.gains[i] = math.Pow(10, -3*float64(len(l.buf))/rt)
+	}
+	f.damp = p.Damping * fdnMaxDamp
+	f.diffusion = p.Diffusion * fdnMaxDiffusion
+}
+
+// hadamard returns the sign of element (i, j) of an 8x8 Hadamard matrix.
+func hadamard(i, j int) float64 {
+	// The parity of the bitwise AND of the indices.
+	x := i & j
+	x ^= x >> 2
+	x ^= x >> 1
+	if x&1 == 1 {
+		return -1
+	}
+	return 1
+}
+
+func (f *fdn) process(in float64, out []float64) {
+	n := len(f.lines)
+	scale := 1 / math.Sqrt(float64(n))
+
+	for i := range f.diffusers {
+		d := &f.diffusers[i]
+		v := in + f.diffusion*d.out()
+		in = d.out() - f.diffusion*v
+		d.in(v)
+	}
+
+	var sum float64
+	for i := range f.lines {
+		f.store[i] = f.lines[i].out()*(1-f.damp) + f.store[i]*f.damp
+		f.vals[i] = f.store[i] * f.gains[i]
+		sum += f.vals[i]
+	}
+	sum *= 2 / float64(n)
+	for i := range f.lines {
+		f.lines[i].in(f.vals[i] - sum + in*scale)
+	}
+
+	for ch := range out {
+		// Channels beyond the eighth reuse the rows, with the lines rotated.
+		row, rot := (ch+1)%n, ch/n
+		var acc float64
+		for i := range f.lines {
+			acc += hadamard(row, i) * f.vals[(i+rot)%n]
+		}
+		out[ch] = acc * scale
+	}
+}
+
+func (f *fdn) length() int {
+	return len(f.lines[len(f.lines)-1].buf)
+}
+
+func newFDN(sampleRate int) *fdn {
+	n := len(fdnLines)
+	f := &fdn{
+		lines: make([]delay, n),
+		store: make([]float64, n),
+		gains: make([]float64, n),
+		vals:  make([]float64, n),
+		rate:  sampleRate,
+	}
+	for i, l := range fdnLines {
+		f.lines[i] = newDelay(ms(l, sampleRate))
+	}
+	for _, l := range fdnDiffusers {
+		f.diffusers = append(f.diffusers, newDelay(ms(l, sampleRate)))
+	}
+	return f
+}
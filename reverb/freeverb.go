@@ -0,0 +1,127 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package reverb
+
+import "math"
+
+// The Freeverb tuning, at its original sample rate of 44.1kHz.
+var (
+	freeverbCombs     = []int{1116, 1188, 1277, 1356, 1422, 1491, 1557, 1617}
+	freeverbAllpasses = []int{556, 441, 341, 225}
+)
+
+const (
+	freeverbRate      = 44100
+	freeverbSpread    = 23 // extra delay of each channel's filters
+	freeverbGain      = 0.015
+	freeverbScaleWet  = 3
+	freeverbScaleDry  = 2
+	freeverbScaleDamp = 0.4
+	freeverbScaleRoom = 0.28
+	freeverbRoom      = 0.7 // room size offset
+)
+
+// delay is a fixed length delay line.
+type delay struct {
+	buf []float64
+	pos int
+}
+
+// out returns the oldest sample in the delay line.
+func (d *delay) out() float64 {
+	return d.buf[d.pos]
+}
+
+// in replaces the oldest sample in the delay line and advances it.
+func (d *delay) in(v float64) {
+	d.buf[d.pos] = v
+	if d.pos++; d.pos == len(d.buf) {
+		d.pos = 0
+	}
+}
+
+func newDelay(n int) delay {
+	if n < 1 {
+		n = 1
+	}
+	return delay{buf: make([]float64, n)}
+}
+
+// scaleLength scales a delay length in samples at the given rate to the
+// sample rate.
+func scaleLength(n, rate, sampleRate int) int {
+	return int(math.Floor(float64(n)*float64(sampleRate)/float64(rate) + 0.5))
+}
+
+// comb is a Freeverb lowpass-feedback comb filter.
+type comb struct {
+	delay
+	store float64
+}
+
+// freeverb is a tank implementing the Freeverb algorithm by Jezar at
+// Dreampoint: per channel, eight parallel lowpass-feedback comb filters
+// followed by four series allpass filters, with each channel's filters
+// slightly longer than the last for decorrelation.
+type freeverb struct {
+	combs     [][]comb
+	allpasses [][]delay
+	longest   int // length of the longest comb filter
+
+	feedback, damp, diffusion float64
+}
+
+func (f *freeverb) set(p Params) {
+	f.feedback = p.RoomSize*freeverbScaleRoom + freeverbRoom
+	f.damp = p.Damping * freeverbScaleDamp
+	f.diffusion = p.Diffusion
+}
+
+func (f *freeverb) process(in float64, out []float64) {
+	in *= freeverbGain
+	for ch := range out {
+		var acc float64
+		for i := range f.combs[ch] {
+			c := &f.combs[ch][i]
+			v := c.out()
+			c.store = v*(1-f.damp) + c.store*f.damp
+			c.in(in + c.store*f.feedback)
+			acc += v
+		}
+		for i := range f.allpasses[ch] {
+			a := &f.allpasses[ch][i]
+			v := a.out()
+			a.in(acc + v*f.diffusion)
+			acc = v - acc
+		}
+		out[ch] = acc
+	}
+}
+
+func (f *freeverb) length() int {
+	return f.longest
+}
+
+func newFreeverb(channels, sampleRate int) *freeverb {
+	f := &freeverb{
+		combs:     make([][]comb, channels),
+		allpasses: make([][]delay, channels),
+	}
+	for ch := 0; ch < channels; ch++ {
+		spread := ch * freeverbSpread
+		for _, n := range freeverbCombs {
+			d := newDelay(scaleLength(n+spread, freeverbRate, sampleRate))
+			f.combs[ch] = append(f.combs[ch], comb{delay: d})
+			if len(d.buf) > f.longest {
+				f.longest = len(d.buf)
+			}
+		}
+		for _, n := range freeverbAllpasses {
+			d := newDelay(scaleLength(n+spread, freeverbRate, sampleRate))
+			f.allpasses[ch] = append(f.allpasses[ch], d)
+		}
+	}
+	return f
+}
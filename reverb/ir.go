@@ -0,0 +1,118 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package reverb
+
+import (
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/dsp"
+)
+
+// IR is an impulse response, for use by a Convolver.
+//
+// The channels of an impulse response are used depending on how many there
+// are, relative to the number of channels of the convolved stream:
+//
+//	1 channel: the same response is applied to every channel.
+//	N channels (for an N channel stream): each channel has its own response.
+//	4 channels (for a stereo stream): true stereo; the responses from the
+//	left input to the left and right outputs, then from the right input to
+//	the left and right outputs.
+type IR struct {
+	// Config is the audio configuration of the impulse response.
+	Config audio.Config
+
+	// Samples are the interleaved samples of the impulse response.
+	Samples audio.F64Samples
+}
+
+// Frames returns the length of the impulse response in sample frames.
+func (ir *IR) Frames() int {
+	return len(ir.Samples) / ir.Config.Channels
+}
+
+// channel returns the samples of a single channel of the impulse response.
+func (ir *IR) channel(ch int) []float64 {
+	c := ir.Config.Channels
+	s := make([]float64, ir.Frames())
+	for i := range s {
+		s[i] = float64(ir.Samples[i*c+ch])
+	}
+	return s
+}
+
+// compatible tells if the impulse response can be used to convolve a stream
+// of the given audio configuration.
+func (ir *IR) compatible(c audio.Config) bool {
+	if ir.Config.SampleRate != c.SampleRate {
+		return false
+	}
+	n := ir.Config.Channels
+	return n == 1 || n == c.Channels || (n == 4 && c.Channels == 2)
+}
+
+// mapChannels maps the interleaved samples s with from channels to the given
+// number of channels: extra channels are averaged together and missing ones
+// are duplicated.
+func mapChannels(s audio.F64Samples, from, to int) audio.F64Samples {
+	frames := len(s) / from
+	out := make(audio.F64Samples, frames*to)
+	for f := 0; f < frames; f++ {
+		for ch := 0; ch < to; ch++ {
+			if from <= to {
+				out[f*to+ch] = s[f*from+ch*from/to]
+				continue
+			}
+			lo, hi := ch*from/to, (ch+1)*from/to
+			var sum audio.F64
+			for i := lo; i < hi; i++ {
+				sum += s[f*from+i]
+			}
+			out[f*to+ch] = sum / audio.F64(hi-lo)
+		}
+	}
+	return out
+}
+
+// ReadIR reads an impulse response from r, whose samples are laid out
+// according to the given audio configuration, until EOS. The impulse response
+// is resampled to the sample rate of, and its channels are mapped for use
+// with, streams of the audio configuration dst.
+//
+// Impulse responses with one channel, the same number of channels as dst, or
+// four channels for a stereo dst (true stereo) are used as-is; otherwise
+// their channels are averaged or duplicated to match dst.
+func ReadIR(r audio.Reader, c, dst audio.Config) (*IR, error) {
+	if c.SampleRate != dst.SampleRate {
+		r = dsp.NewRateConverter(r, c, dst.SampleRate)
+	}
+	buf := audio.NewBuffer(make(audio.F64Samples, 0, 4096))
+	if _, err := audio.Copy(buf, r); err != nil {
+		return nil, err
+	}
+	s := buf.Samples().(audio.F64Samples)
+	s = s[:len(s)-len(s)%c.Channels]
+
+	ir := &IR{
+		Config:  audio.Config{SampleRate: dst.SampleRate, Channels: c.Channels},
+		Samples: s,
+	}
+	if !ir.compatible(dst) {
+		ir.Samples = mapChannels(s, c.Channels, dst.Channels)
+		ir.Config.Channels = dst.Channels
+	}
+	return ir, nil
+}
+
+// LoadIR decodes an impulse response from the encoded audio data in r (an
+// io.Reader or io.ReadSeeker) using audio.NewDecoder, such that it may be in
+// any registered format. As with ReadIR, it is resampled and its channels
+// are mapped for use with streams of the audio configuration dst.
+func LoadIR(r interface{}, dst audio.Config) (*IR, error) {
+	dec, _, err := audio.NewDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	return ReadIR(dec, dec.Config(), dst)
+}
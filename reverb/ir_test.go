@@ -0,0 +1,134 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package reverb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"testing"
+
+	"azul3d.org/audio.v1"
+)
+
+// testDecoder decodes the "tir" test format: the magic "TIR0", the sample
+// rate (uint32) and number of channels (uint16), followed by little-endian
+// 32-bit float samples.
+type testDecoder struct {
+	audio.Reader
+	config audio.Config
+}
+
+func (d *testDecoder) Config() audio.Config { return d.config }
+
+func (d *testDecoder) Seek(sample uint64) error {
+	return errors.New("tir: seeking is not supported")
+}
+
+func init() {
+	audio.RegisterFormat("tir", "TIR0", func(r interface{}) (audio.Decoder, error) {
+		rd := r.(io.Reader)
+		var hdr struct {
+			Magic      [4]byte
+			SampleRate uint32
+			Channels   uint16
+		}
+		if err := binary.Read(rd, binary.LittleEndian, &hdr); err != nil {
+			return nil, audio.ErrInvalidData
+		}
+		return &testDecoder{
+			Reader: audio.NewByteReader(rd, audio.FormatF32LE),
+			config: audio.Config{
+				SampleRate: int(hdr.SampleRate),
+				Channels:   int(hdr.Channels),
+			},
+		}, nil
+	})
+}
+
+// encodeTIR encodes the interleaved samples s in the test format.
+func encodeTIR(c audio.Config, s audio.F64Samples) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("TIR0")
+	binary.Write(&buf, binary.LittleEndian, uint32(c.SampleRate))
+	binary.Write(&buf, binary.LittleEndian, uint16(c.Channels))
+	b := make([]byte, len(s)*4)
+	audio.FormatF32LE.Marshal(b, s)
+	buf.Write(b)
+	return buf.Bytes()
+}
+
+func TestLoadIR(t *testing.T) {
+	// A stereo 24kHz impulse response with a decaying sine in the left
+	// channel and silence in the right.
+	src := audio.Config{SampleRate: 24000, Channels: 2}
+	s := make(audio.F64Samples, 2400*2)
+	for i := 0; i < 2400; i++ {
+		env := math.Exp(-float64(i) / 400)
+		s[i*2] = audio.F64(env * math.Sin(2*math.Pi*1000*float64(i)/24000))
+	}
+	data := encodeTIR(src, s)
+
+	// Loaded for a mono 48kHz stream, the channels are averaged and the
+	// response is resampled.
+	dst := audio.Config{SampleRate: 48000, Channels: 1}
+	ir, err := LoadIR(bytes.NewReader(data), dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ir.Config != dst {
+		t.Fatalf("Config = %+v, want %+v", ir.Config, dst)
+	}
+	if n := ir.Frames(); n < 4790 || n > 4810 {
+		t.Fatalf("Frames() = %d, want about 4800", n)
+	}
+	var peak float64
+	for _, v := range ir.Samples[:2000] {
+		peak = math.Max(peak, math.Abs(float64(v)))
+	}
+	if peak < 0.45 || peak > 0.55 {
+		t.Fatalf("peak = %v, want about 0.5", peak)
+	}
+
+	// Loaded for a stereo stream, it is used as-is.
+	ir, err = LoadIR(bytes.NewReader(data), audio.Config{SampleRate: 24000, Channels: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ir.Config != src || len(ir.Samples) != len(s) {
+		t.Fatalf("got %+v with %d samples", ir.Config, len(ir.Samples))
+	}
+	for i, v := range ir.Samples {
+		if math.Abs(float64(v-s[i])) > 1e-6 {
+			t.Fatalf("sample %d = %v, want %v", i, v, s[i])
+		}
+	}
+
+	if _, err := LoadIR(bytes.NewReader([]byte("nope")), dst); err != audio.ErrFormat {
+		t.Fatalf("got error %v, want ErrFormat", err)
+	}
+}
+
+func TestMapChannels(t *testing.T) {
+	// 3 channels to 2 averages the extra channel into the last.
+	got := mapChannels(audio.F64Samples{1, 2, 4, 3, 6, 8}, 3, 2)
+	want := audio.F64Samples{1, 3, 3, 7}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("3 to 2: got %v, want %v", got, want)
+		}
+	}
+
+	// 1 channel to 3 duplicates it.
+	got = mapChannels(audio.F64Samples{1, 2}, 1, 3)
+	want = audio.F64Samples{1, 1, 1, 2, 2, 2}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("1 to 3: got %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package reverb
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"azul3d.org/audio.v1"
+)
+
+// Mode represents a single algorithmic reverberation algorithm.
+type Mode uint8
+
+const (
+	// FDN is a feedback delay network: a dense, smooth reverberation whose
+	// reverberation time is independent of the damping.
+	FDN Mode = iota
+
+	// Freeverb is the public domain Freeverb algorithm. With a diffusion
+	// of 0.5 and no pre-delay its output matches the original, and its
+	// parameters have the same meaning as the original's controls, such
+	// that existing presets can be used directly.
+	Freeverb
+)
+
+// String returns a string representation of the mode.
+func (m Mode) String() string {
+	switch m {
+	case FDN:
+		return "FDN"
+	case Freeverb:
+		return "Freeverb"
+	}
+	return fmt.Sprintf("Mode(%d)", uint8(m))
+}
+
+// Params describes the parameters of an algorithmic reverb.
+type Params struct {
+	// Mode is the reverberation algorithm.
+	Mode Mode
+
+	// RoomSize, in the range of 0 to 1, controls the reverberation time.
+	// For FDN it maps exponentially to reverberation times (RT60) of 0.2 to
+	// 10 seconds.
+	RoomSize float64
+
+	// Damping, in the range of 0 to 1, controls how much faster high
+	// frequencies decay than low frequencies.
+	Damping float64
+
+	// PreDelay delays the reverberation relative to the dry signal.
+	PreDelay time.Duration
+
+	// Diffusion, in the range of 0 to 1, controls the density of the early
+	// reflections.
+	Diffusion float64
+
+	// Wet and Dry are the gains of the reverberation and of the dry signal.
+	// For FDN they are linear gains; for Freeverb they are scaled by 3 and 2
+	// respectively, as in the original.
+	Wet, Dry float64
+
+	// Width, in the range of 0 to 1, controls the stereo width of the
+	// reverberation: at zero every channel receives the same reverberation,
+	// at one each channel receives its own.
+	Width float64
+}
+
+// validate panics if the parameters are invalid.
+func (p Params) validate() {
+	in := func(v float64) bool { return v >= 0 && v <= 1 }
+	switch {
+	case p.Mode != FDN && p.Mode != Freeverb:
+		panic("reverb: invalid mode")
+	case !in(p.RoomSize), !in(p.Damping), !in(p.Diffusion), !in(p.Width):
+		panic("reverb: parameter out of range")
+	case p.PreDelay < 0:
+		panic("reverb: negative pre-delay")
+	}
+}
+
+// tank is the reverberation stage of an algorithmic reverb.
+type tank interface {
+	// set updates the parameters of the tank.
+	set(p Params)
+
+	// process feeds a single input sample to the tank, storing the
+	// reverberation of each channel in out.
+	process(in float64, out []float64)
+
+	// length returns the length of the longest delay in the tank, in sample
+	// frames.
+	length() int
+}
+
+// tailLevel is the level below which the reverberation tail is considered to
+// have ended (-120dB).
+const tailLevel = 1e-6
+
+// Reverb is an algorithmic reverb, far cheaper than a Convolver. It
+// implements both the audio.Reader and dsp.Processor interfaces. Reverbs must
+// be allocated via the NewReverb function.
+//
+// The input channels are mixed into a single input to the reverberation,
+// which is output with a different (decorrelated) reverberation to each
+// channel, such that any number of channels is supported. The output is
+// fully deterministic.
+//
+// When used as an audio.Reader the reverberation tail is rendered after the
+// input stream ends, until it has decayed below -120dB.
+//
+// It is safe to change the parameters from another goroutine while audio is
+// being processed.
+type Reverb struct {
+	r      *audio.FrameReader
+	config audio.Config
+
+	access sync.Mutex
+	params Params
+	dirty  bool
+
+	tank     tank
+	mode     Mode
+	preDelay delay
+	wet      []float64
+
+	// Reading state.
+	eos   bool
+	quiet int // consecutive silent output frames, once the input has ended
+	past  int // tail frames read, for SourcePosition
+}
+
+// Config returns the audio configuration of the stream.
+func (v *Reverb) Config() audio.Config {
+	return v.config
+}
+
+// Params returns the current parameters.
+func (v *Reverb) Params() Params {
+	v.access.Lock()
+	defer v.access.Unlock()
+	return v.params
+}
+
+// SetParams sets the parameters. Changing the mode or the pre-delay resets the
+// reverberation.
+//
+// It panics if the parameters are out of range.
+func (v *Reverb) SetParams(p Params) {
+	p.validate()
+	v.access.Lock()
+	v.params = p
+	v.dirty = true
+	v.access.Unlock()
+}
+
+// update applies the current parameters to the processing state.
+func (v *Reverb) update() {
+	p := v.params
+	v.dirty = false
+	pre := int(p.PreDelay.Seconds()*float64(v.config.SampleRate) + 0.5)
+	if v.tank == nil || p.Mode != v.mode || pre != len(v.preDelay.buf)-1 {
+		switch p.Mode {
+		case FDN:
+			v.tank = newFDN(v.config.SampleRate)
+		case Freeverb:
+			v.tank = newFreeverb(v.config.Channels, v.config.SampleRate)
+		}
+		v.mode = p.Mode
+		v.preDelay = newDelay(pre + 1)
+	}
+	v.tank.set(p)
+}
+
+// Implements the dsp.Processor interface.
+func (v *Reverb) Process(s audio.Slice, c audio.Config) {
+	if c != v.config {
+		panic("reverb: Reverb audio configuration mismatch")
+	}
+	v.access.Lock()
+	if v.dirty {
+		v.update()
+	}
+	p := v.params
+	v.access.Unlock()
+
+	wet, dry := p.Wet, p.Dry
+	if p.Mode == Freeverb {
+		wet *= freeverbScaleWet
+		dry *= freeverbScaleDry
+	}
+	// Each channel's reverberation is mixed with the others' to narrow the
+	// width; for stereo this is exactly as Freeverb does.
+	n := float64(c.Channels)
+	wet1 := wet * (1 + (n-1)*p.Width) / n
+	wet2 := wet * (1 - p.Width) / n
+
+	frames := s.Len() / c.Channels
+	for f := 0; f < frames; f++ {
+		base := f * c.Channels
+		var in float64
+		for ch := 0; ch < c.Channels; ch++ {
+			in += float64(s.At(base + ch))
+		}
+		v.preDelay.in(in)
+		v.tank.process(v.preDelay.out(), v.wet)
+
+		var sum, peak float64
+		for _, w := range v.wet {
+			sum += w
+		}
+		for ch, w := range v.wet {
+			y := wet1*w + wet2*(sum-w)
+			peak = math.Max(peak, math.Abs(y))
+			s.Set(base+ch, audio.F64(dry*float64(s.At(base+ch))+y))
+		}
+		if peak < tailLevel {
+			v.quiet++
+		} else {
+			v.quiet = 0
+		}
+	}
+}
+
+// Read implements the audio.Reader interface. Once the input stream ends the
+// reverberation tail is rendered, after which EOS is returned.
+func (v *Reverb) Read(b audio.Slice) (n int, err error) {
+	if !v.eos {
+		n, err = v.r.Read(b)
+		if err == audio.EOS {
+			v.eos, err = true, nil
+			v.quiet = 0
+		}
+		if n > 0 {
+			v.Process(b.Slice(0, n), v.config)
+		}
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+
+	// The tail has ended once the output has been silent for longer than
+	// any delay in the reverberation. The quiet count increases by at most one
+	// per frame, so processing no more frames than remain never overshoots
+	// the end.
+	c := v.config.Channels
+	end := len(v.preDelay.buf) + v.tank.length()
+	frames := b.Len() / c
+	for f := 0; f < frames && v.quiet < end; {
+		k := end - v.quiet
+		if k > frames-f {
+			k = frames - f
+		}
+		chunk := b.Slice(f*c, (f+k)*c)
+		for i := 0; i < chunk.Len(); i++ {
+			chunk.Set(i, 0)
+		}
+		v.Process(chunk, v.config)
+		f += k
+		n += k * c
+	}
+	if n == 0 && frames > 0 {
+		return 0, audio.EOS
+	}
+	v.past += n / c
+	return n, nil
+}
+
+// SourcePosition implements the audio.Positioner interface. The position
+// continues past the end of the input stream while the tail is rendered. If
+// the reverb is only used as a dsp.Processor it returns zero.
+func (v *Reverb) SourcePosition() uint64 {
+	if v.r == nil {
+		return 0
+	}
+	return v.r.SourcePositionAt(v.past)
+}
+
+// TotalLatency implements the audio.Positioner interface. The reverb itself
+// introduces no latency (the pre-delay delays only the reverberation). If the
+// reverb is only used as a dsp.Processor it returns zero.
+func (v *Reverb) TotalLatency() int {
+	if v.r == nil {
+		return 0
+	}
+	return v.r.TotalLatency()
+}
+
+// NewReverb returns a new algorithmic reverb with the given parameters, of the
+// stream r whose samples are laid out according to the given audio
+// configuration.
+//
+// If the reverb is only to be used as a dsp.Processor, r may be nil.
+//
+// It panics if the parameters are out of range.
+func NewReverb(r audio.Reader, c audio.Config, p Params) *Reverb {
+	if c.SampleRate < 1 || c.Channels < 1 {
+		panic("reverb: invalid audio configuration")
+	}
+	p.validate()
+	v := &Reverb{
+		config: c,
+		params: p,
+		wet:    make([]float64, c.Channels),
+	}
+	if r != nil {
+		v.r = audio.NewFrameReader(r, c)
+	}
+	v.update()
+	return v
+}
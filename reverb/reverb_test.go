@@ -0,0 +1,282 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package reverb
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"azul3d.org/audio.v1"
+)
+
+// refFreeverb is a direct port of the original (stereo, 44.1kHz) Freeverb
+// revmodel, used as a reference.
+type refFreeverb struct {
+	combL, combR   [8][]float64
+	storeL, storeR [8]float64
+	combIdx        [8]int
+	apL, apR       [4][]float64
+	apIdx          [4]int
+
+	gain, roomsize, damp1, damp2, wet1, wet2, dry float64
+}
+
+func newRefFreeverb(room, damp, wet, dry, width float64) *refFreeverb {
+	combs := []int{1116, 1188, 1277, 1356, 1422, 1491, 1557, 1617}
+	aps := []int{556, 441, 341, 225}
+	m := &refFreeverb{gain: 0.015}
+	for i, n := range combs {
+		m.combL[i] = make([]float64, n)
+		m.combR[i] = make([]float64, n+23)
+	}
+	for i, n := range aps {
+		m.apL[i] = make([]float64, n)
+		m.apR[i] = make([]float64, n+23)
+	}
+	m.roomsize = room*0.28 + 0.7
+	m.damp1 = damp * 0.4
+	m.damp2 = 1 - m.damp1
+	wet *= 3
+	m.wet1 = wet * (width/2 + 0.5)
+	m.wet2 = wet * ((1 - width) / 2)
+	m.dry = dry * 2
+	return m
+}
+
+func (m *refFreeverb) process(inL, inR float64) (outL, outR float64) {
+	input := (inL + inR) * m.gain
+	comb := func(buf []float64, idx *int, store *float64) float64 {
+		output := buf[*idx%len(buf)]
+		*store = output*m.damp2 + *store*m.damp1
+		buf[*idx%len(buf)] = input + *store*m.roomsize
+		return output
+	}
+	allpass := func(buf []float64, idx int, input float64) float64 {
+		bufout := buf[idx%len(buf)]
+		buf[idx%len(buf)] = input + bufout*0.5
+		return -input + bufout
+	}
+	for i := range m.combL {
+		outL += comb(m.combL[i], &m.combIdx[i], &m.storeL[i])
+		outR += comb(m.combR[i], &m.combIdx[i], &m.storeR[i])
+		m.combIdx[i]++
+	}
+	for i := range m.apL {
+		outL = allpass(m.apL[i], m.apIdx[i], outL)
+		outR = allpass(m.apR[i], m.apIdx[i], outR)
+		m.apIdx[i]++
+	}
+	return outL*m.wet1 + outR*m.wet2 + inL*m.dry,
+		outR*m.wet1 + outL*m.wet2 + inR*m.dry
+}
+
+func TestFreeverbCompatible(t *testing.T) {
+	c := audio.Config{SampleRate: 44100, Channels: 2}
+	for _, p := range []Params{
+		{Mode: Freeverb, RoomSize: 0.5, Damping: 0.5, Diffusion: 0.5, Wet: 1.0 / 3, Width: 1},
+		{Mode: Freeverb, RoomSize: 0.9, Damping: 0.1, Diffusion: 0.5, Wet: 0.2, Dry: 0.4, Width: 0.3},
+	} {
+		in := random(rand.New(rand.NewSource(4)), 20000*2)
+		ref := newRefFreeverb(p.RoomSize, p.Damping, p.Wet, p.Dry, p.Width)
+		want := make([]float64, len(in))
+		for f := 0; f < len(in)/2; f++ {
+			want[f*2], want[f*2+1] = ref.process(float64(in[f*2]), float64(in[f*2+1]))
+		}
+		got := make(audio.F64Samples, len(in))
+		copy(got, in)
+		v := NewReverb(nil, c, p)
+		for i := 0; i < len(got); i += 2 * 300 {
+			end := i + 2*300
+			if end > len(got) {
+				end = len(got)
+			}
+			v.Process(got[i:end], c)
+		}
+		for i := range got {
+			if math.Abs(float64(got[i])-want[i]) > 1e-12 {
+				t.Fatalf("%+v: sample %d = %v, want %v", p, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+// impulse returns the response of a reverb with the given parameters to an
+// impulse in every channel, read until EOS.
+func impulse(t *testing.T, c audio.Config, p Params) audio.F64Samples {
+	s := make(audio.F64Samples, c.Channels)
+	s[0] = 1
+	return readAll(t, NewReverb(audio.NewBuffer(s), c, p), 1000)
+}
+
+// decayTime estimates the reverberation time (RT60) in seconds of a single
+// channel impulse response, from the -5 to -35dB range of its Schroeder
+// backward integrated energy decay.
+func decayTime(h []float64, sampleRate int) float64 {
+	energy := make([]float64, len(h))
+	var sum float64
+	for i := len(h) - 1; i >= 0; i-- {
+		sum += h[i] * h[i]
+		energy[i] = sum
+	}
+	at := func(db float64) int {
+		for i, e := range energy {
+			if 10*math.Log10(e/energy[0]) < db {
+				return i
+			}
+		}
+		return len(h)
+	}
+	return 2 * float64(at(-35)-at(-5)) / float64(sampleRate)
+}
+
+func TestReverbDecay(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 2}
+	for _, size := range []float64{0.2, 0.5} {
+		p := Params{RoomSize: size, Diffusion: 0.5, Wet: 1, Width: 1}
+		h := impulse(t, c, p)
+		want := reverbTime(size)
+		for ch := 0; ch < 2; ch++ {
+			got := decayTime(channel(h, ch, 2), c.SampleRate)
+			if math.Abs(got-want)/want > 0.15 {
+				t.Errorf("room size %v: RT60 = %.3fs, want %.3fs", size, got, want)
+			}
+		}
+		if tail := float64(len(h)/2) / float64(c.SampleRate); tail < want || tail > 4*want {
+			t.Errorf("room size %v: tail of %.2fs", size, tail)
+		}
+	}
+}
+
+// brightness returns the ratio of the energy of the first difference of s to
+// the energy of s, which increases with high frequency content.
+func brightness(s []float64) float64 {
+	var e, d float64
+	for i := 1; i < len(s); i++ {
+		e += s[i] * s[i]
+		d += (s[i] - s[i-1]) * (s[i] - s[i-1])
+	}
+	return d / e
+}
+
+func TestReverbDamping(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 1}
+	for _, m := range []Mode{FDN, Freeverb} {
+		late := func(damp float64) float64 {
+			h := impulse(t, c, Params{Mode: m, RoomSize: 0.5, Damping: damp, Diffusion: 0.5, Wet: 1})
+			return brightness(channel(h[24000:48000], 0, 1))
+		}
+		if dark, bright := late(1), late(0); dark > bright/4 {
+			t.Errorf("%v: damped brightness %v, undamped %v", m, dark, bright)
+		}
+	}
+}
+
+func TestReverbPreDelay(t *testing.T) {
+	c := audio.Config{SampleRate: 44100, Channels: 2}
+	p := Params{Mode: Freeverb, RoomSize: 0.5, Diffusion: 0.5, Wet: 1, Width: 1, PreDelay: 10 * time.Millisecond}
+	h := impulse(t, c, p)
+	for i, v := range h {
+		if v != 0 {
+			// 441 frames of pre-delay, then the shortest comb filter.
+			if want := (441 + 1116) * 2; i != want {
+				t.Fatalf("first output at sample %d, want %d", i, want)
+			}
+			break
+		}
+	}
+}
+
+func TestReverbWidth(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 6}
+	for _, m := range []Mode{FDN, Freeverb} {
+		p := Params{Mode: m, RoomSize: 0.3, Diffusion: 0.5, Wet: 1}
+		h := impulse(t, c, p)
+		for i := 0; i < len(h); i += 6 {
+			for ch := 1; ch < 6; ch++ {
+				if math.Abs(float64(h[i+ch]-h[i])) > 1e-12 {
+					t.Fatalf("%v: zero width frame %d differs between channels", m, i/6)
+				}
+			}
+		}
+
+		// At full width the channels are decorrelated.
+		p.Width = 1
+		h = impulse(t, c, p)
+		a := channel(h, 0, 6)
+		for ch := 1; ch < 6; ch++ {
+			b := channel(h, ch, 6)
+			var ab, aa, bb float64
+			for i := range a {
+				ab += a[i] * b[i]
+				aa += a[i] * a[i]
+				bb += b[i] * b[i]
+			}
+			if r := ab / math.Sqrt(aa*bb); math.Abs(r) > 0.3 {
+				t.Errorf("%v: channels 0 and %d correlation %v", m, ch, r)
+			}
+		}
+	}
+}
+
+func TestReverbDeterministic(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 2}
+	p := Params{RoomSize: 0.6, Damping: 0.4, Diffusion: 0.7, Wet: 0.5, Dry: 1, Width: 0.8, PreDelay: 5 * time.Millisecond}
+	in := random(rand.New(rand.NewSource(5)), 4800*2)
+	render := func(chunk int) audio.F64Samples {
+		src := make(audio.F64Samples, len(in))
+		copy(src, in)
+		return readAll(t, NewReverb(audio.NewBuffer(src), c, p), chunk)
+	}
+	a, b := render(64), render(1002)
+	if len(a) != len(b) {
+		t.Fatalf("lengths %d and %d differ", len(a), len(b))
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("sample %d: %v != %v", i, a[i], b[i])
+		}
+	}
+
+	// Golden values, guarding against accidental changes to the output.
+	golden := map[int]float64{
+		1000:  0.486157246222,
+		10000: -0.021028386442,
+		20000: 0.012608306735,
+		40000: -0.010315707950,
+	}
+	for i, want := range golden {
+		if math.Abs(float64(a[i])-want) > 1e-9 {
+			t.Errorf("sample %d = %.12f, want %.12f", i, a[i], want)
+		}
+	}
+}
+
+func TestReverbParams(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 2}
+	v := NewReverb(nil, c, Params{Mode: Freeverb, Width: 1})
+	p := Params{RoomSize: 0.5, Wet: 1}
+	v.SetParams(p)
+	if v.Params() != p {
+		t.Fatalf("Params() = %+v, want %+v", v.Params(), p)
+	}
+	for _, p := range []Params{
+		{Mode: 7},
+		{RoomSize: 1.5},
+		{Damping: -1},
+		{Width: 2},
+		{PreDelay: -time.Second},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("no panic for %+v", p)
+				}
+			}()
+			v.SetParams(p)
+		}()
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audio
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrOverrun is returned by a non-blocking RingBuffer's Write method when
+// there was not enough free space to store all of the given samples.
+var ErrOverrun = errors.New("audio: ring buffer overrun")
+
+// ErrClosedRing is the error used for write operations on a closed ring
+// buffer.
+var ErrClosedRing = errors.New("audio: write on closed ring buffer")
+
+// RingBuffer is a fixed-capacity single-producer, single-consumer ring buffer
+// of audio samples. RingBuffers must be allocated via the NewRingBuffer
+// function.
+//
+// Exactly one goroutine may call Write (and Close) and exactly one other
+// goroutine may call Read, concurrently, without any further synchronization.
+// No locks are taken and no memory is allocated by the Read and Write methods,
+// which makes a RingBuffer suitable for passing data to or from a realtime
+// audio callback.
+//
+// In non-blocking mode (the default) Read returns immediately with however
+// many samples are available (possibly zero), counting an underrun if fewer
+// samples than requested were available, and Write stores as many samples as
+// will fit, counting an overrun and returning ErrOverrun if not all of them
+// fit. In blocking mode Read waits until at least one sample is available and
+// Write waits until all samples have been stored.
+type RingBuffer struct {
+	// Accessed atomically; kept first for 64-bit alignment on 32-bit
+	// platforms.
+	r, w                uint64 // total samples read and written
+	underruns, overruns uint64
+	closed, blocking    uint32
+
+	buf      Slice
+	readable chan struct{}
+	writable chan struct{}
+}
+
+// SetBlocking sets whether the ring buffer operates in blocking (true) or
+// non-blocking (false) mode. It is safe to call from any goroutine.
+func (b *RingBuffer) SetBlocking(blocking bool) {
+	var v uint32
+	if blocking {
+		v = 1
+	}
+	atomic.StoreUint32(&b.blocking, v)
+	b.notify(b.readable)
+	b.notify(b.writable)
+}
+
+// Blocking reports whether the ring buffer operates in blocking mode.
+func (b *RingBuffer) Blocking() bool {
+	return atomic.LoadUint32(&b.blocking) == 1
+}
+
+// Cap returns the fixed capacity of the ring buffer, in samples.
+func (b *RingBuffer) Cap() int {
+	return b.buf.Len()
+}
+
+// Len returns the number of samples that are available for reading.
+func (b *RingBuffer) Len() int {
+	return int(atomic.LoadUint64(&b.w) - atomic.LoadUint64(&b.r))
+}
+
+// Underruns returns the number of Read calls that found fewer samples
+// available than requested.
+func (b *RingBuffer) Underruns() uint64 {
+	return atomic.LoadUint64(&b.underruns)
+}
+
+// Overruns returns the number of Write calls that found less free space than
+// was required to store all of the samples.
+func (b *RingBuffer) Overruns() uint64 {
+	return atomic.LoadUint64(&b.overruns)
+}
+
+// notify wakes up a goroutine waiting on c, if any, without blocking.
+func (b *RingBuffer) notify(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+// Read reads up to p.Len() samples from the ring buffer. If the ring buffer
+// is empty and has been closed, err is EOS.
+//
+// It must only be called by the single consumer goroutine.
+func (b *RingBuffer) Read(p Slice) (n int, err error) {
+	if p.Len() == 0 {
+		return 0, nil
+	}
+	r := b.r // Only modified by this goroutine.
+	var avail int
+	for {
+		avail = int(atomic.LoadUint64(&b.w) - r)
+		if avail > 0 {
+			break
+		}
+		if atomic.LoadUint32(&b.closed) == 1 {
+			// Re-check; the producer may have written before closing.
+			if avail = int(atomic.LoadUint64(&b.w) - r); avail > 0 {
+				break
+			}
+			return 0, EOS
+		}
+		if !b.Blocking() {
+			atomic.AddUint64(&b.underruns, 1)
+			return 0, nil
+		}
+		<-b.readable
+	}
+	n = p.Len()
+	if n > avail {
+		n = avail
+		if !b.Blocking() && atomic.LoadUint32(&b.closed) == 0 {
+			atomic.AddUint64(&b.underruns, 1)
+		}
+	}
+
+	capacity := b.buf.Len()
+	start := int(r % uint64(capacity))
+	first := n
+	if start+first > capacity {
+		first = capacity - start
+	}
+	copyRange(p, 0, b.buf, start, first)
+	copyRange(p, first, b.buf, 0, n-first)
+
+	atomic.StoreUint64(&b.r, r+uint64(n))
+	b.notify(b.writable)
+	return n, nil
+}
+
+// Write writes the samples from p into the ring buffer. In non-blocking mode
+// only as many samples as there is free space for are written, and err is
+// ErrOverrun if that was fewer than p.Len(). Writing to a closed ring buffer
+// returns ErrClosedRing.
+//
+// It must only be called by the single producer goroutine.
+func (b *RingBuffer) Write(p Slice) (n int, err error) {
+	if atomic.LoadUint32(&b.closed) == 1 {
+		return 0, ErrClosedRing
+	}
+	capacity := b.buf.Len()
+	w := b.w // Only modified by this goroutine.
+	for n < p.Len() {
+		free := capacity - int(w-atomic.LoadUint64(&b.r))
+		if free == 0 {
+			if !b.Blocking() {
+				atomic.AddUint64(&b.overruns, 1)
+				return n, ErrOverrun
+			}
+			<-b.writable
+			continue
+		}
+		m := p.Len() - n
+		if m > free {
+			m = free
+		}
+		start := int(w % uint64(capacity))
+		first := m
+		if start+first > capacity {
+			first = capacity - start
+		}
+		copyRange(b.buf, start, p, n, first)
+		copyRange(b.buf, 0, p, n+first, m-first)
+
+		w += uint64(m)
+		n += m
+		atomic.StoreUint64(&b.w, w)
+		b.notify(b.readable)
+	}
+	return n, nil
+}
+
+// Close closes the ring buffer; once the remaining samples have been read,
+// subsequent reads return EOS. It must only be called by the single producer
+// goroutine.
+func (b *RingBuffer) Close() error {
+	atomic.StoreUint32(&b.closed, 1)
+	b.notify(b.readable)
+	return nil
+}
+
+// NewRingBuffer creates and initializes a new, empty, non-blocking RingBuffer
+// whose capacity is buf.Len() samples. The ring buffer will internally use
+// the given slice, buf, which also defines the sample storage type.
+//
+// It panics if buf.Len() is zero.
+func NewRingBuffer(buf Slice) *RingBuffer {
+	if buf.Len() == 0 {
+		panic("audio.NewRingBuffer: zero capacity")
+	}
+	return &RingBuffer{
+		buf:      buf,
+		readable: make(chan struct{}, 1),
+		writable: make(chan struct{}, 1),
+	}
+}
+
+// copyRange copies n samples from src[srcOff:] to dst[dstOff:]. Unlike
+// CopyTo it never allocates: slices of the same type are copied directly, and
+// otherwise each sample is converted via the At and Set methods.
+func copyRange(dst Slice, dstOff int, src Slice, srcOff, n int) {
+	if n == 0 {
+		return
+	}
+	switch s := src.(type) {
+	case F64Samples:
+		if d, ok := dst.(F64Samples); ok {
+			copy(d[dstOff:dstOff+n], s[srcOff:srcOff+n])
+			return
+		}
+	case F32Samples:
+		if d, ok := dst.(F32Samples); ok {
+			copy(d[dstOff:dstOff+n], s[srcOff:srcOff+n])
+			return
+		}
+	case PCM8Samples:
+		if d, ok := dst.(PCM8Samples); ok {
+			copy(d[dstOff:dstOff+n], s[srcOff:srcOff+n])
+			return
+		}
+	case PCM16Samples:
+		if d, ok := dst.(PCM16Samples); ok {
+			copy(d[dstOff:dstOff+n], s[srcOff:srcOff+n])
+			return
+		}
+	case PCM32Samples:
+		if d, ok := dst.(PCM32Samples); ok {
+			copy(d[dstOff:dstOff+n], s[srcOff:srcOff+n])
+			return
+		}
+	case MuLawSamples:
+		if d, ok := dst.(MuLawSamples); ok {
+			copy(d[dstOff:dstOff+n], s[srcOff:srcOff+n])
+			return
+		}
+	case ALawSamples:
+		if d, ok := dst.(ALawSamples); ok {
+			copy(d[dstOff:dstOff+n], s[srcOff:srcOff+n])
+			return
+		}
+	}
+	for i := 0; i < n; i++ {
+		dst.Set(dstOff+i, src.At(srcOff+i))
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audio
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestRingBufferIO(t *testing.T) {
+	rb := NewRingBuffer(make(F64Samples, 16))
+	_ = Reader(rb)
+	_ = Writer(rb)
+}
+
+func TestRingBufferNonBlocking(t *testing.T) {
+	rb := NewRingBuffer(make(F32Samples, 8))
+
+	// Overrun: only 8 of 10 samples fit.
+	n, err := rb.Write(make(F64Samples, 10))
+	if n != 8 || err != ErrOverrun {
+		t.Fatalf("Write got (%d, %v), want (8, ErrOverrun)", n, err)
+	}
+	if rb.Overruns() != 1 {
+		t.Fatalf("Overruns got %d, want 1", rb.Overruns())
+	}
+
+	// Underrun: only 8 of 12 samples available.
+	n, err = rb.Read(make(F64Samples, 12))
+	if n != 8 || err != nil {
+		t.Fatalf("Read got (%d, %v), want (8, nil)", n, err)
+	}
+	n, err = rb.Read(make(F64Samples, 12))
+	if n != 0 || err != nil {
+		t.Fatalf("Read got (%d, %v), want (0, nil)", n, err)
+	}
+	if rb.Underruns() != 2 {
+		t.Fatalf("Underruns got %d, want 2", rb.Underruns())
+	}
+
+	rb.Close()
+	if _, err = rb.Read(make(F64Samples, 1)); err != EOS {
+		t.Fatalf("Read got %v, want EOS", err)
+	}
+	if _, err = rb.Write(make(F64Samples, 1)); err != ErrClosedRing {
+		t.Fatalf("Write got %v, want ErrClosedRing", err)
+	}
+}
+
+func TestRingBufferNoAlloc(t *testing.T) {
+	for _, buf := range []Slice{make(F64Samples, 64), make(PCM16Samples, 64)} {
+		rb := NewRingBuffer(buf)
+		// Convert to the interface type up front, as that conversion
+		// allocates.
+		var src, dst Slice = make(F64Samples, 48), make(F64Samples, 48)
+		allocs := testing.AllocsPerRun(100, func() {
+			rb.Write(src)
+			rb.Read(dst)
+		})
+		if allocs != 0 {
+			t.Fatalf("%T: got %v allocations per run, want 0", buf, allocs)
+		}
+	}
+}
+
+func testRingBufferStress(t *testing.T, blocking bool) {
+	const total = 200000
+	rb := NewRingBuffer(make(F64Samples, 61))
+	rb.SetBlocking(blocking)
+
+	go func() {
+		chunk := make(F64Samples, 37)
+		var next int
+		for next < total {
+			m := len(chunk)
+			if total-next < m {
+				m = total - next
+			}
+			for i := 0; i < m; i++ {
+				chunk[i] = F64(next + i)
+			}
+			n, _ := rb.Write(chunk[:m])
+			next += n
+			if n < m {
+				runtime.Gosched()
+			}
+		}
+		rb.Close()
+	}()
+
+	buf := make(F64Samples, 23)
+	var want int
+	for {
+		n, err := rb.Read(buf)
+		for i := 0; i < n; i++ {
+			if buf[i] != F64(want) {
+				t.Fatalf("sample %d: got %v", want, buf[i])
+			}
+			want++
+		}
+		if err == EOS {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			runtime.Gosched()
+		}
+	}
+	if want != total {
+		t.Fatalf("read %d samples, want %d", want, total)
+	}
+	if blocking && (rb.Overruns() != 0 || rb.Underruns() != 0) {
+		t.Fatalf("blocking mode counted %d overruns, %d underruns", rb.Overruns(), rb.Underruns())
+	}
+}
+
+func TestRingBufferStress(t *testing.T) {
+	testRingBufferStress(t, false)
+}
+
+func TestRingBufferStressBlocking(t *testing.T) {
+	testRingBufferStress(t, true)
+}
@@ -0,0 +1,20 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package schedule implements sample-accurate sequencing of sounds.
+//
+// A Scheduler mixes sounds into a single stream, starting each at an exact
+// sample frame of the stream (even in the middle of a block of audio read),
+// rather than whenever the next block happens to be read. Start times may be
+// given in sample frames, as a time.Duration, or in beats of a tempo:
+//
+//	s := schedule.NewScheduler(audio.Config{SampleRate: 44100, Channels: 2})
+//	s.SetTempo(140)
+//	for beat := 0; beat < 16; beat++ {
+//		s.AtBeat(float64(beat), newKick(), kickConfig)
+//	}
+//
+// All times are relative to the stream clock: the number of sample frames
+// read from the scheduler (see Position).
+package schedule
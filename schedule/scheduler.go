@@ -0,0 +1,341 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package schedule
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/dsp"
+)
+
+// DefaultTempo is the default tempo of a Scheduler, in beats per minute.
+const DefaultTempo = 120
+
+// Event is a sound scheduled to start at a certain time. Events are created
+// via the At, AtTime and AtBeat methods of a Scheduler.
+type Event struct {
+	s      *Scheduler
+	r      audio.Reader
+	start  int64   // the start frame, unless by beat; guarded by s.access
+	beat   float64 // the start beat, if byBeat
+	byBeat bool
+	state  state // guarded by s.access
+	err    error // guarded by s.access
+}
+
+// state is the state of an event.
+type state uint8
+
+const (
+	pending state = iota
+	playing
+	done
+)
+
+// Start returns the sample frame of the stream that the event starts at. For
+// events scheduled by beat, this changes with the tempo until the event has
+// started.
+func (e *Event) Start() int64 {
+	e.s.access.Lock()
+	defer e.s.access.Unlock()
+	return e.s.startOf(e)
+}
+
+// Playing tells if the event has started, and has not yet finished.
+func (e *Event) Playing() bool {
+	e.s.access.Lock()
+	defer e.s.access.Unlock()
+	return e.state == playing
+}
+
+// Done tells if the event has finished: either its stream has ended, or it
+// has been canceled.
+func (e *Event) Done() bool {
+	e.s.access.Lock()
+	defer e.s.access.Unlock()
+	return e.state == done
+}
+
+// Err returns the error, other than EOS, which stopped the event (if any).
+func (e *Event) Err() error {
+	e.s.access.Lock()
+	defer e.s.access.Unlock()
+	return e.err
+}
+
+// Cancel cancels the event: if it has not yet started it never will, and if
+// it is playing it stops at the next block of audio read. It is a no-op if
+// the event is already done.
+func (e *Event) Cancel() {
+	e.s.access.Lock()
+	e.s.finish(e, nil)
+	e.s.access.Unlock()
+}
+
+// Scheduler mixes sounds into a single stream, each starting at an exact
+// sample frame. It implements the audio.Reader interface. Schedulers must be
+// allocated via the NewScheduler function.
+//
+// The mixed stream never ends: when no sounds are playing it is silent.
+//
+// It is safe to schedule and cancel events, and to change the tempo, from
+// other goroutines while audio is being read. Events scheduled to start at a
+// time which has already been read start as soon as possible instead.
+type Scheduler struct {
+	config audio.Config
+
+	access sync.Mutex
+	pos    int64 // frames read
+	events []*Event
+
+	// The tempo, in beats per minute, since the frame origin, which is at the
+	// beat originBeat.
+	tempo      float64
+	origin     int64
+	originBeat float64
+
+	// Used only while mixing.
+	active []*Event
+	starts []int64
+	buf    audio.F64Samples
+}
+
+// Config returns the audio configuration of the mixed stream.
+func (s *Scheduler) Config() audio.Config {
+	return s.config
+}
+
+// Position returns the position of the stream clock: the number of sample
+// frames read so far. Sounds scheduled at this frame start at the beginning
+// of the next read.
+func (s *Scheduler) Position() int64 {
+	s.access.Lock()
+	defer s.access.Unlock()
+	return s.pos
+}
+
+// Time returns the position of the stream clock as a duration.
+func (s *Scheduler) Time() time.Duration {
+	return s.Duration(s.Position())
+}
+
+// Frame returns the number of sample frames in the duration d, rounded to the
+// nearest frame.
+func (s *Scheduler) Frame(d time.Duration) int64 {
+	return int64(math.Floor(d.Seconds()*float64(s.config.SampleRate) + 0.5))
+}
+
+// Duration returns the duration of the given number of sample frames.
+func (s *Scheduler) Duration(frames int64) time.Duration {
+	// Split the frames to avoid overflow for long durations.
+	rate := int64(s.config.SampleRate)
+	sec, frac := frames/rate, frames%rate
+	return time.Duration(sec)*time.Second + time.Duration(frac)*time.Second/time.Duration(rate)
+}
+
+// Tempo returns the tempo in beats per minute.
+func (s *Scheduler) Tempo() float64 {
+	s.access.Lock()
+	defer s.access.Unlock()
+	return s.tempo
+}
+
+// SetTempo sets the tempo in beats per minute, from the current position of
+// the stream clock onwards. Events scheduled by beat which have not yet
+// started are moved to follow the new tempo.
+//
+// It panics if the tempo is not positive.
+func (s *Scheduler) SetTempo(bpm float64) {
+	if !(bpm > 0) {
+		panic("schedule: invalid tempo")
+	}
+	s.access.Lock()
+	s.originBeat = s.beatAt(s.pos)
+	s.origin = s.pos
+	s.tempo = bpm
+	s.access.Unlock()
+}
+
+// beatAt returns the beat at the given frame; s.access must be held.
+func (s *Scheduler) beatAt(frame int64) float64 {
+	return s.originBeat + float64(frame-s.origin)*s.tempo/(60*float64(s.config.SampleRate))
+}
+
+// frameOf returns the frame of the given beat, rounded to the nearest frame;
+// s.access must be held.
+func (s *Scheduler) frameOf(beat float64) int64 {
+	return s.origin + int64(math.Floor((beat-s.originBeat)*60*float64(s.config.SampleRate)/s.tempo+0.5))
+}
+
+// Beat returns the position of the stream clock in beats, counting from beat
+// zero at the start of the stream.
+func (s *Scheduler) Beat() float64 {
+	s.access.Lock()
+	defer s.access.Unlock()
+	return s.beatAt(s.pos)
+}
+
+// BeatFrame returns the sample frame of the given beat, at the current tempo.
+func (s *Scheduler) BeatFrame(beat float64) int64 {
+	s.access.Lock()
+	defer s.access.Unlock()
+	return s.frameOf(beat)
+}
+
+// startOf returns the start frame of e; s.access must be held.
+func (s *Scheduler) startOf(e *Event) int64 {
+	if e.byBeat && e.state == pending {
+		e.start = s.frameOf(e.beat)
+	}
+	return e.start
+}
+
+// add schedules an event for the stream r, whose samples are laid out
+// according to the given audio configuration.
+func (s *Scheduler) add(r audio.Reader, c audio.Config, start int64, beat float64, byBeat bool) *Event {
+	if c.Channels != s.config.Channels {
+		panic("schedule: stream does not match the number of channels")
+	}
+	e := &Event{s: s, start: start, beat: beat, byBeat: byBeat}
+	if c.SampleRate == s.config.SampleRate {
+		e.r = audio.NewFrameReader(r, c)
+	} else {
+		e.r = dsp.NewRateConverter(r, c, s.config.SampleRate)
+	}
+	s.access.Lock()
+	s.events = append(s.events, e)
+	s.access.Unlock()
+	return e
+}
+
+// At schedules the stream r, whose samples are laid out according to the
+// given audio configuration, to start at the given sample frame of the stream
+// clock. The stream may be of any sample rate, but must have the same number
+// of channels as the scheduler.
+//
+// It panics if the stream has a different number of channels.
+func (s *Scheduler) At(frame int64, r audio.Reader, c audio.Config) *Event {
+	return s.add(r, c, frame, 0, false)
+}
+
+// AtTime is like At, but schedules the stream to start at the given time of
+// the stream clock, rounded to the nearest sample frame.
+func (s *Scheduler) AtTime(t time.Duration, r audio.Reader, c audio.Config) *Event {
+	return s.add(r, c, s.Frame(t), 0, false)
+}
+
+// AtBeat is like At, but schedules the stream to start at the given beat (see
+// Beat), rounded to the nearest sample frame. The start frame follows changes
+// of the tempo made before the event starts.
+func (s *Scheduler) AtBeat(beat float64, r audio.Reader, c audio.Config) *Event {
+	return s.add(r, c, 0, beat, true)
+}
+
+// Len returns the number of events which are pending or playing.
+func (s *Scheduler) Len() int {
+	s.access.Lock()
+	defer s.access.Unlock()
+	return len(s.events)
+}
+
+// finish marks e as done, removing it; s.access must be held.
+func (s *Scheduler) finish(e *Event, err error) {
+	if e.state == done {
+		return
+	}
+	if err != audio.EOS {
+		e.err = err
+	}
+	e.state = done
+	for i, o := range s.events {
+		if o == e {
+			s.events = append(s.events[:i], s.events[i+1:]...)
+			return
+		}
+	}
+}
+
+// Read implements the audio.Reader interface. It always fills b with whole
+// frames of audio, and never returns EOS.
+func (s *Scheduler) Read(b audio.Slice) (n int, err error) {
+	channels := s.config.Channels
+	frames := b.Len() / channels
+	n = frames * channels
+	for i := 0; i < n; i++ {
+		b.Set(i, 0)
+	}
+
+	// Find the events which play during this read.
+	s.access.Lock()
+	pos := s.pos
+	end := pos + int64(frames)
+	s.active, s.starts = s.active[:0], s.starts[:0]
+	for _, e := range s.events {
+		if start := s.startOf(e); start < end {
+			if e.state == pending {
+				e.state = playing
+				if start < pos {
+					// Late; start as soon as possible instead.
+					e.start = pos
+				}
+			}
+			s.active = append(s.active, e)
+			s.starts = append(s.starts, e.start)
+		}
+	}
+	s.pos = end
+	s.access.Unlock()
+
+	if len(s.buf) < n {
+		s.buf = make(audio.F64Samples, n)
+	}
+	for i, e := range s.active {
+		offset := 0
+		if start := s.starts[i]; start > pos {
+			offset = int(start-pos) * channels
+		}
+
+		// Read until the rest of the block is filled, such that there are no
+		// gaps in the sound.
+		buf := s.buf[:n-offset]
+		var err error
+		read := 0
+		for read < len(buf) && err == nil {
+			var m int
+			m, err = e.r.Read(buf[read:])
+			read += m
+			if m == 0 && err == nil {
+				// The stream made no progress; treat it as ended.
+				err = audio.EOS
+			}
+		}
+		for j, v := range buf[:read] {
+			b.Set(offset+j, b.At(offset+j)+v)
+		}
+		if err != nil {
+			s.access.Lock()
+			s.finish(e, err)
+			s.access.Unlock()
+		}
+	}
+	return n, nil
+}
+
+// NewScheduler returns a new scheduler whose mixed stream has the given audio
+// configuration, at the default tempo.
+//
+// It panics if the audio configuration is invalid.
+func NewScheduler(c audio.Config) *Scheduler {
+	if c.SampleRate < 1 || c.Channels < 1 {
+		panic("schedule: invalid audio configuration")
+	}
+	return &Scheduler{
+		config: c,
+		tempo:  DefaultTempo,
+	}
+}
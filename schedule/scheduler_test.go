@@ -0,0 +1,203 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package schedule
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"azul3d.org/audio.v1"
+)
+
+var mono = audio.Config{SampleRate: 1000, Channels: 1}
+
+// click returns a mono stream of n samples of value v.
+func click(n int, v audio.F64) audio.Reader {
+	b := make(audio.F64Samples, n)
+	for i := range b {
+		b[i] = v
+	}
+	return audio.NewBuffer(b)
+}
+
+// read reads frames from s in reads of the given size.
+func read(s *Scheduler, frames, size int) audio.F64Samples {
+	out := make(audio.F64Samples, 0, frames)
+	b := make(audio.F64Samples, size*s.Config().Channels)
+	for len(out) < frames*s.Config().Channels {
+		n, _ := s.Read(b)
+		out = append(out, b[:n]...)
+	}
+	return out
+}
+
+// onsets returns the indices of samples which are non-zero after a zero.
+func onsets(b audio.F64Samples) []int {
+	var idx []int
+	var prev audio.F64
+	for i, v := range b {
+		if v != 0 && prev == 0 {
+			idx = append(idx, i)
+		}
+		prev = v
+	}
+	return idx
+}
+
+func TestSchedulerSampleAccurate(t *testing.T) {
+	for _, size := range []int{1, 8, 125, 1000} {
+		s := NewScheduler(mono)
+		s.At(5, click(3, 1), mono)
+		s.At(130, click(3, 1), mono)
+		s.AtTime(250*time.Millisecond, click(3, 1), mono)
+		s.At(999, click(3, 1), mono)
+		got := onsets(read(s, 1000, size))
+		want := []int{5, 130, 250, 999}
+		if len(got) != len(want) {
+			t.Fatalf("read size %d: onsets %v, want %v", size, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("read size %d: onsets %v, want %v", size, got, want)
+			}
+		}
+		if s.Position() != 1000 || s.Time() != time.Second {
+			t.Errorf("Position() = %d, Time() = %v", s.Position(), s.Time())
+		}
+	}
+}
+
+func TestSchedulerDuration(t *testing.T) {
+	// A week at 48 kHz overflows time.Duration(frames) * time.Second.
+	s := NewScheduler(audio.Config{SampleRate: 48000, Channels: 1})
+	d := 7*24*time.Hour + time.Second/2
+	if n := s.Frame(d); s.Duration(n) != d {
+		t.Errorf("Duration(%d) = %v, want %v", n, s.Duration(n), d)
+	}
+}
+
+func TestSchedulerMix(t *testing.T) {
+	s := NewScheduler(mono)
+	a := s.At(0, click(10, 1), mono)
+	b := s.At(5, click(10, 2), mono)
+	out := read(s, 20, 20)
+	for i, v := range out {
+		var want audio.F64
+		if i < 10 {
+			want++
+		}
+		if i >= 5 && i < 15 {
+			want += 2
+		}
+		if v != want {
+			t.Fatalf("sample %d = %v, want %v", i, v, want)
+		}
+	}
+	if !a.Done() || !b.Done() || s.Len() != 0 {
+		t.Errorf("a.Done() = %v, b.Done() = %v, Len() = %d", a.Done(), b.Done(), s.Len())
+	}
+}
+
+func TestSchedulerLate(t *testing.T) {
+	s := NewScheduler(mono)
+	read(s, 100, 100)
+	e := s.At(10, click(5, 1), mono)
+	got := onsets(read(s, 100, 100))
+	if len(got) != 1 || got[0] != 0 || e.Start() != 100 {
+		t.Errorf("onsets %v, Start() = %d", got, e.Start())
+	}
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	s := NewScheduler(mono)
+	pending := s.At(50, click(10, 1), mono)
+	long := s.At(0, click(1000, 2), mono)
+	read(s, 20, 20)
+	if !long.Playing() || pending.Playing() {
+		t.Fatalf("long.Playing() = %v, pending.Playing() = %v", long.Playing(), pending.Playing())
+	}
+	pending.Cancel()
+	long.Cancel()
+	for i, v := range read(s, 100, 20) {
+		if v != 0 {
+			t.Fatalf("sample %d = %v after cancel", i, v)
+		}
+	}
+	if !pending.Done() || !long.Done() || s.Len() != 0 {
+		t.Errorf("Done() = %v, %v, Len() = %d", pending.Done(), long.Done(), s.Len())
+	}
+}
+
+func TestSchedulerTempo(t *testing.T) {
+	s := NewScheduler(mono)
+	// At 120 BPM a beat is 500 frames.
+	if f := s.BeatFrame(3); f != 1500 {
+		t.Errorf("BeatFrame(3) = %d, want 1500", f)
+	}
+	first := s.AtBeat(1, click(3, 1), mono)
+	later := s.AtBeat(3, click(3, 1), mono)
+	read(s, 1000, 100)
+	if b := s.Beat(); b != 2 {
+		t.Errorf("Beat() = %v, want 2", b)
+	}
+	// Doubling the tempo at beat two moves the pending third beat to 250
+	// frames later, while the first has already played.
+	s.SetTempo(240)
+	if first.Start() != 500 || later.Start() != 1250 {
+		t.Errorf("starts %d, %d, want 500, 1250", first.Start(), later.Start())
+	}
+	got := onsets(read(s, 500, 33))
+	if len(got) != 1 || got[0] != 250 {
+		t.Errorf("onsets after tempo change %v, want [250]", got)
+	}
+}
+
+func TestSchedulerSampleRate(t *testing.T) {
+	s := NewScheduler(mono)
+	half := audio.Config{SampleRate: 500, Channels: 1}
+	e := s.At(100, click(100, 1), half)
+	out := read(s, 1000, 100)
+	if got := onsets(out); len(got) != 1 || got[0] < 95 || got[0] > 100 {
+		t.Errorf("onsets %v, want about [100]", got)
+	}
+	if !e.Done() {
+		t.Error("event did not finish")
+	}
+}
+
+func TestSchedulerStereo(t *testing.T) {
+	stereo := audio.Config{SampleRate: 1000, Channels: 2}
+	s := NewScheduler(stereo)
+	s.At(3, audio.NewBuffer(audio.F64Samples{1, 2, 3, 4}), stereo)
+	out := read(s, 10, 4)
+	want := audio.F64Samples{0, 0, 0, 0, 0, 0, 1, 2, 3, 4, 0, 0}
+	for i := range want {
+		if out[i] != want[i] {
+			t.Fatalf("got %v, want %v...", out, want)
+		}
+	}
+}
+
+func TestSchedulerConcurrent(t *testing.T) {
+	s := NewScheduler(mono)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			e := s.AtBeat(s.Beat()+0.01, click(20, 0.1), mono)
+			if i%3 == 0 {
+				e.Cancel()
+			}
+			if i%50 == 0 {
+				s.SetTempo(float64(100 + i))
+			}
+			_ = e.Start()
+		}
+	}()
+	read(s, 5000, 50)
+	wg.Wait()
+}
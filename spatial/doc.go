@@ -0,0 +1,16 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package spatial implements 3D positional audio.
+//
+// A Mixer mixes mono sources, each placed in the world by an Emitter, into a
+// single stream as heard by a Listener: sources are attenuated with distance
+// and by their directional cones, pitch shifted by the Doppler effect of
+// their relative motion, and spatialized by a Panner (by default panned across
+// the speakers of the output, see Layout).
+//
+// Like the rest of Azul3D, a right-handed coordinate system with the Z axis
+// pointing up is used: by default +X is right, +Y is forward and +Z is up.
+// Distances are in arbitrary units (meters, for the default speed of sound).
+package spatial
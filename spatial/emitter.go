@@ -0,0 +1,223 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package spatial
+
+import (
+	"fmt"
+	"math"
+)
+
+// Attenuation represents a single distance attenuation model.
+type Attenuation uint8
+
+const (
+	// Inverse attenuation follows the inverse distance law of a point
+	// source in free space (for a rolloff of one, -6dB per doubling of
+	// distance):
+	//
+	//	gain = min / (min + rolloff * (distance - min))
+	Inverse Attenuation = iota
+
+	// Linear attenuation decreases linearly, reaching silence at the
+	// maximum distance for a rolloff of one:
+	//
+	//	gain = 1 - rolloff * (distance - min) / (max - min)
+	Linear
+
+	// Exponential attenuation decreases exponentially with distance:
+	//
+	//	gain = (distance / min) ^ -rolloff
+	Exponential
+)
+
+// String returns a string representation of the attenuation model.
+func (a Attenuation) String() string {
+	switch a {
+	case Inverse:
+		return "Inverse"
+	case Linear:
+		return "Linear"
+	case Exponential:
+		return "Exponential"
+	}
+	return fmt.Sprintf("Attenuation(%d)", uint8(a))
+}
+
+// Gain returns the linear gain of the attenuation model at the given distance.
+// The distance is first clamped to the range of min to max: sources closer
+// than min are not amplified, and sources further than max are attenuated no
+// further. A max of zero means there is no maximum distance.
+func (a Attenuation) Gain(distance, min, max, rolloff float64) float64 {
+	if min <= 0 {
+		min = 1e-6
+	}
+	if max <= 0 {
+		max = math.Inf(1)
+	}
+	if max < min {
+		max = min
+	}
+	d := math.Max(min, math.Min(max, distance))
+	var g float64
+	switch a {
+	case Inverse:
+		g = min / (min + rolloff*(d-min))
+	case Linear:
+		if math.IsInf(max, 1) {
+			return 1
+		}
+		g = 1 - rolloff*(d-min)/(max-min)
+	case Exponential:
+		g = math.Pow(d/min, -rolloff)
+	default:
+		panic("spatial: invalid attenuation model")
+	}
+	return math.Max(0, math.Min(1, g))
+}
+
+// Cone describes the directionality of an emitter: within the inner cone the
+// emitter is heard at full level, outside of the outer cone it is attenuated
+// by the outer gain, and in between the gain is interpolated. The zero value
+// is omnidirectional.
+type Cone struct {
+	// InnerAngle and OuterAngle are the full angles of the cones, in
+	// radians, centered around the emitter's forward direction. An outer
+	// angle of zero disables the cone.
+	InnerAngle, OuterAngle float64
+
+	// OuterGain is the linear gain outside of the outer cone.
+	OuterGain float64
+}
+
+// Gain returns the linear gain of the cone at the given angle, in radians,
+// from the emitter's forward direction.
+func (c Cone) Gain(angle float64) float64 {
+	if c.OuterAngle <= 0 {
+		return 1
+	}
+	inner, outer := c.InnerAngle/2, c.OuterAngle/2
+	angle = math.Abs(angle)
+	switch {
+	case angle <= inner:
+		return 1
+	case angle >= outer:
+		return c.OuterGain
+	}
+	t := (angle - inner) / (outer - inner)
+	return 1 + (c.OuterGain-1)*t
+}
+
+// Listener describes the position, motion and orientation of the listener.
+type Listener struct {
+	// Position and Velocity (in units per second) of the listener.
+	Position, Velocity Vec3
+
+	// Forward and Up are the directions the listener faces and the top of
+	// their head faces. Zero vectors default to +Y and +Z respectively.
+	Forward, Up Vec3
+}
+
+// basis returns the orthonormal right, forward and up vectors of the
+// listener's orientation.
+func (l Listener) basis() (right, forward, up Vec3) {
+	forward = l.Forward.Normalized()
+	if forward == (Vec3{}) {
+		forward = Vec3{0, 1, 0}
+	}
+	up = l.Up.Normalized()
+	if up == (Vec3{}) {
+		up = Vec3{0, 0, 1}
+	}
+	right = forward.Cross(up).Normalized()
+	if right == (Vec3{}) {
+		// Forward and up are parallel; pick any perpendicular axis.
+		right = forward.Cross(Vec3{1, 0, 0}).Normalized()
+		if right == (Vec3{}) {
+			right = forward.Cross(Vec3{0, 1, 0}).Normalized()
+		}
+	}
+	up = right.Cross(forward)
+	return
+}
+
+// Local returns the position p relative to the listener, in the listener's
+// frame of reference: X is to the right, Y is forward and Z is up.
+func (l Listener) Local(p Vec3) Vec3 {
+	right, forward, up := l.basis()
+	d := p.Sub(l.Position)
+	return Vec3{d.Dot(right), d.Dot(forward), d.Dot(up)}
+}
+
+// Emitter describes the position, motion, orientation and attenuation of a
+// sound source.
+type Emitter struct {
+	// Position and Velocity (in units per second) of the emitter.
+	Position, Velocity Vec3
+
+	// Forward is the direction the emitter faces, used by its cone.
+	Forward Vec3
+
+	// Gain is the linear gain of the emitter.
+	Gain float64
+
+	// Attenuation is the distance attenuation model, with its parameters:
+	// the reference distance at and below which the emitter is heard at full
+	// level, the distance beyond which it is attenuated no further (zero for
+	// none) and the rolloff factor (zero for no attenuation).
+	Attenuation                       Attenuation
+	MinDistance, MaxDistance, Rolloff float64
+
+	// Cone is the directionality of the emitter.
+	Cone Cone
+}
+
+// DefaultEmitter is an omnidirectional emitter at the origin with unity gain
+// and inverse distance attenuation from a distance of one.
+var DefaultEmitter = Emitter{
+	Gain:        1,
+	Attenuation: Inverse,
+	MinDistance: 1,
+	Rolloff:     1,
+}
+
+// Level returns the linear gain of the emitter as heard by the listener,
+// including its gain and its distance and cone attenuation (but not any
+// panning). It can be used to estimate the audibility of the emitter.
+func (e Emitter) Level(l Listener) float64 {
+	d := e.Position.Sub(l.Position)
+	g := e.Gain * e.Attenuation.Gain(d.Len(), e.MinDistance, e.MaxDistance, e.Rolloff)
+	if e.Cone.OuterAngle > 0 {
+		fwd := e.Forward.Normalized()
+		toListener := d.Scale(-1).Normalized()
+		if fwd != (Vec3{}) && toListener != (Vec3{}) {
+			cos := math.Max(-1, math.Min(1, fwd.Dot(toListener)))
+			g *= e.Cone.Gain(math.Acos(cos))
+		}
+	}
+	return g
+}
+
+// SpeedOfSound is the speed of sound in air in meters per second, the default
+// for a Mixer.
+const SpeedOfSound = 343.3
+
+// Doppler returns the Doppler shift, as a ratio of frequencies, of the
+// emitter as heard by the listener given the speed of sound (in units per
+// second) and a factor exaggerating (above one) or reducing (below one) the
+// effect. Relative velocities are clamped to the speed of sound.
+func Doppler(l Listener, e Emitter, speedOfSound, factor float64) float64 {
+	if factor <= 0 || speedOfSound <= 0 {
+		return 1
+	}
+	// The direction from the emitter to the listener.
+	dir := l.Position.Sub(e.Position).Normalized()
+	if dir == (Vec3{}) {
+		return 1
+	}
+	max := speedOfSound / factor * 0.99
+	vl := math.Max(-max, math.Min(max, l.Velocity.Dot(dir)))
+	ve := math.Max(-max, math.Min(max, e.Velocity.Dot(dir)))
+	return (speedOfSound - factor*vl) / (speedOfSound - factor*ve)
+}
@@ -0,0 +1,124 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package spatial
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestAttenuation(t *testing.T) {
+	for _, tst := range []struct {
+		a                                 Attenuation
+		distance, min, max, rolloff, want float64
+	}{
+		{Inverse, 0.5, 1, 0, 1, 1},
+		{Inverse, 2, 1, 0, 1, 0.5},
+		{Inverse, 4, 1, 0, 1, 0.25},
+		{Inverse, 4, 1, 2, 1, 0.5},
+		{Inverse, 3, 1, 0, 0.5, 0.5},
+		{Linear, 5.5, 1, 10, 1, 0.5},
+		{Linear, 20, 1, 10, 1, 0},
+		{Linear, 5.5, 1, 10, 0.5, 0.75},
+		{Linear, 5, 1, 0, 1, 1},
+		{Exponential, 4, 1, 0, 1, 0.25},
+		{Exponential, 4, 2, 0, 2, 0.25},
+		{Exponential, 100, 1, 4, 0.5, 0.5},
+		{Inverse, 100, 1, 0, 0, 1},
+	} {
+		got := tst.a.Gain(tst.distance, tst.min, tst.max, tst.rolloff)
+		if !near(got, tst.want) {
+			t.Errorf("%v.Gain(%v, %v, %v, %v) = %v, want %v", tst.a, tst.distance, tst.min, tst.max, tst.rolloff, got, tst.want)
+		}
+	}
+}
+
+func TestCone(t *testing.T) {
+	c := Cone{InnerAngle: math.Pi / 2, OuterAngle: math.Pi, OuterGain: 0.2}
+	for _, tst := range []struct {
+		angle, want float64
+	}{
+		{0, 1},
+		{math.Pi / 4, 1},
+		{3 * math.Pi / 8, 0.6},
+		{-3 * math.Pi / 8, 0.6},
+		{math.Pi / 2, 0.2},
+		{math.Pi, 0.2},
+	} {
+		if got := c.Gain(tst.angle); !near(got, tst.want) {
+			t.Errorf("Gain(%v) = %v, want %v", tst.angle, got, tst.want)
+		}
+	}
+	if g := (Cone{}).Gain(math.Pi); g != 1 {
+		t.Errorf("zero cone Gain = %v, want 1", g)
+	}
+}
+
+func TestEmitterLevel(t *testing.T) {
+	l := Listener{Position: Vec3{1, 1, 0}}
+	e := DefaultEmitter
+	e.Gain = 0.5
+	e.Position = Vec3{1, 5, 0}
+	if got := e.Level(l); !near(got, 0.125) {
+		t.Fatalf("Level = %v, want 0.125", got)
+	}
+
+	// Facing away from the listener.
+	e.Forward = Vec3{0, 1, 0}
+	e.Cone = Cone{InnerAngle: 1, OuterAngle: 2, OuterGain: 0.1}
+	if got := e.Level(l); !near(got, 0.0125) {
+		t.Fatalf("Level facing away = %v, want 0.0125", got)
+	}
+	e.Forward = Vec3{0, -3, 0}
+	if got := e.Level(l); !near(got, 0.125) {
+		t.Fatalf("Level facing the listener = %v, want 0.125", got)
+	}
+}
+
+func TestListenerLocal(t *testing.T) {
+	// A listener facing -X, with its right hand towards +Y.
+	l := Listener{
+		Position: Vec3{10, 0, 0},
+		Forward:  Vec3{-2, 0, 0},
+		Up:       Vec3{0, 0, 1},
+	}
+	got := l.Local(Vec3{7, 1, 2})
+	if want := (Vec3{1, 3, 2}); !near(got.Sub(want).Len(), 0) {
+		t.Fatalf("Local = %+v, want %+v", got, want)
+	}
+
+	// The default orientation is the identity.
+	got = Listener{}.Local(Vec3{1, 2, 3})
+	if want := (Vec3{1, 2, 3}); !near(got.Sub(want).Len(), 0) {
+		t.Fatalf("default Local = %+v, want %+v", got, want)
+	}
+}
+
+func TestDoppler(t *testing.T) {
+	const c = SpeedOfSound
+	l := Listener{}
+	e := Emitter{Position: Vec3{0, 100, 0}}
+	for _, tst := range []struct {
+		lv, ev       Vec3
+		factor, want float64
+	}{
+		{Vec3{}, Vec3{}, 1, 1},
+		{Vec3{}, Vec3{0, -c / 10, 0}, 1, c / (c - c/10)}, // approaching
+		{Vec3{}, Vec3{0, c / 10, 0}, 1, c / (c + c/10)},  // receding
+		{Vec3{0, c / 10, 0}, Vec3{}, 1, (c + c/10) / c},  // listener approaching
+		{Vec3{}, Vec3{c / 10, 0, 0}, 1, 1},               // tangential
+		{Vec3{}, Vec3{0, -c / 10, 0}, 2, c / (c - c/5)},
+		{Vec3{}, Vec3{0, -c / 10, 0}, 0, 1},
+	} {
+		l.Velocity, e.Velocity = tst.lv, tst.ev
+		if got := Doppler(l, e, c, tst.factor); !near(got, tst.want) {
+			t.Errorf("Doppler(%+v, %+v, %v) = %v, want %v", tst.lv, tst.ev, tst.factor, got, tst.want)
+		}
+	}
+}
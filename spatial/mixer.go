@@ -0,0 +1,302 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package spatial
+
+import (
+	"math"
+	"sync"
+
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/dsp"
+)
+
+// BlockSize is the maximum number of frames mixed at once by a Mixer; the
+// positions of the listener and emitters are sampled once per block, and gains
+// are ramped across each block to avoid clicks.
+const BlockSize = 256
+
+// maxDoppler is the largest Doppler shift (and the inverse of the smallest)
+// applied to a source; shifts beyond two octaves are clamped.
+const maxDoppler = 4
+
+// Source is a single mono sound source playing through a Mixer, placed in the
+// world by an emitter. Sources are created via the Mixer.Add method.
+type Source struct {
+	m       *Mixer
+	rs      *dsp.Resampler // Doppler shifts the source, at the output rate
+	emitter Emitter        // guarded by m.access
+	done    bool           // guarded by m.access
+	err     error          // guarded by m.access
+
+	// Used only while mixing.
+	voice Voice
+	gen   int // generation of the panner which created the voice
+}
+
+// Emitter returns the emitter of the source.
+func (s *Source) Emitter() Emitter {
+	s.m.access.Lock()
+	defer s.m.access.Unlock()
+	return s.emitter
+}
+
+// SetEmitter sets the emitter of the source, e.g. to move it. The change
+// takes effect at the next block of audio mixed.
+func (s *Source) SetEmitter(e Emitter) {
+	s.m.access.Lock()
+	s.emitter = e
+	s.m.access.Unlock()
+}
+
+// Done tells if the source has finished playing: either its stream has
+// ended or it has been removed.
+func (s *Source) Done() bool {
+	s.m.access.Lock()
+	defer s.m.access.Unlock()
+	return s.done
+}
+
+// Err returns the error, other than EOS, which stopped the source (if any).
+func (s *Source) Err() error {
+	s.m.access.Lock()
+	defer s.m.access.Unlock()
+	return s.err
+}
+
+// Remove stops the source and removes it from the mixer.
+func (s *Source) Remove() {
+	s.m.access.Lock()
+	s.m.remove(s)
+	s.m.access.Unlock()
+}
+
+// Mixer mixes mono sources, positioned in 3D space, into a single stream as
+// heard by a listener. It implements the audio.Reader interface. Mixers must
+// be allocated via the NewMixer function.
+//
+// The mixed stream never ends: when there are no sources it is silent, and
+// sources are removed automatically once their streams end (or fail, see
+// Source.Err).
+//
+// It is safe to add and remove sources, and to move the listener and emitters,
+// from other goroutines while audio is being read.
+type Mixer struct {
+	config audio.Config
+
+	access        sync.Mutex
+	panner        Panner
+	gen           int // incremented each time the panner changes
+	listener      Listener
+	speedOfSound  float64
+	dopplerFactor float64
+	sources       []*Source
+
+	// Used only while mixing.
+	active []*Source
+	states []mixState
+	mono   audio.F64Samples
+}
+
+// mixState is a snapshot of a source's state for mixing a single block.
+type mixState struct {
+	emitter Emitter
+	done    bool
+}
+
+// Config returns the audio configuration of the mixed stream.
+func (m *Mixer) Config() audio.Config {
+	return m.config
+}
+
+// Listener returns the listener.
+func (m *Mixer) Listener() Listener {
+	m.access.Lock()
+	defer m.access.Unlock()
+	return m.listener
+}
+
+// SetListener sets the listener, e.g. to move it.
+func (m *Mixer) SetListener(l Listener) {
+	m.access.Lock()
+	m.listener = l
+	m.access.Unlock()
+}
+
+// Panner returns the panner which spatializes the sources.
+func (m *Mixer) Panner() Panner {
+	m.access.Lock()
+	defer m.access.Unlock()
+	return m.panner
+}
+
+// SetPanner sets the panner which spatializes the sources, which by default is
+// the speaker layout DefaultLayout(c.Channels). Each source switches to a new
+// voice of the panner at the next block of audio mixed.
+//
+// It panics if the panner is a layout which does not have one speaker per
+// channel.
+func (m *Mixer) SetPanner(p Panner) {
+	if l, ok := p.(Layout); ok && len(l) != m.config.Channels {
+		panic("spatial: layout does not match the number of channels")
+	}
+	m.access.Lock()
+	m.panner = p
+	m.gen++
+	m.access.Unlock()
+}
+
+// Layout returns the speaker layout of the mixed stream, or nil if the panner
+// is not a speaker layout (e.g. a binaural panner).
+func (m *Mixer) Layout() Layout {
+	l, _ := m.Panner().(Layout)
+	return l
+}
+
+// SetLayout sets the speaker layout of the mixed stream, which by default is
+// DefaultLayout(c.Channels). It is short-hand for SetPanner(l).
+//
+// It panics if the layout does not have one speaker per channel.
+func (m *Mixer) SetLayout(l Layout) {
+	if len(l) != m.config.Channels {
+		panic("spatial: layout does not match the number of channels")
+	}
+	m.SetPanner(l)
+}
+
+// SetDoppler sets the speed of sound (in units per second, by default
+// SpeedOfSound) and a factor exaggerating (above one) or reducing (below one)
+// the Doppler effect; a factor of zero disables it. The default factor is
+// one. Doppler shifts are clamped to two octaves up or down.
+func (m *Mixer) SetDoppler(speedOfSound, factor float64) {
+	m.access.Lock()
+	m.speedOfSound = speedOfSound
+	m.dopplerFactor = factor
+	m.access.Unlock()
+}
+
+// Len returns the number of sources playing.
+func (m *Mixer) Len() int {
+	m.access.Lock()
+	defer m.access.Unlock()
+	return len(m.sources)
+}
+
+// Add adds a source, reading the mono stream r with the given audio
+// configuration (at any sample rate) and placed by the given emitter, and
+// returns it.
+//
+// It panics if the stream is not mono, or if its sample rate is more than 16
+// times higher or lower than the mixer's.
+func (m *Mixer) Add(r audio.Reader, c audio.Config, e Emitter) *Source {
+	if c.Channels != 1 {
+		panic("spatial: source stream must be mono")
+	}
+	// Convert the source to the output sample rate first, such that the
+	// Doppler shift alone is applied (and clamped) below.
+	out := audio.Config{SampleRate: m.config.SampleRate, Channels: 1}
+	if c.SampleRate != out.SampleRate {
+		r = dsp.NewRateConverter(r, c, out.SampleRate)
+	}
+	s := &Source{
+		m:       m,
+		rs:      dsp.NewResampler(r, out, 1),
+		emitter: e,
+	}
+	m.access.Lock()
+	m.sources = append(m.sources, s)
+	m.access.Unlock()
+	return s
+}
+
+// remove removes the source s; m.access must be held.
+func (m *Mixer) remove(s *Source) {
+	s.done = true
+	for i, o := range m.sources {
+		if o == s {
+			m.sources = append(m.sources[:i], m.sources[i+1:]...)
+			return
+		}
+	}
+}
+
+// mix mixes a single block of frames into b.
+func (m *Mixer) mix(b audio.Slice, frames int) {
+	m.access.Lock()
+	l := m.listener
+	panner, gen := m.panner, m.gen
+	sos, factor := m.speedOfSound, m.dopplerFactor
+	m.active = append(m.active[:0], m.sources...)
+	m.states = m.states[:0]
+	for _, s := range m.active {
+		m.states = append(m.states, mixState{s.emitter, s.done})
+	}
+	m.access.Unlock()
+
+	for i, s := range m.active {
+		st := m.states[i]
+		if st.done {
+			continue
+		}
+
+		e := st.emitter
+		if s.voice == nil || s.gen != gen {
+			s.voice, s.gen = panner.NewVoice(m.config), gen
+		}
+
+		// Doppler shift the source by resampling it.
+		shift := Doppler(l, e, sos, factor)
+		s.rs.SetRatio(math.Max(1.0/maxDoppler, math.Min(maxDoppler, shift)))
+
+		// Silence past the end of the stream, such that voices always mix a
+		// whole block.
+		n, err := s.rs.Read(m.mono[:frames])
+		for f := n; f < frames; f++ {
+			m.mono[f] = 0
+		}
+		s.voice.Mix(b, m.mono[:frames], l.Local(e.Position), e.Level(l))
+		if err != nil {
+			// The stream has ended (or failed); remove the source.
+			m.access.Lock()
+			if err != audio.EOS {
+				s.err = err
+			}
+			m.remove(s)
+			m.access.Unlock()
+		}
+	}
+}
+
+// Read implements the audio.Reader interface. It always fills b with whole
+// frames of audio, and never returns EOS.
+func (m *Mixer) Read(b audio.Slice) (n int, err error) {
+	channels := m.config.Channels
+	frames := b.Len() / channels
+	for i := 0; i < frames*channels; i++ {
+		b.Set(i, 0)
+	}
+	for f := 0; f < frames; f += BlockSize {
+		k := frames - f
+		if k > BlockSize {
+			k = BlockSize
+		}
+		m.mix(b.Slice(f*channels, (f+k)*channels), k)
+	}
+	return frames * channels, nil
+}
+
+// NewMixer returns a new mixer whose mixed stream has the given audio
+// configuration, with a listener at the origin facing +Y.
+func NewMixer(c audio.Config) *Mixer {
+	if c.SampleRate < 1 || c.Channels < 1 {
+		panic("spatial: invalid audio configuration")
+	}
+	return &Mixer{
+		config:        c,
+		panner:        DefaultLayout(c.Channels),
+		speedOfSound:  SpeedOfSound,
+		dopplerFactor: 1,
+		mono:          make(audio.F64Samples, BlockSize),
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package spatial
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/gen"
+)
+
+// peak returns the peak absolute value of channel ch of the interleaved
+// samples s, skipping the first skip frames.
+func peak(s audio.F64Samples, ch, channels, skip int) float64 {
+	var p float64
+	for i := skip*channels + ch; i < len(s); i += channels {
+		p = math.Max(p, math.Abs(float64(s[i])))
+	}
+	return p
+}
+
+// frequency estimates the frequency of channel ch of the interleaved samples
+// s from its rising zero crossings.
+func frequency(s audio.F64Samples, ch, channels, sampleRate int) float64 {
+	first, last, n := -1.0, -1.0, 0
+	for f := 1; f < len(s)/channels; f++ {
+		a, b := float64(s[(f-1)*channels+ch]), float64(s[f*channels+ch])
+		if a < 0 && b >= 0 {
+			x := float64(f-1) + a/(a-b)
+			if first < 0 {
+				first = x
+			} else {
+				n++
+			}
+			last = x
+		}
+	}
+	return float64(n) * float64(sampleRate) / (last - first)
+}
+
+func TestMixerPanning(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 2}
+	src := audio.Config{SampleRate: 48000, Channels: 1}
+	m := NewMixer(c)
+
+	// A source two units to the right, and a quieter one four units to the
+	// left.
+	right := DefaultEmitter
+	right.Position = Vec3{2, 0, 0}
+	m.Add(gen.NewSine(src, 440, 1, time.Second), src, right)
+	left := DefaultEmitter
+	left.Position = Vec3{-4, 0, 0}
+	m.Add(gen.NewSine(src, 440, 1, time.Second), src, left)
+
+	out := make(audio.F64Samples, 4800*2)
+	if n, err := m.Read(out); n != len(out) || err != nil {
+		t.Fatalf("Read = %d, %v", n, err)
+	}
+	if l, r := peak(out, 0, 2, 0), peak(out, 1, 2, 0); !near3(l, 0.25) || !near3(r, 0.5) {
+		t.Fatalf("peaks %v, %v; want 0.25, 0.5", l, r)
+	}
+
+	// Turning the listener around swaps the channels.
+	m.SetListener(Listener{Forward: Vec3{0, -1, 0}})
+	m.Read(out) // ramps to the new gains
+	m.Read(out)
+	if l, r := peak(out, 0, 2, 0), peak(out, 1, 2, 0); !near3(l, 0.5) || !near3(r, 0.25) {
+		t.Fatalf("turned around, peaks %v, %v; want 0.5, 0.25", l, r)
+	}
+}
+
+func near3(a, b float64) bool {
+	return math.Abs(a-b) < 1e-3
+}
+
+func TestMixerDoppler(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 1}
+	src := audio.Config{SampleRate: 24000, Channels: 1}
+	for _, v := range []float64{0, -30, 30} {
+		m := NewMixer(c)
+		e := DefaultEmitter
+		e.Position = Vec3{0, 100, 0}
+		e.Velocity = Vec3{0, v, 0}
+		m.Add(gen.NewSine(src, 1000, 1, 0), src, e)
+		out := make(audio.F64Samples, 48000)
+		m.Read(out)
+		want := 1000 * SpeedOfSound / (SpeedOfSound + v)
+		if got := frequency(out, 0, 1, c.SampleRate); math.Abs(got-want) > 0.5 {
+			t.Errorf("velocity %v: frequency %v, want %v", v, got, want)
+		}
+	}
+}
+
+func TestMixerDopplerClamp(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 1}
+	for _, tst := range []struct {
+		v, factor float64
+	}{
+		{-340, 1}, // Approaching at almost the speed of sound.
+		{-200, 3}, // Exaggerated.
+	} {
+		m := NewMixer(c)
+		m.SetDoppler(SpeedOfSound, tst.factor)
+		e := DefaultEmitter
+		e.Position = Vec3{0, 100, 0}
+		e.Velocity = Vec3{0, tst.v, 0}
+		m.Add(gen.NewSine(c, 1000, 1, 0), c, e)
+		out := make(audio.F64Samples, 48000)
+		m.Read(out)
+		want := 1000.0 * maxDoppler
+		if got := frequency(out, 0, 1, c.SampleRate); math.Abs(got-want) > 0.5 {
+			t.Errorf("velocity %v factor %v: frequency %v, want %v", tst.v, tst.factor, got, want)
+		}
+	}
+}
+
+func TestMixerDopplerHighRate(t *testing.T) {
+	// A source at 16 times the output rate must still be Doppler shifted up.
+	c := audio.Config{SampleRate: 8000, Channels: 1}
+	src := audio.Config{SampleRate: 16 * 8000, Channels: 1}
+	m := NewMixer(c)
+	e := DefaultEmitter
+	e.Position = Vec3{0, 100, 0}
+	e.Velocity = Vec3{0, -30, 0}
+	m.Add(gen.NewSine(src, 500, 1, 0), src, e)
+	out := make(audio.F64Samples, 8000)
+	m.Read(out)
+	want := 500 * SpeedOfSound / (SpeedOfSound - 30)
+	if got := frequency(out, 0, 1, c.SampleRate); math.Abs(got-want) > 0.5 {
+		t.Errorf("frequency %v, want %v", got, want)
+	}
+}
+
+func TestMixerSourceEnd(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 2}
+	src := audio.Config{SampleRate: 48000, Channels: 1}
+	m := NewMixer(c)
+	a := m.Add(gen.NewSine(src, 440, 1, 10*time.Millisecond), src, DefaultEmitter)
+	b := m.Add(gen.NewSine(src, 440, 1, 0), src, DefaultEmitter)
+	if m.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", m.Len())
+	}
+	out := make(audio.F64Samples, 1000*2)
+	m.Read(out)
+	if !a.Done() || a.Err() != nil || b.Done() || m.Len() != 1 {
+		t.Fatalf("after EOS: a.Done() = %v, b.Done() = %v, Len() = %d", a.Done(), b.Done(), m.Len())
+	}
+	b.Remove()
+	if !b.Done() || m.Len() != 0 {
+		t.Fatalf("after Remove: b.Done() = %v, Len() = %d", b.Done(), m.Len())
+	}
+	m.Read(out)
+	if p := peak(out, 0, 2, 0); p != 0 {
+		t.Fatalf("peak %v with no sources", p)
+	}
+}
+
+// testPanner records the voices it creates.
+type testPanner struct {
+	voices []*testVoice
+}
+
+func (p *testPanner) NewVoice(c audio.Config) Voice {
+	v := &testVoice{}
+	p.voices = append(p.voices, v)
+	return v
+}
+
+// testVoice records the last direction and gain it was mixed with, and mixes
+// the source into every channel.
+type testVoice struct {
+	dir  Vec3
+	gain float64
+}
+
+func (v *testVoice) Mix(out audio.Slice, in audio.F64Samples, dir Vec3, gain float64) {
+	v.dir, v.gain = dir, gain
+	channels := out.Len() / len(in)
+	for i := 0; i < out.Len(); i++ {
+		out.Set(i, out.At(i)+in[i/channels])
+	}
+}
+
+func TestMixerPanner(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 2}
+	src := audio.Config{SampleRate: 48000, Channels: 1}
+	m := NewMixer(c)
+	e := DefaultEmitter
+	e.Position = Vec3{0, 4, 0}
+	m.Add(gen.NewSine(src, 440, 1, 0), src, e)
+
+	p := &testPanner{}
+	m.SetPanner(p)
+	out := make(audio.F64Samples, 1000*2)
+	m.Read(out)
+	if len(p.voices) != 1 {
+		t.Fatalf("%d voices created, want 1", len(p.voices))
+	}
+	if v := p.voices[0]; v.dir.Sub(Vec3{0, 4, 0}).Len() > 1e-9 || math.Abs(v.gain-0.25) > 1e-9 {
+		t.Errorf("voice mixed at %v with gain %v, want %v and 0.25", v.dir, v.gain, Vec3{0, 4, 0})
+	}
+	if got := peak(out, 1, 2, 0); math.Abs(got-1) > 0.01 {
+		t.Errorf("peak %v, want 1", got)
+	}
+
+	// Changing the panner again replaces the voice.
+	m.SetPanner(p)
+	m.Read(out)
+	if len(p.voices) != 2 {
+		t.Fatalf("%d voices created after SetPanner, want 2", len(p.voices))
+	}
+}
+
+func TestMixerLayout(t *testing.T) {
+	c := audio.Config{SampleRate: 48000, Channels: 2}
+	m := NewMixer(c)
+	if l := m.Layout(); len(l) != 2 {
+		t.Fatalf("default Layout has %d speakers, want 2", len(l))
+	}
+	l := Layout{{Azimuth: 0.5}, {Azimuth: -0.5}}
+	m.SetLayout(l)
+	if got := m.Layout(); len(got) != 2 || got[0] != l[0] {
+		t.Fatalf("Layout got %v, want %v", got, l)
+	}
+	if _, ok := m.Panner().(Layout); !ok {
+		t.Fatalf("Panner got %T, want Layout", m.Panner())
+	}
+	m.SetPanner(&testPanner{})
+	if l := m.Layout(); l != nil {
+		t.Fatalf("Layout got %v with a non-layout panner, want nil", l)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("no panic for a layout with the wrong number of speakers")
+		}
+	}()
+	m.SetLayout(Layout{{}})
+}
@@ -0,0 +1,186 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package spatial
+
+import (
+	"math"
+	"sort"
+)
+
+// Speaker describes the placement of a single speaker relative to the
+// listener.
+type Speaker struct {
+	// Azimuth is the horizontal angle of the speaker in radians,
+	// counter-clockwise from the front: positive angles are to the left and
+	// negative angles to the right.
+	Azimuth float64
+
+	// Elevation is the vertical angle of the speaker in radians, positive
+	// angles being above the listener.
+	Elevation float64
+
+	// LFE marks a low frequency effects channel, which receives no panned
+	// audio.
+	LFE bool
+}
+
+// Layout is a speaker layout, describing the speaker of each channel in
+// order.
+type Layout []Speaker
+
+// deg returns a speaker at the given azimuth in degrees.
+func deg(azimuth float64) Speaker {
+	return Speaker{Azimuth: azimuth * math.Pi / 180}
+}
+
+// DefaultLayout returns the usual speaker layout for the given number of
+// channels (in the usual WAVE channel order):
+//
+//	1: mono (C)
+//	2: stereo (L, R at 30 degrees)
+//	3: L, R, C
+//	4: quadraphonic (FL, FR, RL, RR at 45 and 135 degrees)
+//	5: L, R, C, Ls, Rs (surrounds at 110 degrees)
+//	6: 5.1 (L, R, C, LFE, Ls, Rs)
+//	8: 7.1 (L, R, C, LFE, Lb, Rb at 150 degrees, Ls, Rs at 90 degrees)
+//
+// Any other number of channels are evenly spaced around the listener,
+// counter-clockwise from the front.
+func DefaultLayout(channels int) Layout {
+	lfe := Speaker{LFE: true}
+	switch channels {
+	case 1:
+		return Layout{deg(0)}
+	case 2:
+		return Layout{deg(30), deg(-30)}
+	case 3:
+		return Layout{deg(30), deg(-30), deg(0)}
+	case 4:
+		return Layout{deg(45), deg(-45), deg(135), deg(-135)}
+	case 5:
+		return Layout{deg(30), deg(-30), deg(0), deg(110), deg(-110)}
+	case 6:
+		return Layout{deg(30), deg(-30), deg(0), lfe, deg(110), deg(-110)}
+	case 8:
+		return Layout{deg(30), deg(-30), deg(0), lfe, deg(150), deg(-150), deg(90), deg(-90)}
+	}
+	l := make(Layout, channels)
+	for i := range l {
+		l[i] = deg(360 * float64(i) / float64(channels))
+	}
+	return l
+}
+
+// wrapAngle wraps an angle in radians to the range of 0 to 2*Pi.
+func wrapAngle(a float64) float64 {
+	a = math.Mod(a, 2*math.Pi)
+	if a < 0 {
+		a += 2 * math.Pi
+	}
+	return a
+}
+
+// panSpeaker is a speaker considered for panning.
+type panSpeaker struct {
+	index   int
+	azimuth float64
+}
+
+// byAzimuth sorts speakers by their azimuth.
+type byAzimuth []panSpeaker
+
+func (p byAzimuth) Len() int           { return len(p) }
+func (p byAzimuth) Less(i, j int) bool { return p[i].azimuth < p[j].azimuth }
+func (p byAzimuth) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+// Pan computes the gain of each speaker (stored in gains, which must have the
+// same length as the layout) for a sound arriving from the given direction,
+// in the listener's frame of reference (see Listener.Local).
+//
+// Sounds are panned between the pair of adjacent speakers surrounding their
+// azimuth using the constant-power (sine/cosine) law, such that the sum of
+// the squared gains is always one. Where the speakers do not surround the
+// listener (e.g. stereo) sounds from behind are mirrored to the front. Sounds
+// from above or below are spread evenly across all speakers, in proportion to
+// their elevation.
+func (l Layout) Pan(dir Vec3, gains []float64) {
+	var sp []panSpeaker
+	for i, s := range l {
+		gains[i] = 0
+		if !s.LFE {
+			sp = append(sp, panSpeaker{i, wrapAngle(s.Azimuth)})
+		}
+	}
+	switch len(sp) {
+	case 0:
+		return
+	case 1:
+		gains[sp[0].index] = 1
+		return
+	}
+	sort.Sort(byAzimuth(sp))
+
+	// The horizontal portion of the direction's power is panned, the rest is
+	// spread evenly.
+	h := 1.0
+	if n := dir.Len(); n > 0 {
+		h = math.Min(1, (dir.X*dir.X+dir.Y*dir.Y)/(n*n))
+	}
+	spread := (1 - h) / float64(len(sp))
+	az := 0.0
+	if h > 0 {
+		// Azimuth is counter-clockwise from the front (+Y), towards the
+		// left (-X).
+		az = wrapAngle(math.Atan2(-dir.X, dir.Y))
+	}
+
+	// Find the largest gap between adjacent speakers.
+	gap, gapStart := 0.0, 0
+	for i := range sp {
+		next := sp[(i+1)%len(sp)].azimuth
+		if w := wrapAngle(next - sp[i].azimuth); w > gap {
+			gap, gapStart = w, i
+		}
+	}
+	inGap := func(a float64) bool {
+		return wrapAngle(a-sp[gapStart].azimuth) < gap
+	}
+	if gap > math.Pi && inGap(az) {
+		// The speakers don't surround the listener; mirror the direction
+		// across the axis perpendicular to the gap's center.
+		center := sp[gapStart].azimuth + gap/2
+		az = wrapAngle(2*center + math.Pi - az)
+	}
+
+	// Find the pair of speakers surrounding the azimuth.
+	a, b := sp[len(sp)-1], sp[0]
+	for i := range sp {
+		next := sp[(i+1)%len(sp)]
+		if wrapAngle(az-sp[i].azimuth) <= wrapAngle(next.azimuth-sp[i].azimuth) {
+			a, b = sp[i], next
+			break
+		}
+	}
+	width := wrapAngle(b.azimuth - a.azimuth)
+	t := 0.0
+	switch {
+	case gap > math.Pi && a.index == sp[gapStart].index:
+		// Still within the gap (the direction is beyond the outermost
+		// speakers); use the nearest speaker.
+		if wrapAngle(az-a.azimuth) > width/2 {
+			t = 1
+		}
+	case width > 0:
+		t = wrapAngle(az-a.azimuth) / width
+	}
+	for _, s := range sp {
+		gains[s.index] = spread
+	}
+	gains[a.index] += h * math.Pow(math.Cos(t*math.Pi/2), 2)
+	gains[b.index] += h * math.Pow(math.Sin(t*math.Pi/2), 2)
+	for _, s := range sp {
+		gains[s.index] = math.Sqrt(gains[s.index])
+	}
+}
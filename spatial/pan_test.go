@@ -0,0 +1,101 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package spatial
+
+import (
+	"math"
+	"testing"
+)
+
+// direction returns the unit direction of the given azimuth and elevation in
+// degrees.
+func direction(azimuth, elevation float64) Vec3 {
+	a, e := azimuth*math.Pi/180, elevation*math.Pi/180
+	return Vec3{-math.Sin(a) * math.Cos(e), math.Cos(a) * math.Cos(e), math.Sin(e)}
+}
+
+func TestPanConstantPower(t *testing.T) {
+	for _, channels := range []int{1, 2, 3, 4, 5, 6, 7, 8, 12} {
+		l := DefaultLayout(channels)
+		gains := make([]float64, channels)
+		for az := -180.0; az < 180; az += 7.5 {
+			for _, el := range []float64{0, 30, 90, -60} {
+				l.Pan(direction(az, el), gains)
+				var power float64
+				for _, g := range gains {
+					power += g * g
+				}
+				if !near(power, 1) {
+					t.Fatalf("%d channels, azimuth %v, elevation %v: power %v", channels, az, el, power)
+				}
+			}
+		}
+	}
+}
+
+func TestPanStereo(t *testing.T) {
+	l := DefaultLayout(2)
+	gains := make([]float64, 2)
+	h := math.Sqrt(0.5)
+	for _, tst := range []struct {
+		azimuth, left, right float64
+	}{
+		{0, h, h},
+		{30, 1, 0},
+		{90, 1, 0},
+		{-90, 0, 1},
+		{-30, 0, 1},
+		{180, h, h},
+		{150, 1, 0}, // mirrored to the front
+		{15, math.Cos(math.Pi / 8), math.Sin(math.Pi / 8)},
+	} {
+		l.Pan(direction(tst.azimuth, 0), gains)
+		if !near(gains[0], tst.left) || !near(gains[1], tst.right) {
+			t.Errorf("azimuth %v: gains %v, want [%v %v]", tst.azimuth, gains, tst.left, tst.right)
+		}
+	}
+}
+
+func TestPanSurround(t *testing.T) {
+	l := DefaultLayout(6)
+	gains := make([]float64, 6)
+
+	// Directly at each speaker, only that speaker is used.
+	for ch, az := range []float64{30, -30, 0, 0, 110, -110} {
+		if ch == 3 {
+			continue
+		}
+		l.Pan(direction(az, 0), gains)
+		for i, g := range gains {
+			want := 0.0
+			if i == ch {
+				want = 1
+			}
+			if !near(g, want) {
+				t.Errorf("azimuth %v: gains %v", az, gains)
+				break
+			}
+		}
+	}
+
+	// Directly behind, between the surrounds.
+	l.Pan(direction(180, 0), gains)
+	if h := math.Sqrt(0.5); !near(gains[4], h) || !near(gains[5], h) || gains[3] != 0 {
+		t.Errorf("behind: gains %v", gains)
+	}
+
+	// Directly above, spread evenly (but not to the LFE channel).
+	l.Pan(Vec3{0, 0, 1}, gains)
+	for i, g := range gains {
+		want := math.Sqrt(0.2)
+		if i == 3 {
+			want = 0
+		}
+		if !near(g, want) {
+			t.Errorf("above: gains %v", gains)
+			break
+		}
+	}
+}
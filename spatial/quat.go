@@ -0,0 +1,69 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package spatial
+
+import "math"
+
+// Quat is a quaternion W + Xi + Yj + Zk. Quaternions of unit length represent
+// rotations, e.g. the orientation of a listener's head or of a camera.
+type Quat struct {
+	W, X, Y, Z float64
+}
+
+// IdentityQuat is the quaternion of no rotation.
+var IdentityQuat = Quat{W: 1}
+
+// AxisAngle returns the quaternion of a rotation about the given axis by the
+// given angle in radians, counter-clockwise when looking down the axis
+// towards the origin. E.g. a rotation about the Z (up) axis by a positive
+// angle turns to the left.
+//
+// If the axis is the zero vector, the identity quaternion is returned.
+func AxisAngle(axis Vec3, angle float64) Quat {
+	axis = axis.Normalized()
+	if axis == (Vec3{}) {
+		return IdentityQuat
+	}
+	s, c := math.Sincos(angle / 2)
+	return Quat{c, axis.X * s, axis.Y * s, axis.Z * s}
+}
+
+// Mul returns the product q * r, the rotation r followed by the rotation q.
+func (q Quat) Mul(r Quat) Quat {
+	return Quat{
+		W: q.W*r.W - q.X*r.X - q.Y*r.Y - q.Z*r.Z,
+		X: q.W*r.X + q.X*r.W + q.Y*r.Z - q.Z*r.Y,
+		Y: q.W*r.Y - q.X*r.Z + q.Y*r.W + q.Z*r.X,
+		Z: q.W*r.Z + q.X*r.Y - q.Y*r.X + q.Z*r.W,
+	}
+}
+
+// Conjugate returns the conjugate of q, which for a unit quaternion is the
+// inverse rotation.
+func (q Quat) Conjugate() Quat {
+	return Quat{q.W, -q.X, -q.Y, -q.Z}
+}
+
+// Len returns the length of q.
+func (q Quat) Len() float64 {
+	return math.Sqrt(q.W*q.W + q.X*q.X + q.Y*q.Y + q.Z*q.Z)
+}
+
+// Normalized returns q scaled to unit length, or the identity quaternion if q
+// is the zero quaternion.
+func (q Quat) Normalized() Quat {
+	l := q.Len()
+	if l == 0 {
+		return IdentityQuat
+	}
+	return Quat{q.W / l, q.X / l, q.Y / l, q.Z / l}
+}
+
+// Rotate returns the vector v rotated by the unit quaternion q.
+func (q Quat) Rotate(v Vec3) Vec3 {
+	u := Vec3{q.X, q.Y, q.Z}
+	t := u.Cross(v).Scale(2)
+	return v.Add(t.Scale(q.W)).Add(u.Cross(t))
+}
@@ -0,0 +1,53 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package spatial
+
+import (
+	"math"
+	"testing"
+)
+
+func nearVec(a, b Vec3) bool {
+	return near(a.X, b.X) && near(a.Y, b.Y) && near(a.Z, b.Z)
+}
+
+func TestQuatRotate(t *testing.T) {
+	up := Vec3{Z: 1}
+	for _, tst := range []struct {
+		q       Quat
+		v, want Vec3
+	}{
+		{IdentityQuat, Vec3{1, 2, 3}, Vec3{1, 2, 3}},
+		{Quat{}, Vec3{1, 2, 3}, Vec3{1, 2, 3}},
+		// Turning left moves forward to the left.
+		{AxisAngle(up, math.Pi/2), Vec3{Y: 1}, Vec3{X: -1}},
+		{AxisAngle(up, -math.Pi/2), Vec3{Y: 1}, Vec3{X: 1}},
+		{AxisAngle(Vec3{X: 1}, math.Pi/2), Vec3{Y: 1}, Vec3{Z: 1}},
+		{AxisAngle(up, math.Pi), Vec3{1, 1, 1}, Vec3{-1, -1, 1}},
+		{AxisAngle(Vec3{}, 1), Vec3{1, 2, 3}, Vec3{1, 2, 3}},
+	} {
+		got := tst.q.Normalized().Rotate(tst.v)
+		if !nearVec(got, tst.want) {
+			t.Errorf("%v.Rotate(%v) = %v, want %v", tst.q, tst.v, got, tst.want)
+		}
+	}
+}
+
+func TestQuatMul(t *testing.T) {
+	a := AxisAngle(Vec3{Z: 1}, 0.7)
+	b := AxisAngle(Vec3{1, 2, 3}, -1.3)
+	v := Vec3{0.3, -0.5, 0.8}
+	if got, want := a.Mul(b).Rotate(v), a.Rotate(b.Rotate(v)); !nearVec(got, want) {
+		t.Errorf("a.Mul(b).Rotate(v) = %v, want %v", got, want)
+	}
+	if got := a.Conjugate().Rotate(a.Rotate(v)); !nearVec(got, v) {
+		t.Errorf("inverse rotation = %v, want %v", got, v)
+	}
+	// Direction agrees with the angles of a rotation about the up axis.
+	d := Direction(0.4, 0)
+	if got := AxisAngle(Vec3{Z: 1}, 0.4).Rotate(Vec3{Y: 1}); !nearVec(got, d) {
+		t.Errorf("rotated forward = %v, want %v", got, d)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package spatial
+
+import "math"
+
+// Vec3 is a three-dimensional vector.
+type Vec3 struct {
+	X, Y, Z float64
+}
+
+// Add returns a + b.
+func (a Vec3) Add(b Vec3) Vec3 {
+	return Vec3{a.X + b.X, a.Y + b.Y, a.Z + b.Z}
+}
+
+// Sub returns a - b.
+func (a Vec3) Sub(b Vec3) Vec3 {
+	return Vec3{a.X - b.X, a.Y - b.Y, a.Z - b.Z}
+}
+
+// Scale returns a scaled by s.
+func (a Vec3) Scale(s float64) Vec3 {
+	return Vec3{a.X * s, a.Y * s, a.Z * s}
+}
+
+// Dot returns the dot product of a and b.
+func (a Vec3) Dot(b Vec3) float64 {
+	return a.X*b.X + a.Y*b.Y + a.Z*b.Z
+}
+
+// Cross returns the cross product of a and b.
+func (a Vec3) Cross(b Vec3) Vec3 {
+	return Vec3{
+		a.Y*b.Z - a.Z*b.Y,
+		a.Z*b.X - a.X*b.Z,
+		a.X*b.Y - a.Y*b.X,
+	}
+}
+
+// Len returns the length of a.
+func (a Vec3) Len() float64 {
+	return math.Sqrt(a.Dot(a))
+}
+
+// Normalized returns a scaled to unit length, or the zero vector if a is the
+// zero vector.
+func (a Vec3) Normalized() Vec3 {
+	l := a.Len()
+	if l == 0 {
+		return Vec3{}
+	}
+	return a.Scale(1 / l)
+}
+
+// Direction returns the unit vector of the direction with the given azimuth
+// and elevation in radians, in the listener's frame of reference (see
+// Speaker for the meaning of the angles).
+func Direction(azimuth, elevation float64) Vec3 {
+	sa, ca := math.Sincos(azimuth)
+	se, ce := math.Sincos(elevation)
+	return Vec3{X: -sa * ce, Y: ca * ce, Z: se}
+}
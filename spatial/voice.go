@@ -0,0 +1,75 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package spatial
+
+import "azul3d.org/audio.v1"
+
+// Panner spatializes the sources of a Mixer into its output channels. A
+// Layout is the default Panner, panning each source between a set of
+// speakers; other panners may instead e.g. render binaural audio for
+// headphones.
+type Panner interface {
+	// NewVoice returns a new voice, which spatializes a single source into a
+	// stream with the given audio configuration.
+	NewVoice(c audio.Config) Voice
+}
+
+// Voice spatializes a single source of a Mixer. Voices are only used by the
+// goroutine reading the mixer.
+type Voice interface {
+	// Mix mixes the mono samples in into the interleaved samples of out (one
+	// frame per sample of in), as a source arriving from the direction dir in
+	// the listener's frame of reference (see Listener.Local) at the given
+	// linear gain.
+	//
+	// The direction and gain are those at the end of the block; voices should
+	// interpolate from those of the previous block to avoid clicks. Mixers
+	// mix blocks of at most BlockSize frames.
+	Mix(out audio.Slice, in audio.F64Samples, dir Vec3, gain float64)
+}
+
+// layoutVoice pans a single source between the speakers of a layout.
+type layoutVoice struct {
+	layout        Layout
+	gains, target []float64
+	started       bool
+}
+
+func (v *layoutVoice) Mix(out audio.Slice, in audio.F64Samples, dir Vec3, gain float64) {
+	channels := len(v.layout)
+	v.layout.Pan(dir, v.target)
+	for ch := range v.target {
+		v.target[ch] *= gain
+	}
+	if !v.started {
+		// Start at the target gains, rather than ramping from silence.
+		copy(v.gains, v.target)
+		v.started = true
+	}
+	frames := len(in)
+	for f, x := range in {
+		t := float64(f+1) / float64(frames)
+		for ch := 0; ch < channels; ch++ {
+			g := v.gains[ch] + (v.target[ch]-v.gains[ch])*t
+			j := f*channels + ch
+			out.Set(j, out.At(j)+audio.F64(g*float64(x)))
+		}
+	}
+	copy(v.gains, v.target)
+}
+
+// NewVoice implements the Panner interface.
+//
+// It panics if the layout does not have one speaker per channel.
+func (l Layout) NewVoice(c audio.Config) Voice {
+	if len(l) != c.Channels {
+		panic("spatial: layout does not match the number of channels")
+	}
+	return &layoutVoice{
+		layout: l,
+		gains:  make([]float64, len(l)),
+		target: make([]float64, len(l)),
+	}
+}
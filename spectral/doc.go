@@ -0,0 +1,11 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package spectral implements frequency-domain analysis and resynthesis of
+// audio streams.
+//
+// It provides a fast Fourier transform of any size, common window functions
+// and a short-time Fourier transform (STFT) of audio streams along with its
+// inverse.
+package spectral
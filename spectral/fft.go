@@ -0,0 +1,189 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package spectral
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// FFT is a precomputed plan for performing fast Fourier transforms of a single
+// size. Power-of-two sizes use a radix-2 algorithm, other sizes are factored
+// and use a mixed-radix algorithm (sizes with large prime factors are
+// supported, but are slower).
+//
+// An FFT is not safe for concurrent use by multiple goroutines. FFTs must be
+// allocated via the NewFFT function.
+type FFT struct {
+	n        int
+	factors  []int // pairs of radix p and remaining length m
+	twiddles []complex128
+	scratch  []complex128 // used by the generic butterfly
+	tmp      []complex128 // used for in-place and real transforms
+	buf      []complex128 // used for real transforms
+}
+
+// Len returns the size of the transform.
+func (f *FFT) Len() int {
+	return f.n
+}
+
+// Transform computes the forward discrete Fourier transform of src, storing
+// the result in dst. Both slices must be exactly Len() in length; they may be
+// the same slice.
+func (f *FFT) Transform(dst, src []complex128) {
+	if len(dst) != f.n || len(src) != f.n {
+		panic("spectral: FFT slice length mismatch")
+	}
+	if &dst[0] == &src[0] {
+		copy(f.tmp, src)
+		src = f.tmp
+	}
+	f.work(dst, src, 1, f.factors)
+}
+
+// Inverse computes the inverse discrete Fourier transform of src, storing the
+// result in dst. The result is scaled by 1/Len() such that the inverse of the
+// forward transform is the original input. Both slices must be exactly Len()
+// in length; they may be the same slice.
+func (f *FFT) Inverse(dst, src []complex128) {
+	if len(dst) != f.n || len(src) != f.n {
+		panic("spectral: FFT slice length mismatch")
+	}
+	// ifft(x) = conj(fft(conj(x))) / n
+	for i, v := range src {
+		f.tmp[i] = cmplx.Conj(v)
+	}
+	f.work(dst, f.tmp, 1, f.factors)
+	scale := 1 / float64(f.n)
+	for i, v := range dst {
+		dst[i] = complex(real(v)*scale, -imag(v)*scale)
+	}
+}
+
+// TransformReal computes the forward discrete Fourier transform of the real
+// signal src, which must be exactly Len() in length. As the spectrum of a real
+// signal is conjugate symmetric, only the Len()/2 + 1 non-negative frequency
+// bins are stored in dst.
+func (f *FFT) TransformReal(dst []complex128, src []float64) {
+	if len(src) != f.n || len(dst) < f.n/2+1 {
+		panic("spectral: FFT slice length mismatch")
+	}
+	for i, v := range src {
+		f.buf[i] = complex(v, 0)
+	}
+	f.work(f.tmp, f.buf, 1, f.factors)
+	copy(dst, f.tmp[:f.n/2+1])
+}
+
+// InverseReal computes the inverse discrete Fourier transform of the
+// Len()/2 + 1 non-negative frequency bins in src, assuming a conjugate
+// symmetric spectrum, and stores the real signal into dst, which must be
+// exactly Len() in length.
+func (f *FFT) InverseReal(dst []float64, src []complex128) {
+	if len(dst) != f.n || len(src) < f.n/2+1 {
+		panic("spectral: FFT slice length mismatch")
+	}
+	copy(f.buf, src[:f.n/2+1])
+	for i := f.n/2 + 1; i < f.n; i++ {
+		f.buf[i] = cmplx.Conj(src[f.n-i])
+	}
+	f.Inverse(f.buf, f.buf)
+	for i, v := range f.buf {
+		dst[i] = real(v)
+	}
+}
+
+// work performs the recursive decimation-in-time transform of in (read with
+// the given stride) into out.
+func (f *FFT) work(out, in []complex128, stride int, factors []int) {
+	p, m := factors[0], factors[1]
+	if m == 1 {
+		for i := 0; i < p; i++ {
+			out[i] = in[i*stride]
+		}
+	} else {
+		for i := 0; i < p; i++ {
+			f.work(out[i*m:], in[i*stride:], stride*p, factors[2:])
+		}
+	}
+	if p == 2 {
+		f.butterfly2(out, stride, m)
+	} else {
+		f.butterflyGeneric(out, stride, p, m)
+	}
+}
+
+func (f *FFT) butterfly2(out []complex128, stride, m int) {
+	for i := 0; i < m; i++ {
+		t := out[m+i] * f.twiddles[i*stride]
+		out[m+i] = out[i] - t
+		out[i] += t
+	}
+}
+
+func (f *FFT) butterflyGeneric(out []complex128, stride, p, m int) {
+	scratch := f.scratch[:p]
+	for u := 0; u < m; u++ {
+		for q, k := 0, u; q < p; q, k = q+1, k+m {
+			scratch[q] = out[k]
+		}
+		for q1, k := 0, u; q1 < p; q1, k = q1+1, k+m {
+			tw := 0
+			out[k] = scratch[0]
+			for q := 1; q < p; q++ {
+				tw += stride * k
+				if tw >= f.n {
+					tw %= f.n
+				}
+				out[k] += scratch[q] * f.twiddles[tw]
+			}
+		}
+	}
+}
+
+// NewFFT returns a new FFT plan for transforms of size n.
+//
+// It panics if n is less than one.
+func NewFFT(n int) *FFT {
+	if n < 1 {
+		panic("spectral: invalid FFT size")
+	}
+	f := &FFT{
+		n:        n,
+		twiddles: make([]complex128, n),
+		tmp:      make([]complex128, n),
+		buf:      make([]complex128, n),
+	}
+	for i := range f.twiddles {
+		phase := -2 * math.Pi * float64(i) / float64(n)
+		f.twiddles[i] = complex(math.Cos(phase), math.Sin(phase))
+	}
+
+	// Factor n, preferring radix 2.
+	maxP := 1
+	for m, p := n, 2; m > 1; {
+		for m%p != 0 {
+			if p == 2 {
+				p = 3
+			} else {
+				p += 2
+			}
+			if p*p > m {
+				p = m
+			}
+		}
+		m /= p
+		f.factors = append(f.factors, p, m)
+		if p > maxP {
+			maxP = p
+		}
+	}
+	if n == 1 {
+		f.factors = []int{1, 1}
+	}
+	f.scratch = make([]complex128, maxP)
+	return f
+}
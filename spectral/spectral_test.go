@@ -0,0 +1,191 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package spectral
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+
+	"azul3d.org/audio.v1"
+)
+
+// dft is a naive discrete Fourier transform, for reference.
+func dft(x []complex128) []complex128 {
+	n := len(x)
+	y := make([]complex128, n)
+	for k := range y {
+		for t, v := range x {
+			phase := -2 * math.Pi * float64(k*t) / float64(n)
+			y[k] += v * cmplx.Rect(1, phase)
+		}
+	}
+	return y
+}
+
+func TestFFT(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	sizes := []int{1, 2, 3, 4, 5, 6, 7, 8, 12, 15, 16, 30, 64, 97, 100, 210, 256}
+	for _, n := range sizes {
+		x := make([]complex128, n)
+		for i := range x {
+			x[i] = complex(rnd.Float64()*2-1, rnd.Float64()*2-1)
+		}
+		want := dft(x)
+		f := NewFFT(n)
+		got := make([]complex128, n)
+		f.Transform(got, x)
+		for i := range want {
+			if cmplx.Abs(got[i]-want[i]) > 1e-9*float64(n) {
+				t.Fatalf("n=%d: bin %d got %v, want %v", n, i, got[i], want[i])
+			}
+		}
+
+		// In-place inverse must restore the input.
+		f.Inverse(got, got)
+		for i := range x {
+			if cmplx.Abs(got[i]-x[i]) > 1e-9 {
+				t.Fatalf("n=%d: inverse sample %d got %v, want %v", n, i, got[i], x[i])
+			}
+		}
+	}
+}
+
+func TestFFTReal(t *testing.T) {
+	for _, n := range []int{8, 9, 60} {
+		x := make([]float64, n)
+		for i := range x {
+			x[i] = math.Sin(float64(i)*0.7) + 0.25
+		}
+		f := NewFFT(n)
+		spec := make([]complex128, n/2+1)
+		f.TransformReal(spec, x)
+		y := make([]float64, n)
+		f.InverseReal(y, spec)
+		for i := range x {
+			if math.Abs(x[i]-y[i]) > 1e-12 {
+				t.Fatalf("n=%d: sample %d got %v, want %v", n, i, y[i], x[i])
+			}
+		}
+	}
+}
+
+func TestWindows(t *testing.T) {
+	for name, w := range map[string][]float64{
+		"Hann":           Hann(64),
+		"Hamming":        Hamming(64),
+		"BlackmanHarris": BlackmanHarris(64),
+		"Kaiser":         Kaiser(64, 8.6),
+	} {
+		// Periodic windows peak at the center.
+		if math.Abs(w[32]-1) > 1e-3 {
+			t.Errorf("%s: center got %v, want 1", name, w[32])
+		}
+		for i := 1; i < 32; i++ {
+			if math.Abs(w[32-i]-w[32+i]) > 1e-12 {
+				t.Errorf("%s: not symmetric at %d", name, i)
+				break
+			}
+		}
+	}
+}
+
+func TestSTFTPeak(t *testing.T) {
+	c := audio.Config{SampleRate: 8000, Channels: 1}
+	const size = 256
+	s := make(audio.F64Samples, 4096)
+	for i := range s {
+		s[i] = audio.F64(math.Sin(2 * math.Pi * 1000 * float64(i) / 8000))
+	}
+	stft := NewSTFT(audio.NewBuffer(s), c, size, size/4, Hann(size))
+	f, err := stft.Next()
+	for i := 0; i < 8 && err == nil; i++ {
+		f, err = stft.Next()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var best int
+	for bin, m := range f.Magnitude[0] {
+		if m > f.Magnitude[0][best] {
+			best = bin
+		}
+	}
+	if freq := BinFrequency(best, size, c.SampleRate); freq != 1000 {
+		t.Fatalf("peak at %v Hz, want 1000 Hz", freq)
+	}
+}
+
+func TestSTFTResynthesis(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	c := audio.Config{SampleRate: 44100, Channels: 2}
+	for _, hop := range []int{128, 100, 256} {
+		const size = 512
+		src := make(audio.F64Samples, 2*5001)
+		for i := range src {
+			src[i] = audio.F64(rnd.Float64()*2 - 1)
+		}
+		stft := NewSTFT(audio.NewBuffer(append(audio.F64Samples(nil), src...)), c, size, hop, Hann(size))
+		istft := NewISTFT(c, size, hop, Hann(size))
+		for {
+			f, err := stft.Next()
+			if err == audio.EOS {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			istft.Add(f)
+		}
+		istft.Flush()
+
+		out := audio.NewBuffer(audio.F64Samples{})
+		if _, err := out.ReadFrom(istft); err != nil {
+			t.Fatal(err)
+		}
+		if out.Len() != len(src) {
+			t.Fatalf("hop=%d: resynthesized %d samples, want %d", hop, out.Len(), len(src))
+		}
+		got := out.Samples()
+		for i := range src {
+			if d := math.Abs(float64(got.At(i) - src[i])); d > 1e-9 {
+				t.Fatalf("hop=%d: sample %d got %v, want %v", hop, i, got.At(i), src[i])
+			}
+		}
+	}
+}
+
+func TestISTFTPosition(t *testing.T) {
+	c := audio.Config{SampleRate: 44100, Channels: 2}
+	const size, hop = 512, 128
+	stft := NewSTFT(audio.NewBuffer(make(audio.F64Samples, 2*4096)), c, size, hop, Hann(size))
+	istft := NewISTFT(c, size, hop, Hann(size))
+	if got := audio.TotalLatency(istft); got != (size-hop)*2 {
+		t.Fatalf("TotalLatency() = %d, want %d", got, (size-hop)*2)
+	}
+	buf := make(audio.F64Samples, 2*size)
+	for k := 1; ; k++ {
+		f, err := stft.Next()
+		if err == audio.EOS {
+			break
+		}
+		istft.Add(f)
+		for {
+			n, _ := istft.Read(buf)
+			if n == 0 {
+				break
+			}
+		}
+		// Everything added is read but for the latency.
+		want := k*hop*2 - istft.TotalLatency()
+		if want < 0 {
+			want = 0
+		}
+		if got, _ := audio.SourcePosition(istft); got != uint64(want) {
+			t.Fatalf("after %d frames: SourcePosition() = %d, want %d", k, got, want)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package spectral
+
+import (
+	"math"
+	"math/cmplx"
+
+	"azul3d.org/audio.v1"
+)
+
+// Frame is a single frame of a short-time Fourier transform, holding the
+// spectrum of each channel of an audio stream.
+type Frame struct {
+	// Index is the index of the frame in the stream, counting from zero.
+	Index int
+
+	// Len is the number of new input sample frames that this frame covers,
+	// which is equal to the hop size for all but the final frame of a stream.
+	Len int
+
+	// Magnitude and Phase hold the magnitude and phase (in radians) of each
+	// frequency bin of each channel, indexed as [channel][bin]. There are
+	// Size/2 + 1 bins, where bin i is centered at the frequency
+	// i * SampleRate / Size.
+	Magnitude, Phase [][]float64
+}
+
+// newFrame allocates a new frame for the given number of channels and bins.
+func newFrame(channels, bins int) *Frame {
+	f := &Frame{
+		Magnitude: make([][]float64, channels),
+		Phase:     make([][]float64, channels),
+	}
+	for ch := 0; ch < channels; ch++ {
+		f.Magnitude[ch] = make([]float64, bins)
+		f.Phase[ch] = make([]float64, bins)
+	}
+	return f
+}
+
+// validateSTFT panics if the size, hop and window of a STFT are invalid.
+func validateSTFT(c audio.Config, size, hop int, window []float64) {
+	switch {
+	case c.Channels < 1:
+		panic("spectral: invalid channel count")
+	case size < 1:
+		panic("spectral: invalid STFT size")
+	case hop < 1 || hop > size:
+		panic("spectral: invalid STFT hop size")
+	case len(window) != size:
+		panic("spectral: window length does not match STFT size")
+	}
+}
+
+// STFT is a short-time Fourier transform analyzer of an audio stream. It
+// consumes an audio.Reader and emits a frame of spectra for every hop size
+// sample frames of the stream. STFTs must be allocated via the NewSTFT
+// function.
+//
+// The first frame is aligned such that it ends with the first hop sample
+// frames of the stream (the preceding samples are treated as silence), and the
+// stream is padded with silence to complete the final frame. This ensures that
+// every sample of the stream is covered equally by overlapping windows, as is
+// required for resynthesis using an ISTFT.
+type STFT struct {
+	r         *audio.FrameReader
+	config    audio.Config
+	size, hop int
+	window    []float64
+	fft       *FFT
+
+	hist  [][]float64      // per channel, the last size samples
+	in    audio.F64Samples // hop sample frames of input
+	seg   []float64
+	spec  []complex128
+	index int
+}
+
+// Config returns the audio configuration of the analyzed stream.
+func (s *STFT) Config() audio.Config {
+	return s.config
+}
+
+// Size returns the size of each frame (i.e. the FFT size) in sample frames.
+func (s *STFT) Size() int {
+	return s.size
+}
+
+// Hop returns the hop size between frames in sample frames.
+func (s *STFT) Hop() int {
+	return s.hop
+}
+
+// Next reads the next hop size sample frames from the stream and returns the
+// next STFT frame. At the end of the stream it returns a nil frame and EOS.
+func (s *STFT) Next() (*Frame, error) {
+	c := s.config.Channels
+	want := s.hop * c
+	var got int
+	var err error
+	for got < want && err == nil {
+		var n int
+		n, err = s.r.Read(s.in[got:want])
+		got += n
+	}
+	if got == 0 {
+		return nil, err
+	}
+	if err != nil && err != audio.EOS {
+		return nil, err
+	}
+	// Pad the final frame with silence.
+	for i := got; i < want; i++ {
+		s.in[i] = 0
+	}
+
+	f := newFrame(c, s.size/2+1)
+	f.Index = s.index
+	f.Len = got / c
+	s.index++
+	for ch, h := range s.hist {
+		copy(h, h[s.hop:])
+		for i := 0; i < s.hop; i++ {
+			h[s.size-s.hop+i] = float64(s.in[i*c+ch])
+		}
+		for i, v := range h {
+			s.seg[i] = v * s.window[i]
+		}
+		s.fft.TransformReal(s.spec, s.seg)
+		for bin, v := range s.spec {
+			f.Magnitude[ch][bin] = cmplx.Abs(v)
+			f.Phase[ch][bin] = cmplx.Phase(v)
+		}
+	}
+	return f, nil
+}
+
+// NewSTFT returns a new STFT analyzer of the stream r, whose samples are laid
+// out according to the given audio configuration. Each frame holds the
+// spectrum of size sample frames, multiplied by the window (e.g. Hann(size)),
+// and consecutive frames are hop sample frames apart.
+//
+// It panics if hop is not in the range of 1 to size, or if the window length
+// does not match the size.
+func NewSTFT(r audio.Reader, c audio.Config, size, hop int, window []float64) *STFT {
+	validateSTFT(c, size, hop, window)
+	s := &STFT{
+		r:      audio.NewFrameReader(r, c),
+		config: c,
+		size:   size,
+		hop:    hop,
+		window: window,
+		fft:    NewFFT(size),
+		hist:   make([][]float64, c.Channels),
+		in:     make(audio.F64Samples, hop*c.Channels),
+		seg:    make([]float64, size),
+		spec:   make([]complex128, size/2+1),
+	}
+	for ch := range s.hist {
+		s.hist[ch] = make([]float64, size)
+	}
+	return s
+}
+
+// ISTFT is an inverse short-time Fourier transform, which resynthesizes an
+// audio stream from STFT frames using weighted overlap-add. It implements the
+// audio.Reader interface. ISTFTs must be allocated via the NewISTFT function.
+//
+// Given unmodified frames from a STFT of the same size, hop size and window,
+// the original stream is reconstructed (within floating point precision) so
+// long as the overlapping windows cover every sample with a non-zero weight
+// (e.g. a Hann window with a hop size of at most half the size).
+type ISTFT struct {
+	config    audio.Config
+	size, hop int
+	window    []float64
+	fft       *FFT
+
+	acc     [][]float64 // per channel overlap-add accumulator
+	norm    []float64   // accumulated squared window
+	spec    []complex128
+	seg     []float64
+	pos     int // absolute position of acc[0], in sample frames
+	valid   int // total number of input sample frames
+	next    int // expected index of the next frame
+	out     *audio.Buffer
+	read    uint64 // number of samples returned by Read
+	flushed bool
+}
+
+// Config returns the audio configuration of the resynthesized stream.
+func (s *ISTFT) Config() audio.Config {
+	return s.config
+}
+
+// emit writes the sample frames of the accumulator, up to n, that lie within
+// the stream to the output buffer.
+func (s *ISTFT) emit(n int) {
+	for i := 0; i < n; i++ {
+		if p := s.pos + i; p < 0 || p >= s.valid {
+			continue
+		}
+		for ch := range s.acc {
+			var v float64
+			if s.norm[i] > 1e-12 {
+				v = s.acc[ch][i] / s.norm[i]
+			}
+			s.out.WriteSample(audio.F64(v))
+		}
+	}
+}
+
+// Add adds the next frame of the stream to the resynthesis. Frames must be
+// added in order, starting at index zero.
+//
+// It panics if the frame is out of order or does not match the channel count
+// and size of the ISTFT, or if Flush has been called.
+func (s *ISTFT) Add(f *Frame) {
+	switch {
+	case s.flushed:
+		panic("spectral: ISTFT.Add called after Flush")
+	case f.Index != s.next:
+		panic("spectral: ISTFT frame out of order")
+	case len(f.Magnitude) != len(s.acc) || len(f.Phase) != len(s.acc):
+		panic("spectral: ISTFT frame channel count mismatch")
+	}
+	s.next++
+	s.valid += f.Len
+
+	for ch, acc := range s.acc {
+		mag, phase := f.Magnitude[ch], f.Phase[ch]
+		if len(mag) != len(s.spec) || len(phase) != len(s.spec) {
+			panic("spectral: ISTFT frame size mismatch")
+		}
+		for bin := range s.spec {
+			s.spec[bin] = cmplx.Rect(mag[bin], phase[bin])
+		}
+		s.fft.InverseReal(s.seg, s.spec)
+		for i, v := range s.seg {
+			acc[i] += v * s.window[i]
+		}
+	}
+	for i, w := range s.window {
+		s.norm[i] += w * w
+	}
+
+	// The first hop sample frames are now final.
+	s.emit(s.hop)
+	for _, acc := range s.acc {
+		copy(acc, acc[s.hop:])
+		for i := s.size - s.hop; i < s.size; i++ {
+			acc[i] = 0
+		}
+	}
+	copy(s.norm, s.norm[s.hop:])
+	for i := s.size - s.hop; i < s.size; i++ {
+		s.norm[i] = 0
+	}
+	s.pos += s.hop
+}
+
+// Flush signals that no more frames will be added, making the remaining
+// sample frames of the stream available for reading.
+func (s *ISTFT) Flush() {
+	if s.flushed {
+		return
+	}
+	s.flushed = true
+	s.emit(s.size - s.hop)
+}
+
+// Read reads resynthesized samples into b. If no samples are available it
+// returns zero and a nil error, or EOS once Flush has been called.
+func (s *ISTFT) Read(b audio.Slice) (n int, err error) {
+	if s.out.Len() == 0 {
+		if s.flushed && b.Len() > 0 {
+			return 0, audio.EOS
+		}
+		return 0, nil
+	}
+	n, err = s.out.Read(b)
+	s.read += uint64(n)
+	return n, err
+}
+
+// SourcePosition implements the audio.Positioner interface. The source is the
+// stream which the frames were analyzed from, so the position is simply the
+// number of samples read.
+func (s *ISTFT) SourcePosition() uint64 {
+	return s.read
+}
+
+// TotalLatency implements the audio.Positioner interface. The final size-hop
+// sample frames of each frame added are only readable once the next frame
+// (or Flush) completes their overlap-add.
+func (s *ISTFT) TotalLatency() int {
+	return (s.size - s.hop) * s.config.Channels
+}
+
+// NewISTFT returns a new inverse STFT which resynthesizes a stream of the
+// given audio configuration from frames of the given size, hop size and
+// window.
+//
+// It panics if hop is not in the range of 1 to size, or if the window length
+// does not match the size.
+func NewISTFT(c audio.Config, size, hop int, window []float64) *ISTFT {
+	validateSTFT(c, size, hop, window)
+	s := &ISTFT{
+		config: c,
+		size:   size,
+		hop:    hop,
+		window: window,
+		fft:    NewFFT(size),
+		acc:    make([][]float64, c.Channels),
+		norm:   make([]float64, size),
+		spec:   make([]complex128, size/2+1),
+		seg:    make([]float64, size),
+		pos:    hop - size,
+		out:    audio.NewBuffer(make(audio.F64Samples, 0, size*c.Channels)),
+	}
+	for ch := range s.acc {
+		s.acc[ch] = make([]float64, size)
+	}
+	return s
+}
+
+// BinFrequency returns the center frequency in Hz of the given bin of a
+// transform of the given size, for the given sample rate.
+func BinFrequency(bin, size, sampleRate int) float64 {
+	return float64(bin) * float64(sampleRate) / float64(size)
+}
+
+// Decibels converts a linear magnitude to decibels, limited to a minimum of
+// -200 dB for zero magnitudes.
+func Decibels(mag float64) float64 {
+	if mag < 1e-10 {
+		return -200
+	}
+	return 20 * math.Log10(mag)
+}
@@ -0,0 +1,78 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package spectral
+
+import "math"
+
+// The window functions below return periodic (rather than symmetric) windows
+// of length n, as is appropriate for spectral analysis and overlap-add
+// resynthesis; i.e. the window is one sample of a length n+1 symmetric window
+// short.
+
+// Rectangular returns a rectangular window (i.e. no windowing) of length n.
+func Rectangular(n int) []float64 {
+	w := make([]float64, n)
+	for i := range w {
+		w[i] = 1
+	}
+	return w
+}
+
+// Hann returns a Hann window of length n.
+func Hann(n int) []float64 {
+	return cosineSum(n, 0.5, 0.5)
+}
+
+// Hamming returns a Hamming window of length n.
+func Hamming(n int) []float64 {
+	return cosineSum(n, 0.54, 0.46)
+}
+
+// BlackmanHarris returns a 4-term Blackman-Harris window of length n, which
+// has side lobes below -92 dB.
+func BlackmanHarris(n int) []float64 {
+	return cosineSum(n, 0.35875, 0.48829, 0.14128, 0.01168)
+}
+
+// Kaiser returns a Kaiser window of length n with the shape parameter beta;
+// larger values of beta trade a wider main lobe for lower side lobes (e.g. a
+// beta of 8.6 resembles a Blackman window).
+func Kaiser(n int, beta float64) []float64 {
+	w := make([]float64, n)
+	norm := besselI0(beta)
+	for i := range w {
+		x := 2*float64(i)/float64(n) - 1
+		w[i] = besselI0(beta*math.Sqrt(1-x*x)) / norm
+	}
+	return w
+}
+
+// cosineSum returns a generalized cosine window of length n with the given
+// coefficients, whose signs alternate.
+func cosineSum(n int, a ...float64) []float64 {
+	w := make([]float64, n)
+	for i := range w {
+		sign := 1.0
+		for k, c := range a {
+			w[i] += sign * c * math.Cos(2*math.Pi*float64(k*i)/float64(n))
+			sign = -sign
+		}
+	}
+	return w
+}
+
+// besselI0 returns the zeroth-order modified Bessel function of the first kind
+// of x.
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; k < 500; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+		if term < sum*1e-16 {
+			break
+		}
+	}
+	return sum
+}
@@ -0,0 +1,266 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package stretch
+
+import (
+	"math"
+
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/spectral"
+)
+
+// algorithm is a time-stretching algorithm, which synthesizes each frame of
+// the output from the input.
+type algorithm interface {
+	// synthesize fills out (indexed [channel][sample]) with the windowed
+	// output frame, given the nominal start of the frame in the input.
+	synthesize(out [][]float64, start int64)
+
+	// lookahead returns how many frames beyond the end of a frame's nominal
+	// input segment the algorithm may read.
+	lookahead() int
+}
+
+// core is the time-stretching stage of a Stretcher. It overlap-adds frames of
+// size n, synthesized by the algorithm, at a fixed hop size in the output
+// while advancing through the input at hop*speed frames per frame.
+//
+// Frame k is centered at output frame k*hop, and at input frame center; the
+// first frame is chosen such that output frame zero is covered by a full set
+// of overlapping frames.
+type core struct {
+	r      *audio.FrameReader
+	config audio.Config
+	alg    algorithm
+	speed  func() float64
+
+	n, hop int
+	norm   []float64 // overlap-add normalization, by output frame modulo hop
+
+	// Planar input, beginning at input frame base.
+	in   [][]float64
+	base int64
+	end  int64 // total input frames, or -1 until EOS
+	buf  audio.F64Samples
+
+	center float64     // input position of the center of the next frame
+	k      int64       // index of the next frame
+	acc    [][]float64 // overlap-add accumulator, beginning at output frame k*hop - n/2
+	seg    [][]float64
+	outEnd int64 // total output frames, or -1 until known
+	out    *audio.Buffer
+	done   bool
+}
+
+// fill ensures that the input frames up to (but not including) frame i are
+// buffered, or that the input has ended.
+func (c *core) fill(i int64) error {
+	ch := c.config.Channels
+	for c.end < 0 && c.base+int64(len(c.in[0])) < i {
+		n, err := c.r.Read(c.buf)
+		for f := 0; f < n/ch; f++ {
+			for j := range c.in {
+				c.in[j] = append(c.in[j], float64(c.buf[f*ch+j]))
+			}
+		}
+		if err == audio.EOS {
+			c.end = c.base + int64(len(c.in[0]))
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// at returns input frame i of channel ch; frames outside of the stream are
+// silent.
+func (c *core) at(ch int, i int64) float64 {
+	j := i - c.base
+	if j < 0 || j >= int64(len(c.in[ch])) {
+		return 0
+	}
+	return c.in[ch][j]
+}
+
+// discard drops buffered input before input frame i.
+func (c *core) discard(i int64) {
+	drop := i - c.base
+	if drop < 4096 || drop > int64(len(c.in[0])) {
+		return
+	}
+	for ch := range c.in {
+		c.in[ch] = append(c.in[ch][:0], c.in[ch][drop:]...)
+	}
+	c.base = i
+}
+
+// emit writes the first count frames of the accumulator (which begins at
+// output frame pos) to the output buffer, skipping frames before the start or
+// after the end of the output stream.
+func (c *core) emit(pos int64, count int) {
+	for i := 0; i < count; i++ {
+		t := pos + int64(i)
+		if t < 0 || (c.outEnd >= 0 && t >= c.outEnd) {
+			continue
+		}
+		norm := c.norm[i%c.hop]
+		for ch := range c.acc {
+			c.out.WriteSample(audio.F64(c.acc[ch][i] / norm))
+		}
+	}
+}
+
+// step synthesizes and overlap-adds the next frame.
+func (c *core) step() error {
+	speed := c.speed()
+	if c.k == c.first() {
+		c.center = float64(c.k*int64(c.hop)) * speed
+	}
+	half := int64(c.n / 2)
+	start := int64(math.Floor(c.center+0.5)) - half
+	if err := c.fill(start + int64(c.n+c.alg.lookahead())); err != nil {
+		return err
+	}
+	pos := c.k*int64(c.hop) - half // output position of acc[0]
+	if c.end >= 0 {
+		if c.outEnd < 0 && c.center >= float64(c.end) {
+			// This frame is centered beyond the end of the input; the
+			// output ends here.
+			c.outEnd = c.k*int64(c.hop) - int64((c.center-float64(c.end))/speed)
+		}
+		if c.outEnd >= 0 && pos >= c.outEnd {
+			c.done = true
+			return nil
+		}
+	}
+
+	c.alg.synthesize(c.seg, start)
+	for ch, acc := range c.acc {
+		for i, v := range c.seg[ch] {
+			acc[i] += v
+		}
+	}
+
+	// The first hop frames of the accumulator are now complete.
+	c.emit(pos, c.hop)
+	for _, acc := range c.acc {
+		copy(acc, acc[c.hop:])
+		for i := c.n - c.hop; i < c.n; i++ {
+			acc[i] = 0
+		}
+	}
+	c.discard(start - int64(c.n+c.alg.lookahead()))
+	c.center += float64(c.hop) * speed
+	c.k++
+	return nil
+}
+
+// first returns the index of the first frame.
+func (c *core) first() int64 {
+	return int64(1 - c.n/(2*c.hop))
+}
+
+// latency returns the processing latency in input frames.
+func (c *core) latency() int {
+	return c.n + c.alg.lookahead()
+}
+
+// SourcePosition implements the audio.Positioner interface. The next output
+// frame is mapped to the input frame at the same position relative to the
+// center of the next frame, at the current speed.
+func (c *core) SourcePosition() uint64 {
+	if c.k == c.first() {
+		// No input has been read yet.
+		return c.r.SourcePosition()
+	}
+	out := int64(c.out.SourcePosition()) / int64(c.config.Channels)
+	in := c.center - float64(c.k*int64(c.hop)-out)*c.speed()
+	read := c.base + int64(len(c.in[0])) // input frames read
+	return c.r.SourcePositionAt(int(int64(math.Floor(in+0.5)) - read))
+}
+
+// TotalLatency implements the audio.Positioner interface. It is the latency of
+// the input stream plus that of the algorithm, converted to output samples by
+// the current speed.
+func (c *core) TotalLatency() int {
+	ch := c.config.Channels
+	frames := float64(c.r.TotalLatency()/ch+c.latency()) / c.speed()
+	return int(frames+0.5) * ch
+}
+
+// Read implements the audio.Reader interface.
+func (c *core) Read(b audio.Slice) (n int, err error) {
+	for c.out.Len() < b.Len() && !c.done {
+		if err = c.step(); err != nil {
+			return 0, err
+		}
+	}
+	if c.out.Len() == 0 && c.done {
+		return 0, audio.EOS
+	}
+	return c.out.Read(b)
+}
+
+// newCore returns a new time-stretching stage using the given algorithm,
+// whose speed is queried before each frame.
+func newCore(r audio.Reader, c audio.Config, m Mode, speed func() float64) *core {
+	co := &core{
+		r:      audio.NewFrameReader(r, c),
+		config: c,
+		speed:  speed,
+		in:     make([][]float64, c.Channels),
+		end:    -1,
+		buf:    make(audio.F64Samples, 1024*c.Channels),
+		outEnd: -1,
+	}
+	var analysis, synthesis []float64
+	switch m {
+	case WSOLA:
+		// Frames of about 20ms, overlapping by half, with a search
+		// tolerance of about 10ms.
+		co.hop = int(math.Floor(float64(c.SampleRate)*0.01 + 0.5))
+		if co.hop < 1 {
+			co.hop = 1
+		}
+		co.n = 2 * co.hop
+		analysis = spectral.Hann(co.n)
+		co.alg = newWSOLA(co, analysis, co.hop)
+	case PhaseVocoder:
+		// Frames of about 40ms, overlapping by three quarters.
+		co.n = 4
+		for float64(co.n) < float64(c.SampleRate)*0.04 {
+			co.n *= 2
+		}
+		co.hop = co.n / 4
+		analysis = spectral.Hann(co.n)
+		synthesis = analysis
+		co.alg = newVocoder(co, analysis)
+	default:
+		panic("stretch: invalid mode")
+	}
+
+	co.norm = make([]float64, co.hop)
+	for i := range co.norm {
+		for j := i; j < co.n; j += co.hop {
+			w := analysis[j]
+			if synthesis != nil {
+				w *= synthesis[j]
+			}
+			co.norm[i] += w
+		}
+		if co.norm[i] < 1e-9 {
+			co.norm[i] = 1
+		}
+	}
+	co.acc = make([][]float64, c.Channels)
+	co.seg = make([][]float64, c.Channels)
+	for ch := range co.acc {
+		co.acc[ch] = make([]float64, co.n)
+		co.seg[ch] = make([]float64, co.n)
+	}
+	co.out = audio.NewBuffer(make(audio.F64Samples, 0, co.n*c.Channels))
+	co.k = co.first()
+	return co
+}
@@ -0,0 +1,13 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package stretch implements time-stretching and pitch-shifting of audio
+// streams; i.e. changing the playback speed of a stream without changing its
+// pitch, and vice versa.
+//
+// Two algorithms are provided: WSOLA (waveform similarity overlap-add), which
+// works well for speech and other monophonic sounds, and a phase vocoder with
+// identity phase locking, which works well for music and other polyphonic
+// sounds.
+package stretch
@@ -0,0 +1,180 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package stretch
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/dsp"
+)
+
+// Mode represents a single time-stretching algorithm.
+type Mode uint8
+
+const (
+	// WSOLA stretches time by overlap-adding segments of the input which are
+	// chosen for their similarity to the previous output, preserving the
+	// waveform of periodic sounds. It is best suited to speech.
+	WSOLA Mode = iota
+
+	// PhaseVocoder stretches time in the frequency domain, keeping the phase
+	// of each frequency coherent. Identity phase locking preserves the phase
+	// relationships around spectral peaks, reducing the "phasiness" of plain
+	// phase vocoders. It is best suited to music.
+	PhaseVocoder
+)
+
+// String returns a string representation of the mode.
+func (m Mode) String() string {
+	switch m {
+	case WSOLA:
+		return "WSOLA"
+	case PhaseVocoder:
+		return "PhaseVocoder"
+	}
+	return fmt.Sprintf("Mode(%d)", uint8(m))
+}
+
+// Semitones returns the pitch ratio for a shift of the given number of
+// semitones, e.g. 12 returns 2 (an octave up).
+func Semitones(n float64) float64 {
+	return math.Pow(2, n/12)
+}
+
+const (
+	// minRatio and maxRatio are the limits of the tempo and pitch ratios.
+	minRatio = 0.125
+	maxRatio = 8
+)
+
+// Stretcher is an audio reader which changes the tempo and pitch of a stream
+// independently. Stretchers must be allocated via the NewStretcher function.
+//
+// It is safe to change the tempo and pitch from another goroutine while audio
+// is being read; changes take effect at the next frame of the algorithm.
+type Stretcher struct {
+	config audio.Config
+	mode   Mode
+	core   *core
+	rs     *dsp.Resampler
+
+	access       sync.Mutex
+	tempo, pitch float64
+}
+
+// Config returns the audio configuration of the stream.
+func (s *Stretcher) Config() audio.Config {
+	return s.config
+}
+
+// Mode returns the time-stretching algorithm in use.
+func (s *Stretcher) Mode() Mode {
+	return s.mode
+}
+
+// Tempo returns the tempo ratio.
+func (s *Stretcher) Tempo() float64 {
+	s.access.Lock()
+	defer s.access.Unlock()
+	return s.tempo
+}
+
+// SetTempo sets the tempo ratio: the playback speed relative to the original
+// stream, without changing the pitch. For example a tempo of 2 plays the
+// stream twice as fast, and 0.5 plays it at half speed.
+//
+// It panics if the ratio is not in the range of 1/8 to 8.
+func (s *Stretcher) SetTempo(ratio float64) {
+	validRatio(ratio)
+	s.access.Lock()
+	s.tempo = ratio
+	s.access.Unlock()
+}
+
+// Pitch returns the pitch ratio.
+func (s *Stretcher) Pitch() float64 {
+	s.access.Lock()
+	defer s.access.Unlock()
+	return s.pitch
+}
+
+// SetPitch sets the pitch ratio, without changing the tempo. For example a
+// pitch of 2 shifts the stream an octave up; see also the Semitones function.
+//
+// It panics if the ratio is not in the range of 1/8 to 8.
+func (s *Stretcher) SetPitch(ratio float64) {
+	validRatio(ratio)
+	s.access.Lock()
+	s.pitch = ratio
+	s.access.Unlock()
+	s.rs.SetRatio(ratio)
+}
+
+func validRatio(ratio float64) {
+	if !(ratio >= minRatio && ratio <= maxRatio) {
+		panic("stretch: ratio out of range")
+	}
+}
+
+// speed returns the speed of the time-stretching stage: pitch shifting is
+// implemented by stretching the stream by the pitch ratio and then resampling
+// it back to the original length.
+func (s *Stretcher) speed() float64 {
+	s.access.Lock()
+	defer s.access.Unlock()
+	return s.tempo / s.pitch
+}
+
+// Latency returns the processing latency in sample frames: the amount of
+// input that must be read ahead of the corresponding output when streaming in
+// real time. It includes the interpolation kernel used for pitch shifting, at
+// the current tempo and pitch.
+func (s *Stretcher) Latency() int {
+	s.access.Lock()
+	tempo := s.tempo
+	s.access.Unlock()
+	// The resampler's latency is in output frames.
+	return s.core.latency() + int(float64(s.rs.Latency())*tempo+0.5)
+}
+
+// SourcePosition implements the audio.Positioner interface. The position
+// accounts for the tempo, such that it advances at the tempo ratio relative to
+// the output.
+func (s *Stretcher) SourcePosition() uint64 {
+	return s.rs.SourcePosition()
+}
+
+// TotalLatency implements the audio.Positioner interface. Unlike Latency it
+// is in samples of the output stream, and includes the latency of the input
+// stream.
+func (s *Stretcher) TotalLatency() int {
+	return s.rs.TotalLatency()
+}
+
+// Read implements the audio.Reader interface.
+func (s *Stretcher) Read(b audio.Slice) (n int, err error) {
+	return s.rs.Read(b)
+}
+
+// NewStretcher returns a new time-stretcher, using the given algorithm, of the
+// stream r whose samples are laid out according to the given audio
+// configuration. The initial tempo and pitch ratios are 1.
+func NewStretcher(r audio.Reader, c audio.Config, m Mode) *Stretcher {
+	if c.SampleRate < 1 || c.Channels < 1 {
+		panic("stretch: invalid audio configuration")
+	}
+	s := &Stretcher{
+		config: c,
+		mode:   m,
+		tempo:  1,
+		pitch:  1,
+	}
+	s.core = newCore(r, c, m, s.speed)
+	s.rs = dsp.NewResampler(s.core, c, 1)
+	return s
+}
@@ -0,0 +1,194 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package stretch
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/gen"
+)
+
+var mono = audio.Config{SampleRate: 48000, Channels: 1}
+
+// readAll reads the entire stream r.
+func readAll(t *testing.T, r audio.Reader) []float64 {
+	var all []float64
+	buf := make(audio.F64Samples, 1000)
+	for {
+		n, err := r.Read(buf)
+		for _, v := range buf[:n] {
+			all = append(all, float64(v))
+		}
+		if err == audio.EOS {
+			return all
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// frequency estimates the frequency of the mono signal s by counting rising
+// zero crossings, ignoring the first and last tenth of it.
+func frequency(s []float64, sampleRate int) float64 {
+	var first, last, count int
+	for i := len(s) / 10; i < len(s)*9/10; i++ {
+		if s[i-1] < 0 && s[i] >= 0 {
+			if count == 0 {
+				first = i
+			}
+			last = i
+			count++
+		}
+	}
+	return float64(count-1) * float64(sampleRate) / float64(last-first)
+}
+
+// rms returns the RMS level of s, ignoring the first and last tenth of it.
+func rms(s []float64) float64 {
+	var sum float64
+	s = s[len(s)/10 : len(s)*9/10]
+	for _, v := range s {
+		sum += v * v
+	}
+	return math.Sqrt(sum / float64(len(s)))
+}
+
+func TestUnity(t *testing.T) {
+	for _, m := range []Mode{WSOLA, PhaseVocoder} {
+		in := readAll(t, gen.NewSine(mono, 440, 0.5, time.Second))
+		out := readAll(t, NewStretcher(gen.NewSine(mono, 440, 0.5, time.Second), mono, m))
+		if len(out) != len(in) {
+			t.Fatalf("%v: read %d samples, want %d", m, len(out), len(in))
+		}
+		for i := range in {
+			if math.Abs(out[i]-in[i]) > 1e-6 {
+				t.Fatalf("%v: sample %d = %v, want %v", m, i, out[i], in[i])
+			}
+		}
+	}
+}
+
+func TestTempoAndPitch(t *testing.T) {
+	tests := []struct {
+		tempo, pitch float64
+	}{
+		{2, 1},
+		{0.5, 1},
+		{1.25, 1},
+		{1, 2},
+		{1, Semitones(-5)},
+		{0.75, 1.5},
+	}
+	for _, m := range []Mode{WSOLA, PhaseVocoder} {
+		for _, tst := range tests {
+			s := NewStretcher(gen.NewSine(mono, 440, 0.5, 2*time.Second), mono, m)
+			s.SetTempo(tst.tempo)
+			s.SetPitch(tst.pitch)
+			out := readAll(t, s)
+
+			wantLen := 96000 / tst.tempo
+			if math.Abs(float64(len(out))-wantLen) > 0.01*wantLen {
+				t.Errorf("%v %+v: length %d, want %v", m, tst, len(out), wantLen)
+			}
+			wantFreq := 440 * tst.pitch
+			if got := frequency(out, 48000); math.Abs(got-wantFreq) > 0.01*wantFreq {
+				t.Errorf("%v %+v: frequency %.1f Hz, want %.1f Hz", m, tst, got, wantFreq)
+			}
+			if got := rms(out); math.Abs(got-0.5/math.Sqrt2) > 0.1 {
+				t.Errorf("%v %+v: RMS level %.3f, want %.3f", m, tst, got, 0.5/math.Sqrt2)
+			}
+		}
+	}
+}
+
+func TestRuntimeTempo(t *testing.T) {
+	for _, m := range []Mode{WSOLA, PhaseVocoder} {
+		s := NewStretcher(gen.NewSine(mono, 440, 0.5, 2*time.Second), mono, m)
+		buf := make(audio.F64Samples, 24000)
+		var total int
+		n, _ := s.Read(buf) // 0.5s at tempo 1 consumes 0.5s of input
+		total += n
+		s.SetTempo(2) // the remaining 1.5s of input take 0.75s
+		out := readAll(t, s)
+		total += len(out)
+
+		// The change takes effect after the audio which has already been
+		// processed, which is at most a couple of frames of the algorithm.
+		if want := 24000 + 36000; math.Abs(float64(total-want)) > 0.02*float64(want) {
+			t.Errorf("%v: length %d, want %d", m, total, want)
+		}
+		if got := frequency(out, 48000); math.Abs(got-440) > 4.4 {
+			t.Errorf("%v: frequency %.1f Hz, want 440 Hz", m, got)
+		}
+	}
+}
+
+func TestStereo(t *testing.T) {
+	stereo := audio.Config{SampleRate: 44100, Channels: 2}
+	for _, m := range []Mode{WSOLA, PhaseVocoder} {
+		s := NewStretcher(gen.NewSine(stereo, 1000, 0.5, time.Second), stereo, m)
+		s.SetTempo(1.5)
+		out := readAll(t, s)
+		if len(out)%2 != 0 {
+			t.Fatalf("%v: read a partial frame", m)
+		}
+		for i := 0; i < len(out); i += 2 {
+			if math.Abs(out[i]-out[i+1]) > 1e-9 {
+				t.Fatalf("%v: channels differ at frame %d", m, i/2)
+			}
+		}
+	}
+}
+
+func TestLatency(t *testing.T) {
+	for _, m := range []Mode{WSOLA, PhaseVocoder} {
+		s := NewStretcher(gen.NewSilence(mono, 0), mono, m)
+		if l := s.Latency(); l <= 0 || l > 48000/10 {
+			t.Errorf("%v: latency %d frames, want a positive value under 100ms", m, l)
+		}
+	}
+}
+
+func TestSourcePosition(t *testing.T) {
+	for _, m := range []Mode{WSOLA, PhaseVocoder} {
+		for _, tempo := range []float64{0.5, 2} {
+			s := NewStretcher(gen.NewSine(mono, 440, 0.5, 4*time.Second), mono, m)
+			s.SetTempo(tempo)
+			s.SetPitch(Semitones(3))
+			buf := make(audio.F64Samples, 1000)
+			for read := 1000; read <= 48000; read += 1000 {
+				s.Read(buf)
+				// The source advances at the tempo, within one hop.
+				want := float64(read) * tempo
+				if p := float64(s.SourcePosition()); math.Abs(p-want) > 48000*0.01*tempo {
+					t.Fatalf("%v: tempo %v: after %d frames SourcePosition got %v, want %v", m, tempo, read, p, want)
+				}
+			}
+			if l := s.TotalLatency(); l <= 0 {
+				t.Errorf("%v: tempo %v: TotalLatency got %d, want a positive value", m, tempo, l)
+			}
+		}
+	}
+}
+
+func TestLatencyIncludesResampler(t *testing.T) {
+	for _, m := range []Mode{WSOLA, PhaseVocoder} {
+		s := NewStretcher(gen.NewSilence(mono, 0), mono, m)
+		s.SetTempo(1.5)
+		s.SetPitch(Semitones(-5))
+		if l := s.Latency(); l <= s.core.latency() {
+			t.Errorf("%v: Latency %d excludes the resampler (core latency %d)", m, l, s.core.latency())
+		}
+		// TotalLatency is the same latency, in output frames.
+		want := float64(s.Latency()) / 1.5
+		if got := float64(s.TotalLatency()); math.Abs(got-want) > 2 {
+			t.Errorf("%v: TotalLatency %v, want %v", m, got, want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package stretch
+
+import (
+	"math"
+	"math/cmplx"
+
+	"azul3d.org/audio.v1/spectral"
+)
+
+// vocoder implements a phase vocoder with identity phase locking, as
+// described by Laroche and Dolson: the phase of each spectral peak advances
+// according to its instantaneous frequency, and the bins around each peak
+// keep their phase relationship to it.
+type vocoder struct {
+	c      *core
+	window []float64
+	fft    *spectral.FFT
+
+	seg        []float64
+	spec       []complex128
+	mag, phase []float64
+	prevPhase  [][]float64 // per channel analysis phase of the previous frame
+	synPhase   [][]float64 // per channel synthesis phase of the previous frame
+	peaks      []int
+	prevStart  int64
+	first      bool
+}
+
+func (v *vocoder) lookahead() int {
+	return 0
+}
+
+// wrapPhase wraps the phase p into the range of -Pi to +Pi.
+func wrapPhase(p float64) float64 {
+	return p - 2*math.Pi*math.Floor(p/(2*math.Pi)+0.5)
+}
+
+func (v *vocoder) synthesize(out [][]float64, start int64) {
+	n := len(v.window)
+	hop := float64(v.c.hop)
+	ha := float64(start - v.prevStart) // actual analysis hop
+	for ch, seg := range out {
+		for i := range v.seg {
+			v.seg[i] = v.c.at(ch, start+int64(i)) * v.window[i]
+		}
+		v.fft.TransformReal(v.spec, v.seg)
+		for bin, x := range v.spec {
+			v.mag[bin], v.phase[bin] = cmplx.Abs(x), cmplx.Phase(x)
+		}
+
+		prev, syn := v.prevPhase[ch], v.synPhase[ch]
+		if v.first {
+			copy(syn, v.phase)
+		} else {
+			// Advance the phase of each peak by its instantaneous frequency.
+			v.peaks = v.peaks[:0]
+			for bin := range v.mag {
+				if isPeak(v.mag, bin) {
+					v.peaks = append(v.peaks, bin)
+				}
+			}
+			for _, bin := range v.peaks {
+				omega := 2 * math.Pi * float64(bin) / float64(n)
+				freq := omega
+				if ha != 0 {
+					freq += wrapPhase(v.phase[bin]-prev[bin]-omega*ha) / ha
+				}
+				syn[bin] = wrapPhase(syn[bin] + freq*hop)
+			}
+			// Lock the phase of the other bins to their nearest peak.
+			p := 0
+			for bin := range v.mag {
+				if len(v.peaks) == 0 {
+					break
+				}
+				for p+1 < len(v.peaks) && v.peaks[p+1]-bin < bin-v.peaks[p] {
+					p++
+				}
+				if peak := v.peaks[p]; peak != bin {
+					syn[bin] = wrapPhase(syn[peak] + v.phase[bin] - v.phase[peak])
+				}
+			}
+		}
+		copy(prev, v.phase)
+
+		for bin := range v.spec {
+			v.spec[bin] = cmplx.Rect(v.mag[bin], syn[bin])
+		}
+		v.fft.InverseReal(seg, v.spec)
+		for i := range seg {
+			seg[i] *= v.window[i]
+		}
+	}
+	v.first = false
+	v.prevStart = start
+}
+
+// isPeak tells if the given bin is a local maximum of the magnitude spectrum,
+// within two bins either side.
+func isPeak(mag []float64, bin int) bool {
+	m := mag[bin]
+	if m == 0 {
+		return false
+	}
+	for i := bin - 2; i <= bin+2; i++ {
+		if i >= 0 && i < len(mag) && i != bin && mag[i] > m {
+			return false
+		}
+	}
+	return true
+}
+
+func newVocoder(c *core, window []float64) *vocoder {
+	n := len(window)
+	bins := n/2 + 1
+	v := &vocoder{
+		c:         c,
+		window:    window,
+		fft:       spectral.NewFFT(n),
+		seg:       make([]float64, n),
+		spec:      make([]complex128, bins),
+		mag:       make([]float64, bins),
+		phase:     make([]float64, bins),
+		prevPhase: make([][]float64, c.config.Channels),
+		synPhase:  make([][]float64, c.config.Channels),
+		first:     true,
+	}
+	for ch := range v.prevPhase {
+		v.prevPhase[ch] = make([]float64, bins)
+		v.synPhase[ch] = make([]float64, bins)
+	}
+	return v
+}
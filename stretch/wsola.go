@@ -0,0 +1,84 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package stretch
+
+import "math"
+
+// wsola implements the WSOLA algorithm: each frame is taken from the input
+// near its nominal position, at the offset (within the tolerance) which best
+// matches the natural continuation of the previous frame's input segment.
+type wsola struct {
+	c      *core
+	window []float64
+	tol    int
+	prev   int64 // input start of the previous frame
+	first  bool
+}
+
+func (w *wsola) lookahead() int {
+	return w.tol
+}
+
+// mono returns the sum of all channels of input frame i.
+func (w *wsola) mono(i int64) float64 {
+	var v float64
+	for ch := range w.c.in {
+		v += w.c.at(ch, i)
+	}
+	return v
+}
+
+// similarity returns the normalized cross-correlation between the input
+// segments beginning at a and b, over the region where consecutive frames
+// overlap.
+func (w *wsola) similarity(a, b int64) float64 {
+	var xy, xx float64
+	n := int64(w.c.n - w.c.hop)
+	for i := int64(0); i < n; i += 2 {
+		x, y := w.mono(a+i), w.mono(b+i)
+		xy += x * y
+		xx += x * x
+	}
+	if xx < 1e-12 {
+		return 0
+	}
+	return xy / math.Sqrt(xx)
+}
+
+func (w *wsola) synthesize(out [][]float64, start int64) {
+	best := start
+	if !w.first {
+		nat := w.prev + int64(w.c.hop)
+		tol := int64(w.tol)
+		found := false
+		var bestScore float64
+		if nat >= start-tol && nat <= start+tol {
+			// Prefer the natural continuation itself, such that an unchanged
+			// speed reconstructs the input exactly.
+			best, bestScore, found = nat, w.similarity(nat, nat), true
+		}
+		for p := start - tol; p <= start+tol; p++ {
+			if s := w.similarity(p, nat); !found || s > bestScore+1e-9*math.Abs(bestScore) {
+				best, bestScore, found = p, s, true
+			}
+		}
+	}
+	w.first = false
+	w.prev = best
+	for ch, seg := range out {
+		for i := range seg {
+			seg[i] = w.c.at(ch, best+int64(i)) * w.window[i]
+		}
+	}
+}
+
+func newWSOLA(c *core, window []float64, tol int) *wsola {
+	return &wsola{
+		c:      c,
+		window: window,
+		tol:    tol,
+		first:  true,
+	}
+}
@@ -0,0 +1,19 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package voice implements a voice manager, which mixes many more sounds than
+// can actually be afforded.
+//
+// A Manager mixes voices, each playing an audio.Decoder, into a single stream.
+// At most a fixed number of voices are real (decoded and mixed) at once:
+// should more be audible, the least important are stolen (faded out and
+// stopped), in order of their priority, then their gain, and then their age.
+// Voices whose gain falls below a threshold are virtualized instead: they are
+// not decoded, but their play position is still tracked, such that they can
+// resume (via Decoder.Seek) where they would have been should they become
+// audible again.
+//
+// For 3D sounds, the gain of a voice is typically the level of its emitter
+// as heard by the listener (see spatial.Emitter.Level).
+package voice
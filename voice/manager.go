@@ -0,0 +1,456 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package voice
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"azul3d.org/audio.v1"
+	"azul3d.org/audio.v1/dsp"
+)
+
+// blockSize is the maximum number of frames mixed at once; voices are
+// reassigned (made real, virtual, or stolen) once per block, and gains are
+// ramped across each block to avoid clicks.
+const blockSize = 256
+
+// DefaultThreshold is the default gain below which voices are virtualized,
+// equal to -60 dB.
+const DefaultThreshold = 0.001
+
+// Voice is a single sound playing through a Manager. Voices are created via
+// the Manager.Play method.
+type Voice struct {
+	m        *Manager
+	d        audio.Decoder
+	seq      uint64  // the order the voice was played in
+	priority int     // guarded by m.access
+	gain     float64 // guarded by m.access
+	stopping bool    // guarded by m.access
+	stolen   bool    // guarded by m.access
+	virtual  bool    // guarded by m.access
+	done     bool    // guarded by m.access
+	err      error   // guarded by m.access
+	pos      int64   // frames played, in the output sample rate; guarded by m.access
+	length   int64   // length of the stream in the output sample rate, or -1 if unknown
+
+	// Used only while mixing.
+	r   audio.Reader // nil once virtualized
+	cur float64      // the gain at the end of the last block
+}
+
+// Priority returns the priority of the voice.
+func (v *Voice) Priority() int {
+	v.m.access.Lock()
+	defer v.m.access.Unlock()
+	return v.priority
+}
+
+// SetPriority sets the priority of the voice; voices of higher priority are
+// never stolen in favor of voices of lower priority.
+func (v *Voice) SetPriority(p int) {
+	v.m.access.Lock()
+	v.priority = p
+	v.m.access.Unlock()
+}
+
+// Gain returns the linear gain of the voice.
+func (v *Voice) Gain() float64 {
+	v.m.access.Lock()
+	defer v.m.access.Unlock()
+	return v.gain
+}
+
+// SetGain sets the linear gain of the voice, which is also its audibility:
+// voices below the threshold of the manager are virtualized, after fading out
+// over a block. The change takes effect at the next block of audio mixed.
+func (v *Voice) SetGain(g float64) {
+	v.m.access.Lock()
+	v.gain = g
+	v.m.access.Unlock()
+}
+
+// Position returns the play position of the voice, as the time since it
+// started playing. It advances whether the voice is real or virtual.
+func (v *Voice) Position() time.Duration {
+	v.m.access.Lock()
+	defer v.m.access.Unlock()
+	return duration(v.pos, v.m.config.SampleRate)
+}
+
+// Virtual tells if the voice is currently virtual: too quiet to be heard, and
+// so not being decoded.
+func (v *Voice) Virtual() bool {
+	v.m.access.Lock()
+	defer v.m.access.Unlock()
+	return v.virtual
+}
+
+// Stolen tells if the voice was stopped to make way for more important
+// voices.
+func (v *Voice) Stolen() bool {
+	v.m.access.Lock()
+	defer v.m.access.Unlock()
+	return v.stolen
+}
+
+// Done tells if the voice has finished playing: either its stream has ended,
+// or it has been stopped or stolen.
+func (v *Voice) Done() bool {
+	v.m.access.Lock()
+	defer v.m.access.Unlock()
+	return v.done
+}
+
+// Err returns the error, other than EOS, which stopped the voice (if any).
+func (v *Voice) Err() error {
+	v.m.access.Lock()
+	defer v.m.access.Unlock()
+	return v.err
+}
+
+// Stop stops the voice, fading it out over the next block of audio mixed.
+func (v *Voice) Stop() {
+	v.m.access.Lock()
+	v.stopping = true
+	v.m.access.Unlock()
+}
+
+// byRank sorts voices from the most to the least important: by priority,
+// then by gain, and then by age (newest first).
+type byRank []*Voice
+
+func (p byRank) Len() int      { return len(p) }
+func (p byRank) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p byRank) Less(i, j int) bool {
+	a, b := p[i], p[j]
+	if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	if a.gain != b.gain {
+		return a.gain > b.gain
+	}
+	return a.seq > b.seq
+}
+
+// mixState is a snapshot of a voice's state for mixing a single block.
+type mixState struct {
+	gain     float64
+	virtual  bool
+	stopping bool  // fade out, then stop
+	fading   bool  // fade out, then virtualize
+	pos      int64 // the position to resume at, if not being decoded
+}
+
+// Manager mixes voices, of which at most a fixed number are real at once,
+// into a single stream. It implements the audio.Reader interface. Managers
+// must be allocated via the NewManager function.
+//
+// The mixed stream never ends: when there are no voices it is silent, and
+// voices are removed automatically once they are done (see Play for when
+// virtual voices end).
+//
+// It is safe to play voices and change their parameters from other
+// goroutines while audio is being read.
+type Manager struct {
+	config audio.Config
+
+	access    sync.Mutex
+	max       int
+	threshold float64
+	voices    []*Voice
+	seq       uint64
+
+	// Used only while mixing.
+	active []*Voice
+	ranked []*Voice
+	states []mixState
+	buf    audio.F64Samples
+}
+
+// Config returns the audio configuration of the mixed stream.
+func (m *Manager) Config() audio.Config {
+	return m.config
+}
+
+// MaxVoices returns the maximum number of real voices.
+func (m *Manager) MaxVoices() int {
+	m.access.Lock()
+	defer m.access.Unlock()
+	return m.max
+}
+
+// SetMaxVoices sets the maximum number of real voices. Should more voices be
+// audible, the least important are stolen at the next block of audio mixed.
+func (m *Manager) SetMaxVoices(n int) {
+	m.access.Lock()
+	m.max = n
+	m.access.Unlock()
+}
+
+// Threshold returns the gain below which voices are virtualized.
+func (m *Manager) Threshold() float64 {
+	m.access.Lock()
+	defer m.access.Unlock()
+	return m.threshold
+}
+
+// SetThreshold sets the gain below which voices are virtualized. A threshold
+// of zero virtualizes only silent voices.
+func (m *Manager) SetThreshold(g float64) {
+	m.access.Lock()
+	m.threshold = g
+	m.access.Unlock()
+}
+
+// Len returns the number of voices playing, real or virtual.
+func (m *Manager) Len() int {
+	m.access.Lock()
+	defer m.access.Unlock()
+	return len(m.voices)
+}
+
+// Real returns the number of real voices.
+func (m *Manager) Real() int {
+	m.access.Lock()
+	defer m.access.Unlock()
+	n := 0
+	for _, v := range m.voices {
+		if !v.virtual {
+			n++
+		}
+	}
+	return n
+}
+
+// Play starts playing the stream of the decoder d at the given priority and
+// linear gain, and returns its voice. The stream may be of any sample rate,
+// but must have the same number of channels as the manager. To be resumed
+// after it has been virtualized, the decoder must be able to seek.
+//
+// A virtual voice is done once its play position passes the end of the
+// stream, if the decoder reports the length of it via a Duration method
+// returning a non-zero time.Duration (as gen.Generator does). Otherwise the
+// end of the stream is only found once the voice is resumed, so voices whose
+// decoders cannot report their length should be stopped once no longer
+// needed, lest they remain virtual forever.
+//
+// The voice competes with the others from the next block of audio mixed,
+// where it may immediately be virtualized or stolen.
+//
+// It panics if the stream has a different number of channels.
+func (m *Manager) Play(d audio.Decoder, priority int, gain float64) *Voice {
+	if d.Config().Channels != m.config.Channels {
+		panic("voice: stream does not match the number of channels")
+	}
+	v := &Voice{
+		m:        m,
+		d:        d,
+		priority: priority,
+		gain:     gain,
+		cur:      gain,
+		virtual:  true,
+		length:   -1,
+	}
+	if l, ok := d.(interface {
+		Duration() time.Duration
+	}); ok {
+		if dur := l.Duration(); dur > 0 {
+			v.length = frames(dur, m.config.SampleRate)
+		}
+	}
+	v.r = m.reader(v)
+	m.access.Lock()
+	m.seq++
+	v.seq = m.seq
+	m.voices = append(m.voices, v)
+	m.access.Unlock()
+	return v
+}
+
+// frames returns the number of frames in the duration d at the given sample
+// rate, rounded to the nearest frame.
+func frames(d time.Duration, sampleRate int) int64 {
+	// Split the duration to avoid overflow for long durations.
+	sec, frac := d/time.Second, d%time.Second
+	return int64(sec)*int64(sampleRate) + (int64(frac)*int64(sampleRate)+int64(time.Second/2))/int64(time.Second)
+}
+
+// duration returns the duration of n frames at the given sample rate.
+func duration(n int64, sampleRate int) time.Duration {
+	// Split the frames to avoid overflow for long durations.
+	sec, frac := n/int64(sampleRate), n%int64(sampleRate)
+	return time.Duration(sec)*time.Second + time.Duration(frac)*time.Second/time.Duration(sampleRate)
+}
+
+// reader returns a reader of the decoder of v at the output sample rate.
+func (m *Manager) reader(v *Voice) audio.Reader {
+	c := v.d.Config()
+	if c.SampleRate == m.config.SampleRate {
+		return audio.NewFrameReader(v.d, c)
+	}
+	return dsp.NewRateConverter(v.d, c, m.config.SampleRate)
+}
+
+// finish marks v as done, removing it; m.access must be held.
+func (m *Manager) finish(v *Voice, err error) {
+	if err != audio.EOS {
+		v.err = err
+	}
+	v.done = true
+	for i, o := range m.voices {
+		if o == v {
+			m.voices = append(m.voices[:i], m.voices[i+1:]...)
+			return
+		}
+	}
+}
+
+// audible tells if v is loud enough to be real; m.access must be held.
+func (m *Manager) audible(v *Voice) bool {
+	return v.gain > 0 && v.gain >= m.threshold
+}
+
+// assign decides which voices are real, virtual or stolen for the next block,
+// taking a snapshot of their states; m.access must be held.
+func (m *Manager) assign() {
+	m.ranked = m.ranked[:0]
+	for _, v := range m.voices {
+		if !v.stopping && m.audible(v) {
+			m.ranked = append(m.ranked, v)
+		}
+	}
+	sort.Sort(byRank(m.ranked))
+	for i, v := range m.ranked {
+		if i >= m.max {
+			// Steal the voice, fading it out if it is real.
+			v.stolen, v.stopping = true, true
+		}
+	}
+
+	m.active = append(m.active[:0], m.voices...)
+	m.states = m.states[:0]
+	for _, v := range m.active {
+		st := mixState{gain: v.gain, stopping: v.stopping, pos: v.pos}
+		switch {
+		case v.stopping:
+			st.virtual = v.virtual
+		case !m.audible(v) && v.virtual:
+			st.virtual = true
+		case !m.audible(v):
+			// Fade the voice out over the block before virtualizing it.
+			st.fading = true
+		default:
+			v.virtual = false
+		}
+		m.states = append(m.states, st)
+	}
+}
+
+// mix mixes a single block of frames into b.
+func (m *Manager) mix(b audio.Slice, frames int) {
+	m.access.Lock()
+	m.assign()
+	m.access.Unlock()
+
+	channels := m.config.Channels
+	buf := m.buf[:frames*channels]
+	for i, v := range m.active {
+		st := m.states[i]
+		if st.virtual {
+			v.r = nil
+			m.access.Lock()
+			if !st.stopping {
+				v.pos += int64(frames)
+			}
+			if st.stopping || (v.length >= 0 && v.pos >= v.length) {
+				// Stopped, or the stream would have ended.
+				m.finish(v, nil)
+			}
+			m.access.Unlock()
+			continue
+		}
+
+		from, to := v.cur, st.gain
+		if v.r == nil {
+			// Resume where the voice would have been, fading it in.
+			c := v.d.Config()
+			frame := uint64(st.pos) * uint64(c.SampleRate) / uint64(m.config.SampleRate)
+			if err := v.d.Seek(frame * uint64(c.Channels)); err != nil {
+				m.access.Lock()
+				m.finish(v, err)
+				m.access.Unlock()
+				continue
+			}
+			v.r = m.reader(v)
+			from = 0
+		}
+		if st.stopping || st.fading {
+			to = 0
+		}
+
+		// Silence past the end of the stream, such that voices always mix a
+		// whole block.
+		n, err := v.r.Read(buf)
+		for j := n; j < len(buf); j++ {
+			buf[j] = 0
+		}
+		for f := 0; f < frames; f++ {
+			g := audio.F64(from + (to-from)*float64(f+1)/float64(frames))
+			for ch := f * channels; ch < (f+1)*channels; ch++ {
+				b.Set(ch, b.At(ch)+g*buf[ch])
+			}
+		}
+		v.cur = to
+
+		m.access.Lock()
+		v.pos += int64(frames)
+		switch {
+		case err != nil || st.stopping:
+			m.finish(v, err)
+		case st.fading:
+			v.virtual = true
+			v.r = nil
+		}
+		m.access.Unlock()
+	}
+}
+
+// Read implements the audio.Reader interface. It always fills b with whole
+// frames of audio, and never returns EOS.
+func (m *Manager) Read(b audio.Slice) (n int, err error) {
+	channels := m.config.Channels
+	frames := b.Len() / channels
+	for i := 0; i < frames*channels; i++ {
+		b.Set(i, 0)
+	}
+	for f := 0; f < frames; f += blockSize {
+		k := frames - f
+		if k > blockSize {
+			k = blockSize
+		}
+		m.mix(b.Slice(f*channels, (f+k)*channels), k)
+	}
+	return frames * channels, nil
+}
+
+// NewManager returns a new manager whose mixed stream has the given audio
+// configuration, with at most the given number of real voices and the
+// default threshold.
+//
+// It panics if the audio configuration is invalid.
+func NewManager(c audio.Config, maxVoices int) *Manager {
+	if c.SampleRate < 1 || c.Channels < 1 {
+		panic("voice: invalid audio configuration")
+	}
+	return &Manager{
+		config:    c,
+		max:       maxVoices,
+		threshold: DefaultThreshold,
+		buf:       make(audio.F64Samples, blockSize*c.Channels),
+	}
+}
@@ -0,0 +1,295 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package voice
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"azul3d.org/audio.v1"
+)
+
+var mono = audio.Config{SampleRate: 8000, Channels: 1}
+
+// ramp is a mono decoder of the given number of frames, whose samples are
+// their index plus one.
+type ramp struct {
+	config      audio.Config
+	pos, frames int
+	reads       int
+	seeks       []uint64
+}
+
+func (r *ramp) Config() audio.Config { return r.config }
+
+func (r *ramp) Read(b audio.Slice) (n int, err error) {
+	r.reads++
+	for n < b.Len() && r.pos < r.frames {
+		b.Set(n, audio.F64(r.pos+1))
+		n++
+		r.pos++
+	}
+	if r.pos == r.frames {
+		err = audio.EOS
+	}
+	return
+}
+
+func (r *ramp) Seek(sample uint64) error {
+	r.seeks = append(r.seeks, sample)
+	if sample > uint64(r.frames) {
+		return audio.EOS
+	}
+	r.pos = int(sample)
+	return nil
+}
+
+func newRamp(frames int) *ramp {
+	return &ramp{config: mono, frames: frames}
+}
+
+func read(m *Manager, frames int) audio.F64Samples {
+	b := make(audio.F64Samples, frames*m.Config().Channels)
+	m.Read(b)
+	return b
+}
+
+func TestManagerMix(t *testing.T) {
+	m := NewManager(mono, 4)
+	a := m.Play(newRamp(1000), 0, 1)
+	b := m.Play(newRamp(300), 0, 0.5)
+	out := read(m, 600)
+	for i, v := range out {
+		want := float64(i + 1)
+		if i < 300 {
+			want += 0.5 * float64(i+1)
+		}
+		if float64(v) != want {
+			t.Fatalf("sample %d = %v, want %v", i, v, want)
+		}
+	}
+	if a.Done() || !b.Done() || b.Err() != nil || m.Len() != 1 {
+		t.Errorf("a.Done() = %v, b.Done() = %v, b.Err() = %v, Len() = %d", a.Done(), b.Done(), b.Err(), m.Len())
+	}
+	if got := a.Position(); got != 600*time.Second/8000 {
+		t.Errorf("Position() = %v", got)
+	}
+}
+
+func TestManagerSteal(t *testing.T) {
+	m := NewManager(mono, 2)
+	low := m.Play(newRamp(1000), 0, 1)
+	high := m.Play(newRamp(1000), 1, 0.1)
+	quiet := m.Play(newRamp(1000), 0, 0.5)
+	oldest := m.Play(newRamp(1000), 0, 1)
+	read(m, 10)
+	// The voice of high priority is kept despite its gain, then the loudest
+	// and newest.
+	if !quiet.Stolen() || !low.Stolen() || high.Stolen() || oldest.Stolen() {
+		t.Errorf("stolen: low %v, high %v, quiet %v, oldest %v", low.Stolen(), high.Stolen(), quiet.Stolen(), oldest.Stolen())
+	}
+	if !quiet.Done() || !low.Done() || m.Len() != 2 || m.Real() != 2 {
+		t.Errorf("Len() = %d, Real() = %d", m.Len(), m.Real())
+	}
+
+	// A real voice which is stolen fades out over a block.
+	m.Play(newRamp(1000), 2, 1)
+	m.Play(newRamp(1000), 2, 1)
+	out := read(m, blockSize)
+	if !oldest.Stolen() || !high.Stolen() {
+		t.Fatal("voices were not stolen")
+	}
+	// Frames 11 onwards of oldest and high, fading out, plus two new voices.
+	for i := 0; i < blockSize; i++ {
+		fade := 1 - float64(i+1)/blockSize
+		want := fade*(1.1*float64(i+11)) + 2*float64(i+1)
+		if d := float64(out[i]) - want; d > 1e-9 || d < -1e-9 {
+			t.Fatalf("sample %d = %v, want %v", i, out[i], want)
+		}
+	}
+}
+
+func TestManagerVirtualize(t *testing.T) {
+	m := NewManager(mono, 4)
+	r := newRamp(10000)
+	v := m.Play(r, 0, 1)
+	read(m, blockSize)
+	v.SetGain(DefaultThreshold / 2)
+	read(m, 10*blockSize)
+	reads := r.reads
+	read(m, 10*blockSize)
+	if !v.Virtual() || m.Real() != 0 || r.reads != reads {
+		t.Fatalf("Virtual() = %v, Real() = %d, %d reads while virtual", v.Virtual(), m.Real(), r.reads-reads)
+	}
+
+	// Once audible, the voice resumes where it would have been, fading in.
+	v.SetGain(1)
+	out := read(m, 2*blockSize)
+	if v.Virtual() {
+		t.Fatal("voice was not resumed")
+	}
+	at := 21 * blockSize
+	if len(r.seeks) != 1 || r.seeks[0] != uint64(at) {
+		t.Fatalf("seeks = %v, want [%d]", r.seeks, at)
+	}
+	for i, x := range out {
+		want := float64(at + i + 1)
+		if i < blockSize {
+			want *= float64(i+1) / blockSize
+		}
+		if d := float64(x) - want; d > 1e-9 || d < -1e-9 {
+			t.Fatalf("sample %d = %v, want %v", i, x, want)
+		}
+	}
+}
+
+func TestManagerVirtualizeFade(t *testing.T) {
+	// A voice which becomes inaudible fades out over a block before being
+	// virtualized, rather than being cut off.
+	m := NewManager(mono, 4)
+	v := m.Play(newRamp(10000), 0, 1)
+	read(m, blockSize)
+	v.SetGain(0)
+	out := read(m, 2*blockSize)
+	for i, x := range out[:blockSize] {
+		want := float64(blockSize+i+1) * (1 - float64(i+1)/blockSize)
+		if d := float64(x) - want; d > 1e-9 || d < -1e-9 {
+			t.Fatalf("sample %d = %v, want %v", i, x, want)
+		}
+	}
+	for i, x := range out[blockSize:] {
+		if x != 0 {
+			t.Fatalf("sample %d = %v after fading out, want 0", blockSize+i, x)
+		}
+	}
+	if !v.Virtual() {
+		t.Fatal("voice was not virtualized after fading out")
+	}
+}
+
+// timedRamp is a ramp which reports its length.
+type timedRamp struct {
+	*ramp
+}
+
+func (r timedRamp) Duration() time.Duration {
+	return time.Duration(r.frames) * time.Second / time.Duration(r.config.SampleRate)
+}
+
+func TestManagerVirtualEnd(t *testing.T) {
+	// A virtual voice whose decoder cannot report its length, and which
+	// would have ended, is done once resumed.
+	m := NewManager(mono, 4)
+	v := m.Play(newRamp(blockSize), 0, 0)
+	read(m, 3*blockSize)
+	if v.Done() || !v.Virtual() {
+		t.Fatal("virtual voice ended")
+	}
+	v.SetGain(1)
+	read(m, blockSize)
+	if !v.Done() || v.Err() != nil || m.Len() != 0 {
+		t.Errorf("Done() = %v, Err() = %v, Len() = %d", v.Done(), v.Err(), m.Len())
+	}
+}
+
+func TestManagerVirtualEndLength(t *testing.T) {
+	// A virtual voice whose decoder reports its length is done once it
+	// would have ended, without being resumed.
+	m := NewManager(mono, 4)
+	r := timedRamp{newRamp(2*blockSize + 10)}
+	v := m.Play(r, 0, 0)
+	read(m, 2*blockSize)
+	if v.Done() || !v.Virtual() {
+		t.Fatal("virtual voice ended early")
+	}
+	read(m, blockSize)
+	if !v.Done() || v.Err() != nil || m.Len() != 0 || r.reads != 0 {
+		t.Errorf("Done() = %v, Err() = %v, Len() = %d, %d reads", v.Done(), v.Err(), m.Len(), r.reads)
+	}
+}
+
+func TestManagerStop(t *testing.T) {
+	m := NewManager(mono, 4)
+	v := m.Play(newRamp(10000), 0, 1)
+	read(m, 10)
+	v.Stop()
+	out := read(m, 2*blockSize)
+	for i, x := range out {
+		want := 0.0
+		if i < blockSize {
+			want = float64(i+11) * (1 - float64(i+1)/blockSize)
+		}
+		if d := float64(x) - want; d > 1e-9 || d < -1e-9 {
+			t.Fatalf("sample %d = %v, want %v", i, x, want)
+		}
+	}
+	if !v.Done() || v.Stolen() {
+		t.Errorf("Done() = %v, Stolen() = %v", v.Done(), v.Stolen())
+	}
+}
+
+type failing struct{ ramp }
+
+var errFailing = errors.New("failing")
+
+func (f *failing) Read(b audio.Slice) (n int, err error) {
+	return 0, errFailing
+}
+
+func TestManagerError(t *testing.T) {
+	m := NewManager(mono, 4)
+	v := m.Play(&failing{ramp{config: mono}}, 0, 1)
+	read(m, 10)
+	if !v.Done() || v.Err() != errFailing {
+		t.Errorf("Done() = %v, Err() = %v", v.Done(), v.Err())
+	}
+}
+
+func TestManagerSampleRate(t *testing.T) {
+	m := NewManager(mono, 4)
+	r := newRamp(4000)
+	r.config.SampleRate = 4000
+	v := m.Play(r, 0, 1)
+	n := 0
+	for !v.Done() && n < 20000 {
+		read(m, 100)
+		n += 100
+	}
+	if n < 7900 || n > 8300 {
+		t.Errorf("one second at 4kHz played for %d frames at 8kHz", n)
+	}
+}
+
+func TestManagerConcurrent(t *testing.T) {
+	m := NewManager(mono, 3)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			v := m.Play(newRamp(1000), i%3, float64(i%4)/3)
+			v.SetGain(0.5)
+			v.SetPriority(i % 5)
+			if i%7 == 0 {
+				v.Stop()
+			}
+			_ = v.Virtual()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		read(m, 50)
+	}
+	wg.Wait()
+}
+
+func TestDuration(t *testing.T) {
+	// A week at 48 kHz overflows time.Duration(n) * time.Second.
+	n := int64(7*24*3600*48000 + 24000)
+	if got, want := duration(n, 48000), 7*24*time.Hour+time.Second/2; got != want {
+		t.Errorf("duration(%d) = %v, want %v", n, got, want)
+	}
+}